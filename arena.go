@@ -0,0 +1,390 @@
+package avl
+
+import (
+	"fmt"
+	"iter"
+)
+
+//
+// Arena trees.  An AvlArena keeps its nodes in one slice and links them
+// by int32 index instead of by pointer, so the collector has no links to
+// trace; if T holds no pointers either, the slice is not scanned at
+// all.  Values are stored in the nodes, so unlike AvlTree the arena is
+// not intrusive.  Nodes removed from the arena go on a free list and are
+// reused by later inserts.
+//
+// A node is named by an AvlArenaRef, which stays valid until the node is
+// removed.  Pointers returned by At are only valid until the next
+// insert, which may grow the slice.
+//
+
+// Names a node of an arena.  The zero ref names no node
+
+type AvlArenaRef int32
+
+type avlArenaNode[T any] struct {
+	left, right, parent AvlArenaRef
+	height              int8
+	value               T
+}
+
+type AvlArena[T any] struct {
+	nodes []avlArenaNode[T] // nodes[0] is unused, so that ref 0 is nil
+	root  AvlArenaRef
+	free  AvlArenaRef // Linked through left
+	count int
+	cmp   func(a, b T) int
+}
+
+// Returns an empty arena ordered by cmp, with room for capacity values
+// before its slice has to grow
+
+func NewAvlArena[T any](cmp func(a, b T) int, capacity int) *AvlArena[T] {
+	return &AvlArena[T]{
+		nodes: make([]avlArenaNode[T], 1, capacity+1),
+		cmp:   cmp,
+	}
+}
+
+// Returns the number of values in the arena
+
+func (a *AvlArena[T]) Len() int {
+	return a.count
+}
+
+// Returns the value of the node ref
+
+func (a *AvlArena[T]) Value(ref AvlArenaRef) T {
+	return a.nodes[ref].value
+}
+
+// Returns a pointer to the value of the node ref, valid until the next
+// insert.  The value may be changed in place, but not in a way that
+// changes its order
+
+func (a *AvlArena[T]) At(ref AvlArenaRef) *T {
+	return &a.nodes[ref].value
+}
+
+func (a *AvlArena[T]) height(ref AvlArenaRef) int8 {
+	if ref == 0 {
+		return 0
+	}
+	return a.nodes[ref].height
+}
+
+func (a *AvlArena[T]) updateHeight(ref AvlArenaRef) {
+	n := &a.nodes[ref]
+	n.height = 1 + max(a.height(n.left), a.height(n.right))
+}
+
+// Points parent, or the root if parent is 0, at new instead of old
+
+func (a *AvlArena[T]) replaceChild(parent, old, new AvlArenaRef) {
+	if parent == 0 {
+		a.root = new
+	} else if a.nodes[parent].left == old {
+		a.nodes[parent].left = new
+	} else {
+		a.nodes[parent].right = new
+	}
+	if new != 0 {
+		a.nodes[new].parent = parent
+	}
+}
+
+// Rotates the subtree rooted at x, lifting its left child if sign is -1
+// or its right child if sign is +1, and returns the new subtree root
+
+func (a *AvlArena[T]) rotate(x AvlArenaRef, sign int) AvlArenaRef {
+
+	y := a.child(x, sign)
+	inner := a.child(y, -sign)
+
+	a.replaceChild(a.nodes[x].parent, x, y)
+	a.setChild(x, sign, inner)
+	if inner != 0 {
+		a.nodes[inner].parent = x
+	}
+	a.setChild(y, -sign, x)
+	a.nodes[x].parent = y
+
+	a.updateHeight(x)
+	a.updateHeight(y)
+
+	return y
+}
+
+func (a *AvlArena[T]) child(ref AvlArenaRef, sign int) AvlArenaRef {
+	if sign < 0 {
+		return a.nodes[ref].left
+	}
+	return a.nodes[ref].right
+}
+
+func (a *AvlArena[T]) setChild(ref AvlArenaRef, sign int, child AvlArenaRef) {
+	if sign < 0 {
+		a.nodes[ref].left = child
+	} else {
+		a.nodes[ref].right = child
+	}
+}
+
+// Walks from ref to the root, restoring heights and balance
+
+func (a *AvlArena[T]) rebalance(ref AvlArenaRef) {
+
+	for ref != 0 {
+		n := &a.nodes[ref]
+		bf := int(a.height(n.right)) - int(a.height(n.left))
+
+		if bf > 1 || bf < -1 {
+			sign := 1
+			if bf < 0 {
+				sign = -1
+			}
+			c := a.child(ref, sign)
+			if (sign > 0 && a.height(a.nodes[c].left) > a.height(a.nodes[c].right)) ||
+				(sign < 0 && a.height(a.nodes[c].right) > a.height(a.nodes[c].left)) {
+				a.rotate(c, -sign)
+			}
+			ref = a.rotate(ref, sign)
+		} else {
+			a.updateHeight(ref)
+		}
+
+		ref = a.nodes[ref].parent
+	}
+}
+
+// Looks up value.  Returns the node holding an equal value, or 0
+
+func (a *AvlArena[T]) Lookup(value T) AvlArenaRef {
+
+	ref := a.root
+	for ref != 0 {
+		res := a.cmp(value, a.nodes[ref].value)
+		if res < 0 {
+			ref = a.nodes[ref].left
+		} else if res > 0 {
+			ref = a.nodes[ref].right
+		} else {
+			break
+		}
+	}
+
+	return ref
+}
+
+// Inserts value.  If an equal value is already in the arena, returns its
+// node and false, leaving the arena alone; otherwise returns the new
+// node and true
+
+func (a *AvlArena[T]) Insert(value T) (AvlArenaRef, bool) {
+
+	parent := AvlArenaRef(0)
+	sign := 0
+
+	for ref := a.root; ref != 0; {
+		res := a.cmp(value, a.nodes[ref].value)
+		if res == 0 {
+			return ref, false
+		}
+		parent = ref
+		if res < 0 {
+			sign = -1
+		} else {
+			sign = 1
+		}
+		ref = a.child(ref, sign)
+	}
+
+	ref := a.alloc()
+	a.nodes[ref] = avlArenaNode[T]{parent: parent, height: 1, value: value}
+
+	if parent == 0 {
+		a.root = ref
+	} else {
+		a.setChild(parent, sign, ref)
+		a.rebalance(parent)
+	}
+	a.count++
+
+	return ref, true
+}
+
+func (a *AvlArena[T]) alloc() AvlArenaRef {
+
+	if a.free != 0 {
+		ref := a.free
+		a.free = a.nodes[ref].left
+		return ref
+	}
+
+	if len(a.nodes) > 1<<31-1 {
+		panic("avl: arena is full")
+	}
+	a.nodes = append(a.nodes, avlArenaNode[T]{})
+
+	return AvlArenaRef(len(a.nodes) - 1)
+}
+
+// Removes the node ref from the arena.  ref, and any pointer into its
+// value, must not be used again
+
+func (a *AvlArena[T]) Remove(ref AvlArenaRef) {
+
+	n := a.nodes[ref]
+	var start AvlArenaRef
+
+	if n.left != 0 && n.right != 0 {
+
+		// Move the successor into the node's place, so that no other
+		// node's ref changes
+
+		s := n.right
+		for a.nodes[s].left != 0 {
+			s = a.nodes[s].left
+		}
+
+		if sp := a.nodes[s].parent; sp != ref {
+			a.replaceChild(sp, s, a.nodes[s].right)
+			a.nodes[s].right = n.right
+			a.nodes[n.right].parent = s
+			start = sp
+		} else {
+			start = s
+		}
+
+		a.nodes[s].left = n.left
+		a.nodes[n.left].parent = s
+		a.nodes[s].height = n.height
+		a.replaceChild(n.parent, ref, s)
+	} else {
+		child := n.left
+		if child == 0 {
+			child = n.right
+		}
+		a.replaceChild(n.parent, ref, child)
+		start = n.parent
+	}
+
+	var zero avlArenaNode[T]
+	a.nodes[ref] = zero
+	a.nodes[ref].left = a.free
+	a.free = ref
+	a.count--
+
+	a.rebalance(start)
+}
+
+func (a *AvlArena[T]) extreme(ref AvlArenaRef, sign int) AvlArenaRef {
+	for ref != 0 && a.child(ref, sign) != 0 {
+		ref = a.child(ref, sign)
+	}
+	return ref
+}
+
+// Returns the node holding the least value, or 0 if the arena is empty
+
+func (a *AvlArena[T]) First() AvlArenaRef {
+	return a.extreme(a.root, -1)
+}
+
+// Returns the node holding the greatest value, or 0 if the arena is empty
+
+func (a *AvlArena[T]) Last() AvlArenaRef {
+	return a.extreme(a.root, 1)
+}
+
+func (a *AvlArena[T]) step(ref AvlArenaRef, sign int) AvlArenaRef {
+
+	if c := a.child(ref, sign); c != 0 {
+		return a.extreme(c, -sign)
+	}
+
+	for p := a.nodes[ref].parent; p != 0; ref, p = p, a.nodes[p].parent {
+		if a.child(p, -sign) == ref {
+			return p
+		}
+	}
+
+	return 0
+}
+
+// Returns the node after ref in order, or 0 if ref is the last
+
+func (a *AvlArena[T]) Next(ref AvlArenaRef) AvlArenaRef {
+	return a.step(ref, 1)
+}
+
+// Returns the node before ref in order, or 0 if ref is the first
+
+func (a *AvlArena[T]) Prev(ref AvlArenaRef) AvlArenaRef {
+	return a.step(ref, -1)
+}
+
+// Yields the values in order.  The arena must not be modified while
+// the sequence is being iterated
+
+func (a *AvlArena[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for ref := a.First(); ref != 0; ref = a.Next(ref) {
+			if !yield(a.nodes[ref].value) {
+				return
+			}
+		}
+	}
+}
+
+// Checks the arena's links, ordering, heights and balance
+
+func (a *AvlArena[T]) Validate() error {
+
+	count := 0
+	prev := AvlArenaRef(0)
+
+	var check func(ref, parent AvlArenaRef) (int8, error)
+
+	check = func(ref, parent AvlArenaRef) (int8, error) {
+		if ref == 0 {
+			return 0, nil
+		}
+
+		n := &a.nodes[ref]
+		if n.parent != parent {
+			return 0, fmt.Errorf("%w: node %d has the wrong parent", ErrInvalidTree, ref)
+		}
+
+		lh, err := check(n.left, ref)
+		if err != nil {
+			return 0, err
+		}
+
+		if prev != 0 && a.cmp(a.nodes[prev].value, n.value) >= 0 {
+			return 0, fmt.Errorf("%w: node %d is out of order", ErrInvalidTree, ref)
+		}
+		prev = ref
+
+		rh, err := check(n.right, ref)
+		if err != nil {
+			return 0, err
+		}
+
+		if lh-rh > 1 || rh-lh > 1 || n.height != 1+max(lh, rh) {
+			return 0, fmt.Errorf("%w: node %d is out of balance", ErrInvalidTree, ref)
+		}
+		count++
+
+		return n.height, nil
+	}
+
+	if _, err := check(a.root, 0); err != nil {
+		return err
+	}
+	if count != a.count {
+		return fmt.Errorf("%w: %d nodes, count %d", ErrInvalidTree, count, a.count)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestAvlArena(t *testing.T) {
+
+	a := NewAvlArena(func(x, y int) int { return x - y }, 0)
+	refs := map[int]AvlArenaRef{}
+
+	rnd := rand.New(rand.NewSource(5))
+
+	for i := 0; i < 5000; i++ {
+		k := rnd.Intn(500)
+		if rnd.Intn(2) == 0 {
+			ref, ok := a.Insert(k)
+			_, had := refs[k]
+			assert.Equal(t, !had, ok)
+			if had {
+				assert.Equal(t, refs[k], ref)
+			}
+			refs[k] = ref
+		} else if ref, ok := refs[k]; ok {
+			a.Remove(ref)
+			delete(refs, k)
+		}
+
+		if i%100 == 0 {
+			assert.NoError(t, a.Validate())
+		}
+	}
+
+	assert.NoError(t, a.Validate())
+	assert.Equal(t, len(refs), a.Len())
+
+	// Refs stay valid across other nodes' removal
+
+	var keys []int
+	for k, ref := range refs {
+		assert.Equal(t, k, a.Value(ref))
+		assert.Equal(t, ref, a.Lookup(k))
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	assert.Equal(t, keys, slices.Collect(a.All()))
+
+	// Backwards too
+
+	var back []int
+	for ref := a.Last(); ref != 0; ref = a.Prev(ref) {
+		back = append(back, *a.At(ref))
+	}
+	slices.Reverse(back)
+	assert.Equal(t, keys, back)
+
+	assert.Equal(t, AvlArenaRef(0), a.Lookup(-1))
+}
+
+func TestAvlArenaReusesNodes(t *testing.T) {
+
+	a := NewAvlArena(func(x, y int) int { return x - y }, 4)
+
+	for i := 0; i < 4; i++ {
+		a.Insert(i)
+	}
+	for i := 0; i < 4; i++ {
+		a.Remove(a.Lookup(i))
+	}
+	assert.Equal(t, 0, a.Len())
+	assert.Equal(t, AvlArenaRef(0), a.First())
+
+	for i := 0; i < 4; i++ {
+		ref, _ := a.Insert(i)
+		assert.True(t, ref <= 4)
+	}
+	assert.Equal(t, 5, len(a.nodes))
+	assert.NoError(t, a.Validate())
+}
@@ -1,5 +1,7 @@
 package avl
 
+import "iter"
+
 //
 // This package is a GO implementation of AVL trees
 //
@@ -57,6 +59,15 @@ package avl
 // }
 //
 
+//
+// Every node also records the number of nodes in its subtree (itself
+// included), which is what the order-statistic functions (rank, select
+// and friends) run on.  The count sits in what would otherwise be
+// padding after the balance factor, so it costs no memory, and keeping
+// it up to date costs one walk to the root per insertion or removal.
+// It limits a tree to 2^31 - 1 nodes
+//
+
 type AvlNode struct {
 	left    *AvlNode
 	right   *AvlNode
@@ -64,6 +75,7 @@ type AvlNode struct {
 	owner   interface{}
 	balance int8
 	pad     [3]int8
+	size    int32
 }
 
 type CmpFuncKey func(interface{}, interface{}) int
@@ -148,6 +160,34 @@ func avlAdjustBalanceFactor(node *AvlNode, amount int) {
 	node.balance += int8(amount)
 }
 
+// Returns the number of nodes in the subtree rooted at node, which
+// may be nil
+
+func avlGetSize(node *AvlNode) int {
+
+	if node == nil {
+		return 0
+	}
+
+	return int(node.size)
+}
+
+// Recompute the subtree size of the specified node from its children
+
+func avlUpdateSize(node *AvlNode) {
+
+	node.size = int32(1 + avlGetSize(node.left) + avlGetSize(node.right))
+}
+
+// Add amount to the subtree sizes of node and all its ancestors
+
+func avlAdjustSizes(node *AvlNode, amount int) {
+
+	for ; node != nil; node = avlGetParent(node) {
+		node.size += int32(amount)
+	}
+}
+
 // Replace a child
 
 func avlReplaceChild(root **AvlNode, parent, oldChild, newChild *AvlNode) {
@@ -187,7 +227,7 @@ func avlReplaceChild(root **AvlNode, parent, oldChild, newChild *AvlNode) {
 //            / \       / \
 //           E?  D?    C?  E?
 //
-// This updates pointers but not balance factors!
+// This updates pointers and subtree sizes but not balance factors!
 //
 
 func avlRotate(root **AvlNode, A *AvlNode, sign int) {
@@ -205,6 +245,9 @@ func avlRotate(root **AvlNode, A *AvlNode, sign int) {
 		avlSetParent(E, A)
 	}
 
+	avlUpdateSize(A)
+	avlUpdateSize(B)
+
 	avlReplaceChild(root, P, A, B)
 }
 
@@ -281,6 +324,10 @@ func avlDoDoubleRotate(root **AvlNode, B, A *AvlNode, sign int) *AvlNode {
 		avlSetParent(F, B)
 	}
 
+	avlUpdateSize(A)
+	avlUpdateSize(B)
+	avlUpdateSize(E)
+
 	avlReplaceChild(root, P, A, E)
 
 	return E
@@ -312,8 +359,12 @@ func avlDoDoubleRotate(root **AvlNode, B, A *AvlNode, sign int) *AvlNode {
 // Indeed, a single node insertion cannot require that more than one
 // (single or double) rotation be done.
 //
+// rotations is incremented by the number of rotations done (a double
+// rotation counts as two)
+//
 
-func avlHandleSubtreeGrowth(root **AvlNode, node, parent *AvlNode, sign int) bool {
+func avlHandleSubtreeGrowth(root **AvlNode, node, parent *AvlNode, sign int,
+	rotations *uint64) bool {
 	oldBalanceFactor := avlGetBalanceFactor(parent)
 
 	if oldBalanceFactor == 0 {
@@ -385,6 +436,7 @@ func avlHandleSubtreeGrowth(root **AvlNode, node, parent *AvlNode, sign int) boo
 		//
 
 		avlRotate(root, parent, -sign)
+		*rotations++
 
 		// Equivalent to setting parent's balance factor to 0.
 		avlAdjustBalanceFactor(parent, -sign) /* A */
@@ -434,6 +486,7 @@ func avlHandleSubtreeGrowth(root **AvlNode, node, parent *AvlNode, sign int) boo
 		//
 
 		avlDoDoubleRotate(root, node, parent, -sign)
+		*rotations += 2
 	}
 
 	// Height after rotation is unchanged; nothing more to do
@@ -441,9 +494,11 @@ func avlHandleSubtreeGrowth(root **AvlNode, node, parent *AvlNode, sign int) boo
 	return true
 }
 
-// Rebalance the tree after insertion of the specified node
+// Rebalance the tree after insertion of the specified node, counting
+// rotations in rotations
 
-func avlTreeRebalanceAfterInsert(root **AvlNode, inserted *AvlNode) {
+func avlTreeRebalanceAfterInsert(root **AvlNode, inserted *AvlNode,
+	rotations *uint64) {
 
 	inserted.left = nil
 	inserted.right = nil
@@ -482,9 +537,9 @@ func avlTreeRebalanceAfterInsert(root **AvlNode, inserted *AvlNode) {
 
 		// The subtree rooted at node has increased in height by 1
 		if node == parent.left {
-			done = avlHandleSubtreeGrowth(root, node, parent, -1)
+			done = avlHandleSubtreeGrowth(root, node, parent, -1, rotations)
 		} else {
-			done = avlHandleSubtreeGrowth(root, node, parent, +1)
+			done = avlHandleSubtreeGrowth(root, node, parent, +1, rotations)
 		}
 	}
 }
@@ -516,10 +571,11 @@ func avlTreeRebalanceAfterInsert(root **AvlNode, inserted *AvlNode) {
 // the full AVL tree is now adequately balanced, or a pointer to the
 // parent of parent if parent is now adequately balanced but has
 // decreased in height by 1.  Also in the latter case, leftDeletedRet
-// will be set.
+// will be set.  rotations is incremented as for avlHandleSubtreeGrowth.
 //
 
-func avlHandleSubtreeShrink(root **AvlNode, parent *AvlNode, sign int, leftDeletedRet *bool) *AvlNode {
+func avlHandleSubtreeShrink(root **AvlNode, parent *AvlNode, sign int, leftDeletedRet *bool,
+	rotations *uint64) *AvlNode {
 
 	var node *AvlNode
 
@@ -560,6 +616,7 @@ func avlHandleSubtreeShrink(root **AvlNode, parent *AvlNode, sign int, leftDelet
 		if sign*avlGetBalanceFactor(node) >= 0 {
 
 			avlRotate(root, parent, -sign)
+			*rotations++
 
 			if avlGetBalanceFactor(node) == 0 {
 
@@ -621,6 +678,7 @@ func avlHandleSubtreeShrink(root **AvlNode, parent *AvlNode, sign int, leftDelet
 			}
 		} else {
 			node = avlDoDoubleRotate(root, node, parent, -sign)
+			*rotations += 2
 		}
 	}
 
@@ -698,6 +756,7 @@ func avlTreeSwapWithSuccessor(root **AvlNode, X *AvlNode, leftDeletedRet *bool)
 
 	Y.parent = X.parent
 	Y.balance = X.balance
+	Y.size = X.size
 	avlReplaceChild(root, avlGetParent(X), X, Y)
 
 	return ret
@@ -721,6 +780,91 @@ func avlTreeNextOrPrevInOrder(node *AvlNode, sign int) *AvlNode {
 	return next
 }
 
+// Search for the insertion point of item and link it there, unless a
+// node with the same key is already present.  Returns nil if item was
+// inserted, and the existing node if not
+
+func avlTreeInsertNode(root **AvlNode, item *AvlNode,
+	owner interface{}, cmp CmpFuncNode) *AvlNode {
+
+	var rotations uint64
+
+	return avlTreeInsertNodeDup(root, item, owner, cmp, 0, &rotations)
+}
+
+// avlTreeInsertNode, but with dup -1 or +1 a node with the same key as
+// an existing one is linked in anyway, before or after all the nodes
+// with that key respectively.  With dup 0 the existing node is returned.
+// Rotations are counted in rotations
+
+func avlTreeInsertNodeDup(root **AvlNode, item *AvlNode,
+	owner interface{}, cmp CmpFuncNode, dup int, rotations *uint64) *AvlNode {
+
+	curPtr := root
+	var cur *AvlNode = nil
+
+	for *curPtr != nil {
+		cur = *curPtr
+
+		res := cmp(owner, cur.owner)
+		if res == 0 {
+			res = dup
+		}
+		if res < 0 {
+			curPtr = &cur.left
+		} else if res > 0 {
+			curPtr = &cur.right
+		} else {
+			return cur
+		}
+	}
+
+	*curPtr = item
+
+	item.parent = cur
+	item.balance = 1
+	item.owner = owner
+	item.size = 1
+
+	avlAdjustSizes(cur, +1)
+	avlTreeRebalanceAfterInsert(root, item, rotations)
+
+	return nil
+}
+
+// Put item in the exact position in the tree of old, which is left
+// unlinked (but not marked as such)
+
+func avlTreeReplaceNode(root **AvlNode, old, item *AvlNode, owner interface{}) {
+
+	item.left = old.left
+	item.right = old.right
+	item.parent = old.parent
+	item.balance = old.balance
+	item.size = old.size
+	item.owner = owner
+
+	if item.left != nil {
+		avlSetParent(item.left, item)
+	}
+	if item.right != nil {
+		avlSetParent(item.right, item)
+	}
+
+	avlReplaceChild(root, avlGetParent(old), old, item)
+}
+
+func avlTreePop(root **AvlNode, node *AvlNode) interface{} {
+
+	if node == nil {
+		return nil
+	}
+
+	AvlTreeRemove(root, node)
+
+	return node.owner
+}
+
 // Exported functions
 
 // Look up a specified key.  nil if not present
@@ -747,37 +891,128 @@ func AvlTreeLookup(root *AvlNode, key interface{}, cmp CmpFuncKey) interface{} {
 	}
 }
 
+// Look up a specified key.  def if not present
+
+func AvlTreeLookupOr(root *AvlNode, key interface{}, cmp CmpFuncKey,
+	def interface{}) interface{} {
+
+	if owner := AvlTreeLookup(root, key, cmp); owner != nil {
+		return owner
+	} else {
+		return def
+	}
+}
+
+// Typed AvlTreeLookupOr: look up a specified key and return its owner as
+// a T, or def if not present.  Panics if the owner is not a T
+
+func AvlTreeLookupAs[T any](root *AvlNode, key interface{}, cmp CmpFuncKey,
+	def T) T {
+
+	if owner := AvlTreeLookup(root, key, cmp); owner != nil {
+		return owner.(T)
+	} else {
+		return def
+	}
+}
+
+// Returns the first owner, in order, for which pred is true, or nil if
+// there is none.  Like sort.Search, pred must be monotone: false for
+// some (possibly empty) prefix of the tree and true for the rest.
+// O(log n) calls to pred
+
+func AvlTreeSearch(root *AvlNode, pred func(owner interface{}) bool) interface{} {
+
+	var found *AvlNode
+
+	for cur := root; cur != nil; {
+		if pred(cur.owner) {
+			found = cur
+			cur = cur.left
+		} else {
+			cur = cur.right
+		}
+	}
+
+	if found != nil {
+		return found.owner
+	} else {
+		return nil
+	}
+}
+
 // Insert a node into the tree.  Returns nil if not already present,
 // and existing node address if already present
 
 func AvlTreeInsert(root **AvlNode, item *AvlNode,
 	owner interface{}, cmp CmpFuncNode) interface{} {
 
-	curPtr := root
-	var cur *AvlNode = nil
+	existing := avlTreeInsertNode(root, item, owner, cmp)
+	if existing != nil {
+		return existing.owner
+	} else {
+		return nil
+	}
+}
 
-	for *curPtr != nil {
-		cur = *curPtr
+// Insert a node into the tree if no node with the same key is present.
+// Returns true if item was inserted
 
-		res := cmp(owner, cur.owner)
-		if res < 0 {
-			curPtr = &cur.left
-		} else if res > 0 {
-			curPtr = &cur.right
-		} else {
-			return cur.owner
-		}
+func AvlTreeInsertIfAbsent(root **AvlNode, item *AvlNode,
+	owner interface{}, cmp CmpFuncNode) bool {
+
+	return avlTreeInsertNode(root, item, owner, cmp) == nil
+}
+
+// Insert a node into the tree, replacing any node already present with
+// the same key.  The new node takes over the position (and balance
+// factor) of the node it displaces, so no rebalancing is needed.
+// Returns the displaced owner, or nil if there was none
+
+func AvlTreeInsertOrReplace(root **AvlNode, item *AvlNode,
+	owner interface{}, cmp CmpFuncNode) interface{} {
+
+	existing := avlTreeInsertNode(root, item, owner, cmp)
+	if existing == nil {
+		return nil
 	}
 
-	*curPtr = item
+	avlTreeReplaceNode(root, existing, item, owner)
 
-	item.parent = cur
-	item.balance = 1
-	item.owner = owner
+	return existing.owner
+}
 
-	avlTreeRebalanceAfterInsert(root, item)
+// Insert a node into the tree, or, if a node with the same key is
+// already present, call onExisting with that node's owner so the caller
+// can update it in place.  onExisting must not change the owner's key.
+// Returns true if item was inserted
 
-	return nil
+func AvlTreeUpsert(root **AvlNode, item *AvlNode, owner interface{},
+	cmp CmpFuncNode, onExisting func(existing interface{})) bool {
+
+	existing := avlTreeInsertNode(root, item, owner, cmp)
+	if existing == nil {
+		return true
+	}
+
+	onExisting(existing.owner)
+
+	return false
+}
+
+// Returns the owner already present under item's key, or inserts item
+// and returns owner.  The bool is true if item was inserted.  This needs
+// only the one descent, where a Lookup followed by an Insert needs two
+
+func AvlTreeGetOrInsert(root **AvlNode, item *AvlNode,
+	owner interface{}, cmp CmpFuncNode) (interface{}, bool) {
+
+	existing := avlTreeInsertNode(root, item, owner, cmp)
+	if existing != nil {
+		return existing.owner, false
+	} else {
+		return owner, true
+	}
 }
 
 // Removes an item from the specified AVL tree.
@@ -790,11 +1025,24 @@ func AvlTreeInsert(root **AvlNode, item *AvlNode,
 // node
 //      Pointer to the `AvlNode' embedded in the item to remove from the tree
 //
+// Returns the owner of the removed node.
+//
 // Note: This function *only* removes the node and rebalances the tree.
 // It does not free any memory, nor does it do the equivalent of
-// avl_TreeNodeSetUnlinked()
+// node.SetUnlinked()
+
+func AvlTreeRemove(root **AvlNode, node *AvlNode) interface{} {
+
+	var rotations uint64
+
+	avlTreeRemove(root, node, &rotations)
+
+	return node.owner
+}
+
+// AvlTreeRemove, counting rotations in rotations
 
-func AvlTreeRemove(root **AvlNode, node *AvlNode) {
+func avlTreeRemove(root **AvlNode, node *AvlNode, rotations *uint64) {
 	var parent *AvlNode
 	leftDeleted := false
 
@@ -805,6 +1053,7 @@ func AvlTreeRemove(root **AvlNode, node *AvlNode) {
 		// child), then unlink node
 
 		parent = avlTreeSwapWithSuccessor(root, node, &leftDeleted)
+		avlAdjustSizes(parent, -1)
 
 		// parent is now the parent of what was node's in-order
 		// successor.  It cannot be NULL, since node itself was
@@ -829,6 +1078,7 @@ func AvlTreeRemove(root **AvlNode, node *AvlNode) {
 		}
 		parent = avlGetParent(node)
 		if parent != nil {
+			avlAdjustSizes(parent, -1)
 			if node == parent.left {
 				parent.left = child
 				leftDeleted = true
@@ -852,9 +1102,9 @@ func AvlTreeRemove(root **AvlNode, node *AvlNode) {
 
 	for {
 		if leftDeleted {
-			parent = avlHandleSubtreeShrink(root, parent, +1, &leftDeleted)
+			parent = avlHandleSubtreeShrink(root, parent, +1, &leftDeleted, rotations)
 		} else {
-			parent = avlHandleSubtreeShrink(root, parent, -1, &leftDeleted)
+			parent = avlHandleSubtreeShrink(root, parent, -1, &leftDeleted, rotations)
 		}
 		if parent == nil {
 			break
@@ -874,6 +1124,20 @@ func AvlTreeFirstInOrder(root *AvlNode) interface{} {
     }
 }
 
+// Removes the least node from the tree and returns its owner, or nil
+// if the tree is empty
+
+func AvlTreePopMin(root **AvlNode) interface{} {
+	return avlTreePop(root, avlTreeFirstOrLastInOrder(*root, -1))
+}
+
+// Removes the greatest node from the tree and returns its owner, or nil
+// if the tree is empty
+
+func AvlTreePopMax(root **AvlNode) interface{} {
+	return avlTreePop(root, avlTreeFirstOrLastInOrder(*root, 1))
+}
+
 // Starts an reverse in-order traversal of the tree: returns the
 // greatest-valued node, or nil if the tree is empty
 
@@ -908,6 +1172,65 @@ func AvlTreePrevInOrder(node *AvlNode) interface{} {
 	}
 }
 
+// Merges the in-order traversals of two trees: yields the owners of
+// both, in increasing order by cmp, which compares two owners.  Owners
+// that compare equal are both yielded, a's first.  Neither tree may be
+// modified while the sequence is being iterated
+
+func AvlTreeMergeIter(a, b *AvlTree, cmp CmpFuncNode) iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		x := avlTreeFirstOrLastInOrder(a.root, -1)
+		y := avlTreeFirstOrLastInOrder(b.root, -1)
+
+		for x != nil || y != nil {
+			var next *AvlNode
+			if y == nil || (x != nil && cmp(x.owner, y.owner) <= 0) {
+				next, x = x, avlTreeNextOrPrevInOrder(x, 1)
+			} else {
+				next, y = y, avlTreeNextOrPrevInOrder(y, 1)
+			}
+			if !yield(next.owner) {
+				return
+			}
+		}
+	}
+}
+
+// Walks two trees together by key: yields (ownerA, ownerB) for each
+// pair of owners that compare equal under cmp, which compares an owner
+// of a with an owner of b, and (ownerA, nil) or (nil, ownerB) for an
+// owner with no match in the other tree, all in increasing key order.
+// Neither tree may be modified while the sequence is being iterated
+
+func AvlTreeZip(a, b *AvlTree, cmp CmpFuncNode) iter.Seq2[interface{}, interface{}] {
+	return func(yield func(interface{}, interface{}) bool) {
+		x := avlTreeFirstOrLastInOrder(a.root, -1)
+		y := avlTreeFirstOrLastInOrder(b.root, -1)
+
+		for x != nil || y != nil {
+			var res int
+			if x == nil {
+				res = 1
+			} else if y == nil {
+				res = -1
+			} else {
+				res = cmp(x.owner, y.owner)
+			}
+
+			var ownerA, ownerB interface{}
+			if res <= 0 {
+				ownerA, x = x.owner, avlTreeNextOrPrevInOrder(x, 1)
+			}
+			if res >= 0 {
+				ownerB, y = y.owner, avlTreeNextOrPrevInOrder(y, 1)
+			}
+			if !yield(ownerA, ownerB) {
+				return
+			}
+		}
+	}
+}
+
 // Starts a postorder traversal of the tree
 
 func AvlTreeFirstInPostOrder(root *AvlNode) interface{} {
@@ -996,3 +1319,60 @@ func AvlGetBalanceFactor(node *AvlNode) int {
 	return avlGetBalanceFactor(node)
 
 }
+
+// Mark a node as not linked into any tree.  The AVL functions never do
+// this themselves (the AvlTree methods do); it is for callers that need
+// to tell later, with IsUnlinked, whether a removed node can be
+// inserted again
+
+func (n *AvlNode) SetUnlinked() {
+	avlTreeNodeSetUnlinked(n)
+}
+
+// Reports whether a node has been marked with SetUnlinked and not
+// inserted into a tree since.  A node that was never marked is not
+// considered unlinked
+
+func (n *AvlNode) IsUnlinked() bool {
+	return avlTreeNodeIsUnlinked(n)
+}
+
+// Replaces the node's owner with newOwner, in place, and returns the old
+// owner.  The node is not relinked, so newOwner must order exactly as
+// the old owner did.  For an AvlTree, observers and the journal do not
+// see the change
+
+func (n *AvlNode) SwapOwner(newOwner interface{}) interface{} {
+	old := n.owner
+	n.owner = newOwner
+	return old
+}
+
+// The AvlNode accessors below return nodes rather than owners, for code
+// outside the package that needs to walk the tree structure itself
+// (checkers, visualizers).  Nothing outside the package can modify the
+// links, so handing out the nodes is safe
+
+// Returns the node's left child, or nil
+
+func (n *AvlNode) Left() *AvlNode {
+	return n.left
+}
+
+// Returns the node's right child, or nil
+
+func (n *AvlNode) Right() *AvlNode {
+	return n.right
+}
+
+// Returns the node's parent, or nil for the root
+
+func (n *AvlNode) Parent() *AvlNode {
+	return avlGetParent(n)
+}
+
+// Returns the owner stored in the node when it was inserted
+
+func (n *AvlNode) Owner() interface{} {
+	return n.owner
+}
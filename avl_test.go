@@ -146,7 +146,7 @@ func TestAvlTreeNextInPostOrder(t *testing.T) {
 func TestAvlTreeRemove(t *testing.T) {
 
 	for i := 0; i < maxNodes; i += 2 {
-		AvlTreeRemove(&root, &nodes[i].avlHeader)
+		assert.Same(t, &nodes[i], AvlTreeRemove(&root, &nodes[i].avlHeader))
 		nodes[i].deleted = true
 	}
 }
@@ -182,3 +182,247 @@ func TestAvlTreeLookup(t *testing.T) {
 		}
 	}
 }
+
+func TestAvlTreeInsertOrReplace(t *testing.T) {
+
+	var r *AvlNode
+
+	ns := newIntNodes(1, 2, 3, 4, 5)
+	for _, n := range ns {
+		assert.Nil(t, AvlTreeInsertOrReplace(&r, &n.avlHeader, n, cmpIntNode))
+	}
+
+	dup := &intNode{key: 2}
+	old := AvlTreeInsertOrReplace(&r, &dup.avlHeader, dup, cmpIntNode)
+	assert.Equal(t, ns[1], old)
+	assert.Equal(t, dup, AvlTreeLookup(r, 2, cmpIntKey))
+
+	// The replacement must have taken over the old node's position
+
+	assert.Equal(t, AvlGetParent(&ns[1].avlHeader), AvlGetParent(&dup.avlHeader))
+	assert.Equal(t, AvlGetBalanceFactor(&ns[1].avlHeader),
+		AvlGetBalanceFactor(&dup.avlHeader))
+
+	var keys []int
+	for p := AvlTreeFirstInOrder(r); p != nil; p = AvlTreeNextInOrder(&p.(*intNode).avlHeader) {
+		keys = append(keys, p.(*intNode).key)
+	}
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, keys)
+}
+
+func TestAvlTreeUpsert(t *testing.T) {
+
+	type counter struct {
+		intNode
+		count int
+	}
+
+	cmpCounter := func(node1 interface{}, node2 interface{}) int {
+		return node1.(*counter).key - node2.(*counter).key
+	}
+
+	var r *AvlNode
+
+	for _, k := range []int{3, 1, 3, 2, 3, 1} {
+		c := &counter{intNode{key: k}, 1}
+		AvlTreeUpsert(&r, &c.avlHeader, c, cmpCounter, func(existing interface{}) {
+			existing.(*counter).count++
+		})
+	}
+
+	counts := map[int]int{}
+	for p := AvlTreeFirstInOrder(r); p != nil; p = AvlTreeNextInOrder(&p.(*counter).avlHeader) {
+		counts[p.(*counter).key] = p.(*counter).count
+	}
+	assert.Equal(t, map[int]int{1: 2, 2: 1, 3: 3}, counts)
+}
+
+func TestAvlTreeGetOrInsert(t *testing.T) {
+
+	var r *AvlNode
+
+	ns := newIntNodes(4, 4)
+
+	got, inserted := AvlTreeGetOrInsert(&r, &ns[0].avlHeader, ns[0], cmpIntNode)
+	assert.True(t, inserted)
+	assert.Equal(t, ns[0], got)
+
+	got, inserted = AvlTreeGetOrInsert(&r, &ns[1].avlHeader, ns[1], cmpIntNode)
+	assert.False(t, inserted)
+	assert.Equal(t, ns[0], got)
+}
+
+func TestAvlNodeSetUnlinked(t *testing.T) {
+
+	var r *AvlNode
+
+	n := &intNode{key: 1}
+	assert.False(t, n.avlHeader.IsUnlinked())
+
+	n.avlHeader.SetUnlinked()
+	assert.True(t, n.avlHeader.IsUnlinked())
+
+	assert.Nil(t, AvlTreeInsert(&r, &n.avlHeader, n, cmpIntNode))
+	assert.False(t, n.avlHeader.IsUnlinked())
+
+	AvlTreeRemove(&r, &n.avlHeader)
+	n.avlHeader.SetUnlinked()
+	assert.True(t, n.avlHeader.IsUnlinked())
+}
+
+func TestAvlTreeRawPopMinMax(t *testing.T) {
+
+	r, _ := buildIntTree(5, 2, 8, 1, 9)
+
+	assert.Equal(t, 1, AvlTreePopMin(&r).(*intNode).key)
+	assert.Equal(t, 9, AvlTreePopMax(&r).(*intNode).key)
+	assert.Equal(t, []int{2, 5, 8}, inOrderKeys(r))
+
+	var empty *AvlNode
+	assert.Nil(t, AvlTreePopMin(&empty))
+	assert.Nil(t, AvlTreePopMax(&empty))
+}
+
+func TestAvlTreeLookupOr(t *testing.T) {
+
+	r, ns := buildIntTree(1, 2, 3)
+	def := &intNode{key: -1}
+
+	assert.Equal(t, ns[1], AvlTreeLookupOr(r, 2, cmpIntKey, def))
+	assert.Equal(t, def, AvlTreeLookupOr(r, 7, cmpIntKey, def))
+
+	assert.Equal(t, ns[2], AvlTreeLookupAs(r, 3, cmpIntKey, def))
+	assert.Equal(t, def, AvlTreeLookupAs(r, 9, cmpIntKey, def))
+	assert.Nil(t, AvlTreeLookupAs[*intNode](r, 9, cmpIntKey, nil))
+	assert.Panics(t, func() {
+		AvlTreeLookupAs(r, 1, cmpIntKey, "none")
+	})
+}
+
+func TestAvlTreeInsertIfAbsent(t *testing.T) {
+
+	var r *AvlNode
+
+	ns := newIntNodes(1, 2, 1)
+	assert.True(t, AvlTreeInsertIfAbsent(&r, &ns[0].avlHeader, ns[0], cmpIntNode))
+	assert.True(t, AvlTreeInsertIfAbsent(&r, &ns[1].avlHeader, ns[1], cmpIntNode))
+	assert.False(t, AvlTreeInsertIfAbsent(&r, &ns[2].avlHeader, ns[2], cmpIntNode))
+	assert.Equal(t, ns[0], AvlTreeLookup(r, 1, cmpIntKey))
+}
+
+func TestAvlTreeSearch(t *testing.T) {
+
+	r, ns := buildIntTree(10, 20, 30, 40, 50)
+
+	calls := 0
+	ge := func(k int) func(owner interface{}) bool {
+		return func(owner interface{}) bool {
+			calls++
+			return owner.(*intNode).key >= k
+		}
+	}
+
+	assert.Equal(t, ns[2], AvlTreeSearch(r, ge(25)))
+	assert.Equal(t, ns[2], AvlTreeSearch(r, ge(30)))
+	assert.Equal(t, ns[0], AvlTreeSearch(r, ge(-5)))
+	assert.Nil(t, AvlTreeSearch(r, ge(51)))
+	assert.Nil(t, AvlTreeSearch(nil, ge(0)))
+
+	// One call per level of the tree
+
+	calls = 0
+	AvlTreeSearch(r, ge(45))
+	assert.True(t, calls <= 3)
+}
+
+func TestAvlTreeMergeIter(t *testing.T) {
+
+	var a, b AvlTree
+
+	for _, n := range newIntNodes(1, 4, 6, 9) {
+		a.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+	for _, n := range newIntNodes(2, 4, 10) {
+		b.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	var keys []int
+	var fromA []bool
+	for owner := range AvlTreeMergeIter(&a, &b, cmpIntNode) {
+		keys = append(keys, owner.(*intNode).key)
+		fromA = append(fromA, a.Lookup(owner.(*intNode).key, cmpIntKey) == owner)
+	}
+	assert.Equal(t, []int{1, 2, 4, 4, 6, 9, 10}, keys)
+	assert.Equal(t, []bool{true, false, true, false, true, true, false}, fromA)
+
+	// Stopping early, and empty trees
+
+	keys = nil
+	for owner := range AvlTreeMergeIter(&a, &AvlTree{}, cmpIntNode) {
+		keys = append(keys, owner.(*intNode).key)
+		if len(keys) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []int{1, 4}, keys)
+
+	for range AvlTreeMergeIter(&AvlTree{}, &AvlTree{}, cmpIntNode) {
+		t.Fatal("empty merge yielded")
+	}
+}
+
+func TestAvlTreeZip(t *testing.T) {
+
+	var a, b AvlTree
+
+	as := newIntNodes(1, 4, 6)
+	bs := newIntNodes(2, 4, 6, 8)
+	for _, n := range as {
+		a.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+	for _, n := range bs {
+		b.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	type pair struct{ a, b interface{} }
+	var got []pair
+	for x, y := range AvlTreeZip(&a, &b, cmpIntNode) {
+		got = append(got, pair{x, y})
+	}
+
+	assert.Equal(t, []pair{
+		{as[0], nil},
+		{nil, bs[0]},
+		{as[1], bs[1]},
+		{as[2], bs[2]},
+		{nil, bs[3]},
+	}, got)
+
+	n := 0
+	for range AvlTreeZip(&a, &b, cmpIntNode) {
+		n++
+		break
+	}
+	assert.Equal(t, 1, n)
+}
+
+func TestAvlNodeSwapOwner(t *testing.T) {
+
+	var tree AvlTree
+
+	ns := newIntNodes(1, 2, 3)
+	for _, n := range ns {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	stats := tree.Stats()
+
+	// A payload with the same key takes the old one's place
+
+	repl := &intNode{key: 2}
+	assert.Same(t, ns[1], ns[1].avlHeader.SwapOwner(repl))
+	assert.Same(t, repl, tree.Lookup(2, cmpIntKey))
+	assert.Same(t, repl, ns[1].avlHeader.Owner())
+	assert.Equal(t, stats, tree.Stats())
+	assert.NoError(t, tree.Validate(cmpIntNode))
+}
@@ -0,0 +1,127 @@
+//
+// Copyright as per Creative Commons Legal Code license, which can
+// be found in the file COPYING
+//
+
+/*
+
+Package avlmetrics exposes the size, height and operation counts of avl
+trees to monitoring systems, through expvar or anything that can take a
+list of samples, such as a Prometheus collector.  It depends only on the
+standard library; the Prometheus glue is a few lines in the caller:
+
+	type collector struct{ m *avlmetrics.Metrics }
+
+	func (c collector) Describe(ch chan<- *prometheus.Desc) {
+		prometheus.DescribeByCollect(c, ch)
+	}
+
+	func (c collector) Collect(ch chan<- prometheus.Metric) {
+		c.m.Collect(func(s avlmetrics.Sample) {
+			vt := prometheus.GaugeValue
+			if s.Kind == avlmetrics.Counter {
+				vt = prometheus.CounterValue
+			}
+			desc := prometheus.NewDesc(s.Name, s.Help, []string{"tree"}, nil)
+			ch <- prometheus.MustNewConstMetric(desc, vt, s.Value, s.Tree)
+		})
+	}
+
+Operation rates are left to the monitoring system, which derives them
+from the counters.
+
+*/
+
+package avlmetrics
+
+import (
+	"expvar"
+	"github.com/danswartzendruber/avl"
+)
+
+// Anything that reports a tree's stats.  *avl.AvlTree is one, but a tree
+// shared between goroutines must be wrapped (see SourceFunc) so that
+// Stats is called under the tree's lock, since metrics are collected on
+// the monitoring system's goroutine
+
+type Source interface {
+	Stats() avl.AvlTreeStats
+}
+
+// Adapts a function to Source
+
+type SourceFunc func() avl.AvlTreeStats
+
+func (f SourceFunc) Stats() avl.AvlTreeStats {
+	return f()
+}
+
+// Whether a sample can go down as well as up
+
+type Kind int
+
+const (
+	Gauge Kind = iota
+	Counter
+)
+
+// One metric of one tree
+
+type Sample struct {
+	Name  string
+	Help  string
+	Kind  Kind
+	Tree  string
+	Value float64
+}
+
+// The metrics of one named tree
+
+type Metrics struct {
+	name string
+	src  Source
+}
+
+// Returns the metrics of the tree src reports on, under name
+
+func New(name string, src Source) *Metrics {
+	return &Metrics{name: name, src: src}
+}
+
+// Calls emit with each of the tree's current metrics
+
+func (m *Metrics) Collect(emit func(Sample)) {
+
+	s := m.src.Stats()
+
+	for _, smp := range []Sample{
+		{"avl_tree_size", "Number of nodes in the tree.", Gauge, m.name, float64(s.Size)},
+		{"avl_tree_height", "Height of the tree.", Gauge, m.name, float64(s.Height)},
+		{"avl_tree_inserts_total", "Nodes inserted into the tree.", Counter, m.name, float64(s.Inserts)},
+		{"avl_tree_removes_total", "Nodes removed from the tree.", Counter, m.name, float64(s.Removes)},
+		{"avl_tree_rotations_total", "Rotations done rebalancing the tree.", Counter, m.name, float64(s.Rotations)},
+		{"avl_tree_allocs_total", "Owners allocated by the avl package.", Counter, m.name, float64(s.Allocs)},
+	} {
+		emit(smp)
+	}
+}
+
+// Returns an expvar.Var whose value is a JSON object of the tree's
+// current metrics
+
+func (m *Metrics) Var() expvar.Var {
+	return expvar.Func(func() interface{} {
+		vals := map[string]float64{}
+		m.Collect(func(s Sample) {
+			vals[s.Name] = s.Value
+		})
+		return vals
+	})
+}
+
+// Publishes the metrics with expvar under the tree's name.  Like
+// expvar.Publish, panics if the name is already in use
+
+func (m *Metrics) Publish() {
+	expvar.Publish(m.name, m.Var())
+}
@@ -0,0 +1,48 @@
+package avlmetrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"github.com/danswartzendruber/avl"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+
+	var tree avl.AvlTree
+
+	items := make([]*avl.AvlItem, 100)
+	for i := range items {
+		items[i] = &avl.AvlItem{Key: i}
+		tree.Insert(&items[i].Header, items[i], avl.AvlItemCmpNode)
+	}
+	for _, it := range items[:10] {
+		tree.Remove(&it.Header)
+	}
+
+	m := New("test_tree", &tree)
+
+	got := map[string]Sample{}
+	m.Collect(func(s Sample) {
+		got[s.Name] = s
+	})
+
+	assert.Equal(t, 90.0, got["avl_tree_size"].Value)
+	assert.Equal(t, Gauge, got["avl_tree_size"].Kind)
+	assert.Equal(t, "test_tree", got["avl_tree_size"].Tree)
+	assert.Equal(t, 100.0, got["avl_tree_inserts_total"].Value)
+	assert.Equal(t, 10.0, got["avl_tree_removes_total"].Value)
+	assert.Equal(t, Counter, got["avl_tree_rotations_total"].Kind)
+
+	// Inserting 0..99 in order rotates at every power of two and more
+
+	assert.True(t, got["avl_tree_rotations_total"].Value > 80)
+	assert.True(t, got["avl_tree_height"].Value >= 7)
+
+	m.Publish()
+
+	var vals map[string]float64
+	assert.NoError(t, json.Unmarshal([]byte(expvar.Get("test_tree").String()), &vals))
+	assert.Equal(t, 90.0, vals["avl_tree_size"])
+}
@@ -0,0 +1,29 @@
+//
+// Copyright as per Creative Commons Legal Code license, which can
+// be found in the file COPYING
+//
+
+/*
+
+Package avltest provides helpers for property-based testing of code
+built on the avl package: a generator of random operation sequences, a
+shrinker that reduces a failing sequence to a minimal one, and checkers
+for the structural invariants of a tree (ordering, balance, subtree
+sizes, and parent pointers).
+
+A property is a function that applies a sequence of operations to the
+code under test and returns an error if anything went wrong.  Check runs
+the property against many random sequences and, on the first failure,
+shrinks the sequence before reporting it:
+
+	avltest.Check(t, avltest.Config{}, func(ops []avltest.Op) error {
+		var tree avl.AvlTree
+		for _, op := range ops {
+			(apply op to the tree)
+		}
+		return avltest.CheckTree(tree.Root(), cmp)
+	})
+
+*/
+
+package avltest
@@ -0,0 +1,85 @@
+package avltest
+
+import (
+	"fmt"
+	"github.com/danswartzendruber/avl"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+type node struct {
+	hdr avl.AvlNode
+	key int
+}
+
+func cmpNode(a interface{}, b interface{}) int {
+	return a.(*node).key - b.(*node).key
+}
+
+func cmpKey(k interface{}, b interface{}) int {
+	return k.(int) - b.(*node).key
+}
+
+func apply(ops []Op) (*avl.AvlTree, error) {
+
+	var tree avl.AvlTree
+
+	for _, op := range ops {
+		switch op.Kind {
+		case OpInsert:
+			n := &node{key: op.Key}
+			tree.Insert(&n.hdr, n, cmpNode)
+		case OpRemove:
+			if p := tree.Lookup(op.Key, cmpKey); p != nil {
+				tree.Remove(&p.(*node).hdr)
+			}
+		}
+		if err := CheckTree(tree.Root(), cmpNode); err != nil {
+			return &tree, err
+		}
+	}
+
+	return &tree, nil
+}
+
+func TestCheckAvlTree(t *testing.T) {
+
+	Check(t, Config{Runs: 20}, func(ops []Op) error {
+		_, err := apply(ops)
+		return err
+	})
+}
+
+func TestCheckersRejectBadOrdering(t *testing.T) {
+
+	tree, err := apply([]Op{{OpInsert, 1}, {OpInsert, 2}, {OpInsert, 3}})
+	assert.NoError(t, err)
+
+	tree.Root().Left().Owner().(*node).key = 5
+	assert.Error(t, CheckOrdering(tree.Root(), cmpNode))
+	assert.NoError(t, CheckBalance(tree.Root()))
+	assert.NoError(t, CheckParents(tree.Root()))
+}
+
+func TestShrink(t *testing.T) {
+
+	// A "bug" that shows up once keys 3 and 7 have both been inserted
+
+	fails := func(ops []Op) bool {
+		seen := map[int]bool{}
+		for _, op := range ops {
+			if op.Kind == OpInsert {
+				seen[op.Key] = true
+			}
+		}
+		return seen[3] && seen[7]
+	}
+
+	ops := GenOps(rand.New(rand.NewSource(2)), 300, 10)
+	assert.True(t, fails(ops))
+
+	min := Shrink(ops, fails)
+	assert.Len(t, min, 2)
+	assert.True(t, fails(min), fmt.Sprint(min))
+}
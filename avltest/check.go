@@ -0,0 +1,183 @@
+package avltest
+
+import (
+	"fmt"
+	"github.com/danswartzendruber/avl"
+	"testing"
+)
+
+// No AVL tree that fits in memory comes close to this height, so
+// anything deeper is a cycle in the child pointers
+
+const maxHeight = 128
+
+// Checks that an in-order walk of the tree rooted at root visits owners
+// in strictly increasing order according to cmp
+
+func CheckOrdering(root *avl.AvlNode, cmp avl.CmpFuncNode) error {
+
+	var prev *avl.AvlNode
+	var stack []*avl.AvlNode
+
+	for n := root; n != nil || len(stack) > 0; {
+		for ; n != nil; n = n.Left() {
+			if len(stack) >= maxHeight {
+				return fmt.Errorf("tree deeper than %d; cycle?", maxHeight)
+			}
+			stack = append(stack, n)
+		}
+
+		n = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if prev != nil && cmp(prev.Owner(), n.Owner()) >= 0 {
+			return fmt.Errorf("out of order: %v before %v", prev.Owner(),
+				n.Owner())
+		}
+		prev = n
+		n = n.Right()
+	}
+
+	return nil
+}
+
+// Checks that every balance factor is in [-1, +1] and equal to the
+// height of the node's right subtree minus that of its left subtree
+
+func CheckBalance(root *avl.AvlNode) error {
+	_, err := height(root, 0)
+	return err
+}
+
+func height(n *avl.AvlNode, depth int) (int, error) {
+
+	if n == nil {
+		return 0, nil
+	}
+	if depth >= maxHeight {
+		return 0, fmt.Errorf("tree deeper than %d; cycle?", maxHeight)
+	}
+
+	lh, err := height(n.Left(), depth+1)
+	if err != nil {
+		return 0, err
+	}
+	rh, err := height(n.Right(), depth+1)
+	if err != nil {
+		return 0, err
+	}
+
+	bf := avl.AvlGetBalanceFactor(n)
+	if bf != rh-lh || bf < -1 || bf > 1 {
+		return 0, fmt.Errorf("%v: balance factor %d, subtree heights %d/%d",
+			n.Owner(), bf, lh, rh)
+	}
+
+	if lh > rh {
+		return lh + 1, nil
+	}
+	return rh + 1, nil
+}
+
+// Checks that the root has no parent and that every child's parent
+// pointer points back at the node it hangs from
+
+func CheckParents(root *avl.AvlNode) error {
+
+	if root == nil {
+		return nil
+	}
+	if root.Parent() != nil {
+		return fmt.Errorf("root %v has parent %v", root.Owner(),
+			root.Parent().Owner())
+	}
+
+	stack := []*avl.AvlNode{root}
+
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		for _, c := range []*avl.AvlNode{n.Left(), n.Right()} {
+			if c == nil {
+				continue
+			}
+			if c.Parent() != n {
+				return fmt.Errorf("%v: parent is not %v", c.Owner(),
+					n.Owner())
+			}
+			stack = append(stack, c)
+		}
+	}
+
+	return nil
+}
+
+// Checks that every node's subtree size is one more than the sum of its
+// children's
+
+func CheckSizes(root *avl.AvlNode) error {
+
+	var stack []*avl.AvlNode
+
+	if root != nil {
+		stack = append(stack, root)
+	}
+
+	for visited := 0; len(stack) > 0; visited++ {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if visited >= root.SubtreeSize() {
+			return fmt.Errorf("more than %d nodes below the root",
+				root.SubtreeSize())
+		}
+
+		want := 1 + n.Left().SubtreeSize() + n.Right().SubtreeSize()
+		if n.SubtreeSize() != want {
+			return fmt.Errorf("%v: subtree size %d, children add up to %d",
+				n.Owner(), n.SubtreeSize(), want)
+		}
+
+		for _, c := range []*avl.AvlNode{n.Left(), n.Right()} {
+			if c != nil {
+				stack = append(stack, c)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Runs all of the checks, parents first so that the others are not
+// sent around a cycle
+
+func CheckTree(root *avl.AvlNode, cmp avl.CmpFuncNode) error {
+
+	if err := CheckParents(root); err != nil {
+		return err
+	}
+	if err := CheckBalance(root); err != nil {
+		return err
+	}
+	if err := CheckSizes(root); err != nil {
+		return err
+	}
+
+	return CheckOrdering(root, cmp)
+}
+
+// Reports any CheckTree failure through t.  Returns true if the tree
+// is valid
+
+func AssertTree(t testing.TB, root *avl.AvlNode, cmp avl.CmpFuncNode) bool {
+
+	t.Helper()
+
+	if err := CheckTree(root, cmp); err != nil {
+		t.Errorf("invalid tree: %v", err)
+		return false
+	}
+
+	return true
+}
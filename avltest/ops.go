@@ -0,0 +1,155 @@
+package avltest
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// Kinds of generated operation
+
+type OpKind int
+
+const (
+	OpInsert OpKind = iota
+	OpRemove
+	OpLookup
+)
+
+func (k OpKind) String() string {
+	switch k {
+	case OpInsert:
+		return "insert"
+	case OpRemove:
+		return "remove"
+	case OpLookup:
+		return "lookup"
+	default:
+		return "unknown"
+	}
+}
+
+// One operation on a tree.  Keys are small integers; a property maps
+// them to whatever owners the code under test stores
+
+type Op struct {
+	Kind OpKind
+	Key  int
+}
+
+func (op Op) String() string {
+	return fmt.Sprintf("%v(%d)", op.Kind, op.Key)
+}
+
+// Returns n random operations on keys in [0, keyRange).  Insertions are
+// generated twice as often as the other kinds, so trees grow
+
+func GenOps(r *rand.Rand, n, keyRange int) []Op {
+
+	ops := make([]Op, n)
+
+	for i := range ops {
+		kind := OpInsert
+		switch r.Intn(4) {
+		case 2:
+			kind = OpRemove
+		case 3:
+			kind = OpLookup
+		}
+		ops[i] = Op{kind, r.Intn(keyRange)}
+	}
+
+	return ops
+}
+
+// Returns a smaller sequence of operations for which fails still
+// returns true, given one for which it does.  Chunks of operations are
+// dropped (delta debugging) until no single operation can be removed,
+// then keys are lowered towards 0 where that keeps the failure
+
+func Shrink(ops []Op, fails func([]Op) bool) []Op {
+
+	cur := append([]Op(nil), ops...)
+
+	for chunk := len(cur) / 2; chunk >= 1; {
+		removed := false
+		for start := 0; start+chunk <= len(cur); {
+			try := append(append([]Op(nil), cur[:start]...),
+				cur[start+chunk:]...)
+			if fails(try) {
+				cur = try
+				removed = true
+			} else {
+				start += chunk
+			}
+		}
+		if !removed {
+			chunk /= 2
+		}
+	}
+
+	for i := range cur {
+		for cur[i].Key > 0 {
+			try := append([]Op(nil), cur...)
+			try[i].Key /= 2
+			if !fails(try) {
+				try[i].Key = cur[i].Key - 1
+				if !fails(try) {
+					break
+				}
+			}
+			cur = try
+		}
+	}
+
+	return cur
+}
+
+// Settings for Check.  Zero fields take the defaults shown
+
+type Config struct {
+	Seed     int64 // 1
+	Runs     int   // 100
+	Ops      int   // 200
+	KeyRange int   // 50
+}
+
+// Runs prop against Runs random operation sequences.  On the first
+// failure, the sequence is shrunk and reported through t together with
+// the seed, and Check returns false
+
+func Check(t testing.TB, cfg Config, prop func(ops []Op) error) bool {
+
+	t.Helper()
+
+	if cfg.Seed == 0 {
+		cfg.Seed = 1
+	}
+	if cfg.Runs == 0 {
+		cfg.Runs = 100
+	}
+	if cfg.Ops == 0 {
+		cfg.Ops = 200
+	}
+	if cfg.KeyRange == 0 {
+		cfg.KeyRange = 50
+	}
+
+	r := rand.New(rand.NewSource(cfg.Seed))
+
+	for run := 0; run < cfg.Runs; run++ {
+		ops := GenOps(r, cfg.Ops, cfg.KeyRange)
+		if prop(ops) == nil {
+			continue
+		}
+
+		min := Shrink(ops, func(ops []Op) bool {
+			return prop(ops) != nil
+		})
+		t.Errorf("seed %d, run %d: %v\nminimal failing sequence: %v",
+			cfg.Seed, run, prop(min), min)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,163 @@
+package avl
+
+import "fmt"
+
+//
+// Validated batches.  ApplyBatch checks a whole list of insertions and
+// removals against the tree, and against each other in order, before it
+// applies any of them, so a batch is either applied in full or not at
+// all.  Unlike a transaction nothing is ever undone: a bad batch is
+// refused before the tree is touched, and observers only ever see the
+// mutations of a good one.
+//
+
+// One mutation of a batch.  Owner is the owner of Node for an insertion,
+// and unused for a removal
+
+type AvlMutation struct {
+	Op    AvlOp
+	Node  *AvlNode
+	Owner interface{}
+}
+
+// A key the batch has inserted or removed, in a scratch tree of them.
+// item is the node that holds the key, or nil once it is removed
+
+type avlBatchKey struct {
+	node  AvlNode
+	owner interface{}
+	item  *AvlNode
+}
+
+// Applies the batch of mutations in order, with keys ordered by cmp, or
+// returns an error for the first one that would fail and leaves the tree
+// alone.  A mutation fails if it inserts a nil node, a node already in
+// the tree or a key already in the tree (unless the tree's duplicate
+// policy keeps or replaces it; see WithDuplicates), or removes a nil node
+// or one not in the tree, taking the earlier mutations of the batch into
+// account.  An insertion into a bounded tree (see WithMaxSize) may evict
+// any owner, so on a bounded tree a removal after an insertion fails
+// too.  Errors wrap ErrNilComparator, ErrNilNode, ErrAlreadyLinked,
+// ErrKeyExists, ErrNotInTree or ErrBatchEvicts
+
+func (tree *AvlTree) ApplyBatch(batch []AvlMutation,
+	cmp CmpFuncNode) (err error) {
+
+	defer tree.recoverToErr(&err)
+
+	if err := tree.checkBatch(batch, cmp); err != nil {
+		return err
+	}
+
+	for _, m := range batch {
+		if m.Op == AvlOpInsert {
+			tree.insert(m.Node, m.Owner, cmp)
+		} else {
+			tree.unlink(m.Node)
+		}
+	}
+
+	return nil
+}
+
+func (tree *AvlTree) checkBatch(batch []AvlMutation, cmp CmpFuncNode) error {
+
+	if cmp == nil {
+		return ErrNilComparator
+	}
+
+	// The nodes and keys the batch has touched so far, and whether they
+	// are in the tree after the mutations so far
+
+	nodes := make(map[*AvlNode]bool)
+	owners := make(map[*AvlNode]interface{})
+	var keys *AvlNode
+	inserted := false
+
+	keyCmp := func(key interface{}, node interface{}) int {
+		return cmp(key, node.(*avlBatchKey).owner)
+	}
+
+	nodeIn := func(node *AvlNode) bool {
+		if in, ok := nodes[node]; ok {
+			return in
+		}
+		return avlTreeContains(tree.root, node)
+	}
+
+	// The node holding owner's key, or nil
+
+	keyNode := func(owner interface{}) *AvlNode {
+		if k := AvlTreeLookup(keys, owner, keyCmp); k != nil {
+			return k.(*avlBatchKey).item
+		}
+		for cur := tree.root; cur != nil; {
+			switch c := cmp(owner, cur.owner); {
+			case c < 0:
+				cur = cur.left
+			case c > 0:
+				cur = cur.right
+			default:
+				return cur
+			}
+		}
+		return nil
+	}
+
+	setKey := func(owner interface{}, item *AvlNode) {
+		if k := AvlTreeLookup(keys, owner, keyCmp); k != nil {
+			k.(*avlBatchKey).item = item
+			return
+		}
+		k := &avlBatchKey{owner: owner, item: item}
+		AvlTreeInsert(&keys, &k.node, k, func(a, b interface{}) int {
+			return cmp(a.(*avlBatchKey).owner, b.(*avlBatchKey).owner)
+		})
+	}
+
+	for i, m := range batch {
+		if m.Node == nil {
+			return fmt.Errorf("%w: mutation %d", ErrNilNode, i)
+		}
+
+		switch m.Op {
+		case AvlOpInsert:
+			if nodeIn(m.Node) {
+				return fmt.Errorf("%w: mutation %d: %v", ErrAlreadyLinked, i, m.Owner)
+			}
+			if !tree.keepsDups() {
+				if existing := keyNode(m.Owner); existing != nil {
+					if tree.dups != AvlDupReplace {
+						return fmt.Errorf("%w: mutation %d: %v", ErrKeyExists, i, m.Owner)
+					}
+					nodes[existing] = false
+				}
+				setKey(m.Owner, m.Node)
+			}
+			nodes[m.Node] = true
+			owners[m.Node] = m.Owner
+			inserted = true
+
+		case AvlOpRemove:
+			owner, ok := owners[m.Node]
+			if !ok {
+				owner = m.Node.owner
+			}
+			if inserted && tree.bound != nil {
+				return fmt.Errorf("%w: mutation %d: %v", ErrBatchEvicts, i, owner)
+			}
+			if !nodeIn(m.Node) {
+				return fmt.Errorf("%w: mutation %d: %v", ErrNotInTree, i, owner)
+			}
+			nodes[m.Node] = false
+			if !tree.keepsDups() {
+				setKey(owner, nil)
+			}
+
+		default:
+			return fmt.Errorf("avl: mutation %d: unknown operation %v", i, m.Op)
+		}
+	}
+
+	return nil
+}
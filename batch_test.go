@@ -0,0 +1,109 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAvlTreeApplyBatch(t *testing.T) {
+
+	var tree AvlTree
+	var ops []AvlOp
+
+	tree.Watch(func(op AvlOp, owner interface{}) {
+		ops = append(ops, op)
+	})
+
+	ns := newIntNodes(1, 2, 3, 2, 4, 4)
+	tree.Insert(&ns[0].avlHeader, ns[0], cmpIntNode)
+	tree.Insert(&ns[1].avlHeader, ns[1], cmpIntNode)
+	ops = nil
+
+	ins := func(n *intNode) AvlMutation {
+		return AvlMutation{Op: AvlOpInsert, Node: &n.avlHeader, Owner: n}
+	}
+	rem := func(n *intNode) AvlMutation {
+		return AvlMutation{Op: AvlOpRemove, Node: &n.avlHeader}
+	}
+
+	// Bad batches are refused whole
+
+	bad := []struct {
+		batch []AvlMutation
+		err   error
+	}{
+		{[]AvlMutation{ins(ns[2]), ins(ns[3])}, ErrKeyExists},
+		{[]AvlMutation{ins(ns[4]), ins(ns[5])}, ErrKeyExists},
+		{[]AvlMutation{ins(ns[2]), ins(ns[2])}, ErrAlreadyLinked},
+		{[]AvlMutation{ins(ns[2]), rem(ns[4])}, ErrNotInTree},
+		{[]AvlMutation{rem(ns[0]), rem(ns[0])}, ErrNotInTree},
+		{[]AvlMutation{ins(ns[2]), {Op: AvlOpRemove}}, ErrNilNode},
+	}
+	for _, b := range bad {
+		assert.ErrorIs(t, tree.ApplyBatch(b.batch, cmpIntNode), b.err)
+		assert.Equal(t, []int{1, 2}, keysOf(&tree))
+		assert.Empty(t, ops)
+	}
+	assert.ErrorIs(t, tree.ApplyBatch(nil, nil), ErrNilComparator)
+
+	// Earlier mutations are taken into account: a removed key can be
+	// inserted again, and a node inserted by the batch removed
+
+	assert.NoError(t, tree.ApplyBatch([]AvlMutation{
+		rem(ns[1]),
+		ins(ns[3]),
+		ins(ns[4]),
+		rem(ns[4]),
+		ins(ns[5]),
+		ins(ns[2]),
+	}, cmpIntNode))
+
+	assert.Equal(t, []int{1, 2, 3, 4}, keysOf(&tree))
+	assert.Equal(t, ns[3], tree.Lookup(2, cmpIntKey))
+	assert.Equal(t, ns[5], tree.Lookup(4, cmpIntKey))
+	assert.Len(t, ops, 6)
+	assert.NoError(t, tree.Validate(cmpIntNode))
+}
+
+func TestAvlTreeApplyBatchPolicies(t *testing.T) {
+
+	ins := func(n *intNode) AvlMutation {
+		return AvlMutation{Op: AvlOpInsert, Node: &n.avlHeader, Owner: n}
+	}
+	rem := func(n *intNode) AvlMutation {
+		return AvlMutation{Op: AvlOpRemove, Node: &n.avlHeader}
+	}
+
+	// A replacing tree takes a key already present, and the node it
+	// displaces is no longer there to remove
+
+	tree := NewAvlTree(WithDuplicates(AvlDupReplace))
+	ns := newIntNodes(1, 2, 2, 2)
+	tree.Insert(&ns[0].avlHeader, ns[0], cmpIntNode)
+	tree.Insert(&ns[1].avlHeader, ns[1], cmpIntNode)
+
+	assert.ErrorIs(t, tree.ApplyBatch([]AvlMutation{ins(ns[2]), rem(ns[1])},
+		cmpIntNode), ErrNotInTree)
+	assert.NoError(t, tree.ApplyBatch([]AvlMutation{ins(ns[2]), ins(ns[3]),
+		rem(ns[3])}, cmpIntNode))
+	assert.Equal(t, []int{1}, keysOf(tree))
+	assert.True(t, ns[1].avlHeader.IsUnlinked())
+	assert.True(t, ns[2].avlHeader.IsUnlinked())
+	assert.NoError(t, tree.Validate(cmpIntNode))
+
+	// An insertion into a bounded tree may evict the node a later
+	// removal names
+
+	tree = NewAvlTree(WithMaxSize(2, nil))
+	ns = newIntNodes(1, 2, 3)
+	tree.Insert(&ns[0].avlHeader, ns[0], cmpIntNode)
+	tree.Insert(&ns[1].avlHeader, ns[1], cmpIntNode)
+
+	assert.ErrorIs(t, tree.ApplyBatch([]AvlMutation{ins(ns[2]), rem(ns[0])},
+		cmpIntNode), ErrBatchEvicts)
+	assert.Equal(t, []int{1, 2}, keysOf(tree))
+	assert.NoError(t, tree.ApplyBatch([]AvlMutation{rem(ns[0]), ins(ns[2])},
+		cmpIntNode))
+	assert.Equal(t, []int{2, 3}, keysOf(tree))
+	assert.NoError(t, tree.Validate(cmpIntNode))
+}
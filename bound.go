@@ -0,0 +1,126 @@
+package avl
+
+//
+// Bounded trees.  A tree made with WithMaxSize holds at most a given
+// number of owners: when an insertion takes it over the limit, the
+// least owner (or the greatest, with WithEvictMax) is removed again and
+// handed to an eviction callback.  The owner evicted may be the one
+// just inserted.  Only insertions evict; a tree rebuilt wholesale (by
+// RestoreFrom, say) is not trimmed until the next insertion.
+//
+
+type avlBound struct {
+	maxSize  int
+	evictMax bool
+	onEvict  func(owner interface{})
+	maxCost  int64
+	cost     func(owner interface{}) int64
+	total    int64
+}
+
+// Adds owner's cost to the total (sign +1) or takes it off (sign -1)
+
+func (b *avlBound) add(owner interface{}, sign int64) {
+	if b.cost != nil {
+		b.total += sign * b.cost(owner)
+	}
+}
+
+// Recomputes the total cost after the contents have been replaced
+
+func (tree *AvlTree) recost() {
+	tree.bound.total = 0
+	for n := tree.first; n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+		tree.bound.add(n.owner, 1)
+	}
+}
+
+func (b *avlBound) over(size int) bool {
+	return (b.maxSize > 0 && size > b.maxSize) ||
+		(b.cost != nil && size > 0 && b.total > b.maxCost)
+}
+
+func (tree *AvlTree) boundOpts() *avlBound {
+	if tree.bound == nil {
+		tree.bound = &avlBound{}
+	}
+	return tree.bound
+}
+
+// Limits the tree to n owners, evicting the least owner when an
+// insertion would take it beyond that, and then calling onEvict (if not
+// nil) with the evicted owner.  Observers see the eviction as a removal
+
+func WithMaxSize(n int, onEvict func(owner interface{})) AvlTreeOption {
+	return func(tree *AvlTree) {
+		b := tree.boundOpts()
+		b.maxSize = n
+		b.onEvict = onEvict
+	}
+}
+
+// Limits the total cost of the tree's owners, by cost, to max, evicting
+// the least owner when an insertion would take it beyond that (more than
+// one if need be), and then calling onEvict (if not nil) with each
+// evicted owner.  With WithMaxSize too, both bounds apply, and the
+// onEvict of the later option is the one called
+
+func WithMaxCost(max int64, cost func(owner interface{}) int64,
+	onEvict func(owner interface{})) AvlTreeOption {
+
+	return func(tree *AvlTree) {
+		b := tree.boundOpts()
+		b.maxCost = max
+		b.cost = cost
+		b.onEvict = onEvict
+	}
+}
+
+// Returns the total cost of the tree's owners, by the cost function of
+// WithMaxCost; 0 without one.  O(1)
+
+func (tree *AvlTree) Cost() int64 {
+	if tree.bound == nil {
+		return 0
+	}
+	return tree.bound.total
+}
+
+// Evicts the greatest owner, rather than the least, from a bounded tree
+
+func WithEvictMax() AvlTreeOption {
+	return func(tree *AvlTree) {
+		tree.boundOpts().evictMax = true
+	}
+}
+
+// Evicts owners, after an insertion, until the tree is within its bound
+
+func (tree *AvlTree) evict() {
+
+	b := tree.bound
+
+	// Undo and redo replay evictions themselves
+
+	if tree.journal != nil && tree.journal.replaying {
+		return
+	}
+
+	for b.over(tree.size) {
+		node := tree.first
+		if b.evictMax {
+			node = tree.last
+		}
+
+		// An undo of the insertion undoes the eviction too
+
+		if tree.journal != nil {
+			tree.journal.join = true
+		}
+
+		tree.unlink(node)
+		if b.onEvict != nil {
+			b.onEvict(node.owner)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAvlTreeMaxSize(t *testing.T) {
+
+	var evicted []int
+	onEvict := func(owner interface{}) {
+		evicted = append(evicted, owner.(*intNode).key)
+	}
+
+	tree := NewAvlTree(WithMaxSize(3, onEvict))
+	for _, n := range newIntNodes(5, 3, 8, 1, 9, 4) {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	// 1 and 4 are evicted as soon as they are inserted, being the least
+
+	assert.Equal(t, []int{1, 3, 4}, evicted)
+	assert.Equal(t, []int{5, 8, 9}, keysOf(tree))
+
+	// Keeping the least instead, and undoing an insertion brings the
+	// evicted owner back
+
+	evicted = nil
+	tree = NewAvlTree(WithMaxSize(2, onEvict), WithEvictMax())
+	j := tree.Journal(cmpIntNode)
+
+	for _, n := range newIntNodes(5, 3, 1) {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+	assert.Equal(t, []int{5}, evicted)
+	assert.Equal(t, []int{1, 3}, keysOf(tree))
+
+	j.Undo(1)
+	assert.Equal(t, []int{3, 5}, keysOf(tree))
+	assert.NoError(t, tree.Validate(cmpIntNode))
+}
+
+func TestAvlTreeMaxCost(t *testing.T) {
+
+	var evicted []int
+	cost := func(owner interface{}) int64 {
+		return int64(owner.(*intNode).key)
+	}
+
+	tree := NewAvlTree(WithMaxCost(20, cost, func(owner interface{}) {
+		evicted = append(evicted, owner.(*intNode).key)
+	}))
+
+	for _, n := range newIntNodes(5, 3, 8, 2) {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+	assert.Equal(t, int64(18), tree.Cost())
+	assert.Empty(t, evicted)
+
+	// 9 takes the total to 27, so the least go until it is back under
+
+	n := &intNode{key: 9}
+	tree.Insert(&n.avlHeader, n, cmpIntNode)
+	assert.Equal(t, []int{2, 3, 5}, evicted)
+	assert.Equal(t, int64(17), tree.Cost())
+
+	// An owner that doesn't fit on its own doesn't stay
+
+	n = &intNode{key: 30}
+	tree.Insert(&n.avlHeader, n, cmpIntNode)
+	assert.Equal(t, 0, tree.Len())
+	assert.Equal(t, int64(0), tree.Cost())
+
+	// Removal and swapping keep the total right
+
+	for _, n := range newIntNodes(4, 6) {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+	tree.PopMin()
+	assert.Equal(t, int64(6), tree.Cost())
+
+	var other AvlTree
+	AvlTreeSwap(tree, &other)
+	assert.Equal(t, int64(0), tree.Cost())
+	AvlTreeSwap(tree, &other)
+	assert.Equal(t, int64(6), tree.Cost())
+}
@@ -0,0 +1,364 @@
+package avl
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+//
+// Building trees from sorted input in O(n), rather than O(n log n) by
+// repeated insertion, and a small fluent builder on top of it for
+// examples and table-driven tests:
+//
+//	tree := avl.Build().Keys(5, 3, 8, 1).Tree()
+//
+// The builder boxes each key in an AvlItem, so the owners of the tree
+// it returns are *AvlItem, ordered by AvlItemCmpNode.
+//
+
+// Builds a perfectly balanced tree from n nodes supplied in increasing
+// order by at, and returns its root.  at(i) returns the header and owner
+// of the i'th node.  The ordering is not checked
+
+func AvlTreeBuildSorted(n int, at func(i int) (*AvlNode, interface{})) *AvlNode {
+
+	root, _ := AvlTreeBuildSortedContext(context.Background(), n, at)
+
+	return root
+}
+
+// Like AvlTreeBuildSorted, but gives up and returns ctx.Err() once ctx is
+// done.  The nodes built by then are linked to one another, but not into
+// any tree
+
+func AvlTreeBuildSortedContext(ctx context.Context, n int,
+	at func(i int) (*AvlNode, interface{})) (*AvlNode, error) {
+
+	root, _, err := avlBuildRange(&avlContextTicker{ctx: ctx}, 0, n, nil, at)
+	if err != nil {
+		return nil, err
+	}
+
+	return root, nil
+}
+
+// Builds the subtree of nodes [lo, hi) under parent, returning its root
+// and height.  The middle node becomes the root, so the two halves
+// differ in size, and therefore in height, by at most one
+
+func avlBuildRange(t *avlContextTicker, lo, hi int, parent *AvlNode,
+	at func(i int) (*AvlNode, interface{})) (*AvlNode, int, error) {
+
+	if lo >= hi {
+		return nil, 0, nil
+	}
+	if err := t.tick(); err != nil {
+		return nil, 0, err
+	}
+
+	mid := lo + (hi-lo)/2
+
+	node, owner := at(mid)
+	node.owner = owner
+
+	left, lh, err := avlBuildRange(t, lo, mid, node, at)
+	if err != nil {
+		return nil, 0, err
+	}
+	right, rh, err := avlBuildRange(t, mid+1, hi, node, at)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	node, h := avlBuildLink(node, parent, left, lh, right, rh)
+
+	return node, h, nil
+}
+
+// Links node above its built subtrees, returning it and its height
+
+func avlBuildLink(node, parent, left *AvlNode, lh int, right *AvlNode,
+	rh int) (*AvlNode, int) {
+
+	node.left = left
+	node.right = right
+	node.size = int32(1 + avlGetSize(left) + avlGetSize(right))
+	avlSetParentBalance(node, parent, rh-lh)
+
+	if lh > rh {
+		return node, lh + 1
+	}
+	return node, rh + 1
+}
+
+// Below this many nodes a subtree is built on the current goroutine
+
+const avlParallelBuildMin = 1 << 14
+
+// Like AvlTreeBuildSorted, but builds disjoint subtrees on up to workers
+// goroutines (GOMAXPROCS if workers <= 0) and links them at the end.
+// at is called concurrently, once for each i, so it must be safe for
+// that; indexing into a slice is.  The tree is the same as the one
+// AvlTreeBuildSorted would build
+
+func AvlTreeBuildSortedParallel(n, workers int,
+	at func(i int) (*AvlNode, interface{})) *AvlNode {
+
+	root, _ := AvlTreeBuildSortedParallelContext(context.Background(), n,
+		workers, at)
+
+	return root
+}
+
+// Like AvlTreeBuildSortedParallel, but gives up and returns ctx.Err()
+// once ctx is done.  Each goroutine checks ctx as it goes.  The nodes
+// built by then are linked to one another, but not into any tree
+
+func AvlTreeBuildSortedParallelContext(ctx context.Context, n, workers int,
+	at func(i int) (*AvlNode, interface{})) (*AvlNode, error) {
+
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	root, _, err := avlBuildRangeParallel(ctx, 0, n, nil, at, workers)
+	if err != nil {
+		return nil, err
+	}
+
+	return root, nil
+}
+
+// avlBuildRange, splitting the workers between the two halves
+
+func avlBuildRangeParallel(ctx context.Context, lo, hi int, parent *AvlNode,
+	at func(i int) (*AvlNode, interface{}), workers int) (*AvlNode, int, error) {
+
+	if workers <= 1 || hi-lo < avlParallelBuildMin {
+		return avlBuildRange(&avlContextTicker{ctx: ctx}, lo, hi, parent, at)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	mid := lo + (hi-lo)/2
+
+	node, owner := at(mid)
+	node.owner = owner
+
+	var left *AvlNode
+	var lh int
+	var lerr error
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		left, lh, lerr = avlBuildRangeParallel(ctx, lo, mid, node, at, workers/2)
+	}()
+	right, rh, rerr := avlBuildRangeParallel(ctx, mid+1, hi, node, at,
+		workers-workers/2)
+	wg.Wait()
+
+	if lerr != nil {
+		return nil, 0, lerr
+	}
+	if rerr != nil {
+		return nil, 0, rerr
+	}
+
+	node, h := avlBuildLink(node, parent, left, lh, right, rh)
+
+	return node, h, nil
+}
+
+// A boxed key, for trees of plain values
+
+type AvlItem struct {
+	Header AvlNode
+	Key    interface{}
+}
+
+// Compares the keys of two *AvlItem owners with AvlCompareValues
+
+func AvlItemCmpNode(node1 interface{}, node2 interface{}) int {
+	return AvlCompareValues(node1.(*AvlItem).Key, node2.(*AvlItem).Key)
+}
+
+// Compares a key with the key of an *AvlItem owner with
+// AvlCompareValues
+
+func AvlItemCmpKey(key interface{}, node interface{}) int {
+	return AvlCompareValues(key, node.(*AvlItem).Key)
+}
+
+// Returns a CmpFuncNode comparing the string keys of two *AvlItem
+// owners with c, e.g. a collate.Collator for locale-correct order
+
+func AvlItemCollateNode(c AvlStringCollator) CmpFuncNode {
+	return func(node1 interface{}, node2 interface{}) int {
+		return c.CompareString(node1.(*AvlItem).Key.(string),
+			node2.(*AvlItem).Key.(string))
+	}
+}
+
+// Returns a CmpFuncKey comparing a string key with the string key of an
+// *AvlItem owner with c
+
+func AvlItemCollateKey(c AvlStringCollator) CmpFuncKey {
+	return func(key interface{}, node interface{}) int {
+		return c.CompareString(key.(string), node.(*AvlItem).Key.(string))
+	}
+}
+
+// Fluent builder for trees of AvlItems
+
+type AvlBuilder struct {
+	items []*AvlItem
+	cmp   CmpFuncNode
+}
+
+// Starts building a tree
+
+func Build() *AvlBuilder {
+	return &AvlBuilder{cmp: AvlItemCmpNode}
+}
+
+// Adds keys to the tree being built, in any order
+
+func (b *AvlBuilder) Keys(keys ...interface{}) *AvlBuilder {
+	for _, k := range keys {
+		b.items = append(b.items, &AvlItem{Key: k})
+	}
+	return b
+}
+
+// Orders the tree with cmp, which compares two *AvlItem owners, instead
+// of AvlItemCmpNode
+
+func (b *AvlBuilder) Cmp(cmp CmpFuncNode) *AvlBuilder {
+	b.cmp = cmp
+	return b
+}
+
+// Orders the tree, whose keys must be strings, with c; see
+// AvlItemCollateNode.  Look keys up with AvlItemCollateKey(c)
+
+func (b *AvlBuilder) Collate(c AvlStringCollator) *AvlBuilder {
+	return b.Cmp(AvlItemCollateNode(c))
+}
+
+// Returns the items added so far, in increasing order with duplicates
+// removed (the first one added is kept)
+
+func (b *AvlBuilder) sorted() []*AvlItem {
+
+	items := append([]*AvlItem(nil), b.items...)
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return b.cmp(items[i], items[j]) < 0
+	})
+
+	out := items[:0]
+	for _, it := range items {
+		if len(out) == 0 || b.cmp(out[len(out)-1], it) != 0 {
+			out = append(out, it)
+		}
+	}
+
+	return out
+}
+
+// Builds the tree.  Each call builds a new tree from new items
+
+func (b *AvlBuilder) Tree() *AvlTree {
+
+	items := b.sorted()
+
+	tree := NewAvlTree()
+	tree.reset(AvlTreeBuildSorted(len(items), func(i int) (*AvlNode, interface{}) {
+		it := &AvlItem{Key: items[i].Key}
+		return &it.Header, it
+	}))
+	tree.counts.Allocs += uint64(len(items))
+
+	return tree
+}
+
+// Splits tree into one tree per group, as named by keyFn, moving each
+// node into its group's tree in O(n) with no comparisons, so the owners
+// of each group stay in the order they had in tree.  tree is left
+// empty, and its observers see a removal for every owner.  The new trees
+// get tree's options, but not its observers, its journal or its lookup
+// filter (see WithLookupFilter), which are tied to tree.  A bounded
+// tree's groups are each bounded in the same way, and like any tree
+// rebuilt wholesale are not trimmed until their next insertion
+
+func AvlTreeGroupBy[K comparable](tree *AvlTree,
+	keyFn func(owner interface{}) K) map[K]*AvlTree {
+
+	defer tree.recoverTo()
+
+	groups := make(map[K][]*AvlNode)
+	var owners []interface{}
+
+	tree.labeled(context.Background(), "groupby", func(context.Context) {
+		for n := tree.first; n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+			k := keyFn(n.owner)
+			groups[k] = append(groups[k], n)
+			if len(tree.observers) > 0 {
+				owners = append(owners, n.owner)
+			}
+		}
+	})
+
+	tree.reset(nil)
+
+	trees := make(map[K]*AvlTree, len(groups))
+	for k, nodes := range groups {
+		t := tree.emptyLike()
+		t.reset(AvlTreeBuildSorted(len(nodes), func(i int) (*AvlNode, interface{}) {
+			return nodes[i], nodes[i].owner
+		}))
+		trees[k] = t
+	}
+
+	for _, owner := range owners {
+		tree.notify(AvlOpRemove, owner)
+	}
+	tree.check(AvlOpRemove)
+
+	return trees
+}
+
+// Returns a new, empty tree with tree's options, but not those tied to
+// tree itself: its observers, its journal and its lookup filter
+
+func (tree *AvlTree) emptyLike() *AvlTree {
+
+	t := &AvlTree{
+		selfCheck:   tree.selfCheck,
+		dups:        tree.dups,
+		profileName: tree.profileName,
+		errSink:     tree.errSink,
+		onMisuse:    tree.onMisuse,
+	}
+	if tree.bound != nil {
+		b := *tree.bound
+		b.total = 0
+		t.bound = &b
+	}
+	if tree.order != nil {
+		t.order = &avlInsertionOrder{links: tree.order.links}
+	}
+	if tree.cmpCheck != nil {
+		WithCmpCheck(tree.cmpCheck.cmp, tree.cmpCheck.every)(t)
+	}
+	if tree.keyCheck != nil {
+		WithKeyCheck(tree.keyCheck.key)(t)
+	}
+
+	return t
+}
@@ -0,0 +1,230 @@
+package avl
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func itemKeys(tree *AvlTree) []interface{} {
+
+	var keys []interface{}
+
+	for p := AvlTreeFirstInOrder(tree.Root()); p != nil; p = AvlTreeNextInOrder(&p.(*AvlItem).Header) {
+		keys = append(keys, p.(*AvlItem).Key)
+	}
+
+	return keys
+}
+
+func TestBuild(t *testing.T) {
+
+	tree := Build().Keys(5, 3, 8, 1, 3).Tree()
+
+	assert.Equal(t, 4, tree.Len())
+	assert.Equal(t, []interface{}{1, 3, 5, 8}, itemKeys(tree))
+	assert.NoError(t, tree.Validate(AvlItemCmpNode))
+	assert.NotNil(t, tree.Lookup(8, AvlItemCmpKey))
+
+	desc := Build().Keys("b", "c", "a").Cmp(func(a, b interface{}) int {
+		return -AvlItemCmpNode(a, b)
+	}).Tree()
+	assert.Equal(t, []interface{}{"c", "b", "a"}, itemKeys(desc))
+
+	assert.Equal(t, 0, Build().Tree().Len())
+}
+
+func TestAvlTreeBuildSorted(t *testing.T) {
+
+	for n := 0; n < 100; n++ {
+		ns := make([]*intNode, n)
+		for i := range ns {
+			ns[i] = &intNode{key: i}
+		}
+
+		r := AvlTreeBuildSorted(n, func(i int) (*AvlNode, interface{}) {
+			return &ns[i].avlHeader, ns[i]
+		})
+		assert.NoError(t, AvlTreeValidate(r, cmpIntNode))
+
+		// The result must be an ordinary tree
+
+		if n > 0 {
+			AvlTreeRemove(&r, &ns[n/3].avlHeader)
+			assert.NoError(t, AvlTreeValidate(r, cmpIntNode))
+		}
+	}
+}
+
+func TestAvlTreeBuildSortedContext(t *testing.T) {
+
+	ns := newIntNodes(make([]int, 5000)...)
+	for i, n := range ns {
+		n.key = i
+	}
+	at := func(i int) (*AvlNode, interface{}) {
+		return &ns[i].avlHeader, ns[i]
+	}
+
+	r, err := AvlTreeBuildSortedContext(context.Background(), len(ns), at)
+	assert.NoError(t, err)
+	assert.NoError(t, AvlTreeValidate(r, cmpIntNode))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r, err = AvlTreeBuildSortedContext(ctx, len(ns), at)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, r)
+}
+
+func TestAvlCompareValues(t *testing.T) {
+
+	type level uint8
+
+	assert.Equal(t, -1, AvlCompareValues(1, 2))
+	assert.Equal(t, 1, AvlCompareValues("b", "a"))
+	assert.Equal(t, 0, AvlCompareValues(level(3), level(3)))
+	assert.Equal(t, -1, AvlCompareValues(int64(-5), int64(5)))
+	assert.Equal(t, 1, AvlCompareValues(float32(2.5), float32(1)))
+	assert.Panics(t, func() { AvlCompareValues(1, "1") })
+}
+
+func TestAvlTreeBuildSortedParallel(t *testing.T) {
+
+	for _, n := range []int{0, 1, 1000, 100000} {
+		seq := newIntNodes(make([]int, n)...)
+		par := newIntNodes(make([]int, n)...)
+		for i := 0; i < n; i++ {
+			seq[i].key = i
+			par[i].key = i
+		}
+
+		AvlTreeBuildSorted(n, func(i int) (*AvlNode, interface{}) {
+			return &seq[i].avlHeader, seq[i]
+		})
+		r2 := AvlTreeBuildSortedParallel(n, 8, func(i int) (*AvlNode, interface{}) {
+			return &par[i].avlHeader, par[i]
+		})
+
+		assert.NoError(t, AvlTreeValidate(r2, cmpIntNode))
+		assert.Equal(t, n, r2.SubtreeSize())
+
+		// Same shape as the sequential build
+
+		same := true
+		for i := 0; i < n; i++ {
+			same = same && seq[i].avlHeader.Depth() == par[i].avlHeader.Depth()
+		}
+		assert.True(t, same)
+	}
+}
+
+func TestAvlTreeBuildSortedParallelContext(t *testing.T) {
+
+	ns := newIntNodes(make([]int, 100000)...)
+	for i, n := range ns {
+		n.key = i
+	}
+	at := func(i int) (*AvlNode, interface{}) {
+		return &ns[i].avlHeader, ns[i]
+	}
+
+	r, err := AvlTreeBuildSortedParallelContext(context.Background(),
+		len(ns), 4, at)
+	assert.NoError(t, err)
+	assert.Equal(t, len(ns), r.SubtreeSize())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r, err = AvlTreeBuildSortedParallelContext(ctx, len(ns), 4, at)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, r)
+}
+
+// Orders strings ignoring case, standing in for a real collator
+
+type foldCollator struct{}
+
+func (foldCollator) CompareString(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func TestBuildCollate(t *testing.T) {
+
+	tree := Build().Keys("b", "C", "a", "B").Collate(foldCollator{}).Tree()
+
+	assert.Equal(t, []interface{}{"a", "b", "C"}, itemKeys(tree))
+	assert.Equal(t, "b", tree.Lookup("B", AvlItemCollateKey(foldCollator{})).(*AvlItem).Key)
+	assert.NoError(t, tree.Validate(AvlItemCollateNode(foldCollator{})))
+}
+
+func TestAvlTreeGroupBy(t *testing.T) {
+
+	var tree AvlTree
+
+	for i := 0; i < 100; i++ {
+		n := &intNode{key: i}
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	groups := AvlTreeGroupBy(&tree, func(owner interface{}) int {
+		return owner.(*intNode).key % 3
+	})
+
+	assert.Equal(t, 0, tree.Len())
+	assert.Nil(t, tree.First())
+	assert.Len(t, groups, 3)
+
+	for r, g := range groups {
+		assert.NoError(t, g.Validate(cmpIntNode))
+		keys := keysOf(g)
+		assert.Equal(t, (100-r+2)/3, len(keys))
+		for i, k := range keys {
+			assert.Equal(t, r+3*i, k)
+		}
+	}
+}
+
+func TestAvlTreeGroupByOptions(t *testing.T) {
+
+	var evicted []int
+	tree := NewAvlTree(WithDuplicates(AvlDupKeepRight), WithKeyCheck(intNodeKey),
+		WithMaxSize(100, func(owner interface{}) {
+			evicted = append(evicted, owner.(*intNode).key)
+		}))
+	var removed []int
+	tree.Watch(func(op AvlOp, owner interface{}) {
+		if op == AvlOpRemove {
+			removed = append(removed, owner.(*intNode).key)
+		}
+	})
+
+	ns := newIntNodes(1, 2, 2, 3, 4)
+	for _, n := range ns {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+	removed = nil
+
+	groups := AvlTreeGroupBy(tree, func(owner interface{}) bool {
+		return owner.(*intNode).key%2 == 0
+	})
+	assert.Equal(t, []int{1, 2, 2, 3, 4}, removed)
+
+	// The groups keep the duplicate policy, the key check and the bound,
+	// each with a bound of its own
+
+	even := groups[true]
+	n := &intNode{key: 2}
+	assert.Nil(t, even.Insert(&n.avlHeader, n, cmpIntNode))
+	assert.Equal(t, 4, even.Len())
+
+	ns[0].key = 0
+	assert.ErrorIs(t, groups[false].Validate(cmpIntNode), ErrKeyMutated)
+	ns[0].key = 1
+
+	assert.Equal(t, 100, even.bound.maxSize)
+	assert.True(t, tree.bound != even.bound)
+	assert.Empty(t, evicted)
+	assert.Empty(t, even.observers)
+}
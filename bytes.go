@@ -0,0 +1,198 @@
+package avl
+
+import (
+	"bytes"
+	"fmt"
+	"iter"
+)
+
+//
+// Trees keyed by byte slices.  The generic functions take keys as
+// interface{}, and boxing a []byte allocates, as does converting it to a
+// string; an AvlBytesTree instead keeps each node's key alongside it and
+// compares with bytes.Compare directly, so a lookup by a caller-owned
+// slice neither copies nor allocates.  The tree keeps the slices it is
+// given on insert, and does not copy them either: a key must not change
+// while its node is linked.
+//
+
+type AvlBytesNode struct {
+	node  AvlNode
+	key   []byte
+	owner interface{}
+}
+
+// A tree of AvlBytesNodes, with at most one node per key.  The zero value
+// is an empty tree ready to use
+
+type AvlBytesTree struct {
+	root      *AvlNode
+	size      int
+	rotations uint64
+}
+
+// Returns the key of a linked node
+
+func (n *AvlBytesNode) Key() []byte {
+	return n.key
+}
+
+func avlBytesKey(node *AvlNode) []byte {
+	return node.owner.(*AvlBytesNode).key
+}
+
+// Returns the number of nodes
+
+func (tree *AvlBytesTree) Len() int {
+	return tree.size
+}
+
+func (tree *AvlBytesTree) lookup(key []byte) *AvlNode {
+
+	for cur := tree.root; cur != nil; {
+		res := bytes.Compare(key, avlBytesKey(cur))
+		if res < 0 {
+			cur = cur.left
+		} else if res > 0 {
+			cur = cur.right
+		} else {
+			return cur
+		}
+	}
+
+	return nil
+}
+
+// Look up a specified key.  nil if not present
+
+func (tree *AvlBytesTree) Lookup(key []byte) interface{} {
+	if n := tree.lookup(key); n != nil {
+		return n.owner.(*AvlBytesNode).owner
+	}
+	return nil
+}
+
+// Link item into the tree under key.  Returns nil if inserted, and the
+// owner of the existing node with the same key if not
+
+func (tree *AvlBytesTree) Insert(item *AvlBytesNode, key []byte,
+	owner interface{}) interface{} {
+
+	curPtr := &tree.root
+	var cur *AvlNode
+
+	for *curPtr != nil {
+		cur = *curPtr
+
+		res := bytes.Compare(key, avlBytesKey(cur))
+		if res < 0 {
+			curPtr = &cur.left
+		} else if res > 0 {
+			curPtr = &cur.right
+		} else {
+			return cur.owner.(*AvlBytesNode).owner
+		}
+	}
+
+	item.key = key
+	item.owner = owner
+
+	node := &item.node
+	*curPtr = node
+	node.parent = cur
+	node.balance = 1
+	node.owner = item
+	node.size = 1
+
+	avlAdjustSizes(cur, +1)
+	avlTreeRebalanceAfterInsert(&tree.root, node, &tree.rotations)
+	tree.size++
+
+	return nil
+}
+
+// Unlink node from the tree
+
+func (tree *AvlBytesTree) Remove(node *AvlBytesNode) {
+	avlTreeRemove(&tree.root, &node.node, &tree.rotations)
+	avlTreeNodeSetUnlinked(&node.node)
+	tree.size--
+}
+
+// Returns the least owner, or nil if empty
+
+func (tree *AvlBytesTree) First() interface{} {
+	if n := avlTreeFirstOrLastInOrder(tree.root, -1); n != nil {
+		return n.owner.(*AvlBytesNode).owner
+	}
+	return nil
+}
+
+// Returns the greatest owner, or nil if empty
+
+func (tree *AvlBytesTree) Last() interface{} {
+	if n := avlTreeFirstOrLastInOrder(tree.root, 1); n != nil {
+		return n.owner.(*AvlBytesNode).owner
+	}
+	return nil
+}
+
+// Yields the keys and owners in [lo, hi), in order; a nil bound leaves
+// that end of the range open.  The tree must not be modified while the
+// sequence is being iterated
+
+func (tree *AvlBytesTree) Range(lo, hi []byte) iter.Seq2[[]byte, interface{}] {
+
+	return func(yield func([]byte, interface{}) bool) {
+
+		var cur *AvlNode
+
+		if lo != nil {
+			for n := tree.root; n != nil; {
+				if bytes.Compare(lo, avlBytesKey(n)) <= 0 {
+					cur = n
+					n = n.left
+				} else {
+					n = n.right
+				}
+			}
+		} else {
+			cur = avlTreeFirstOrLastInOrder(tree.root, -1)
+		}
+
+		for ; cur != nil; cur = avlTreeNextOrPrevInOrder(cur, 1) {
+			item := cur.owner.(*AvlBytesNode)
+			if hi != nil && bytes.Compare(hi, item.key) <= 0 {
+				return
+			}
+			if !yield(item.key, item.owner) {
+				return
+			}
+		}
+	}
+}
+
+// Yields every key and owner, in order
+
+func (tree *AvlBytesTree) All() iter.Seq2[[]byte, interface{}] {
+	return tree.Range(nil, nil)
+}
+
+// Checks the tree's invariants, including that the keys are in strictly
+// increasing order, which a key changed in place would break
+
+func (tree *AvlBytesTree) Validate() error {
+
+	if err := AvlTreeValidate(tree.root, func(a, b interface{}) int {
+		return bytes.Compare(a.(*AvlBytesNode).key, b.(*AvlBytesNode).key)
+	}); err != nil {
+		return err
+	}
+
+	if n := avlGetSize(tree.root); n != tree.size {
+		return fmt.Errorf("%w: %d nodes, size %d", ErrInvalidTree, n,
+			tree.size)
+	}
+
+	return nil
+}
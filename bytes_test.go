@@ -0,0 +1,109 @@
+package avl
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+type tupleNode struct {
+	avlHeader AvlBytesNode
+	id        int
+}
+
+func tupleKey(a, b uint32) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint32(key, a)
+	binary.BigEndian.PutUint32(key[4:], b)
+	return key
+}
+
+func TestAvlBytesTree(t *testing.T) {
+
+	var tree AvlBytesTree
+
+	assert.Nil(t, tree.First())
+
+	rnd := rand.New(rand.NewSource(9))
+	model := map[string]*tupleNode{}
+
+	for i := 0; i < 2000; i++ {
+		key := tupleKey(uint32(rnd.Intn(20)), uint32(rnd.Intn(50)))
+		if n, ok := model[string(key)]; ok && rnd.Intn(2) == 0 {
+			tree.Remove(&n.avlHeader)
+			assert.True(t, n.avlHeader.node.IsUnlinked())
+			delete(model, string(key))
+			continue
+		}
+		n := &tupleNode{id: i}
+		if old := tree.Insert(&n.avlHeader, key, n); old != nil {
+			assert.Same(t, model[string(key)], old)
+		} else {
+			model[string(key)] = n
+		}
+	}
+
+	assert.NoError(t, tree.Validate())
+	assert.Equal(t, len(model), tree.Len())
+
+	var keys []string
+	for k := range model {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var got []string
+	for k, owner := range tree.All() {
+		got = append(got, string(k))
+		assert.Same(t, model[string(k)], owner)
+		assert.Equal(t, k, owner.(*tupleNode).avlHeader.Key())
+	}
+	assert.Equal(t, keys, got)
+	assert.Same(t, model[keys[0]], tree.First())
+	assert.Same(t, model[keys[len(keys)-1]], tree.Last())
+
+	// A range over every tuple whose first element is 7
+
+	got = nil
+	for k := range tree.Range(tupleKey(7, 0), tupleKey(8, 0)) {
+		assert.Equal(t, uint32(7), binary.BigEndian.Uint32(k))
+		got = append(got, string(k))
+	}
+	var want []string
+	for _, k := range keys {
+		if binary.BigEndian.Uint32([]byte(k)) == 7 {
+			want = append(want, k)
+		}
+	}
+	assert.Equal(t, want, got)
+
+	// Changing a linked key in place is caught
+
+	for _, owner := range tree.All() {
+		owner.(*tupleNode).avlHeader.Key()[0] = 0xff
+		break
+	}
+	assert.Error(t, tree.Validate())
+}
+
+func TestAvlBytesTreeLookupAllocs(t *testing.T) {
+
+	var tree AvlBytesTree
+
+	for i := 0; i < 100; i++ {
+		n := &tupleNode{id: i}
+		tree.Insert(&n.avlHeader, tupleKey(uint32(i), 0), n)
+	}
+
+	probe := tupleKey(42, 0)
+	var found interface{}
+	allocs := testing.AllocsPerRun(100, func() {
+		found = tree.Lookup(probe)
+	})
+
+	assert.Equal(t, 0.0, allocs)
+	assert.Equal(t, 42, found.(*tupleNode).id)
+	assert.Nil(t, tree.Lookup(tupleKey(42, 1)))
+}
@@ -0,0 +1,159 @@
+package avl
+
+import "fmt"
+
+//
+// Checked versions of the basic operations.  The plain functions trust
+// their arguments: a nil comparator panics with a nil dereference, and
+// removing a node that belongs to another tree silently corrupts both
+// trees.  The Try functions check for that kind of misuse first and
+// return an error instead, at the cost of walking from the node to the
+// root.  The Must functions make the same checks and panic with the
+// error, for callers that want misuse to fail loudly at the call site
+// rather than as corruption later.
+//
+
+// Reports whether node is linked into the tree rooted at root.  Every
+// step up checks that the parent really points back at the child, so
+// a node left stale by AvlTreeRemove is not mistaken for a linked one
+
+func avlTreeContains(root *AvlNode, node *AvlNode) bool {
+
+	if root == nil || avlTreeNodeIsUnlinked(node) {
+		return false
+	}
+
+	for n := node; ; {
+		p := avlGetParent(n)
+		if p == nil {
+			return n == root
+		}
+		if p.left != n && p.right != n {
+			return false
+		}
+		n = p
+	}
+}
+
+// Returns nil if node is linked into the tree rooted at root, and
+// otherwise ErrNilNode, or an error wrapping ErrNotInTree that says
+// whether the node is unlinked, stale, or in another tree.  Like
+// avlTreeContains it walks from node to the root.  Call it before a
+// destructive operation on a node whose tree is in doubt
+
+func AvlTreeAssertContains(root *AvlNode, node *AvlNode) error {
+
+	if node == nil {
+		return ErrNilNode
+	}
+	if avlTreeNodeIsUnlinked(node) {
+		return fmt.Errorf("%w: %v is unlinked", ErrNotInTree, node.owner)
+	}
+
+	for n := node; ; {
+		p := avlGetParent(n)
+		if p == nil {
+			if n != root {
+				return fmt.Errorf("%w: %v is in the tree rooted at %v",
+					ErrNotInTree, node.owner, n.owner)
+			}
+			return nil
+		}
+		if p.left != n && p.right != n {
+			return fmt.Errorf("%w: %v is stale, its parent %v does not link to it",
+				ErrNotInTree, node.owner, p.owner)
+		}
+		n = p
+	}
+}
+
+// Checked AvlTreeInsert.  Returns ErrNilComparator, ErrNilNode or
+// ErrAlreadyLinked on misuse; otherwise the same result as AvlTreeInsert
+
+func AvlTreeTryInsert(root **AvlNode, item *AvlNode,
+	owner interface{}, cmp CmpFuncNode) (interface{}, error) {
+
+	if err := avlTreeCheckInsert(*root, item, cmp); err != nil {
+		return nil, err
+	}
+
+	return AvlTreeInsert(root, item, owner, cmp), nil
+}
+
+// The misuse checks of AvlTreeTryInsert
+
+func avlTreeCheckInsert(root *AvlNode, item *AvlNode, cmp CmpFuncNode) error {
+
+	if cmp == nil {
+		return ErrNilComparator
+	}
+	if item == nil {
+		return ErrNilNode
+	}
+	if avlTreeContains(root, item) {
+		return fmt.Errorf("%w: %v", ErrAlreadyLinked, item.owner)
+	}
+
+	return nil
+}
+
+// Checked AvlTreeRemove.  Returns ErrNilNode or ErrNotInTree on misuse,
+// in which case neither tree has been touched
+
+func AvlTreeTryRemove(root **AvlNode, node *AvlNode) error {
+
+	if err := AvlTreeAssertContains(*root, node); err != nil {
+		return err
+	}
+
+	AvlTreeRemove(root, node)
+
+	return nil
+}
+
+// Checked AvlTreeLookup.  Returns ErrNilComparator on misuse
+
+func AvlTreeTryLookup(root *AvlNode, key interface{},
+	cmp CmpFuncKey) (interface{}, error) {
+
+	if cmp == nil {
+		return nil, ErrNilComparator
+	}
+
+	return AvlTreeLookup(root, key, cmp), nil
+}
+
+// AvlTreeTryInsert, panicking on misuse
+
+func AvlTreeMustInsert(root **AvlNode, item *AvlNode,
+	owner interface{}, cmp CmpFuncNode) interface{} {
+
+	existing, err := AvlTreeTryInsert(root, item, owner, cmp)
+	if err != nil {
+		panic(err)
+	}
+
+	return existing
+}
+
+// AvlTreeTryRemove, panicking on misuse
+
+func AvlTreeMustRemove(root **AvlNode, node *AvlNode) {
+
+	if err := AvlTreeTryRemove(root, node); err != nil {
+		panic(err)
+	}
+}
+
+// AvlTreeTryLookup, panicking on misuse
+
+func AvlTreeMustLookup(root *AvlNode, key interface{},
+	cmp CmpFuncKey) interface{} {
+
+	owner, err := AvlTreeTryLookup(root, key, cmp)
+	if err != nil {
+		panic(err)
+	}
+
+	return owner
+}
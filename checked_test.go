@@ -0,0 +1,94 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAvlTreeTryInsert(t *testing.T) {
+
+	var r *AvlNode
+
+	ns := newIntNodes(1, 2)
+
+	_, err := AvlTreeTryInsert(&r, &ns[0].avlHeader, ns[0], nil)
+	assert.ErrorIs(t, err, ErrNilComparator)
+
+	_, err = AvlTreeTryInsert(&r, nil, ns[0], cmpIntNode)
+	assert.ErrorIs(t, err, ErrNilNode)
+
+	existing, err := AvlTreeTryInsert(&r, &ns[0].avlHeader, ns[0], cmpIntNode)
+	assert.NoError(t, err)
+	assert.Nil(t, existing)
+
+	_, err = AvlTreeTryInsert(&r, &ns[0].avlHeader, ns[0], cmpIntNode)
+	assert.ErrorIs(t, err, ErrAlreadyLinked)
+
+	assert.Panics(t, func() {
+		AvlTreeMustInsert(&r, &ns[1].avlHeader, ns[1], nil)
+	})
+}
+
+func TestAvlTreeTryRemove(t *testing.T) {
+
+	r1, ns1 := buildIntTree(1, 2, 3)
+	r2, ns2 := buildIntTree(1, 2, 3)
+
+	assert.ErrorIs(t, AvlTreeTryRemove(&r1, &ns2[1].avlHeader), ErrNotInTree)
+	assert.ErrorIs(t, AvlTreeTryRemove(&r1, nil), ErrNilNode)
+	assert.NoError(t, AvlTreeValidate(r1, cmpIntNode))
+	assert.NoError(t, AvlTreeValidate(r2, cmpIntNode))
+
+	assert.NoError(t, AvlTreeTryRemove(&r1, &ns1[1].avlHeader))
+
+	// A node left stale by the removal must not look linked
+
+	assert.ErrorIs(t, AvlTreeTryRemove(&r1, &ns1[1].avlHeader), ErrNotInTree)
+	assert.Panics(t, func() {
+		AvlTreeMustRemove(&r1, &ns1[1].avlHeader)
+	})
+
+	_, err := AvlTreeTryInsert(&r1, &ns1[1].avlHeader, ns1[1], cmpIntNode)
+	assert.NoError(t, err)
+	assert.NoError(t, AvlTreeValidate(r1, cmpIntNode))
+}
+
+func TestAvlTreeTryLookup(t *testing.T) {
+
+	r, ns := buildIntTree(1, 2, 3)
+
+	_, err := AvlTreeTryLookup(r, 2, nil)
+	assert.ErrorIs(t, err, ErrNilComparator)
+	assert.Equal(t, ns[1], AvlTreeMustLookup(r, 2, cmpIntKey))
+}
+
+func TestAvlTreeAssertContains(t *testing.T) {
+
+	var a, b AvlTree
+
+	ns := newIntNodes(1, 2, 3, 4)
+	for _, n := range ns[:3] {
+		a.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+	b.Insert(&ns[3].avlHeader, ns[3], cmpIntNode)
+
+	for _, n := range ns[:3] {
+		assert.NoError(t, a.AssertContains(&n.avlHeader))
+	}
+
+	err := a.AssertContains(&ns[3].avlHeader)
+	assert.ErrorIs(t, err, ErrNotInTree)
+	assert.Contains(t, err.Error(), "rooted at")
+	assert.ErrorIs(t, a.AssertContains(nil), ErrNilNode)
+
+	a.Remove(&ns[0].avlHeader)
+	assert.Contains(t, a.AssertContains(&ns[0].avlHeader).Error(), "unlinked")
+
+	// A node removed by the plain function keeps its stale links
+
+	r, rs := buildIntTree(1, 2, 3)
+	AvlTreeRemove(&r, &rs[0].avlHeader)
+	err = AvlTreeAssertContains(r, &rs[0].avlHeader)
+	assert.ErrorIs(t, err, ErrNotInTree)
+	assert.Contains(t, err.Error(), "stale")
+}
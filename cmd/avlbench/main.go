@@ -0,0 +1,88 @@
+//
+// Copyright as per Creative Commons Legal Code license, which can
+// be found in the file COPYING
+//
+
+/*
+
+Command avlbench runs a synthetic workload against an avl.AvlTree and
+reports throughput, latency percentiles for each kind of operation, and
+memory use:
+
+	avlbench -n 1000000 -ops 5000000 -mix 10,80,5,5 -dist zipf -goroutines 8
+
+The tree is preloaded with n elements, then the goroutines share the
+operations between them.  -mix gives the relative weights of inserts,
+lookups, deletes and scans (of -scanlen elements each).  Keys are drawn
+from [0, 2n) uniformly, from a Zipf distribution concentrated on low
+keys, or sequentially.  The tree is guarded by a sync.RWMutex, with
+lookups and scans taking the read lock, as a service sharing a tree
+between goroutines would do.
+
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func main() {
+
+	var cfg config
+	var mix string
+
+	flag.IntVar(&cfg.n, "n", 100000, "elements to preload")
+	flag.IntVar(&cfg.ops, "ops", 1000000, "operations to run")
+	flag.StringVar(&mix, "mix", "10,80,5,5", "weights of insert,lookup,delete,scan")
+	flag.StringVar(&cfg.dist, "dist", "uniform", "key distribution: uniform, zipf or sequential")
+	flag.IntVar(&cfg.goroutines, "goroutines", 1, "goroutines sharing the operations")
+	flag.IntVar(&cfg.scanLen, "scanlen", 100, "elements visited by each scan")
+	flag.Int64Var(&cfg.seed, "seed", 1, "random seed")
+	flag.Parse()
+
+	var err error
+	if cfg.mix, err = parseMix(mix); err != nil {
+		fmt.Fprintln(os.Stderr, "avlbench:", err)
+		os.Exit(2)
+	}
+
+	res, err := run(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "avlbench:", err)
+		os.Exit(1)
+	}
+
+	res.report(os.Stdout)
+}
+
+// Parses the four comma-separated weights of -mix
+
+func parseMix(s string) ([numKinds]int, error) {
+
+	var mix [numKinds]int
+
+	parts := strings.Split(s, ",")
+	if len(parts) != numKinds {
+		return mix, fmt.Errorf("-mix needs %d weights, got %q", numKinds, s)
+	}
+
+	total := 0
+	for i, p := range parts {
+		w, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || w < 0 {
+			return mix, fmt.Errorf("bad -mix weight %q", p)
+		}
+		mix[i] = w
+		total += w
+	}
+	if total == 0 {
+		return mix, fmt.Errorf("-mix weights are all zero")
+	}
+
+	return mix, nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"github.com/danswartzendruber/avl"
+	"io"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Kinds of operation, in -mix order
+
+const (
+	opInsert = iota
+	opLookup
+	opDelete
+	opScan
+	numKinds
+)
+
+var kindNames = [numKinds]string{"insert", "lookup", "delete", "scan"}
+
+type config struct {
+	n          int
+	ops        int
+	mix        [numKinds]int
+	dist       string
+	goroutines int
+	scanLen    int
+	seed       int64
+}
+
+type entry struct {
+	hdr avl.AvlNode
+	key uint64
+}
+
+func cmpEntryNode(node1 interface{}, node2 interface{}) int {
+	return cmpKeys(node1.(*entry).key, node2.(*entry).key)
+}
+
+func cmpEntryKey(key interface{}, node interface{}) int {
+	return cmpKeys(key.(uint64), node.(*entry).key)
+}
+
+func cmpKeys(a, b uint64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	} else {
+		return 0
+	}
+}
+
+// The outcome of a run
+
+type result struct {
+	cfg       config
+	elapsed   time.Duration
+	latencies [numKinds][]time.Duration
+	size      int
+	heapBytes int64
+}
+
+// Returns a function drawing keys from the configured distribution
+
+func keySource(cfg config, r *rand.Rand, next *atomic.Uint64) (func() uint64, error) {
+
+	space := uint64(2 * cfg.n)
+	if space == 0 {
+		space = 1
+	}
+
+	switch cfg.dist {
+	case "uniform":
+		return func() uint64 { return uint64(r.Int63n(int64(space))) }, nil
+	case "zipf":
+		z := rand.NewZipf(r, 1.1, 1, space-1)
+		return z.Uint64, nil
+	case "sequential":
+		return func() uint64 { return next.Add(1) % space }, nil
+	default:
+		return nil, fmt.Errorf("unknown distribution %q", cfg.dist)
+	}
+}
+
+func run(cfg config) (*result, error) {
+
+	if cfg.goroutines < 1 {
+		cfg.goroutines = 1
+	}
+
+	tree := avl.NewAvlTree()
+	var mu sync.RWMutex
+	var seq atomic.Uint64
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	// Preload the even keys, so that about half the lookups and deletes
+	// of uniform keys hit
+
+	for i := 0; i < cfg.n; i++ {
+		e := &entry{key: uint64(2 * i)}
+		tree.Insert(&e.hdr, e, cmpEntryNode)
+	}
+
+	res := &result{cfg: cfg}
+
+	totalWeight := 0
+	for _, w := range cfg.mix {
+		totalWeight += w
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, cfg.goroutines)
+	lat := make([][numKinds][]time.Duration, cfg.goroutines)
+
+	start := time.Now()
+
+	for g := 0; g < cfg.goroutines; g++ {
+		ops := cfg.ops / cfg.goroutines
+		if g < cfg.ops%cfg.goroutines {
+			ops++
+		}
+
+		wg.Add(1)
+		go func(g, ops int) {
+			defer wg.Done()
+
+			r := rand.New(rand.NewSource(cfg.seed + int64(g)))
+			nextKey, err := keySource(cfg, r, &seq)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			for i := 0; i < ops; i++ {
+				kind := pickKind(r, cfg.mix, totalWeight)
+				key := nextKey()
+
+				t0 := time.Now()
+				doOp(tree, &mu, kind, key, cfg.scanLen)
+				lat[g][kind] = append(lat[g][kind], time.Since(t0))
+			}
+		}(g, ops)
+	}
+
+	wg.Wait()
+	res.elapsed = time.Since(start)
+
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+	}
+
+	for _, l := range lat {
+		for k := range l {
+			res.latencies[k] = append(res.latencies[k], l[k]...)
+		}
+	}
+
+	res.size = tree.Len()
+
+	var after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	res.heapBytes = int64(after.HeapAlloc) - int64(before.HeapAlloc)
+
+	// Keep the tree, and so the measured memory, alive until here
+
+	runtime.KeepAlive(tree)
+
+	return res, nil
+}
+
+func pickKind(r *rand.Rand, mix [numKinds]int, total int) int {
+
+	n := r.Intn(total)
+	for k, w := range mix {
+		if n < w {
+			return k
+		}
+		n -= w
+	}
+
+	return numKinds - 1
+}
+
+func doOp(tree *avl.AvlTree, mu *sync.RWMutex, kind int, key uint64, scanLen int) {
+
+	switch kind {
+	case opInsert:
+		e := &entry{key: key}
+		mu.Lock()
+		tree.Insert(&e.hdr, e, cmpEntryNode)
+		mu.Unlock()
+
+	case opLookup:
+		mu.RLock()
+		tree.Lookup(key, cmpEntryKey)
+		mu.RUnlock()
+
+	case opDelete:
+		mu.Lock()
+		if e := tree.Lookup(key, cmpEntryKey); e != nil {
+			tree.Remove(&e.(*entry).hdr)
+		}
+		mu.Unlock()
+
+	case opScan:
+		n := 0
+		mu.RLock()
+		tree.Range(key, nil, cmpEntryKey, func(owner interface{}) bool {
+			n++
+			return n < scanLen
+		})
+		mu.RUnlock()
+	}
+}
+
+// Returns the p'th percentile of sorted durations
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := int(p / 100 * float64(len(sorted)))
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+
+	return sorted[i]
+}
+
+func (res *result) report(w io.Writer) {
+
+	total := 0
+	for _, l := range res.latencies {
+		total += len(l)
+	}
+
+	fmt.Fprintf(w, "elements  %d preloaded, %d at end\n", res.cfg.n, res.size)
+	fmt.Fprintf(w, "workload  %d ops, mix %v, %s keys, %d goroutines\n",
+		total, res.cfg.mix, res.cfg.dist, res.cfg.goroutines)
+	fmt.Fprintf(w, "elapsed   %v\n", res.elapsed.Round(time.Millisecond))
+	if res.elapsed > 0 {
+		fmt.Fprintf(w, "throughput %.0f ops/s\n",
+			float64(total)/res.elapsed.Seconds())
+	}
+
+	fmt.Fprintf(w, "\n%-8s %10s %10s %10s %10s %10s\n",
+		"op", "count", "p50", "p90", "p99", "p99.9")
+
+	for k, l := range res.latencies {
+		if len(l) == 0 {
+			continue
+		}
+		sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+		fmt.Fprintf(w, "%-8s %10d %10v %10v %10v %10v\n", kindNames[k], len(l),
+			percentile(l, 50), percentile(l, 90), percentile(l, 99),
+			percentile(l, 99.9))
+	}
+
+	fmt.Fprintf(w, "\nheap      %d bytes", res.heapBytes)
+	if res.size > 0 {
+		fmt.Fprintf(w, ", %d per element", res.heapBytes/int64(res.size))
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+
+	for _, dist := range []string{"uniform", "zipf", "sequential"} {
+		res, err := run(config{
+			n:          1000,
+			ops:        5000,
+			mix:        [numKinds]int{10, 70, 10, 10},
+			dist:       dist,
+			goroutines: 4,
+			scanLen:    10,
+			seed:       1,
+		})
+		assert.NoError(t, err)
+
+		total := 0
+		for _, l := range res.latencies {
+			total += len(l)
+		}
+		assert.Equal(t, 5000, total)
+		assert.True(t, len(res.latencies[opLookup]) > len(res.latencies[opInsert]))
+
+		var out bytes.Buffer
+		res.report(&out)
+		assert.Contains(t, out.String(), "throughput")
+		assert.Contains(t, out.String(), "lookup")
+	}
+
+	_, err := run(config{n: 10, ops: 10, mix: [numKinds]int{1, 0, 0, 0}, dist: "normal"})
+	assert.Error(t, err)
+}
+
+func TestParseMix(t *testing.T) {
+
+	mix, err := parseMix("1, 2,3,4")
+	assert.NoError(t, err)
+	assert.Equal(t, [numKinds]int{1, 2, 3, 4}, mix)
+
+	for _, bad := range []string{"1,2,3", "1,2,3,x", "0,0,0,0", "1,-1,1,1"} {
+		_, err := parseMix(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+
+	var d []time.Duration
+	for i := 1; i <= 100; i++ {
+		d = append(d, time.Duration(i))
+	}
+
+	assert.Equal(t, time.Duration(51), percentile(d, 50))
+	assert.Equal(t, time.Duration(100), percentile(d, 99.9))
+	assert.Equal(t, time.Duration(0), percentile(nil, 50))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The tree, as a template over config
+
+//go:embed tree.tmpl
+var treeTemplate string
+
+var tmpl = template.Must(template.New("tree").Parse(treeTemplate))
+
+// What to generate
+
+type config struct {
+	Package string
+	Type    string // The tree type
+	Key     string
+	Value   string
+	Cmp     string   // Function comparing keys, or "" for < and >
+	Imports []string // Import paths
+	Augs    []aug    // Augmentation fields, kept up to date in every node
+	Args    string   // The command line, for the header
+
+	// Derived names
+
+	Node      string // The node type
+	Prefix    string // Prefix for the package-level names
+	UsesLeft  bool   // Whether an augmentation reads the left child
+	UsesRight bool
+}
+
+// An augmentation field, declared as "name type = expr".  The expression
+// computes a node's field from the node and its children, referred to as
+// self, left and right; an empty child reads as a node whose fields are
+// all zero, so left.height == 0 means there is no left child
+
+type aug struct {
+	Name   string
+	Type   string
+	Expr   string // With self rewritten to the node
+	Method string // The exported getter
+}
+
+// Node fields and methods an augmentation cannot be named, or have as
+// its getter
+var reservedFields = map[string]bool{
+	"left": true, "right": true, "height": true, "key": true, "value": true,
+	"self": true, "Left": true, "Right": true, "Key": true, "Value": true,
+}
+
+var (
+	selfRef  = regexp.MustCompile(`\bself\b`)
+	leftRef  = regexp.MustCompile(`\bleft\b`)
+	rightRef = regexp.MustCompile(`\bright\b`)
+)
+
+// Parses an augmentation declared as "name type = expr"
+
+func parseAug(decl string) (aug, error) {
+
+	lhs, expr, ok := strings.Cut(decl, "=")
+	fields := strings.Fields(lhs)
+	expr = strings.TrimSpace(expr)
+
+	switch {
+	case !ok || len(fields) < 2 || expr == "":
+		return aug{}, fmt.Errorf("augmentation %q: want \"name type = expr\"", decl)
+	case !token.IsIdentifier(fields[0]) || reservedFields[fields[0]] ||
+		reservedFields[strings.ToUpper(fields[0][:1])+fields[0][1:]]:
+		return aug{}, fmt.Errorf("augmentation %q: bad field name %q", decl, fields[0])
+	}
+	if _, err := parser.ParseExpr(expr); err != nil {
+		return aug{}, fmt.Errorf("augmentation %q: %v", decl, err)
+	}
+
+	r, n := utf8.DecodeRuneInString(fields[0])
+
+	return aug{
+		Name:   fields[0],
+		Type:   strings.Join(fields[1:], " "),
+		Expr:   selfRef.ReplaceAllString(expr, "n"),
+		Method: string(unicode.ToUpper(r)) + fields[0][n:],
+	}, nil
+}
+
+// Returns the formatted source of the tree described by cfg
+
+func generate(cfg config) ([]byte, error) {
+
+	switch {
+	case cfg.Package == "":
+		return nil, errors.New("no package: set -package, or run from go generate")
+	case !token.IsIdentifier(cfg.Type):
+		return nil, errors.New("-type must be an identifier")
+	case cfg.Key == "" || cfg.Value == "":
+		return nil, errors.New("-key and -value are required")
+	}
+
+	r, n := utf8.DecodeRuneInString(cfg.Type)
+	cfg.Node = cfg.Type + "Node"
+	cfg.Prefix = string(unicode.ToLower(r)) + cfg.Type[n:]
+
+	methods := map[string]bool{}
+	for _, a := range cfg.Augs {
+		if methods[a.Method] {
+			return nil, fmt.Errorf("augmentation %s declared twice", a.Name)
+		}
+		methods[a.Method] = true
+		cfg.UsesLeft = cfg.UsesLeft || leftRef.MatchString(a.Expr)
+		cfg.UsesRight = cfg.UsesRight || rightRef.MatchString(a.Expr)
+	}
+
+	if cfg.Args == "" {
+		cfg.Args = strings.Join([]string{"-type", cfg.Type, "-key", cfg.Key,
+			"-value", cfg.Value}, " ")
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, cfg); err != nil {
+		return nil, err
+	}
+
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// The generated trees in this directory must match what the generator
+// makes of the command lines in their headers
+
+func TestGenerateGolden(t *testing.T) {
+
+	for file, cfg := range map[string]config{
+		"inttree_avl_test.go": {Package: "main", Type: "intTree",
+			Key: "int", Value: "string",
+			Args: "-type intTree -key int -value string -package main -o inttree_avl_test.go"},
+		"wordtree_avl_test.go": {Package: "main", Type: "wordTree",
+			Key: "string", Value: "int", Cmp: "strings.Compare",
+			Imports: []string{"strings"},
+			Args:    "-type wordTree -key string -value int -cmp strings.Compare -import strings -package main -o wordtree_avl_test.go"},
+		"sumtree_avl_test.go": {Package: "main", Type: "sumTree",
+			Key: "int", Value: "int64",
+			Augs: []aug{
+				mustParseAug("sum int64 = left.sum + right.sum + self.value"),
+				mustParseAug("count int = left.count + right.count + 1"),
+			},
+			Args: "-type sumTree -key int -value int64 -aug 'sum int64 = left.sum + right.sum + self.value' -aug 'count int = left.count + right.count + 1' -package main -o sumtree_avl_test.go"},
+	} {
+		want, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		got, err := generate(cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, string(want), string(got), file)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+
+	_, err := generate(config{Type: "T", Key: "int", Value: "int"})
+	assert.Error(t, err)
+	_, err = generate(config{Package: "p", Type: "a-b", Key: "int", Value: "int"})
+	assert.Error(t, err)
+	_, err = generate(config{Package: "p", Type: "T", Key: "int"})
+	assert.Error(t, err)
+	_, err = generate(config{Package: "p", Type: "T", Key: "int", Value: "}{"})
+	assert.Error(t, err)
+
+	for _, decl := range []string{
+		"sum int64",
+		"sum = left.sum",
+		"sum int64 =",
+		"left int = 1",
+		"Key int = 1",
+		"1x int = 1",
+		"sum int64 = left.sum +",
+	} {
+		_, err := parseAug(decl)
+		assert.Error(t, err, decl)
+	}
+
+	sum := mustParseAug("sum int = left.sum + right.sum + self.value")
+	_, err = generate(config{Package: "p", Type: "T", Key: "int", Value: "int",
+		Augs: []aug{sum, sum}})
+	assert.Error(t, err)
+}
+
+func mustParseAug(decl string) aug {
+	a, err := parseAug(decl)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func TestParseAug(t *testing.T) {
+
+	a, err := parseAug("maxEnd  time.Time = max(self.value, left.maxEnd)")
+	assert.NoError(t, err)
+	assert.Equal(t, aug{Name: "maxEnd", Type: "time.Time",
+		Expr: "max(n.value, left.maxEnd)", Method: "MaxEnd"}, a)
+}
+
+// Checks every node's augmentations against a recount, after random sets,
+// replacements and deletes
+
+func TestGeneratedAugmentations(t *testing.T) {
+
+	var tree sumTree
+	model := map[int]int64{}
+	rnd := rand.New(rand.NewSource(1215))
+
+	var check func(n *sumTreeNode) (int64, int)
+	check = func(n *sumTreeNode) (int64, int) {
+		if n == nil {
+			return 0, 0
+		}
+		ls, lc := check(n.Left())
+		rs, rc := check(n.Right())
+		assert.Equal(t, ls+rs+n.Value(), n.Sum())
+		assert.Equal(t, lc+rc+1, n.Count())
+		return n.Sum(), n.Count()
+	}
+
+	for i := 0; i < 3000; i++ {
+		k := rnd.Intn(200)
+		if rnd.Intn(3) == 0 {
+			tree.Delete(k)
+			delete(model, k)
+		} else {
+			v := rnd.Int63n(1000)
+			tree.Set(k, v)
+			model[k] = v
+		}
+		if i%100 == 0 {
+			check(tree.Root())
+		}
+	}
+	check(tree.Root())
+
+	var total int64
+	for _, v := range model {
+		total += v
+	}
+	assert.Equal(t, total, tree.Root().Sum())
+	assert.Equal(t, len(model), tree.Root().Count())
+
+	// Find the key at which the running total first reaches half, by
+	// descending on the sums
+
+	half := total / 2
+	var want int
+	var running int64
+	tree.Ascend(func(key int, value int64) bool {
+		running += value
+		want = key
+		return running < half
+	})
+
+	n, need := tree.Root(), half
+	for {
+		if ls := n.Left().Sum(); need <= ls && n.Left() != nil {
+			n = n.Left()
+		} else if need <= ls+n.Value() {
+			break
+		} else {
+			need -= ls + n.Value()
+			n = n.Right()
+		}
+	}
+	assert.Equal(t, want, n.Key())
+
+	var empty sumTree
+	assert.Equal(t, int64(0), empty.Root().Sum())
+}
+
+// Checks tree against model after random sets and deletes
+
+func TestGeneratedTree(t *testing.T) {
+
+	var tree intTree
+	model := map[int]string{}
+	rnd := rand.New(rand.NewSource(15))
+
+	_, _, ok := tree.Min()
+	assert.False(t, ok)
+
+	for i := 0; i < 5000; i++ {
+		k := rnd.Intn(400)
+		if rnd.Intn(3) == 0 {
+			_, present := model[k]
+			assert.Equal(t, present, tree.Delete(k))
+			delete(model, k)
+		} else {
+			_, present := model[k]
+			assert.Equal(t, !present, tree.Set(k, strconv.Itoa(i)))
+			model[k] = strconv.Itoa(i)
+		}
+	}
+	assert.Equal(t, len(model), tree.Len())
+
+	var keys []int
+	for k := range model {
+		keys = append(keys, k)
+		v, ok := tree.Get(k)
+		assert.True(t, ok)
+		assert.Equal(t, model[k], v)
+	}
+	sort.Ints(keys)
+
+	var got []int
+	tree.Ascend(func(key int, value string) bool {
+		got = append(got, key)
+		return true
+	})
+	assert.Equal(t, keys, got)
+
+	got = nil
+	tree.Descend(func(key int, value string) bool {
+		got = append([]int{key}, got...)
+		return true
+	})
+	assert.Equal(t, keys, got)
+
+	got = nil
+	tree.AscendFrom(keys[10]+1, func(key int, value string) bool {
+		got = append(got, key)
+		return len(got) < 3
+	})
+	assert.Equal(t, keys[11:14], got)
+
+	k, _, _ := tree.Min()
+	assert.Equal(t, keys[0], k)
+	k, _, _ = tree.Max()
+	assert.Equal(t, keys[len(keys)-1], k)
+
+	// Heights stay logarithmic
+
+	assert.True(t, tree.root.height <= 12, "height %d", tree.root.height)
+
+	var words wordTree
+	for i, w := range []string{"pear", "apple", "fig"} {
+		words.Set(w, i)
+	}
+	var ws []string
+	words.Ascend(func(key string, value int) bool {
+		ws = append(ws, key)
+		return true
+	})
+	assert.Equal(t, []string{"apple", "fig", "pear"}, ws)
+}
@@ -0,0 +1,355 @@
+// Code generated by avlgen -type intTree -key int -value string -package main -o inttree_avl_test.go; DO NOT EDIT.
+
+package main
+
+// An AVL tree mapping int keys to string values, specialized
+// from the lean trees of github.com/danswartzendruber/avl.  The zero
+// value is an empty tree ready to use.  It is not safe for concurrent
+// use
+
+type intTree struct {
+	root *intTreeNode
+	size int
+}
+
+type intTreeNode struct {
+	left, right *intTreeNode
+	height      int8
+	key         int
+	value       string
+}
+
+// Trees of up to 2^64 nodes are at most 92 levels deep
+
+const intTreeMaxHeight = 96
+
+func intTreeCompare(a, b int) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func (n *intTreeNode) getHeight() int8 {
+	if n == nil {
+		return 0
+	}
+	return n.height
+}
+
+func (n *intTreeNode) child(sign int) *intTreeNode {
+	if sign < 0 {
+		return n.left
+	}
+	return n.right
+}
+
+func (n *intTreeNode) setChild(sign int, child *intTreeNode) {
+	if sign < 0 {
+		n.left = child
+	} else {
+		n.right = child
+	}
+}
+
+// Recomputes the height of n from its children
+
+func (n *intTreeNode) update() {
+	l, r := n.left.getHeight(), n.right.getHeight()
+	if l > r {
+		n.height = l + 1
+	} else {
+		n.height = r + 1
+	}
+}
+
+// Rotates the subtree rooted at a, lifting its child on the sign side,
+// and returns the new subtree root
+
+func (a *intTreeNode) rotate(sign int) *intTreeNode {
+
+	b := a.child(sign)
+	a.setChild(sign, b.child(-sign))
+	b.setChild(-sign, a)
+
+	a.update()
+	b.update()
+
+	return b
+}
+
+// Rebalances the subtree rooted at n, whose children are balanced and
+// differ in height by at most two, and returns its new root
+
+func (n *intTreeNode) balance() *intTreeNode {
+
+	bf := int(n.right.getHeight()) - int(n.left.getHeight())
+
+	if bf >= -1 && bf <= 1 {
+		n.update()
+		return n
+	}
+
+	sign := 1
+	if bf < 0 {
+		sign = -1
+	}
+
+	c := n.child(sign)
+	if c.child(-sign).getHeight() > c.child(sign).getHeight() {
+		n.setChild(sign, c.rotate(-sign))
+	}
+
+	return n.rotate(sign)
+}
+
+// Points path[i]'s parent, or the root, at new instead of old
+
+func (t *intTree) relink(path []*intTreeNode, i int, old, new *intTreeNode) {
+
+	if i == 0 {
+		t.root = new
+	} else if path[i-1].left == old {
+		path[i-1].left = new
+	} else {
+		path[i-1].right = new
+	}
+}
+
+// Rebalances the nodes on path, from the bottom up, stopping once a
+// subtree's height is unchanged
+
+func (t *intTree) retrace(path []*intTreeNode) {
+
+	for i := len(path) - 1; i >= 0; i-- {
+		n := path[i]
+		height := n.height
+
+		sub := n.balance()
+		if sub != n {
+			t.relink(path, i, n, sub)
+		}
+		if sub.height == height {
+			break
+		}
+	}
+}
+
+// Returns the number of keys
+
+func (t *intTree) Len() int {
+	return t.size
+}
+
+// Returns the value for key, and whether it was present
+
+func (t *intTree) Get(key int) (string, bool) {
+
+	for cur := t.root; cur != nil; {
+		res := intTreeCompare(key, cur.key)
+		if res < 0 {
+			cur = cur.left
+		} else if res > 0 {
+			cur = cur.right
+		} else {
+			return cur.value, true
+		}
+	}
+
+	var zero string
+	return zero, false
+}
+
+// Sets the value for key.  Returns true if key was added, and false if
+// it was present and its value replaced
+
+func (t *intTree) Set(key int, value string) bool {
+
+	var buf [intTreeMaxHeight]*intTreeNode
+	path := buf[:0]
+	sign := 0
+
+	for cur := t.root; cur != nil; cur = cur.child(sign) {
+		res := intTreeCompare(key, cur.key)
+		if res == 0 {
+			cur.value = value
+			return false
+		}
+		path = append(path, cur)
+		if res < 0 {
+			sign = -1
+		} else {
+			sign = 1
+		}
+	}
+
+	n := &intTreeNode{key: key, value: value, height: 1}
+
+	if len(path) == 0 {
+		t.root = n
+	} else {
+		path[len(path)-1].setChild(sign, n)
+		t.retrace(path)
+	}
+	t.size++
+
+	return true
+}
+
+// Removes key.  Returns true if it was present
+
+func (t *intTree) Delete(key int) bool {
+
+	var buf [intTreeMaxHeight]*intTreeNode
+	path := buf[:0]
+
+	n := t.root
+	for n != nil {
+		res := intTreeCompare(key, n.key)
+		if res == 0 {
+			break
+		}
+		path = append(path, n)
+		if res < 0 {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+
+	if n == nil {
+		return false
+	}
+
+	if n.left != nil && n.right != nil {
+
+		// Unlink the successor and put it in the node's place
+
+		at := len(path)
+		path = append(path, n)
+
+		succ := n.right
+		for succ.left != nil {
+			path = append(path, succ)
+			succ = succ.left
+		}
+
+		if parent := path[len(path)-1]; parent == n {
+			n.right = succ.right
+		} else {
+			parent.left = succ.right
+		}
+
+		succ.left, succ.right, succ.height = n.left, n.right, n.height
+		t.relink(path, at, n, succ)
+		path[at] = succ
+	} else {
+		child := n.left
+		if child == nil {
+			child = n.right
+		}
+		t.relink(path, len(path), n, child)
+	}
+
+	t.retrace(path)
+	t.size--
+
+	return true
+}
+
+// Returns the least key and its value, or false if the tree is empty
+
+func (t *intTree) Min() (int, string, bool) {
+	return t.extreme(-1)
+}
+
+// Returns the greatest key and its value, or false if the tree is empty
+
+func (t *intTree) Max() (int, string, bool) {
+	return t.extreme(1)
+}
+
+func (t *intTree) extreme(sign int) (int, string, bool) {
+
+	n := t.root
+	if n == nil {
+		var key int
+		var value string
+		return key, value, false
+	}
+	for c := n.child(sign); c != nil; c = n.child(sign) {
+		n = c
+	}
+
+	return n.key, n.value, true
+}
+
+// Calls fn with each key and value in increasing key order, until fn
+// returns false.  The tree must not be modified meanwhile
+
+func (t *intTree) Ascend(fn func(key int, value string) bool) {
+	t.walk(t.root, 1, fn)
+}
+
+// Calls fn with each key and value in decreasing key order, until fn
+// returns false.  The tree must not be modified meanwhile
+
+func (t *intTree) Descend(fn func(key int, value string) bool) {
+	t.walk(t.root, -1, fn)
+}
+
+// Calls fn with each key at or after from, and its value, in increasing
+// key order, until fn returns false.  The tree must not be modified
+// meanwhile
+
+func (t *intTree) AscendFrom(from int, fn func(key int, value string) bool) {
+
+	var buf [intTreeMaxHeight]*intTreeNode
+	stack := buf[:0]
+
+	for cur := t.root; cur != nil; {
+		if intTreeCompare(from, cur.key) <= 0 {
+			stack = append(stack, cur)
+			cur = cur.left
+		} else {
+			cur = cur.right
+		}
+	}
+
+	t.drain(stack, 1, fn)
+}
+
+// Walks the subtree rooted at n in the sign direction
+
+func (t *intTree) walk(n *intTreeNode, sign int, fn func(key int, value string) bool) {
+
+	var buf [intTreeMaxHeight]*intTreeNode
+	stack := buf[:0]
+
+	for ; n != nil; n = n.child(-sign) {
+		stack = append(stack, n)
+	}
+
+	t.drain(stack, sign, fn)
+}
+
+// Visits the nodes on the stack, each followed by its subtree on the
+// sign side
+
+func (t *intTree) drain(stack []*intTreeNode, sign int, fn func(key int, value string) bool) {
+
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if !fn(n.key, n.value) {
+			return
+		}
+
+		for c := n.child(sign); c != nil; c = c.child(-sign) {
+			stack = append(stack, c)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+//
+// Copyright as per Creative Commons Legal Code license, which can
+// be found in the file COPYING
+//
+
+/*
+
+Command avlgen writes the source of an AVL tree specialized to one key
+type and one value type, for programs that want every comparison to be a
+direct call with no interface boxing, or that must build with a Go too
+old for generics.  It is meant to be run by go generate:
+
+	//go:generate avlgen -type ScoreTree -key int64 -value string
+
+writes scoretree_avl.go, in the package of the file with the directive,
+declaring ScoreTree with Len, Get, Set, Delete, Min, Max, Ascend,
+AscendFrom and Descend.  Keys are compared with < and > unless -cmp
+names a function func(a, b Key) int to use instead; -import adds the
+imports that the key or value types, or the function, need:
+
+	//go:generate avlgen -type SpanTree -key time.Time -value *Span -cmp time.Time.Compare -import time
+
+The generated tree is the lean tree of the avl package (no parent
+pointers, heights in the nodes, iteration on a stack) with the owner
+replaced by a key and a value.
+
+Each -aug flag adds an augmentation: a field of every node holding a
+summary of its subtree, declared as "name type = expr".  The expression
+reads the node as self and its children as left and right, where an
+empty child reads as a node whose fields are all zero:
+
+	//go:generate avlgen -type WeightTree -key string -value float64 -aug "sum float64 = left.sum + right.sum + self.value"
+
+The update is written out in the node's update method, which the
+rotations and the retracing after Set and Delete call bottom up, so
+keeping the sums right costs no indirect calls.  The tree gets a Root
+method, and the nodes Left, Right, Key and Value methods and a getter
+per augmentation (Sum, above), for queries that descend the tree by the
+sums, such as finding the key at which a running total reaches some
+weight.  A tree with augmentations retraces to the root after every
+change, and after a Set that replaces a value, since an augmentation may
+change where the height does not.
+
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+
+	var cfg config
+	var imports string
+	var augs augFlag
+
+	flag.StringVar(&cfg.Type, "type", "", "name of the tree type to generate")
+	flag.StringVar(&cfg.Key, "key", "", "key type")
+	flag.StringVar(&cfg.Value, "value", "", "value type")
+	flag.StringVar(&cfg.Cmp, "cmp", "", "function func(a, b key) int ordering the keys (default < and >)")
+	flag.StringVar(&cfg.Package, "package", os.Getenv("GOPACKAGE"), "package of the generated file")
+	flag.StringVar(&imports, "import", "", "comma-separated import paths the generated file needs")
+	flag.Var(&augs, "aug", "augmentation \"name type = expr\" (repeatable)")
+	out := flag.String("o", "", "output file (default <type>_avl.go, lower case)")
+	flag.Parse()
+
+	if imports != "" {
+		cfg.Imports = strings.Split(imports, ",")
+	}
+	cfg.Augs = augs
+	cfg.Args = shellJoin(os.Args[1:])
+
+	src, err := generate(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "avlgen:", err)
+		os.Exit(2)
+	}
+
+	if *out == "" {
+		*out = strings.ToLower(cfg.Type) + "_avl.go"
+	}
+	if err := os.WriteFile(*out, src, 0666); err != nil {
+		fmt.Fprintln(os.Stderr, "avlgen:", err)
+		os.Exit(1)
+	}
+}
+
+// The -aug flag, which accumulates
+
+type augFlag []aug
+
+func (f *augFlag) String() string {
+	return fmt.Sprint(len(*f), " augmentations")
+}
+
+func (f *augFlag) Set(decl string) error {
+	a, err := parseAug(decl)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, a)
+	return nil
+}
+
+// Joins args into a command line, quoting those a shell would split
+
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \t\"'$*?[]{}()<>|&;") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,423 @@
+// Code generated by avlgen -type sumTree -key int -value int64 -aug 'sum int64 = left.sum + right.sum + self.value' -aug 'count int = left.count + right.count + 1' -package main -o sumtree_avl_test.go; DO NOT EDIT.
+
+package main
+
+// An AVL tree mapping int keys to int64 values, specialized
+// from the lean trees of github.com/danswartzendruber/avl.  The zero
+// value is an empty tree ready to use.  It is not safe for concurrent
+// use
+
+type sumTree struct {
+	root *sumTreeNode
+	size int
+}
+
+type sumTreeNode struct {
+	left, right *sumTreeNode
+	height      int8
+	key         int
+	value       int64
+	sum         int64
+	count       int
+}
+
+// Read in place of an empty subtree by the augmentation expressions
+
+var sumTreeEmpty sumTreeNode
+
+// Trees of up to 2^64 nodes are at most 92 levels deep
+
+const sumTreeMaxHeight = 96
+
+func sumTreeCompare(a, b int) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+func (n *sumTreeNode) getHeight() int8 {
+	if n == nil {
+		return 0
+	}
+	return n.height
+}
+
+func (n *sumTreeNode) child(sign int) *sumTreeNode {
+	if sign < 0 {
+		return n.left
+	}
+	return n.right
+}
+
+func (n *sumTreeNode) setChild(sign int, child *sumTreeNode) {
+	if sign < 0 {
+		n.left = child
+	} else {
+		n.right = child
+	}
+}
+
+// Recomputes the height and the augmentations of n from its children
+
+func (n *sumTreeNode) update() {
+	l, r := n.left.getHeight(), n.right.getHeight()
+	if l > r {
+		n.height = l + 1
+	} else {
+		n.height = r + 1
+	}
+
+	left := n.left
+	if left == nil {
+		left = &sumTreeEmpty
+	}
+
+	right := n.right
+	if right == nil {
+		right = &sumTreeEmpty
+	}
+
+	n.sum = left.sum + right.sum + n.value
+	n.count = left.count + right.count + 1
+}
+
+// Rotates the subtree rooted at a, lifting its child on the sign side,
+// and returns the new subtree root
+
+func (a *sumTreeNode) rotate(sign int) *sumTreeNode {
+
+	b := a.child(sign)
+	a.setChild(sign, b.child(-sign))
+	b.setChild(-sign, a)
+
+	a.update()
+	b.update()
+
+	return b
+}
+
+// Rebalances the subtree rooted at n, whose children are balanced and
+// differ in height by at most two, and returns its new root
+
+func (n *sumTreeNode) balance() *sumTreeNode {
+
+	bf := int(n.right.getHeight()) - int(n.left.getHeight())
+
+	if bf >= -1 && bf <= 1 {
+		n.update()
+		return n
+	}
+
+	sign := 1
+	if bf < 0 {
+		sign = -1
+	}
+
+	c := n.child(sign)
+	if c.child(-sign).getHeight() > c.child(sign).getHeight() {
+		n.setChild(sign, c.rotate(-sign))
+	}
+
+	return n.rotate(sign)
+}
+
+// Points path[i]'s parent, or the root, at new instead of old
+
+func (t *sumTree) relink(path []*sumTreeNode, i int, old, new *sumTreeNode) {
+
+	if i == 0 {
+		t.root = new
+	} else if path[i-1].left == old {
+		path[i-1].left = new
+	} else {
+		path[i-1].right = new
+	}
+}
+
+// Rebalances the nodes on path, and updates their augmentations, from
+// the bottom up
+
+func (t *sumTree) retrace(path []*sumTreeNode) {
+
+	for i := len(path) - 1; i >= 0; i-- {
+		n := path[i]
+
+		sub := n.balance()
+		if sub != n {
+			t.relink(path, i, n, sub)
+		}
+	}
+}
+
+// Returns the number of keys
+
+func (t *sumTree) Len() int {
+	return t.size
+}
+
+// Returns the value for key, and whether it was present
+
+func (t *sumTree) Get(key int) (int64, bool) {
+
+	for cur := t.root; cur != nil; {
+		res := sumTreeCompare(key, cur.key)
+		if res < 0 {
+			cur = cur.left
+		} else if res > 0 {
+			cur = cur.right
+		} else {
+			return cur.value, true
+		}
+	}
+
+	var zero int64
+	return zero, false
+}
+
+// Sets the value for key.  Returns true if key was added, and false if
+// it was present and its value replaced
+
+func (t *sumTree) Set(key int, value int64) bool {
+
+	var buf [sumTreeMaxHeight]*sumTreeNode
+	path := buf[:0]
+	sign := 0
+
+	for cur := t.root; cur != nil; cur = cur.child(sign) {
+		res := sumTreeCompare(key, cur.key)
+		if res == 0 {
+			cur.value = value
+			t.retrace(append(path, cur))
+			return false
+		}
+		path = append(path, cur)
+		if res < 0 {
+			sign = -1
+		} else {
+			sign = 1
+		}
+	}
+
+	n := &sumTreeNode{key: key, value: value, height: 1}
+	n.update()
+
+	if len(path) == 0 {
+		t.root = n
+	} else {
+		path[len(path)-1].setChild(sign, n)
+		t.retrace(path)
+	}
+	t.size++
+
+	return true
+}
+
+// Removes key.  Returns true if it was present
+
+func (t *sumTree) Delete(key int) bool {
+
+	var buf [sumTreeMaxHeight]*sumTreeNode
+	path := buf[:0]
+
+	n := t.root
+	for n != nil {
+		res := sumTreeCompare(key, n.key)
+		if res == 0 {
+			break
+		}
+		path = append(path, n)
+		if res < 0 {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+
+	if n == nil {
+		return false
+	}
+
+	if n.left != nil && n.right != nil {
+
+		// Unlink the successor and put it in the node's place
+
+		at := len(path)
+		path = append(path, n)
+
+		succ := n.right
+		for succ.left != nil {
+			path = append(path, succ)
+			succ = succ.left
+		}
+
+		if parent := path[len(path)-1]; parent == n {
+			n.right = succ.right
+		} else {
+			parent.left = succ.right
+		}
+
+		succ.left, succ.right, succ.height = n.left, n.right, n.height
+		t.relink(path, at, n, succ)
+		path[at] = succ
+	} else {
+		child := n.left
+		if child == nil {
+			child = n.right
+		}
+		t.relink(path, len(path), n, child)
+	}
+
+	t.retrace(path)
+	t.size--
+
+	return true
+}
+
+// Returns the least key and its value, or false if the tree is empty
+
+func (t *sumTree) Min() (int, int64, bool) {
+	return t.extreme(-1)
+}
+
+// Returns the greatest key and its value, or false if the tree is empty
+
+func (t *sumTree) Max() (int, int64, bool) {
+	return t.extreme(1)
+}
+
+func (t *sumTree) extreme(sign int) (int, int64, bool) {
+
+	n := t.root
+	if n == nil {
+		var key int
+		var value int64
+		return key, value, false
+	}
+	for c := n.child(sign); c != nil; c = n.child(sign) {
+		n = c
+	}
+
+	return n.key, n.value, true
+}
+
+// Calls fn with each key and value in increasing key order, until fn
+// returns false.  The tree must not be modified meanwhile
+
+func (t *sumTree) Ascend(fn func(key int, value int64) bool) {
+	t.walk(t.root, 1, fn)
+}
+
+// Calls fn with each key and value in decreasing key order, until fn
+// returns false.  The tree must not be modified meanwhile
+
+func (t *sumTree) Descend(fn func(key int, value int64) bool) {
+	t.walk(t.root, -1, fn)
+}
+
+// Calls fn with each key at or after from, and its value, in increasing
+// key order, until fn returns false.  The tree must not be modified
+// meanwhile
+
+func (t *sumTree) AscendFrom(from int, fn func(key int, value int64) bool) {
+
+	var buf [sumTreeMaxHeight]*sumTreeNode
+	stack := buf[:0]
+
+	for cur := t.root; cur != nil; {
+		if sumTreeCompare(from, cur.key) <= 0 {
+			stack = append(stack, cur)
+			cur = cur.left
+		} else {
+			cur = cur.right
+		}
+	}
+
+	t.drain(stack, 1, fn)
+}
+
+// Walks the subtree rooted at n in the sign direction
+
+func (t *sumTree) walk(n *sumTreeNode, sign int, fn func(key int, value int64) bool) {
+
+	var buf [sumTreeMaxHeight]*sumTreeNode
+	stack := buf[:0]
+
+	for ; n != nil; n = n.child(-sign) {
+		stack = append(stack, n)
+	}
+
+	t.drain(stack, sign, fn)
+}
+
+// Visits the nodes on the stack, each followed by its subtree on the
+// sign side
+
+func (t *sumTree) drain(stack []*sumTreeNode, sign int, fn func(key int, value int64) bool) {
+
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if !fn(n.key, n.value) {
+			return
+		}
+
+		for c := n.child(sign); c != nil; c = c.child(-sign) {
+			stack = append(stack, c)
+		}
+	}
+}
+
+// Returns the root of the tree, or nil if it is empty, for queries that
+// descend using the augmentations
+
+func (t *sumTree) Root() *sumTreeNode {
+	return t.root
+}
+
+// Returns the node's left child, or nil
+
+func (n *sumTreeNode) Left() *sumTreeNode {
+	return n.left
+}
+
+// Returns the node's right child, or nil
+
+func (n *sumTreeNode) Right() *sumTreeNode {
+	return n.right
+}
+
+// Returns the node's key
+
+func (n *sumTreeNode) Key() int {
+	return n.key
+}
+
+// Returns the node's value
+
+func (n *sumTreeNode) Value() int64 {
+	return n.value
+}
+
+// Returns the sum of the subtree rooted at n, which is the zero
+// value for an empty subtree
+
+func (n *sumTreeNode) Sum() int64 {
+	if n == nil {
+		return sumTreeEmpty.sum
+	}
+	return n.sum
+}
+
+// Returns the count of the subtree rooted at n, which is the zero
+// value for an empty subtree
+
+func (n *sumTreeNode) Count() int {
+	if n == nil {
+		return sumTreeEmpty.count
+	}
+	return n.count
+}
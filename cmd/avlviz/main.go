@@ -0,0 +1,62 @@
+//
+// Copyright as per Creative Commons Legal Code license, which can
+// be found in the file COPYING
+//
+
+/*
+
+Command avlviz renders a serialized tree (see avl.AvlTreeDump), in either
+the JSON or the binary format, as ASCII art, Graphviz DOT or SVG:
+
+	avlviz [-format ascii|dot|svg] [-path key] [file]
+
+It reads standard input if no file is given.  With -path, the nodes
+visited by a search for key, given as a JSON value, are highlighted; keys
+are compared with avl.AvlCompareValues, so numbers order as numbers and
+strings as strings.
+
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+
+	format := flag.String("format", "ascii", "output `format`: ascii, dot or svg")
+	path := flag.String("path", "", "highlight the search path of `key`, a JSON value")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(),
+			"usage: avlviz [-format ascii|dot|svg] [-path key] [file]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var in io.Reader = os.Stdin
+
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "avlviz:", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		in = f
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(os.Stdout, in, *format, *path); err != nil {
+		fmt.Fprintln(os.Stderr, "avlviz:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/danswartzendruber/avl"
+	"html"
+	"io"
+	"strconv"
+)
+
+// Reads a dump from r and renders it to w
+
+func run(w io.Writer, r io.Reader, format, pathKey string) error {
+
+	d, err := avl.AvlDumpRead(r)
+	if err != nil {
+		return err
+	}
+
+	var path map[*avl.AvlDump]bool
+	if pathKey != "" {
+		if path, err = searchPath(d, pathKey); err != nil {
+			return err
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+
+	switch format {
+	case "ascii":
+		renderASCII(bw, d, path)
+	case "dot":
+		renderDOT(bw, d, path)
+	case "svg":
+		renderSVG(bw, d, path)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+
+	return bw.Flush()
+}
+
+// Returns the nodes a search for key visits
+
+func searchPath(d *avl.AvlDump, key string) (path map[*avl.AvlDump]bool, err error) {
+
+	var k interface{}
+	if err := json.Unmarshal([]byte(key), &k); err != nil {
+		return nil, fmt.Errorf("bad -path key: %v", err)
+	}
+
+	// AvlCompareValues panics on keys of different types
+
+	defer func() {
+		if r := recover(); r != nil {
+			path, err = nil, fmt.Errorf("bad -path key: %v", r)
+		}
+	}()
+
+	path = map[*avl.AvlDump]bool{}
+
+	for d != nil {
+		path[d] = true
+
+		var nk interface{}
+		if err := json.Unmarshal(d.Key, &nk); err != nil {
+			return nil, err
+		}
+
+		res := avl.AvlCompareValues(k, nk)
+		if res < 0 {
+			d = d.Left
+		} else if res > 0 {
+			d = d.Right
+		} else {
+			break
+		}
+	}
+
+	return path, nil
+}
+
+// Sideways, root on the left and the greatest key at the top.  Nodes on
+// the search path are bracketed
+
+func renderASCII(w *bufio.Writer, d *avl.AvlDump, path map[*avl.AvlDump]bool) {
+
+	if d == nil {
+		fmt.Fprintln(w, "(empty)")
+		return
+	}
+
+	var walk func(d *avl.AvlDump, prefix, edge, above, below string)
+
+	walk = func(d *avl.AvlDump, prefix, edge, above, below string) {
+		if d.Right != nil {
+			walk(d.Right, prefix+above, "┌── ", "    ", "│   ")
+		}
+
+		label := string(d.Key)
+		if path[d] {
+			label = "[" + label + "]"
+		}
+		fmt.Fprintln(w, prefix+edge+label)
+
+		if d.Left != nil {
+			walk(d.Left, prefix+below, "└── ", "│   ", "    ")
+		}
+	}
+
+	walk(d, "", "", "", "")
+}
+
+func renderDOT(w *bufio.Writer, d *avl.AvlDump, path map[*avl.AvlDump]bool) {
+
+	fmt.Fprintln(w, "digraph avl {")
+	fmt.Fprintln(w, "\tnode [shape=circle];")
+
+	id := 0
+
+	var walk func(d *avl.AvlDump) string
+
+	walk = func(d *avl.AvlDump) string {
+		name := fmt.Sprintf("n%d", id)
+		id++
+
+		if d == nil {
+			fmt.Fprintf(w, "\t%s [shape=point, style=invis];\n", name)
+			return name
+		}
+
+		attrs := ""
+		if path[d] {
+			attrs = ", style=filled, fillcolor=lightblue"
+		}
+		fmt.Fprintf(w, "\t%s [label=%s%s];\n", name,
+			strconv.Quote(string(d.Key)), attrs)
+
+		// A lone child gets an invisible sibling, so that left and
+		// right children are drawn on their own sides
+
+		if d.Left != nil || d.Right != nil {
+			for _, c := range []*avl.AvlDump{d.Left, d.Right} {
+				cname := walk(c)
+				switch {
+				case c == nil:
+					fmt.Fprintf(w, "\t%s -> %s [style=invis];\n", name, cname)
+				case path[c]:
+					fmt.Fprintf(w, "\t%s -> %s [color=blue, penwidth=2];\n", name, cname)
+				default:
+					fmt.Fprintf(w, "\t%s -> %s;\n", name, cname)
+				}
+			}
+		}
+
+		return name
+	}
+
+	if d != nil {
+		walk(d)
+	}
+
+	fmt.Fprintln(w, "}")
+}
+
+// Nodes are laid out with x from their in-order position and y from
+// their depth
+
+func renderSVG(w *bufio.Writer, d *avl.AvlDump, path map[*avl.AvlDump]bool) {
+
+	const (
+		dx     = 40
+		dy     = 50
+		radius = 16
+	)
+
+	type pos struct{ x, y int }
+
+	at := map[*avl.AvlDump]pos{}
+	var order []*avl.AvlDump
+	width, height := 0, 0
+
+	var place func(d *avl.AvlDump, depth int)
+
+	place = func(d *avl.AvlDump, depth int) {
+		if d == nil {
+			return
+		}
+		place(d.Left, depth+1)
+		at[d] = pos{width*dx + dx/2, depth*dy + dy/2}
+		order = append(order, d)
+		width++
+		if depth+1 > height {
+			height = depth + 1
+		}
+		place(d.Right, depth+1)
+	}
+	place(d, 0)
+
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="monospace" font-size="10">`+"\n",
+		width*dx, height*dy)
+
+	for _, n := range order {
+		p := at[n]
+		for _, c := range []*avl.AvlDump{n.Left, n.Right} {
+			if c == nil {
+				continue
+			}
+			color := "black"
+			if path[c] {
+				color = "blue"
+			}
+			fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n",
+				p.x, p.y, at[c].x, at[c].y, color)
+		}
+	}
+
+	for _, n := range order {
+		p := at[n]
+		fill := "white"
+		if path[n] {
+			fill = "lightblue"
+		}
+		fmt.Fprintf(w, `<circle cx="%d" cy="%d" r="%d" fill="%s" stroke="black"/>`+"\n",
+			p.x, p.y, radius, fill)
+		fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="middle" dominant-baseline="central">%s</text>`+"\n",
+			p.x, p.y, html.EscapeString(string(n.Key)))
+	}
+
+	fmt.Fprintln(w, "</svg>")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"github.com/danswartzendruber/avl"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func dumpOf(t *testing.T, keys ...interface{}) *bytes.Buffer {
+
+	d, err := avl.AvlTreeDump(avl.Build().Keys(keys...).Tree().Root(),
+		avl.AvlItemEncodeKey)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, avl.AvlDumpWriteBinary(&buf, d))
+
+	return &buf
+}
+
+func TestRenderASCII(t *testing.T) {
+
+	var out bytes.Buffer
+	assert.NoError(t, run(&out, dumpOf(t, 1, 2, 3, 4, 5), "ascii", "1"))
+
+	assert.Equal(t, strings.Join([]string{
+		"┌── 5",
+		"│   └── 4",
+		"[3]",
+		"└── [2]",
+		"    └── [1]",
+		"",
+	}, "\n"), out.String())
+}
+
+func TestRenderDOTAndSVG(t *testing.T) {
+
+	var out bytes.Buffer
+	assert.NoError(t, run(&out, dumpOf(t, 1, 2), "dot", "2"))
+	assert.True(t, strings.HasPrefix(out.String(), "digraph avl {"))
+	assert.Contains(t, out.String(), `label="2", style=filled`)
+	assert.Contains(t, out.String(), "[style=invis]")
+
+	out.Reset()
+	assert.NoError(t, run(&out, dumpOf(t, "a", "it's"), "svg", ""))
+	assert.Contains(t, out.String(), "&#34;it&#39;s&#34;")
+	assert.Equal(t, 2, strings.Count(out.String(), "<circle"))
+}
+
+func TestRunErrors(t *testing.T) {
+
+	var out bytes.Buffer
+	assert.Error(t, run(&out, dumpOf(t, 1), "png", ""))
+	assert.Error(t, run(&out, dumpOf(t, 1), "ascii", `"one"`))
+	assert.Error(t, run(&out, dumpOf(t, 1), "ascii", "{"))
+	assert.ErrorIs(t, run(&out, strings.NewReader("junk"), "ascii", ""), avl.ErrBadDump)
+}
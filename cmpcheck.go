@@ -0,0 +1,139 @@
+package avl
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+//
+// Comparator spot-checks.  A comparison function that is not a total
+// order (one that ignores the order of its arguments, say, or compares
+// floats with NaNs among them) does not fail; it leaves owners in places
+// where a lookup will not find them, and the bug shows up much later as
+// an owner that has disappeared.  A full check after every mutation, as
+// WithSelfCheck makes, is O(n); a tree made WithCmpCheck instead samples
+// a few triples of owners every so many mutations and checks, for each,
+// that the comparator orders an owner equal to itself, agrees with itself
+// when the arguments are swapped, orders the three as the tree does (so
+// that, in particular, it is transitive on them), and that a lookup of
+// each finds it.  The sampling is seeded, so a failing run fails the same
+// way when repeated.
+//
+
+// Triples sampled per check
+
+const avlCmpCheckSamples = 4
+
+type avlCmpCheck struct {
+	cmp   CmpFuncNode
+	every int
+	count int
+	rnd   *rand.Rand
+}
+
+// Debugging option: every every insertions and removals, spot-check cmp
+// against the tree, and panic at the first inconsistency found (or tell
+// the error sink; see WithErrorSink) with an error wrapping
+// ErrInconsistentCmp.  Each check costs O(log n) comparisons
+
+func WithCmpCheck(cmp CmpFuncNode, every int) AvlTreeOption {
+	return func(tree *AvlTree) {
+		if every < 1 {
+			every = 1
+		}
+		tree.cmpCheck = &avlCmpCheck{cmp: cmp, every: every,
+			rnd: rand.New(rand.NewSource(1))}
+	}
+}
+
+// Counts a mutation, spot-checking the comparator if it is time to
+
+func (c *avlCmpCheck) mutated(tree *AvlTree) {
+	c.count++
+	if c.count%c.every != 0 {
+		return
+	}
+	err := AvlTreeCheckCmp(tree.root, c.cmp, c.rnd, avlCmpCheckSamples)
+	if err != nil {
+		tree.fail(err)
+	}
+}
+
+// Spot-checks cmp against the tree rooted at root, on samples triples of
+// owners drawn using r.  Returns nil, or an error wrapping
+// ErrInconsistentCmp that names the owners cmp got wrong.  Owners that
+// compare equal are allowed to be adjacent, as in a tree with duplicates
+
+func AvlTreeCheckCmp(root *AvlNode, cmp CmpFuncNode, r *rand.Rand,
+	samples int) error {
+
+	size := avlGetSize(root)
+	if size == 0 {
+		return nil
+	}
+
+	for s := 0; s < samples; s++ {
+
+		idx := []int{r.Intn(size), r.Intn(size), r.Intn(size)}
+		sort.Ints(idx)
+		var o [3]interface{}
+		for i := range o {
+			o[i] = avlTreeSelect(root, idx[i]).owner
+		}
+
+		for i := range o {
+			if err := avlCheckCmpOwner(root, cmp, o[i]); err != nil {
+				return err
+			}
+			for j := i + 1; j < len(o); j++ {
+				if err := avlCheckCmpPair(cmp, o[i], o[j], idx[i], idx[j]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Checks that cmp orders owner equal to itself, and that a lookup of it
+// finds it or an owner equal to it
+
+func avlCheckCmpOwner(root *AvlNode, cmp CmpFuncNode, owner interface{}) error {
+
+	if c := cmp(owner, owner); c != 0 {
+		return fmt.Errorf("%w: %v compares %d with itself",
+			ErrInconsistentCmp, owner, c)
+	}
+
+	found := AvlTreeLookup(root, owner, CmpFuncKey(cmp))
+	if found == nil || cmp(owner, found) != 0 {
+		return fmt.Errorf("%w: a lookup of %v, which is in the tree, does "+
+			"not find it", ErrInconsistentCmp, owner)
+	}
+
+	return nil
+}
+
+// Checks that cmp agrees with itself on a and b either way round, and
+// with the tree, which holds a at index i and b at index j >= i
+
+func avlCheckCmpPair(cmp CmpFuncNode, a, b interface{}, i, j int) error {
+
+	ab, ba := avlSign(cmp(a, b)), avlSign(cmp(b, a))
+
+	switch {
+	case ab != -ba:
+		return fmt.Errorf("%w: comparing %v with %v gives %d, and the other "+
+			"way round %d", ErrInconsistentCmp, a, b, ab, ba)
+	case i == j && ab != 0:
+		return fmt.Errorf("%w: %v compares %d with itself",
+			ErrInconsistentCmp, a, ab)
+	case ab > 0:
+		return fmt.Errorf("%w: %v at index %d compares greater than %v at "+
+			"index %d", ErrInconsistentCmp, a, i, b, j)
+	}
+
+	return nil
+}
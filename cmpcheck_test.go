@@ -0,0 +1,73 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestAvlTreeCmpCheck(t *testing.T) {
+
+	var errs []error
+	sink := WithErrorSink(func(err error) { errs = append(errs, err) })
+
+	tree := NewAvlTree(WithCmpCheck(cmpIntNode, 1), sink)
+	for _, k := range rand.New(rand.NewSource(1218)).Perm(300) {
+		n := &intNode{key: k}
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+	for i := 0; i < 100; i++ {
+		tree.PopMin()
+	}
+	assert.Empty(t, errs)
+
+	// Subtraction that overflows, so that keys far apart compare the
+	// wrong way round
+
+	wraps := func(node1 interface{}, node2 interface{}) int {
+		return int(int32(node1.(*intNode).key - node2.(*intNode).key))
+	}
+	r := rand.New(rand.NewSource(1))
+	tree = NewAvlTree(WithCmpCheck(wraps, 10), sink)
+	for i := 0; i < 300; i++ {
+		n := &intNode{key: int(int32(r.Uint32()))}
+		tree.Insert(&n.avlHeader, n, wraps)
+	}
+	assert.True(t, len(errs) > 0)
+	for _, err := range errs {
+		assert.ErrorIs(t, err, ErrInconsistentCmp)
+	}
+
+	// Without a sink the first inconsistency panics
+
+	tree = NewAvlTree(WithCmpCheck(wraps, 1))
+	assert.Panics(t, func() {
+		for i := 0; i < 300; i++ {
+			n := &intNode{key: int(int32(r.Uint32()))}
+			tree.Insert(&n.avlHeader, n, wraps)
+		}
+	})
+}
+
+func TestAvlTreeCheckCmp(t *testing.T) {
+
+	var r *AvlNode
+	r0 := rand.New(rand.NewSource(0))
+	assert.NoError(t, AvlTreeCheckCmp(r, cmpIntNode, r0, 10))
+
+	ns := newIntNodes(1, 2, 3, 4, 5, 6, 7, 8)
+	for _, n := range ns {
+		AvlTreeInsert(&r, &n.avlHeader, n, cmpIntNode)
+	}
+	assert.NoError(t, AvlTreeCheckCmp(r, cmpIntNode, r0, 100))
+
+	// An argument-order dependent comparator
+
+	first := func(node1 interface{}, node2 interface{}) int { return -1 }
+	assert.ErrorIs(t, AvlTreeCheckCmp(r, first, r0, 100), ErrInconsistentCmp)
+
+	// A key changed while linked, so that lookups miss it
+
+	ns[1].key = 7
+	assert.ErrorIs(t, AvlTreeCheckCmp(r, cmpIntNode, r0, 100), ErrInconsistentCmp)
+}
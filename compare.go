@@ -0,0 +1,177 @@
+package avl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Compares two values of the same built-in ordered kind (any signed or
+// unsigned integer, float or string type, including named types based
+// on them), returning -1, 0 or +1.  NaN sorts before every other float.
+// Panics if the values are of different types or not of an ordered kind.
+// This is the default ordering for the package's boxed containers
+
+func AvlCompareValues(a, b interface{}) int {
+
+	switch x := a.(type) {
+	case int:
+		if y, ok := b.(int); ok {
+			return avlCompareOrdered(x, y)
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y)
+		}
+	case float64:
+		if y, ok := b.(float64); ok {
+			return avlCompareFloats(x, y)
+		}
+	}
+
+	va := reflect.ValueOf(a)
+	vb := reflect.ValueOf(b)
+
+	if !va.IsValid() || !vb.IsValid() || va.Type() != vb.Type() {
+		panic(fmt.Sprintf("avl: cannot compare %T with %T", a, b))
+	}
+
+	switch va.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return avlCompareOrdered(va.Int(), vb.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return avlCompareOrdered(va.Uint(), vb.Uint())
+	case reflect.Float32, reflect.Float64:
+		return avlCompareFloats(va.Float(), vb.Float())
+	case reflect.String:
+		return strings.Compare(va.String(), vb.String())
+	}
+
+	panic(fmt.Sprintf("avl: %T is not an ordered type", a))
+}
+
+func avlCompareOrdered[T int | int64 | uint64](x, y T) int {
+	if x < y {
+		return -1
+	} else if x > y {
+		return 1
+	} else {
+		return 0
+	}
+}
+
+func avlCompareFloats(x, y float64) int {
+	xNaN := x != x
+	yNaN := y != y
+
+	if xNaN || yNaN {
+		if xNaN && yNaN {
+			return 0
+		} else if xNaN {
+			return -1
+		} else {
+			return 1
+		}
+	}
+
+	if x < y {
+		return -1
+	} else if x > y {
+		return 1
+	} else {
+		return 0
+	}
+}
+
+// Either kind of comparison function
+
+type avlCmpFunc interface {
+	~func(interface{}, interface{}) int
+}
+
+// Returns a comparison function ordering the other way round from cmp,
+// for descending trees
+
+func Reverse[F avlCmpFunc](cmp F) F {
+	return func(a, b interface{}) int {
+		return -cmp(a, b)
+	}
+}
+
+// Returns a comparison function that compares with each of cmps in turn
+// and returns the first non-zero result, so that later ones break ties
+// in earlier ones
+
+func Chain[F avlCmpFunc](cmps ...F) F {
+	return func(a, b interface{}) int {
+		for _, cmp := range cmps {
+			if res := cmp(a, b); res != 0 {
+				return res
+			}
+		}
+		return 0
+	}
+}
+
+// Fluent builder for comparison functions over compound keys:
+//
+//	cmp := avl.By(lastName).ThenBy(firstName).ThenByDesc(age).Cmp()
+//
+// Each accessor extracts one field from an owner; the fields are
+// compared with AvlCompareValues, in the order given
+
+type AvlCmpBuilder struct {
+	fields []avlCmpField
+}
+
+type avlCmpField struct {
+	get  func(owner interface{}) interface{}
+	sign int
+}
+
+// Starts a comparison function ordered first by the field get returns
+
+func By(get func(owner interface{}) interface{}) *AvlCmpBuilder {
+	return (&AvlCmpBuilder{}).ThenBy(get)
+}
+
+// Breaks ties by the field get returns, ascending
+
+func (b *AvlCmpBuilder) ThenBy(get func(owner interface{}) interface{}) *AvlCmpBuilder {
+	b.fields = append(b.fields, avlCmpField{get, 1})
+	return b
+}
+
+// Breaks ties by the field get returns, descending
+
+func (b *AvlCmpBuilder) ThenByDesc(get func(owner interface{}) interface{}) *AvlCmpBuilder {
+	b.fields = append(b.fields, avlCmpField{get, -1})
+	return b
+}
+
+// Returns the comparison function.  Later calls on the builder do not
+// change it
+
+func (b *AvlCmpBuilder) Cmp() CmpFuncNode {
+
+	fields := append([]avlCmpField(nil), b.fields...)
+
+	return func(node1 interface{}, node2 interface{}) int {
+		for _, f := range fields {
+			res := AvlCompareValues(f.get(node1), f.get(node2))
+			if res != 0 {
+				return f.sign * res
+			}
+		}
+		return 0
+	}
+}
+
+// Anything that can order strings, such as a collate.Collator from
+// golang.org/x/text, for locale-correct ordering without the package
+// depending on it
+
+type AvlStringCollator interface {
+	CompareString(a, b string) int
+}
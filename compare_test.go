@@ -0,0 +1,78 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type pairNode struct {
+	avlHeader AvlNode
+	a, b      int
+}
+
+func cmpPairA(node1 interface{}, node2 interface{}) int {
+	return node1.(*pairNode).a - node2.(*pairNode).a
+}
+
+func cmpPairB(node1 interface{}, node2 interface{}) int {
+	return node1.(*pairNode).b - node2.(*pairNode).b
+}
+
+func TestReverseAndChain(t *testing.T) {
+
+	var r *AvlNode
+
+	for _, k := range []int{3, 1, 2} {
+		n := &intNode{key: k}
+		AvlTreeInsert(&r, &n.avlHeader, n, Reverse(CmpFuncNode(cmpIntNode)))
+	}
+	assert.Equal(t, []int{3, 2, 1}, inOrderKeys(r))
+	assert.NotNil(t, AvlTreeLookup(r, 2, Reverse(CmpFuncKey(cmpIntKey))))
+
+	r = nil
+	cmp := Chain(CmpFuncNode(cmpPairA), Reverse(CmpFuncNode(cmpPairB)))
+	for _, p := range [][2]int{{2, 1}, {1, 5}, {2, 7}, {1, 6}} {
+		n := &pairNode{a: p[0], b: p[1]}
+		assert.Nil(t, AvlTreeInsert(&r, &n.avlHeader, n, cmp))
+	}
+
+	var got [][2]int
+	for p := AvlTreeFirstInOrder(r); p != nil; p = AvlTreeNextInOrder(&p.(*pairNode).avlHeader) {
+		got = append(got, [2]int{p.(*pairNode).a, p.(*pairNode).b})
+	}
+	assert.Equal(t, [][2]int{{1, 6}, {1, 5}, {2, 7}, {2, 1}}, got)
+
+	assert.Equal(t, 0, Chain[CmpFuncNode]()(nil, nil))
+}
+
+func TestBy(t *testing.T) {
+
+	type person struct {
+		avlHeader AvlNode
+		last      string
+		first     string
+		age       int
+	}
+
+	b := By(func(o interface{}) interface{} { return o.(*person).last }).
+		ThenBy(func(o interface{}) interface{} { return o.(*person).first })
+	cmp := b.Cmp()
+	b.ThenByDesc(func(o interface{}) interface{} { return o.(*person).age })
+	cmpAge := b.Cmp()
+
+	x := &person{last: "Smith", first: "Ann", age: 30}
+	y := &person{last: "Smith", first: "Ann", age: 40}
+	z := &person{last: "Jones", first: "Bob", age: 50}
+
+	assert.Equal(t, 0, cmp(x, y))
+	assert.Equal(t, 1, cmpAge(x, y))
+	assert.Equal(t, -1, cmpAge(y, x))
+	assert.Equal(t, 1, cmpAge(x, z))
+
+	var r *AvlNode
+	for _, p := range []*person{x, y, z} {
+		assert.Nil(t, AvlTreeInsert(&r, &p.avlHeader, p, cmpAge))
+	}
+	assert.Equal(t, z, AvlTreeFirstInOrder(r))
+	assert.Equal(t, x, AvlTreeLastInOrder(r))
+}
@@ -0,0 +1,182 @@
+package avl
+
+import (
+	"cmp"
+	"iter"
+)
+
+//
+// Frequency counting.  AvlCounter counts occurrences of keys, and keeps
+// the keys ordered by count, most frequent first, for heavy-hitter
+// reports: a hash index finds a key's entry in O(1), and a tree orders
+// the entries by count, so changing a count, and finding the k most
+// frequent keys or a key's rank, are O(log n) (plus k).  Keys with
+// equal counts are ordered by when they were first counted, earliest
+// first.  A key whose count falls to zero or below is dropped.
+//
+// The zero AvlCounter is an empty counter ready to use.
+//
+
+type avlCountEntry[K comparable] struct {
+	header AvlNode
+	key    K
+	count  int64
+	seq    uint64
+}
+
+type AvlCounter[K comparable] struct {
+	byCount AvlTree
+	index   map[K]*avlCountEntry[K]
+	seq     uint64
+	total   int64
+}
+
+// A key and its count
+
+type AvlCount[K comparable] struct {
+	Key   K
+	Count int64
+}
+
+// Returns an empty counter
+
+func NewAvlCounter[K comparable]() *AvlCounter[K] {
+	return &AvlCounter[K]{}
+}
+
+// Orders entries by count, greatest first, then first counted first
+
+func avlCmpCountEntries[K comparable](a, b interface{}) int {
+
+	ea := a.(*avlCountEntry[K])
+	eb := b.(*avlCountEntry[K])
+
+	if c := cmp.Compare(eb.count, ea.count); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(ea.seq, eb.seq)
+}
+
+// Returns the number of keys counted
+
+func (c *AvlCounter[K]) Len() int {
+	return c.byCount.Len()
+}
+
+// Returns the sum of the counts
+
+func (c *AvlCounter[K]) Total() int64 {
+	return c.total
+}
+
+// Adds delta, which may be negative, to the count of key, and returns
+// the new count.  The key is added if it was not counted, and dropped if
+// its count falls to zero or below.  O(log n)
+
+func (c *AvlCounter[K]) Add(key K, delta int64) int64 {
+
+	if c.index == nil {
+		c.index = make(map[K]*avlCountEntry[K])
+	}
+
+	e := c.index[key]
+	if e == nil {
+		if delta <= 0 {
+			return 0
+		}
+		c.seq++
+		e = &avlCountEntry[K]{key: key, seq: c.seq}
+		c.index[key] = e
+	} else {
+		c.byCount.Remove(&e.header)
+	}
+
+	old := e.count
+	e.count += delta
+	if e.count <= 0 {
+		delete(c.index, key)
+		c.total -= old
+		return 0
+	}
+	c.total += delta
+	c.byCount.Insert(&e.header, e, avlCmpCountEntries[K])
+
+	return e.count
+}
+
+// Adds one to the count of key, and returns the new count
+
+func (c *AvlCounter[K]) Increment(key K) int64 {
+	return c.Add(key, 1)
+}
+
+// Takes one from the count of key, dropping it at zero, and returns the
+// new count
+
+func (c *AvlCounter[K]) Decrement(key K) int64 {
+	return c.Add(key, -1)
+}
+
+// Drops key.  Returns false if it was not counted
+
+func (c *AvlCounter[K]) Remove(key K) bool {
+
+	e := c.index[key]
+	if e == nil {
+		return false
+	}
+
+	c.Add(key, -e.count)
+
+	return true
+}
+
+// Returns the count of key, zero if it is not counted.  O(1)
+
+func (c *AvlCounter[K]) CountOf(key K) int64 {
+	if e := c.index[key]; e != nil {
+		return e.count
+	}
+	return 0
+}
+
+// Returns the rank of key, 1 for the most frequent, or false if it is
+// not counted.  O(log n)
+
+func (c *AvlCounter[K]) RankOf(key K) (int, bool) {
+	e := c.index[key]
+	if e == nil {
+		return 0, false
+	}
+	return AvlTreeNodeRank(&e.header) + 1, true
+}
+
+// Returns the k most frequent keys and their counts, most frequent first
+
+func (c *AvlCounter[K]) TopK(k int) []AvlCount[K] {
+
+	top := make([]AvlCount[K], 0, min(max(k, 0), c.byCount.Len()))
+	for key, count := range c.All() {
+		if len(top) == cap(top) {
+			break
+		}
+		top = append(top, AvlCount[K]{key, count})
+	}
+
+	return top
+}
+
+// Yields the keys and their counts, most frequent first.  The counter
+// must not be changed while the sequence is being iterated
+
+func (c *AvlCounter[K]) All() iter.Seq2[K, int64] {
+	return func(yield func(K, int64) bool) {
+		for n := c.byCount.first; n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+			e := n.owner.(*avlCountEntry[K])
+			if !yield(e.key, e.count) {
+				return
+			}
+		}
+	}
+}
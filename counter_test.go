@@ -0,0 +1,85 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestAvlCounter(t *testing.T) {
+
+	var c AvlCounter[string]
+
+	for _, w := range []string{"b", "a", "c", "a", "b", "a"} {
+		c.Increment(w)
+	}
+	assert.Equal(t, 3, c.Len())
+	assert.Equal(t, int64(6), c.Total())
+	assert.Equal(t, int64(3), c.CountOf("a"))
+	assert.Equal(t, int64(0), c.CountOf("z"))
+
+	// Ties go to the key counted first
+
+	assert.Equal(t, []AvlCount[string]{{"a", 3}, {"b", 2}}, c.TopK(2))
+	assert.Equal(t, []AvlCount[string]{{"a", 3}, {"b", 2}, {"c", 1}}, c.TopK(10))
+	assert.Empty(t, c.TopK(-1))
+
+	rank, ok := c.RankOf("b")
+	assert.True(t, ok)
+	assert.Equal(t, 2, rank)
+	_, ok = c.RankOf("z")
+	assert.False(t, ok)
+
+	assert.Equal(t, int64(2), c.Add("c", 1))
+	assert.Equal(t, []AvlCount[string]{{"a", 3}, {"b", 2}, {"c", 2}}, c.TopK(3))
+
+	// Counts that fall to zero are dropped
+
+	assert.Equal(t, int64(1), c.Decrement("c"))
+	assert.Equal(t, int64(0), c.Add("c", -5))
+	assert.Equal(t, int64(0), c.CountOf("c"))
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, int64(0), c.Decrement("z"))
+
+	assert.True(t, c.Remove("a"))
+	assert.False(t, c.Remove("a"))
+	assert.Equal(t, []AvlCount[string]{{"b", 2}}, c.TopK(3))
+	assert.Equal(t, int64(2), c.Total())
+}
+
+func TestAvlCounterAgainstMap(t *testing.T) {
+
+	rnd := rand.New(rand.NewSource(1230))
+	c := NewAvlCounter[int]()
+	model := map[int]int64{}
+
+	for i := 0; i < 20000; i++ {
+		k := int(rnd.ExpFloat64() * 20)
+		if rnd.Intn(4) == 0 {
+			c.Decrement(k)
+			if model[k]--; model[k] <= 0 {
+				delete(model, k)
+			}
+		} else {
+			c.Increment(k)
+			model[k]++
+		}
+	}
+
+	var total int64
+	counts := make([]int64, 0, len(model))
+	for k, n := range model {
+		assert.Equal(t, n, c.CountOf(k))
+		counts = append(counts, n)
+		total += n
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i] > counts[j] })
+
+	assert.Equal(t, len(model), c.Len())
+	assert.Equal(t, total, c.Total())
+	for i, kc := range c.TopK(10) {
+		assert.Equal(t, counts[i], kc.Count)
+	}
+	assert.NoError(t, c.byCount.Validate(avlCmpCountEntries[int]))
+}
@@ -0,0 +1,162 @@
+package avl
+
+//
+// Cursors.  An AvlCursor sits on one owner of an AvlTree, or just off
+// either end, and moves forwards and backwards through it in order.  It
+// knows the in-order index of where it is: moving by one step updates
+// the index as it goes, and the Seek methods work it out from the
+// subtree sizes, so Index is always O(1).
+//
+// The cursor remembers the tree's generation, which every mutation
+// through the tree's methods advances, as of when it was last
+// positioned.  Once the tree has changed the cursor's node may have
+// been removed, or moved, so the cursor refuses to go on: it falls off
+// the tree and Err returns ErrConcurrentModification.  Repositioning it
+// with one of the Seek methods (or First or Last) makes it usable again.
+// Mutations made with the raw functions are not detected.
+//
+
+type AvlCursor struct {
+	tree  *AvlTree
+	node  *AvlNode
+	index int
+	gen   uint64
+	err   error
+}
+
+// Returns a cursor on the first owner of the tree
+
+func (tree *AvlTree) Cursor() *AvlCursor {
+	c := &AvlCursor{tree: tree}
+	c.First()
+	return c
+}
+
+// Moves to the first owner.  Returns false if the tree is empty
+
+func (c *AvlCursor) First() bool {
+	c.sync()
+	c.node = c.tree.first
+	c.index = 0
+	return c.node != nil
+}
+
+// Moves to the last owner.  Returns false if the tree is empty
+
+func (c *AvlCursor) Last() bool {
+	c.sync()
+	c.node = c.tree.last
+	c.index = c.tree.size - 1
+	return c.node != nil
+}
+
+// Moves to the first owner whose key is >= key, or past the end if there
+// is none.  Returns false if there is none
+
+func (c *AvlCursor) Seek(key interface{}, cmp CmpFuncKey) bool {
+	c.sync()
+	c.node = avlTreeFirstAtOrAfter(c.tree.root, key, cmp)
+	c.index = avlTreeCountBefore(c.tree.root, key, cmp, false)
+	return c.node != nil
+}
+
+// Moves to the owner at in-order index i.  An index below 0 leaves the
+// cursor before the first owner, and one at or beyond the end past the
+// last.  Returns false if there is no owner at i
+
+func (c *AvlCursor) SeekIndex(i int) bool {
+	c.sync()
+	c.index = min(max(i, -1), c.tree.size)
+	c.node = avlTreeSelect(c.tree.root, c.index)
+	return c.node != nil
+}
+
+// Moves to the next owner, or from before the first owner to the first.
+// Returns false once the cursor is past the last
+
+func (c *AvlCursor) Next() bool {
+
+	if c.stale() {
+		return false
+	}
+
+	switch {
+	case c.node != nil:
+		c.node = avlTreeNextOrPrevInOrder(c.node, 1)
+		c.index++
+	case c.index < 0:
+		return c.First()
+	}
+
+	return c.node != nil
+}
+
+// Moves to the previous owner, or from past the last owner to the last.
+// Returns false once the cursor is before the first
+
+func (c *AvlCursor) Prev() bool {
+
+	if c.stale() {
+		return false
+	}
+
+	switch {
+	case c.node != nil:
+		c.node = avlTreeNextOrPrevInOrder(c.node, -1)
+		c.index--
+	case c.index >= c.tree.size:
+		return c.Last()
+	}
+
+	return c.node != nil
+}
+
+// True if the cursor is on an owner, and the tree has not changed since
+// it was positioned
+
+func (c *AvlCursor) Valid() bool {
+	return !c.stale() && c.node != nil
+}
+
+// Returns the owner the cursor is on, or nil if it is off either end or
+// the tree has changed since it was positioned
+
+func (c *AvlCursor) Owner() interface{} {
+	if c.stale() || c.node == nil {
+		return nil
+	}
+	return c.node.owner
+}
+
+// Returns ErrConcurrentModification if the tree has changed since the
+// cursor was positioned, and otherwise nil
+
+func (c *AvlCursor) Err() error {
+	c.stale()
+	return c.err
+}
+
+// Notes the tree's generation, when the cursor is positioned
+
+func (c *AvlCursor) sync() {
+	c.gen = c.tree.gen.Load()
+	c.err = nil
+}
+
+// True if the tree has changed since the cursor was positioned, in which
+// case the cursor is taken off the tree
+
+func (c *AvlCursor) stale() bool {
+	if c.err == nil && c.tree.gen.Load() != c.gen {
+		c.err = ErrConcurrentModification
+		c.node = nil
+	}
+	return c.err != nil
+}
+
+// Returns the in-order index of the cursor's position: -1 before the
+// first owner, and the number of owners past the last
+
+func (c *AvlCursor) Index() int {
+	return c.index
+}
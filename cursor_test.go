@@ -0,0 +1,97 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAvlCursor(t *testing.T) {
+
+	var tree AvlTree
+
+	c := tree.Cursor()
+	assert.False(t, c.Valid())
+	assert.Equal(t, 0, c.Index())
+	assert.False(t, c.Next())
+
+	for i := 0; i < 100; i++ {
+		n := &intNode{key: i * 2}
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	// The index follows the cursor forwards and backwards
+
+	i := 0
+	for c = tree.Cursor(); c.Valid(); c.Next() {
+		assert.Equal(t, i, c.Index())
+		assert.Equal(t, i*2, c.Owner().(*intNode).key)
+		i++
+	}
+	assert.Equal(t, 100, c.Index())
+	assert.Nil(t, c.Owner())
+
+	assert.True(t, c.Prev())
+	assert.Equal(t, 99, c.Index())
+	assert.Equal(t, 198, c.Owner().(*intNode).key)
+
+	for c.Prev() {
+	}
+	assert.Equal(t, -1, c.Index())
+	assert.True(t, c.Next())
+	assert.Equal(t, 0, c.Index())
+
+	// Seeking works the index out
+
+	assert.True(t, c.Seek(51, cmpIntKey))
+	assert.Equal(t, 26, c.Index())
+	assert.Equal(t, 52, c.Owner().(*intNode).key)
+	assert.True(t, c.Next())
+	assert.Equal(t, 27, c.Index())
+
+	assert.False(t, c.Seek(500, cmpIntKey))
+	assert.Equal(t, 100, c.Index())
+
+	assert.True(t, c.SeekIndex(10))
+	assert.Equal(t, 20, c.Owner().(*intNode).key)
+	assert.False(t, c.SeekIndex(-5))
+	assert.Equal(t, -1, c.Index())
+
+	assert.True(t, c.Last())
+	assert.Equal(t, 99, c.Index())
+}
+
+func TestAvlCursorConcurrentModification(t *testing.T) {
+
+	var tree AvlTree
+
+	ns := newIntNodes(1, 2, 3, 4)
+	for _, n := range ns[:3] {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	c := tree.Cursor()
+	assert.True(t, c.Next())
+	assert.NoError(t, c.Err())
+
+	// The node under the cursor goes away
+
+	tree.Remove(&ns[1].avlHeader)
+
+	assert.False(t, c.Valid())
+	assert.Nil(t, c.Owner())
+	assert.False(t, c.Next())
+	assert.False(t, c.Prev())
+	assert.ErrorIs(t, c.Err(), ErrConcurrentModification)
+
+	// Repositioning makes it usable again
+
+	assert.True(t, c.Seek(2, cmpIntKey))
+	assert.NoError(t, c.Err())
+	assert.Same(t, ns[2], c.Owner())
+	assert.Equal(t, 1, c.Index())
+
+	tree.Insert(&ns[3].avlHeader, ns[3], cmpIntNode)
+	assert.ErrorIs(t, c.Err(), ErrConcurrentModification)
+	assert.True(t, c.Last())
+	assert.Same(t, ns[3], c.Owner())
+}
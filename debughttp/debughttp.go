@@ -0,0 +1,201 @@
+//
+// Copyright as per Creative Commons Legal Code license, which can
+// be found in the file COPYING
+//
+
+/*
+
+Package debughttp serves live views of registered avl trees over HTTP,
+in the manner of net/http/pprof.  Importing it registers handlers under
+/debug/avl/ on http.DefaultServeMux:
+
+	/debug/avl/                          the registered trees and their stats
+	/debug/avl/stats?tree=name           one tree's stats, as JSON
+	/debug/avl/top?tree=name&depth=n     the top n levels of the tree
+	/debug/avl/validate?tree=name        runs Validate on the tree
+
+A tree is registered under a name, with the lock that guards it:
+
+	debughttp.Register("users", debughttp.Tree{
+		Tree:   users,
+		Cmp:    cmpUser,
+		Locker: usersMu.RLocker(),
+	})
+
+As with pprof, the handlers expose internals of the process, so only
+serve them on a port that is not open to the public.
+
+*/
+
+package debughttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/danswartzendruber/avl"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A tree to serve
+
+type Tree struct {
+	Tree *avl.AvlTree
+
+	// Orders the owners for validation.  If nil, validation checks only
+	// the structure
+	Cmp avl.CmpFuncNode
+
+	// Held while the handlers read the tree.  Nil if the tree needs no
+	// locking
+	Locker sync.Locker
+
+	// Labels an owner in the structure view.  Defaults to fmt.Sprint
+	Label func(owner interface{}) string
+}
+
+var (
+	mu    sync.Mutex
+	trees = map[string]Tree{}
+)
+
+func init() {
+	http.HandleFunc("/debug/avl/", Index)
+	http.HandleFunc("/debug/avl/stats", Stats)
+	http.HandleFunc("/debug/avl/top", Top)
+	http.HandleFunc("/debug/avl/validate", Validate)
+}
+
+// Registers a tree under name, replacing any tree already registered
+// under it
+
+func Register(name string, t Tree) {
+	mu.Lock()
+	defer mu.Unlock()
+	trees[name] = t
+}
+
+// Unregisters the tree registered under name
+
+func Unregister(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(trees, name)
+}
+
+// Runs fn with the tree registered under name locked, or reports that
+// there is no such tree
+
+func withTree(w http.ResponseWriter, name string, fn func(t Tree)) {
+
+	mu.Lock()
+	t, ok := trees[name]
+	mu.Unlock()
+
+	if !ok {
+		http.Error(w, fmt.Sprintf("no tree registered as %q", name),
+			http.StatusNotFound)
+		return
+	}
+
+	if t.Locker != nil {
+		t.Locker.Lock()
+		defer t.Locker.Unlock()
+	}
+
+	fn(t)
+}
+
+// Lists the registered trees with their stats
+
+func Index(w http.ResponseWriter, r *http.Request) {
+
+	mu.Lock()
+	names := make([]string, 0, len(trees))
+	for name := range trees {
+		names = append(names, name)
+	}
+	mu.Unlock()
+
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	fmt.Fprintf(w, "%-20s %10s %6s %10s %10s %10s\n",
+		"tree", "size", "height", "inserts", "removes", "rotations")
+
+	for _, name := range names {
+		withTree(w, name, func(t Tree) {
+			s := t.Tree.Stats()
+			fmt.Fprintf(w, "%-20s %10d %6d %10d %10d %10d\n", name,
+				s.Size, s.Height, s.Inserts, s.Removes, s.Rotations)
+		})
+	}
+}
+
+// Serves a tree's stats as JSON
+
+func Stats(w http.ResponseWriter, r *http.Request) {
+	withTree(w, r.FormValue("tree"), func(t Tree) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(t.Tree.Stats())
+	})
+}
+
+// Serves the top levels of a tree, right subtrees above left ones, with
+// each node's subtree size and balance factor
+
+func Top(w http.ResponseWriter, r *http.Request) {
+
+	depth, err := strconv.Atoi(r.FormValue("depth"))
+	if err != nil || depth <= 0 {
+		depth = 4
+	}
+
+	withTree(w, r.FormValue("tree"), func(t Tree) {
+		label := t.Label
+		if label == nil {
+			label = func(owner interface{}) string {
+				return fmt.Sprint(owner)
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+		var walk func(n *avl.AvlNode, level int)
+
+		walk = func(n *avl.AvlNode, level int) {
+			if n == nil || level >= depth {
+				return
+			}
+			walk(n.Right(), level+1)
+			fmt.Fprintf(w, "%s%s  (size %d, balance %+d)\n",
+				strings.Repeat("    ", level), label(n.Owner()),
+				n.SubtreeSize(), avl.AvlGetBalanceFactor(n))
+			walk(n.Left(), level+1)
+		}
+
+		if root := t.Tree.Root(); root != nil {
+			walk(root, 0)
+		} else {
+			fmt.Fprintln(w, "(empty)")
+		}
+	})
+}
+
+// Validates a tree, giving up if the request is cancelled
+
+func Validate(w http.ResponseWriter, r *http.Request) {
+	withTree(w, r.FormValue("tree"), func(t Tree) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := t.Tree.ValidateContext(r.Context(), t.Cmp); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintln(w, err)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
@@ -0,0 +1,66 @@
+package debughttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/danswartzendruber/avl"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func get(t *testing.T, url string) (int, string) {
+
+	rec := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+
+	return rec.Code, rec.Body.String()
+}
+
+func TestHandlers(t *testing.T) {
+
+	tree := avl.Build().Keys(1, 2, 3, 4, 5, 6, 7).Tree()
+
+	var mu sync.RWMutex
+	Register("nums", Tree{
+		Tree:   tree,
+		Cmp:    avl.AvlItemCmpNode,
+		Locker: mu.RLocker(),
+		Label: func(owner interface{}) string {
+			return fmt.Sprint(owner.(*avl.AvlItem).Key)
+		},
+	})
+	defer Unregister("nums")
+
+	code, body := get(t, "/debug/avl/")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "nums")
+
+	code, body = get(t, "/debug/avl/stats?tree=nums")
+	assert.Equal(t, http.StatusOK, code)
+	var s avl.AvlTreeStats
+	assert.NoError(t, json.Unmarshal([]byte(body), &s))
+	assert.Equal(t, 7, s.Size)
+	assert.Equal(t, 3, s.Height)
+
+	code, body = get(t, "/debug/avl/top?tree=nums&depth=2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "    6  (size 3, balance +0)\n"+
+		"4  (size 7, balance +0)\n"+
+		"    2  (size 3, balance +0)\n", body)
+
+	code, body = get(t, "/debug/avl/validate?tree=nums")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok\n", body)
+
+	// Corrupt the ordering
+
+	tree.Root().Owner().(*avl.AvlItem).Key = 0
+	code, _ = get(t, "/debug/avl/validate?tree=nums")
+	assert.Equal(t, http.StatusInternalServerError, code)
+
+	code, _ = get(t, "/debug/avl/stats?tree=other")
+	assert.Equal(t, http.StatusNotFound, code)
+}
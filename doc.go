@@ -29,6 +29,13 @@ Briefly, the supported operations are:
 - Search
 - In-order traversal (forwards and backwards)
 - Post-order traversal
+- AvlTree wrapper with size tracking and insert/remove observers
+- Validation of the tree invariants, cancellable through a context
+- Order statistics: every node knows the size of its subtree
+- Range iteration, and a frozen, array-backed form for read-only trees
+- OrderedMap, a typed map over boxed entries, optionally of fixed capacity
+- OrderedDict, an OrderedMap with a hash index for O(1) lookups
+- NewTreeFor, a typed tree whose key fields are declared by struct tags
 
 See avl_tree.h for details.
 
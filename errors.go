@@ -0,0 +1,63 @@
+package avl
+
+import "errors"
+
+// Errors returned by the checked functions
+
+var (
+	// The tree's structure or ordering is inconsistent
+	ErrInvalidTree = errors.New("avl: invalid tree")
+
+	// A nil comparison function was passed
+	ErrNilComparator = errors.New("avl: nil comparison function")
+
+	// A nil node was passed
+	ErrNilNode = errors.New("avl: nil node")
+
+	// The node is not linked into the tree it was used with
+	ErrNotInTree = errors.New("avl: node not in tree")
+
+	// The node being inserted is already linked into the tree
+	ErrAlreadyLinked = errors.New("avl: node already in tree")
+
+	// A node with the same key is already in the destination tree
+	ErrKeyExists = errors.New("avl: key already in tree")
+
+	// The tree was modified while it was being walked
+	ErrConcurrentModification = errors.New("avl: tree modified during iteration")
+
+	// A serialized tree is malformed or of an unknown version
+	ErrBadDump = errors.New("avl: malformed tree dump")
+
+	// The transaction has already been committed or rolled back
+	ErrTxnDone = errors.New("avl: transaction already finished")
+
+	// A fixed-capacity container is full
+	ErrFull = errors.New("avl: container is full")
+
+	// A node was linked through a header other than the one registered
+	// for its tree, or with a different owner
+	ErrWrongHeader = errors.New("avl: node linked through the wrong header")
+
+	// The tree's contents were replaced wholesale during the transaction,
+	// so it cannot be rolled back
+	ErrTxnLost = errors.New("avl: transaction history lost")
+
+	// Transactions on one tree must finish innermost first
+	ErrTxnOrder = errors.New("avl: transactions must finish in the reverse order they began")
+
+	// A tree method panicked; the sink of a tree made WithErrorSink gets
+	// this in place of the panic
+	ErrPanic = errors.New("avl: panic in tree operation")
+
+	// A comparison function gave answers that cannot all be true, such as
+	// a < b and b < a
+	ErrInconsistentCmp = errors.New("avl: inconsistent comparison function")
+
+	// The key of an owner changed while its node was in the tree
+	ErrKeyMutated = errors.New("avl: key changed while in tree")
+
+	// A batch removes a node after an insertion into a bounded tree,
+	// which may have evicted it
+	ErrBatchEvicts = errors.New("avl: batch removes after inserting into a bounded tree")
+)
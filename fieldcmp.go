@@ -0,0 +1,178 @@
+package avl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//
+// Comparison functions built by reflection from the name of a struct
+// field, for prototypes, and for programs that choose the field to order
+// by at run time.  The field is found once per owner type, and the
+// comparison function remembers the last type it saw, so a tree of one
+// type of owner pays for two field reads through reflect per comparison
+// and no lookups by name.  A hand-written comparison function is still
+// several times faster.
+//
+
+// How to read and compare one field of one type of owner
+
+type avlFieldAccess struct {
+	typ   reflect.Type // The owner's type, a struct or a pointer to one
+	index []int
+	ptr   bool
+	cmp   func(a, b reflect.Value) int
+}
+
+type avlFieldKey struct {
+	typ  reflect.Type
+	name string
+}
+
+// Every avlFieldAccess made, by owner type and field name
+
+var avlFieldAccesses sync.Map
+
+// Returns how to read the field name, which may be a dotted path through
+// nested structs, of owners of type typ
+
+func avlFieldAccessFor(typ reflect.Type, name string) (*avlFieldAccess, error) {
+
+	key := avlFieldKey{typ, name}
+	if fa, ok := avlFieldAccesses.Load(key); ok {
+		return fa.(*avlFieldAccess), nil
+	}
+
+	fa := &avlFieldAccess{typ: typ}
+	st := typ
+	if st != nil && st.Kind() == reflect.Pointer {
+		st = st.Elem()
+		fa.ptr = true
+	}
+	if st == nil || st.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("avl: cannot order %v by field %s: not a struct", typ, name)
+	}
+
+	for _, part := range strings.Split(name, ".") {
+		if st.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("avl: %v has no field %s: %v is not a struct",
+				typ, name, st)
+		}
+		f, ok := st.FieldByName(part)
+		if !ok {
+			return nil, fmt.Errorf("avl: %v has no field %s", typ, name)
+		}
+		fa.index = append(fa.index, f.Index...)
+		st = f.Type
+	}
+
+	cmp, err := avlFieldCmp(st)
+	if err != nil {
+		return nil, fmt.Errorf("avl: cannot order %v by field %s: %w", typ, name, err)
+	}
+	fa.cmp = cmp
+
+	actual, _ := avlFieldAccesses.LoadOrStore(key, fa)
+
+	return actual.(*avlFieldAccess), nil
+}
+
+// Returns a function comparing values of type typ, which must be of an
+// ordered kind, as AvlCompareValues would.  Reading a field through
+// reflect this way works for unexported fields too
+
+func avlFieldCmp(typ reflect.Type) (func(a, b reflect.Value) int, error) {
+
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(a, b reflect.Value) int {
+			return avlCompareOrdered(a.Int(), b.Int())
+		}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return func(a, b reflect.Value) int {
+			return avlCompareOrdered(a.Uint(), b.Uint())
+		}, nil
+	case reflect.Float32, reflect.Float64:
+		return func(a, b reflect.Value) int {
+			return avlCompareFloats(a.Float(), b.Float())
+		}, nil
+	case reflect.String:
+		return func(a, b reflect.Value) int {
+			return strings.Compare(a.String(), b.String())
+		}, nil
+	case reflect.Bool:
+		return func(a, b reflect.Value) int {
+			x, y := a.Bool(), b.Bool()
+			if x == y {
+				return 0
+			} else if y {
+				return -1
+			}
+			return 1
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%v is not an ordered type", typ)
+}
+
+// Returns the field of owner, whose type is fa.typ
+
+func (fa *avlFieldAccess) field(owner interface{}) reflect.Value {
+
+	v := reflect.ValueOf(owner)
+	if fa.ptr {
+		v = v.Elem()
+	}
+
+	return v.FieldByIndex(fa.index)
+}
+
+// Compares owners a and b, both of type fa.typ, by the field
+
+func (fa *avlFieldAccess) compare(a, b interface{}) int {
+	return fa.cmp(fa.field(a), fa.field(b))
+}
+
+// Returns a comparison function ordering owners by the field fieldName,
+// as AvlCompareValues would order its values.  The owners must be
+// structs, or pointers to them, and the field of an ordered kind (an
+// integer, float, string or bool type); fieldName may be a dotted path
+// through nested structs, such as "Meta.Priority", and names unexported
+// fields as well.  The function panics when it meets an owner without
+// such a field; owners of different types may be compared if their
+// fields are of the same kind.  It is safe for concurrent use
+
+func CmpByField(fieldName string) CmpFuncNode {
+
+	var last atomic.Pointer[avlFieldAccess]
+
+	access := func(owner interface{}) *avlFieldAccess {
+
+		typ := reflect.TypeOf(owner)
+		if fa := last.Load(); fa != nil && fa.typ == typ {
+			return fa
+		}
+
+		fa, err := avlFieldAccessFor(typ, fieldName)
+		if err != nil {
+			panic(err)
+		}
+		last.Store(fa)
+
+		return fa
+	}
+
+	return func(node1 interface{}, node2 interface{}) int {
+
+		fa := access(node1)
+		if reflect.TypeOf(node2) == fa.typ {
+			return fa.compare(node1, node2)
+		}
+
+		return fa.cmp(fa.field(node1), access(node2).field(node2))
+	}
+}
@@ -0,0 +1,58 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type ruleMeta struct {
+	Priority int
+}
+
+type rule struct {
+	avlHeader AvlNode
+	Name      string
+	weight    float64
+	Meta      ruleMeta
+}
+
+func TestCmpByField(t *testing.T) {
+
+	rules := []*rule{
+		{Name: "b", weight: 2.5, Meta: ruleMeta{3}},
+		{Name: "c", weight: 0.5, Meta: ruleMeta{1}},
+		{Name: "a", weight: 1.5, Meta: ruleMeta{2}},
+	}
+
+	for field, want := range map[string][]string{
+		"Name":          {"a", "b", "c"},
+		"weight":        {"c", "a", "b"},
+		"Meta.Priority": {"c", "a", "b"},
+	} {
+		var r *AvlNode
+		cmp := CmpByField(field)
+		for _, x := range rules {
+			x.avlHeader = AvlNode{}
+			assert.Nil(t, AvlTreeInsert(&r, &x.avlHeader, x, cmp))
+		}
+
+		var got []string
+		for o := AvlTreeFirstInOrder(r); o != nil; o = AvlTreeNextInOrder(&o.(*rule).avlHeader) {
+			got = append(got, o.(*rule).Name)
+		}
+		assert.Equal(t, want, got, field)
+	}
+
+	// Struct values, and another type with a field of the same kind
+
+	type other struct{ Name string }
+	cmp := CmpByField("Name")
+	assert.Equal(t, -1, cmp(rule{Name: "a"}, rule{Name: "b"}))
+	assert.Equal(t, 1, cmp(&rule{Name: "b"}, other{Name: "a"}))
+	assert.Equal(t, 0, cmp(other{Name: "a"}, &rule{Name: "a"}))
+
+	assert.Panics(t, func() { CmpByField("Missing")(rules[0], rules[1]) })
+	assert.Panics(t, func() { CmpByField("Meta")(rules[0], rules[1]) })
+	assert.Panics(t, func() { CmpByField("Name.Inner")(rules[0], rules[1]) })
+	assert.Panics(t, func() { CmpByField("Name")(1, 2) })
+}
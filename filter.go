@@ -0,0 +1,177 @@
+package avl
+
+import "math"
+
+//
+// Lookup filters.  When most lookups are for keys that are not in the
+// tree, each miss still costs a full descent and O(log n) comparator
+// calls.  A tree made WithLookupFilter keeps a probabilistic set of the
+// hashes of its keys, such as a Bloom filter, and consults it before
+// descending: a key the filter has never seen cannot be in the tree, so
+// Lookup returns nil straight away.  The filter may say yes for a key
+// that is not there, which only costs the descent it would have made
+// anyway.
+//
+// Keys are added to the filter as they are inserted.  Filters like Bloom
+// filters cannot forget a key, so removals, and growth well past the
+// size the filter was built for, make it less useful rather than wrong;
+// once they add up the tree rebuilds the filter, from scratch, on the
+// next lookup.  A wholesale replacement of the tree's contents does the
+// same.  The rebuild is O(n).
+//
+
+// A probabilistic set of 64-bit key hashes.  MayContain must return true
+// for every hash added since the last Reset, and may return true for
+// others
+
+type AvlFilter interface {
+	// Empties the filter, and sizes it for n hashes
+	Reset(n int)
+
+	Add(hash uint64)
+
+	MayContain(hash uint64) bool
+}
+
+type avlFilterState struct {
+	filter    AvlFilter
+	hashKey   func(key interface{}) uint64
+	hashOwner func(owner interface{}) uint64
+	builtFor  int  // The size the filter was last built for
+	removes   int  // Removals since
+	stale     bool // Rebuild before the next use
+}
+
+// Consults filter before every Lookup.  hashKey hashes a lookup key and
+// hashOwner the key of an owner, and they must agree: an owner and a key
+// that compare equal must hash the same
+
+func WithLookupFilter(filter AvlFilter, hashKey func(key interface{}) uint64,
+	hashOwner func(owner interface{}) uint64) AvlTreeOption {
+
+	return func(tree *AvlTree) {
+		tree.filter = &avlFilterState{
+			filter:    filter,
+			hashKey:   hashKey,
+			hashOwner: hashOwner,
+			stale:     true,
+		}
+	}
+}
+
+func (fs *avlFilterState) inserted(owner interface{}, size int) {
+	if fs.stale {
+		return
+	}
+	fs.filter.Add(fs.hashOwner(owner))
+	if size > 2*max(fs.builtFor, 16) {
+		fs.stale = true
+	}
+}
+
+func (fs *avlFilterState) removed(size int) {
+	fs.removes++
+	if fs.removes > size/2+16 {
+		fs.stale = true
+	}
+}
+
+// True if key may be in the tree, rebuilding the filter first if it has
+// gone stale
+
+func (tree *AvlTree) mayContain(key interface{}) bool {
+
+	fs := tree.filter
+
+	if fs.stale {
+		fs.filter.Reset(tree.size)
+		n := tree.first
+		for ; n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+			fs.filter.Add(fs.hashOwner(n.owner))
+		}
+		fs.builtFor = tree.size
+		fs.removes = 0
+		fs.stale = false
+	}
+
+	return fs.filter.MayContain(fs.hashKey(key))
+}
+
+// A Bloom filter, for WithLookupFilter
+
+type AvlBloomFilter struct {
+	bits       []uint64
+	bitsPerKey int
+	hashes     int
+}
+
+// Returns a Bloom filter using bitsPerKey bits of memory per key, with
+// a false positive rate of about 0.6^bitsPerKey: 10 bits per key gives
+// about 1%
+
+func NewAvlBloomFilter(bitsPerKey int) *AvlBloomFilter {
+
+	bitsPerKey = max(bitsPerKey, 1)
+
+	return &AvlBloomFilter{
+		bitsPerKey: bitsPerKey,
+		hashes:     min(max(int(math.Round(float64(bitsPerKey)*math.Ln2)), 1), 16),
+	}
+}
+
+func (b *AvlBloomFilter) Reset(n int) {
+	words := max(n*b.bitsPerKey/64+1, 1)
+	if cap(b.bits) >= words {
+		b.bits = b.bits[:words]
+		clear(b.bits)
+	} else {
+		b.bits = make([]uint64, words)
+	}
+}
+
+// Returns the two hashes whose combinations pick the bits for hash
+
+func (b *AvlBloomFilter) split(hash uint64) (uint64, uint64) {
+
+	// The finalizer of SplitMix64, so that poor hashes still spread
+
+	hash ^= hash >> 30
+	hash *= 0xbf58476d1ce4e5b9
+	hash ^= hash >> 27
+	hash *= 0x94d049bb133111eb
+	hash ^= hash >> 31
+
+	return hash, hash>>32 | 1
+}
+
+func (b *AvlBloomFilter) Add(hash uint64) {
+
+	if len(b.bits) == 0 {
+		b.Reset(0)
+	}
+
+	m := uint64(len(b.bits)) * 64
+	h1, h2 := b.split(hash)
+	for i := 0; i < b.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b *AvlBloomFilter) MayContain(hash uint64) bool {
+
+	if len(b.bits) == 0 {
+		return false
+	}
+
+	m := uint64(len(b.bits)) * 64
+	h1, h2 := b.split(hash)
+	for i := 0; i < b.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
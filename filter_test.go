@@ -0,0 +1,82 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestAvlBloomFilter(t *testing.T) {
+
+	b := NewAvlBloomFilter(10)
+	assert.False(t, b.MayContain(1))
+
+	b.Reset(1000)
+	for i := uint64(0); i < 1000; i++ {
+		b.Add(i)
+	}
+	for i := uint64(0); i < 1000; i++ {
+		assert.True(t, b.MayContain(i))
+	}
+
+	// About 1% false positives at 10 bits per key
+
+	fp := 0
+	for i := uint64(1000); i < 11000; i++ {
+		if b.MayContain(i) {
+			fp++
+		}
+	}
+	assert.True(t, fp < 300, "%d false positives", fp)
+}
+
+func TestAvlTreeLookupFilter(t *testing.T) {
+
+	hashKey := func(key interface{}) uint64 {
+		return uint64(key.(int))
+	}
+	hashOwner := func(owner interface{}) uint64 {
+		return uint64(owner.(*intNode).key)
+	}
+
+	tree := NewAvlTree(WithLookupFilter(NewAvlBloomFilter(10), hashKey, hashOwner))
+
+	calls := 0
+	counted := func(key interface{}, node interface{}) int {
+		calls++
+		return cmpIntKey(key, node)
+	}
+
+	rnd := rand.New(rand.NewSource(14))
+	model := map[int]*intNode{}
+
+	for i := 0; i < 20000; i++ {
+		k := rnd.Intn(2000)
+		if n, ok := model[k]; ok && rnd.Intn(3) == 0 {
+			tree.Remove(&n.avlHeader)
+			delete(model, k)
+		} else if !ok {
+			n := &intNode{key: k}
+			tree.Insert(&n.avlHeader, n, cmpIntNode)
+			model[k] = n
+		}
+
+		// Never a false negative
+
+		probe := rnd.Intn(2000)
+		if n, ok := model[probe]; ok {
+			assert.Same(t, n, tree.Lookup(probe, counted))
+		} else {
+			assert.Nil(t, tree.Lookup(probe, counted))
+		}
+	}
+
+	// Misses mostly skip the descent
+
+	calls = 0
+	for k := 10000; k < 11000; k++ {
+		assert.Nil(t, tree.Lookup(k, counted))
+		assert.Equal(t, "none", tree.LookupOr(k, counted, "none"))
+	}
+	assert.True(t, calls < 1000, "%d comparator calls", calls)
+}
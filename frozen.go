@@ -0,0 +1,163 @@
+package avl
+
+import (
+	"context"
+	"sort"
+)
+
+//
+// A frozen tree is a read-only, array-backed copy of an AvlTree for
+// trees that are built once and then only read.  Lookups are binary
+// searches over a sorted slice of owners, which is kinder to the cache
+// than chasing node pointers, and there is no per-operation bookkeeping.
+//
+// Freezing takes the nodes out of the tree, and thawing links the same
+// nodes back into a new tree, so no owner is ever copied or reallocated.
+//
+
+type AvlFrozen struct {
+	owners []interface{}
+	nodes  []*AvlNode
+}
+
+// Moves the contents of the tree into a new frozen tree, leaving tree
+// empty.  Observers are not notified.  O(n)
+
+func (tree *AvlTree) Freeze() *AvlFrozen {
+	frozen, _ := tree.FreezeContext(context.Background())
+	return frozen
+}
+
+// Like Freeze, but gives up and returns ctx.Err() once ctx is done,
+// leaving the tree as it was
+
+func (tree *AvlTree) FreezeContext(ctx context.Context) (*AvlFrozen, error) {
+
+	frozen := &AvlFrozen{
+		owners: make([]interface{}, 0, tree.size),
+		nodes:  make([]*AvlNode, 0, tree.size),
+	}
+
+	var err error
+
+	tree.labeled(ctx, "freeze", func(ctx context.Context) {
+		t := &avlContextTicker{ctx: ctx}
+		for n := tree.first; n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+			if err = t.tick(); err != nil {
+				return
+			}
+			frozen.owners = append(frozen.owners, n.owner)
+			frozen.nodes = append(frozen.nodes, n)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tree.reset(nil)
+
+	return frozen, nil
+}
+
+// Links the nodes back into a new, perfectly balanced tree, leaving the
+// frozen tree empty.  O(n)
+
+func (frozen *AvlFrozen) Thaw() *AvlTree {
+	tree, _ := frozen.ThawContext(context.Background())
+	return tree
+}
+
+// Like Thaw, but gives up and returns ctx.Err() once ctx is done,
+// leaving the frozen tree as it was
+
+func (frozen *AvlFrozen) ThawContext(ctx context.Context) (*AvlTree, error) {
+
+	root, err := AvlTreeBuildSortedContext(ctx, len(frozen.nodes),
+		func(i int) (*AvlNode, interface{}) {
+			return frozen.nodes[i], frozen.owners[i]
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	tree := &AvlTree{}
+	tree.reset(root)
+
+	frozen.owners = nil
+	frozen.nodes = nil
+
+	return tree, nil
+}
+
+// Returns the number of owners
+
+func (frozen *AvlFrozen) Len() int {
+	return len(frozen.owners)
+}
+
+// Returns the i'th owner in order
+
+func (frozen *AvlFrozen) At(i int) interface{} {
+	return frozen.owners[i]
+}
+
+// Returns the least owner, or nil if empty
+
+func (frozen *AvlFrozen) First() interface{} {
+	if len(frozen.owners) > 0 {
+		return frozen.owners[0]
+	} else {
+		return nil
+	}
+}
+
+// Returns the greatest owner, or nil if empty
+
+func (frozen *AvlFrozen) Last() interface{} {
+	if len(frozen.owners) > 0 {
+		return frozen.owners[len(frozen.owners)-1]
+	} else {
+		return nil
+	}
+}
+
+// Returns the index of the first owner at or after key
+
+func (frozen *AvlFrozen) search(key interface{}, cmp CmpFuncKey) int {
+	return sort.Search(len(frozen.owners), func(i int) bool {
+		return cmp(key, frozen.owners[i]) <= 0
+	})
+}
+
+// Look up a specified key.  nil if not present
+
+func (frozen *AvlFrozen) Lookup(key interface{}, cmp CmpFuncKey) interface{} {
+
+	i := frozen.search(key, cmp)
+	if i < len(frozen.owners) && cmp(key, frozen.owners[i]) == 0 {
+		return frozen.owners[i]
+	} else {
+		return nil
+	}
+}
+
+// Calls fn, in order, with each owner whose key is in [lo, hi), until fn
+// returns false; see AvlTreeRange
+
+func (frozen *AvlFrozen) Range(lo, hi interface{}, cmp CmpFuncKey,
+	fn func(owner interface{}) bool) {
+
+	i := 0
+	if lo != nil {
+		i = frozen.search(lo, cmp)
+	}
+
+	for ; i < len(frozen.owners); i++ {
+		if hi != nil && cmp(hi, frozen.owners[i]) <= 0 {
+			break
+		}
+		if !fn(frozen.owners[i]) {
+			break
+		}
+	}
+}
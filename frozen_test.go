@@ -0,0 +1,82 @@
+package avl
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAvlFrozen(t *testing.T) {
+
+	var tree AvlTree
+
+	ns := newIntNodes(5, 1, 9, 3, 7)
+	for _, n := range ns {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	frozen := tree.Freeze()
+	assert.Equal(t, 0, tree.Len())
+	assert.Nil(t, tree.Root())
+
+	assert.Equal(t, 5, frozen.Len())
+	assert.Equal(t, ns[1], frozen.First())
+	assert.Equal(t, ns[2], frozen.Last())
+	assert.Equal(t, ns[0], frozen.At(2))
+	assert.Equal(t, ns[3], frozen.Lookup(3, cmpIntKey))
+	assert.Nil(t, frozen.Lookup(4, cmpIntKey))
+
+	// Same ranges as TestAvlTreeRange
+
+	assert.Equal(t, []int{1, 3, 5, 7, 9}, collectRange(frozen.Range, nil, nil))
+	assert.Equal(t, []int{3, 5}, collectRange(frozen.Range, 3, 7))
+	assert.Equal(t, []int{5, 7}, collectRange(frozen.Range, 4, 8))
+	assert.Equal(t, []int{7, 9}, collectRange(frozen.Range, 6, nil))
+	assert.Empty(t, collectRange(frozen.Range, 10, nil))
+
+	thawed := frozen.Thaw()
+	assert.Equal(t, 0, frozen.Len())
+	assert.Equal(t, 5, thawed.Len())
+	assert.Equal(t, ns[1], thawed.First())
+	assert.Equal(t, ns[2], thawed.Last())
+	assert.Equal(t, ns[4], thawed.Lookup(7, cmpIntKey))
+	assert.NoError(t, thawed.Validate(cmpIntNode))
+
+	empty := (&AvlTree{}).Freeze()
+	assert.Nil(t, empty.First())
+	assert.Nil(t, empty.Last())
+	assert.Equal(t, 0, empty.Thaw().Len())
+}
+
+func TestAvlFrozenContext(t *testing.T) {
+
+	var tree AvlTree
+
+	ns := newIntNodes(make([]int, 3000)...)
+	for i, n := range ns {
+		n.key = i
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	// Cancelled, both leave what they were given alone
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := tree.FreezeContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 3000, tree.Len())
+
+	frozen, err := tree.FreezeContext(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, tree.Len())
+
+	_, err = frozen.ThawContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 3000, frozen.Len())
+
+	thawed, err := frozen.ThawContext(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3000, thawed.Len())
+	assert.NoError(t, thawed.Validate(cmpIntNode))
+}
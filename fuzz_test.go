@@ -0,0 +1,128 @@
+package avl
+
+import (
+	"sort"
+	"testing"
+)
+
+//
+// Differential fuzzing: every input is decoded into a sequence of
+// operations that is applied both to an AvlTree and to a sorted slice,
+// and after every step the two must agree and the tree must pass
+// Validate.
+//
+// Each operation takes two bytes: the first selects the operation, the
+// second the key.  Keys are reduced to a small range so that removals
+// and duplicate insertions hit keys that are actually present.
+//
+
+const fuzzKeyRange = 64
+
+type fuzzModel []int
+
+func (m fuzzModel) find(key int) (int, bool) {
+	i := sort.SearchInts(m, key)
+	return i, i < len(m) && m[i] == key
+}
+
+func fuzzCheck(t *testing.T, step int, tree *AvlTree, model fuzzModel) {
+
+	t.Helper()
+
+	if err := tree.Validate(cmpIntNode); err != nil {
+		t.Fatalf("step %d: %v", step, err)
+	}
+	if tree.Len() != len(model) {
+		t.Fatalf("step %d: tree has %d nodes, model %d", step, tree.Len(),
+			len(model))
+	}
+
+	i := 0
+	for p := AvlTreeFirstInOrder(tree.Root()); p != nil; p = AvlTreeNextInOrder(&p.(*intNode).avlHeader) {
+		if i >= len(model) || p.(*intNode).key != model[i] {
+			t.Fatalf("step %d: in-order walk disagrees with model at %d",
+				step, i)
+		}
+		i++
+	}
+
+	i = len(model) - 1
+	for p := AvlTreeLastInOrder(tree.Root()); p != nil; p = AvlTreePrevInOrder(&p.(*intNode).avlHeader) {
+		if i < 0 || p.(*intNode).key != model[i] {
+			t.Fatalf("step %d: reverse walk disagrees with model at %d",
+				step, i)
+		}
+		i--
+	}
+}
+
+func fuzzApply(t *testing.T, ops []byte) {
+
+	var tree AvlTree
+	var model fuzzModel
+
+	for step := 0; step+1 < len(ops); step += 2 {
+		key := int(ops[step+1]) % fuzzKeyRange
+		i, present := model.find(key)
+
+		switch ops[step] % 4 {
+		case 0:
+			n := &intNode{key: key}
+			existing := tree.Insert(&n.avlHeader, n, cmpIntNode)
+			if present != (existing != nil) {
+				t.Fatalf("step %d: insert of %d returned %v", step, key,
+					existing)
+			}
+			if !present {
+				model = append(model[:i], append([]int{key}, model[i:]...)...)
+			}
+
+		case 1:
+			p := tree.Lookup(key, cmpIntKey)
+			if present != (p != nil) {
+				t.Fatalf("step %d: lookup of %d returned %v", step, key, p)
+			}
+			if p != nil {
+				tree.Remove(&p.(*intNode).avlHeader)
+				model = append(model[:i], model[i+1:]...)
+			}
+
+		case 2:
+			p := tree.Lookup(key, cmpIntKey)
+			if present != (p != nil) {
+				t.Fatalf("step %d: lookup of %d returned %v", step, key, p)
+			}
+
+		case 3:
+			n := &intNode{key: key}
+			old := tree.InsertOrReplace(&n.avlHeader, n, cmpIntNode)
+			if present != (old != nil) {
+				t.Fatalf("step %d: replace of %d returned %v", step, key, old)
+			}
+			if !present {
+				model = append(model[:i], append([]int{key}, model[i:]...)...)
+			}
+		}
+
+		fuzzCheck(t, step, &tree, model)
+	}
+}
+
+func FuzzAvlTree(f *testing.F) {
+
+	// Ascending and descending runs force single rotations, zig-zags
+	// force double ones, and removing from a full tree of 7 walks the
+	// balance == 0 path of avlHandleSubtreeShrink
+
+	f.Add([]byte{0, 1, 0, 2, 0, 3, 0, 4, 0, 5, 0, 6, 0, 7})
+	f.Add([]byte{0, 7, 0, 6, 0, 5, 0, 4, 0, 3, 0, 2, 0, 1})
+	f.Add([]byte{0, 10, 0, 5, 0, 7, 0, 20, 0, 15, 0, 17})
+	f.Add([]byte{0, 4, 0, 2, 0, 6, 0, 1, 0, 3, 0, 5, 0, 7,
+		1, 7, 1, 6, 1, 1, 1, 4, 1, 2})
+	f.Add([]byte{0, 8, 0, 4, 0, 12, 0, 2, 0, 6, 0, 10, 0, 14, 0, 5, 0, 7,
+		1, 14, 1, 10, 1, 12, 3, 4, 2, 4, 1, 8})
+
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		fuzzApply(t, ops)
+	})
+}
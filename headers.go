@@ -0,0 +1,145 @@
+package avl
+
+import "fmt"
+
+//
+// Owners with several headers.  An owner that lives in several trees at
+// once embeds one AvlNode per tree, and nothing stops code from passing
+// the wrong one: linking the "by name" header into the "by id" tree
+// corrupts both trees without a word.  An AvlHeaders registry names
+// each header with its tree, so code can ask which trees an owner is in,
+// remove it from all of them at once, and check for headers used with
+// the wrong tree or owner.
+//
+
+type avlHeaderEntry struct {
+	name   string
+	tree   *AvlTree
+	header func(owner interface{}) *AvlNode
+}
+
+type AvlHeaders struct {
+	entries []avlHeaderEntry
+}
+
+func (h *AvlHeaders) entry(name string) *avlHeaderEntry {
+	for i := range h.entries {
+		if h.entries[i].name == name {
+			return &h.entries[i]
+		}
+	}
+	panic(fmt.Sprintf("avl: no header %q registered", name))
+}
+
+// Registers the header named name, which header returns for an owner,
+// as the one owners use to link into tree.  Names must be distinct
+
+func (h *AvlHeaders) Register(name string, tree *AvlTree,
+	header func(owner interface{}) *AvlNode) {
+
+	for _, e := range h.entries {
+		if e.name == name {
+			panic(fmt.Sprintf("avl: header %q registered twice", name))
+		}
+	}
+
+	h.entries = append(h.entries, avlHeaderEntry{name, tree, header})
+}
+
+// Returns the named header of owner
+
+func (h *AvlHeaders) Header(owner interface{}, name string) *AvlNode {
+	return h.entry(name).header(owner)
+}
+
+// True if owner's named header is linked into its tree.  O(log n)
+
+func (h *AvlHeaders) Linked(owner interface{}, name string) bool {
+	e := h.entry(name)
+	return avlTreeContains(e.tree.root, e.header(owner))
+}
+
+// Returns the names of the headers through which owner is linked into
+// their trees, in registration order
+
+func (h *AvlHeaders) LinkedIn(owner interface{}) []string {
+
+	var names []string
+
+	for _, e := range h.entries {
+		if avlTreeContains(e.tree.root, e.header(owner)) {
+			names = append(names, e.name)
+		}
+	}
+
+	return names
+}
+
+// Removes owner from every tree it is linked into, and returns how many
+// that was
+
+func (h *AvlHeaders) RemoveAll(owner interface{}) int {
+
+	removed := 0
+
+	for _, e := range h.entries {
+		if node := e.header(owner); avlTreeContains(e.tree.root, node) {
+			e.tree.Remove(node)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// Debugging check of owner's headers: each must either be unlinked or
+// linked into its own tree, with owner as its owner.  Returns an error
+// wrapping ErrWrongHeader for the first that isn't.  O(log n) per header
+
+func (h *AvlHeaders) Check(owner interface{}) error {
+
+	for _, e := range h.entries {
+		node := e.header(owner)
+
+		if avlTreeContains(e.tree.root, node) {
+			if node.owner != owner {
+				return fmt.Errorf("%w: header %q of %v is linked with owner %v",
+					ErrWrongHeader, e.name, owner, node.owner)
+			}
+			continue
+		}
+
+		// Linked, but not into its own tree.  A lone root has no links
+		// to give it away, so look for it in the other trees too
+
+		linked := !avlTreeNodeIsUnlinked(node) &&
+			(avlGetParent(node) != nil || node.left != nil || node.right != nil)
+		for _, other := range h.entries {
+			linked = linked || other.tree.root == node
+		}
+		if linked {
+			return fmt.Errorf("%w: header %q of %v is linked into another tree",
+				ErrWrongHeader, e.name, owner)
+		}
+	}
+
+	return nil
+}
+
+// Debugging check of the named header's tree: every node in it must be
+// its owner's header of that name, so none was linked through another
+// header.  O(n)
+
+func (h *AvlHeaders) CheckTree(name string) error {
+
+	e := h.entry(name)
+
+	for n := e.tree.first; n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+		if e.header(n.owner) != n {
+			return fmt.Errorf("%w: tree %q holds a node of %v that is not its %q header",
+				ErrWrongHeader, name, n.owner, name)
+		}
+	}
+
+	return nil
+}
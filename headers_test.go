@@ -0,0 +1,50 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAvlHeaders(t *testing.T) {
+
+	var byId, byName AvlTree
+	var h AvlHeaders
+
+	cmpId := func(a, b interface{}) int { return a.(*userNode).id - b.(*userNode).id }
+	cmpName := func(a, b interface{}) int {
+		return AvlCompareValues(a.(*userNode).name, b.(*userNode).name)
+	}
+
+	h.Register("id", &byId, func(owner interface{}) *AvlNode { return &owner.(*userNode).byId })
+	h.Register("name", &byName, func(owner interface{}) *AvlNode { return &owner.(*userNode).byName })
+	assert.Panics(t, func() { h.Register("id", &byId, nil) })
+
+	ann := &userNode{id: 1, name: "ann"}
+	bob := &userNode{id: 2, name: "bob"}
+
+	byId.Insert(h.Header(ann, "id"), ann, cmpId)
+	byName.Insert(h.Header(ann, "name"), ann, cmpName)
+	byId.Insert(h.Header(bob, "id"), bob, cmpId)
+
+	assert.True(t, h.Linked(ann, "name"))
+	assert.False(t, h.Linked(bob, "name"))
+	assert.Equal(t, []string{"id", "name"}, h.LinkedIn(ann))
+	assert.Equal(t, []string{"id"}, h.LinkedIn(bob))
+	assert.NoError(t, h.Check(ann))
+	assert.NoError(t, h.CheckTree("id"))
+
+	assert.Equal(t, 2, h.RemoveAll(ann))
+	assert.Empty(t, h.LinkedIn(ann))
+	assert.Equal(t, 0, byName.Len())
+	assert.NoError(t, h.Check(ann))
+
+	// Linking bob's id header into the name tree is caught from both
+	// sides
+
+	byId.Remove(&bob.byId)
+	byName.Insert(&bob.byId, bob, cmpName)
+	assert.ErrorIs(t, h.Check(bob), ErrWrongHeader)
+	assert.ErrorIs(t, h.CheckTree("name"), ErrWrongHeader)
+
+	assert.Panics(t, func() { h.Linked(bob, "nope") })
+}
@@ -0,0 +1,142 @@
+package avl
+
+import "iter"
+
+//
+// Insertion order.  A tree made WithInsertionOrder also threads its
+// owners on a doubly linked list in the order they were inserted, oldest
+// first, through an AvlInsertionLinks each owner embeds, so they can be
+// visited in the order they arrived as well as in key order.  The links
+// are intrusive, like the AvlNode: the tree finds an owner's links with
+// the function given to the option, and allocates nothing.
+//
+// An owner joins the newest end of the list whenever it is inserted,
+// including by InsertOrReplace and when an undo puts it back.  The
+// operations that replace the tree's contents wholesale (building,
+// thawing, pruning, swapping and the like) have no insertion order to go
+// on, and thread the owners in key order.
+//
+
+// Links between owners in insertion order, to be embedded in the owner
+
+type AvlInsertionLinks struct {
+	older, newer interface{}
+}
+
+type avlInsertionOrder struct {
+	links          func(owner interface{}) *AvlInsertionLinks
+	oldest, newest interface{}
+}
+
+// Threads the tree's owners in insertion order, finding each owner's
+// links with links
+
+func WithInsertionOrder(links func(owner interface{}) *AvlInsertionLinks) AvlTreeOption {
+	return func(tree *AvlTree) {
+		tree.order = &avlInsertionOrder{links: links}
+	}
+}
+
+// Adds owner at the newest end
+
+func (o *avlInsertionOrder) push(owner interface{}) {
+
+	l := o.links(owner)
+	l.older = o.newest
+	l.newer = nil
+
+	if o.newest != nil {
+		o.links(o.newest).newer = owner
+	} else {
+		o.oldest = owner
+	}
+	o.newest = owner
+}
+
+// Takes owner off the list
+
+func (o *avlInsertionOrder) drop(owner interface{}) {
+
+	l := o.links(owner)
+
+	if l.older != nil {
+		o.links(l.older).newer = l.newer
+	} else {
+		o.oldest = l.newer
+	}
+	if l.newer != nil {
+		o.links(l.newer).older = l.older
+	} else {
+		o.newest = l.older
+	}
+
+	l.older = nil
+	l.newer = nil
+}
+
+// Threads the owners of the tree rooted at root in key order
+
+func (o *avlInsertionOrder) rethread(root *AvlNode) {
+
+	o.oldest = nil
+	o.newest = nil
+
+	n := avlTreeFirstOrLastInOrder(root, -1)
+	for ; n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+		o.push(n.owner)
+	}
+}
+
+// Returns the owner inserted longest ago, or nil if the tree is empty or
+// does not keep insertion order
+
+func (tree *AvlTree) FirstInserted() interface{} {
+	if tree.order == nil {
+		return nil
+	}
+	return tree.order.oldest
+}
+
+// Returns the owner inserted most recently, or nil if the tree is empty
+// or does not keep insertion order
+
+func (tree *AvlTree) LastInserted() interface{} {
+	if tree.order == nil {
+		return nil
+	}
+	return tree.order.newest
+}
+
+// Returns the owner inserted after owner, which must be in the tree, or
+// nil if it is the newest or the tree does not keep insertion order
+
+func (tree *AvlTree) NextInserted(owner interface{}) interface{} {
+	if tree.order == nil {
+		return nil
+	}
+	return tree.order.links(owner).newer
+}
+
+// Returns the owner inserted before owner, which must be in the tree, or
+// nil if it is the oldest or the tree does not keep insertion order
+
+func (tree *AvlTree) PrevInserted(owner interface{}) interface{} {
+	if tree.order == nil {
+		return nil
+	}
+	return tree.order.links(owner).older
+}
+
+// Yields the owners in insertion order, oldest first.  The tree must not
+// be modified while the sequence is being iterated
+
+func (tree *AvlTree) Inserted() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		owner := tree.FirstInserted()
+		for ; owner != nil; owner = tree.NextInserted(owner) {
+			if !yield(owner) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type auditNode struct {
+	avlHeader AvlNode
+	order     AvlInsertionLinks
+	key       int
+}
+
+func cmpAuditNode(node1 interface{}, node2 interface{}) int {
+	return node1.(*auditNode).key - node2.(*auditNode).key
+}
+
+func auditLinks(owner interface{}) *AvlInsertionLinks {
+	return &owner.(*auditNode).order
+}
+
+func insertedKeys(tree *AvlTree) []int {
+	var keys []int
+	for owner := range tree.Inserted() {
+		keys = append(keys, owner.(*auditNode).key)
+	}
+	return keys
+}
+
+func TestAvlTreeInsertionOrder(t *testing.T) {
+
+	tree := NewAvlTree(WithInsertionOrder(auditLinks))
+
+	assert.Nil(t, tree.FirstInserted())
+
+	ns := make([]*auditNode, 6)
+	for i, k := range []int{5, 2, 8, 1, 9, 5} {
+		ns[i] = &auditNode{key: k}
+	}
+	for _, n := range ns[:5] {
+		tree.Insert(&n.avlHeader, n, cmpAuditNode)
+	}
+	assert.Equal(t, []int{5, 2, 8, 1, 9}, insertedKeys(tree))
+	assert.Same(t, ns[0], tree.FirstInserted())
+	assert.Same(t, ns[4], tree.LastInserted())
+	assert.Same(t, ns[2], tree.NextInserted(ns[1]))
+	assert.Same(t, ns[0], tree.PrevInserted(ns[1]))
+
+	// Removal unthreads, and replacement goes to the newest end
+
+	tree.Remove(&ns[2].avlHeader)
+	tree.InsertOrReplace(&ns[5].avlHeader, ns[5], cmpAuditNode)
+	assert.Equal(t, []int{2, 1, 9, 5}, insertedKeys(tree))
+	assert.Same(t, ns[5], tree.LastInserted())
+	assert.Nil(t, tree.PrevInserted(ns[1]))
+
+	tree.PopMax()
+	assert.Equal(t, []int{2, 1, 5}, insertedKeys(tree))
+
+	// Wholesale replacement falls back to key order
+
+	var other AvlTree
+	AvlTreeSwap(tree, &other)
+	assert.Nil(t, tree.FirstInserted())
+	AvlTreeSwap(tree, &other)
+	assert.Equal(t, []int{1, 2, 5}, insertedKeys(tree))
+
+	var plain AvlTree
+	plain.Insert(&ns[2].avlHeader, ns[2], cmpAuditNode)
+	assert.Nil(t, plain.FirstInserted())
+	assert.Nil(t, plain.NextInserted(ns[2]))
+	assert.Nil(t, plain.PrevInserted(ns[2]))
+}
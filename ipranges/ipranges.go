@@ -0,0 +1,194 @@
+//
+// Copyright as per Creative Commons Legal Code license, which can
+// be found in the file COPYING
+//
+
+/*
+
+Package ipranges maps non-overlapping ranges of IP addresses, given as
+CIDR prefixes or as first and last addresses, to values, and finds the
+range covering an address in O(log n), as firewall and geo-IP tables
+need:
+
+	var table ipranges.Table[string]
+	table.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8"), "internal")
+	r, v, ok := table.LookupIP(netip.MustParseAddr("10.1.2.3"))
+
+The ranges are kept in an AVL tree from the avl package ordered by their
+first address; since they don't overlap, the range covering an address
+can only be the last one starting at or before it.  Insert refuses a
+range that overlaps one already in the table.  IPv4 addresses mapped
+into IPv6 are treated as IPv4.  A Table is not safe for concurrent use.
+
+*/
+
+package ipranges
+
+import (
+	"errors"
+	"fmt"
+	"github.com/danswartzendruber/avl"
+	"iter"
+	"net/netip"
+)
+
+var (
+	// The range overlaps one already in the table
+	ErrOverlap = errors.New("ipranges: range overlaps an existing range")
+
+	// The range's first address is after its last, or they are not of
+	// the same family
+	ErrBadRange = errors.New("ipranges: invalid range")
+)
+
+// A range of addresses, first and last inclusive
+
+type Range struct {
+	First, Last netip.Addr
+}
+
+// Returns the range of addresses in p
+
+func PrefixRange(p netip.Prefix) Range {
+
+	p = p.Masked()
+	first := p.Addr()
+	bits := first.BitLen() - p.Bits()
+	if first.Is4In6() && bits <= 32 {
+		first = first.Unmap()
+	}
+	last := first
+
+	b := last.AsSlice()
+	for i := len(b) - 1; bits > 0; i-- {
+		n := min(bits, 8)
+		b[i] |= byte(1<<n - 1)
+		bits -= n
+	}
+	last, _ = netip.AddrFromSlice(b)
+
+	return Range{first, last}
+}
+
+// True if the range holds a
+
+func (r Range) Contains(a netip.Addr) bool {
+	a = a.Unmap()
+	return r.First.Compare(a) <= 0 && a.Compare(r.Last) <= 0
+}
+
+func (r Range) String() string {
+	return r.First.String() + "-" + r.Last.String()
+}
+
+func (r Range) valid() bool {
+	return r.First.IsValid() && r.Last.IsValid() &&
+		r.First.Is4() == r.Last.Is4() && r.First.Compare(r.Last) <= 0
+}
+
+type entry[V any] struct {
+	header avl.AvlNode
+	r      Range
+	v      V
+}
+
+// A table of ranges.  The zero value is an empty table ready to use
+
+type Table[V any] struct {
+	tree avl.AvlTree
+}
+
+func cmpEntries[V any](a, b interface{}) int {
+	return a.(*entry[V]).r.First.Compare(b.(*entry[V]).r.First)
+}
+
+func cmpAddr[V any](key interface{}, node interface{}) int {
+	return key.(netip.Addr).Compare(node.(*entry[V]).r.First)
+}
+
+// Returns the number of ranges
+
+func (t *Table[V]) Len() int {
+	return t.tree.Len()
+}
+
+// Adds r, mapped to v.  Returns an error wrapping ErrBadRange, or
+// ErrOverlap and naming the range it overlaps, if r can't be added
+
+func (t *Table[V]) Insert(r Range, v V) error {
+
+	r = Range{r.First.Unmap(), r.Last.Unmap()}
+	if !r.valid() {
+		return fmt.Errorf("%w: %v", ErrBadRange, r)
+	}
+
+	if e := t.tree.Floor(r.First, cmpAddr[V]); e != nil {
+		if other := e.(*entry[V]).r; other.Last.Compare(r.First) >= 0 {
+			return fmt.Errorf("%w: %v overlaps %v", ErrOverlap, r, other)
+		}
+	}
+	if e := t.tree.Ceiling(r.First, cmpAddr[V]); e != nil {
+		if other := e.(*entry[V]).r; other.First.Compare(r.Last) <= 0 {
+			return fmt.Errorf("%w: %v overlaps %v", ErrOverlap, r, other)
+		}
+	}
+
+	e := &entry[V]{r: r, v: v}
+	t.tree.Insert(&e.header, e, cmpEntries[V])
+
+	return nil
+}
+
+// Adds the range of addresses in p, mapped to v; see Insert
+
+func (t *Table[V]) InsertPrefix(p netip.Prefix, v V) error {
+	if !p.IsValid() {
+		return fmt.Errorf("%w: %v", ErrBadRange, p)
+	}
+	return t.Insert(PrefixRange(p), v)
+}
+
+// Returns the range covering a, and its value, or false if there is none
+
+func (t *Table[V]) LookupIP(a netip.Addr) (Range, V, bool) {
+
+	a = a.Unmap()
+
+	if e := t.tree.Floor(a, cmpAddr[V]); e != nil {
+		if e := e.(*entry[V]); e.r.Contains(a) {
+			return e.r, e.v, true
+		}
+	}
+
+	var v V
+	return Range{}, v, false
+}
+
+// Removes the range r, which must match a range in the table exactly.
+// Returns false if there is no such range
+
+func (t *Table[V]) Remove(r Range) bool {
+
+	r = Range{r.First.Unmap(), r.Last.Unmap()}
+
+	e, _ := t.tree.Lookup(r.First, cmpAddr[V]).(*entry[V])
+	if e == nil || e.r != r {
+		return false
+	}
+
+	t.tree.Remove(&e.header)
+
+	return true
+}
+
+// Yields the ranges and their values, in address order.  The table must
+// not be modified while the sequence is being iterated
+
+func (t *Table[V]) All() iter.Seq2[Range, V] {
+	return func(yield func(Range, V) bool) {
+		t.tree.Range(nil, nil, cmpAddr[V], func(owner interface{}) bool {
+			e := owner.(*entry[V])
+			return yield(e.r, e.v)
+		})
+	}
+}
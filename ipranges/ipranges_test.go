@@ -0,0 +1,73 @@
+package ipranges
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/netip"
+	"testing"
+)
+
+func addr(s string) netip.Addr {
+	return netip.MustParseAddr(s)
+}
+
+func TestPrefixRange(t *testing.T) {
+
+	r := PrefixRange(netip.MustParsePrefix("10.1.2.3/16"))
+	assert.Equal(t, Range{addr("10.1.0.0"), addr("10.1.255.255")}, r)
+
+	r = PrefixRange(netip.MustParsePrefix("192.168.1.7/29"))
+	assert.Equal(t, Range{addr("192.168.1.0"), addr("192.168.1.7")}, r)
+
+	r = PrefixRange(netip.MustParsePrefix("2001:db8::/32"))
+	assert.Equal(t, addr("2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"), r.Last)
+
+	r = PrefixRange(netip.MustParsePrefix("::ffff:10.0.0.0/104"))
+	assert.Equal(t, Range{addr("10.0.0.0"), addr("10.255.255.255")}, r)
+
+	assert.True(t, r.Contains(addr("::ffff:10.9.9.9")))
+	assert.False(t, r.Contains(addr("11.0.0.0")))
+}
+
+func TestTable(t *testing.T) {
+
+	var table Table[string]
+
+	assert.NoError(t, table.InsertPrefix(netip.MustParsePrefix("10.0.0.0/8"), "ten"))
+	assert.NoError(t, table.Insert(Range{addr("192.168.1.10"), addr("192.168.1.20")}, "lan"))
+	assert.NoError(t, table.InsertPrefix(netip.MustParsePrefix("2001:db8::/32"), "doc"))
+
+	r, v, ok := table.LookupIP(addr("10.200.1.1"))
+	assert.True(t, ok)
+	assert.Equal(t, "ten", v)
+	assert.Equal(t, addr("10.255.255.255"), r.Last)
+
+	_, v, _ = table.LookupIP(addr("192.168.1.20"))
+	assert.Equal(t, "lan", v)
+	_, _, ok = table.LookupIP(addr("192.168.1.21"))
+	assert.False(t, ok)
+	_, _, ok = table.LookupIP(addr("9.255.255.255"))
+	assert.False(t, ok)
+	_, v, _ = table.LookupIP(addr("2001:db8::1"))
+	assert.Equal(t, "doc", v)
+
+	// Overlaps on either side, and bad ranges, are refused
+
+	assert.ErrorIs(t, table.Insert(Range{addr("192.168.1.0"), addr("192.168.1.10")}, "x"), ErrOverlap)
+	assert.ErrorIs(t, table.Insert(Range{addr("192.168.1.15"), addr("192.168.1.30")}, "x"), ErrOverlap)
+	assert.ErrorIs(t, table.InsertPrefix(netip.MustParsePrefix("10.5.0.0/16"), "x"), ErrOverlap)
+	assert.ErrorIs(t, table.Insert(Range{addr("1.0.0.2"), addr("1.0.0.1")}, "x"), ErrBadRange)
+	assert.ErrorIs(t, table.Insert(Range{addr("1.0.0.2"), addr("::2")}, "x"), ErrBadRange)
+	assert.NoError(t, table.Insert(Range{addr("192.168.1.0"), addr("192.168.1.9")}, "low"))
+	assert.Equal(t, 4, table.Len())
+
+	var names []string
+	for _, v := range table.All() {
+		names = append(names, v)
+	}
+	assert.Equal(t, []string{"ten", "low", "lan", "doc"}, names)
+
+	assert.False(t, table.Remove(Range{addr("10.0.0.0"), addr("10.0.0.255")}))
+	assert.True(t, table.Remove(PrefixRange(netip.MustParsePrefix("10.0.0.0/8"))))
+	_, _, ok = table.LookupIP(addr("10.0.0.1"))
+	assert.False(t, ok)
+}
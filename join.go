@@ -0,0 +1,139 @@
+package avl
+
+//
+// Joining: given two trees L and R and a node k such that every key in
+// L is less than k's and every key in R greater, link them into one
+// valid AVL tree.  The node goes in at the point down the near edge of
+// the taller tree where the heights of the two trees meet, and from
+// there on up this is exactly the rebalancing done after an insertion,
+// so the same avlHandleSubtreeGrowth does the work.  This is the
+// primitive beneath pruning and splitting.
+//
+
+// Returns the height of the subtree rooted at node, in O(log n): the
+// balance factor tells which child is the taller
+
+func avlHeight(node *AvlNode) int {
+
+	h := 0
+
+	for ; node != nil; h++ {
+		if avlGetBalanceFactor(node) < 0 {
+			node = node.left
+		} else {
+			node = node.right
+		}
+	}
+
+	return h
+}
+
+// Rebalance the tree after the subtree rooted at node has increased in
+// height by 1, node itself being balanced in its new position
+
+func avlTreeRebalanceAfterGrowth(root **AvlNode, node *AvlNode) {
+
+	var rotations uint64
+
+	for done := false; !done; {
+		parent := avlGetParent(node)
+		if parent == nil {
+			return
+		}
+
+		if node == parent.left {
+			done = avlHandleSubtreeGrowth(root, node, parent, -1, &rotations)
+		} else {
+			done = avlHandleSubtreeGrowth(root, node, parent, +1, &rotations)
+		}
+
+		node = parent
+	}
+}
+
+// Joins the tree l of height lh, the node k, and the tree r of height rh
+// into one tree, returning its root.  k must already hold its owner,
+// but its links are ignored; l and r may be nil
+
+func avlJoin(l *AvlNode, lh int, k *AvlNode, r *AvlNode, rh int) *AvlNode {
+
+	if lh-rh > 1 {
+		return avlJoinInto(l, lh, k, r, rh, +1)
+	} else if rh-lh > 1 {
+		return avlJoinInto(r, rh, k, l, lh, -1)
+	}
+
+	// Heights are close enough for k to be the root
+
+	k.left = l
+	k.right = r
+	avlSetParentBalance(k, nil, rh-lh)
+	if l != nil {
+		avlSetParent(l, k)
+	}
+	if r != nil {
+		avlSetParent(r, k)
+	}
+	avlUpdateSize(k)
+
+	return k
+}
+
+// Joins the short tree s (height sh) to the tall tree t (height th) with
+// k between them.  sign > 0 if s goes to the right of t, sign < 0 if to
+// the left
+
+func avlJoinInto(t *AvlNode, th int, k *AvlNode, s *AvlNode, sh int,
+	sign int) *AvlNode {
+
+	// Walk down the near edge of t to the first subtree that is no more
+	// than one taller than s
+
+	var p *AvlNode
+
+	c := t
+	h := th
+	for h > sh+1 {
+		if sign*avlGetBalanceFactor(c) >= 0 {
+			h--
+		} else {
+			h -= 2
+		}
+		p = c
+		c = avlGetChild(c, +sign)
+	}
+
+	//
+	// sign > 0:
+	//
+	//         t                t
+	//          \                \
+	//           p                p
+	//            \       =>       \
+	//             c?               k
+	//                             / \
+	//                            c?  s?
+	//
+
+	avlSetChild(k, -sign, c)
+	avlSetChild(k, +sign, s)
+	if c != nil {
+		avlSetParent(c, k)
+	}
+	if s != nil {
+		avlSetParent(s, k)
+	}
+	avlSetParentBalance(k, p, sign*(sh-h))
+	avlUpdateSize(k)
+
+	avlSetChild(p, +sign, k)
+	avlAdjustSizes(p, 1+avlGetSize(s))
+
+	// k is one taller than c was.  Rebalance from there, exactly as if k
+	// had grown by an insertion
+
+	root := t
+	avlTreeRebalanceAfterGrowth(&root, k)
+
+	return root
+}
@@ -0,0 +1,137 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func inOrderKeys(root *AvlNode) []int {
+
+	var keys []int
+
+	for p := AvlTreeFirstInOrder(root); p != nil; p = AvlTreeNextInOrder(&p.(*intNode).avlHeader) {
+		keys = append(keys, p.(*intNode).key)
+	}
+
+	return keys
+}
+
+func TestAvlJoin(t *testing.T) {
+
+	// Every combination of heights up to a difference of 10 or so
+
+	for nl := 0; nl < 40; nl += 3 {
+		for nr := 0; nr < 600; nr += 37 {
+			var want []int
+
+			l, _ := buildIntTree()
+			for i := 0; i < nl; i++ {
+				n := &intNode{key: i}
+				AvlTreeInsert(&l, &n.avlHeader, n, cmpIntNode)
+				want = append(want, i)
+			}
+			k := &intNode{key: nl}
+			k.avlHeader.owner = k
+			want = append(want, nl)
+			var r *AvlNode
+			for i := nl + 1; i <= nl+nr; i++ {
+				n := &intNode{key: i}
+				AvlTreeInsert(&r, &n.avlHeader, n, cmpIntNode)
+				want = append(want, i)
+			}
+
+			j := avlJoin(l, avlHeight(l), &k.avlHeader, r, avlHeight(r))
+			assert.NoError(t, AvlTreeValidate(j, cmpIntNode))
+			assert.Equal(t, want, inOrderKeys(j))
+			assert.Equal(t, nl+nr+1, j.SubtreeSize())
+		}
+	}
+}
+
+func TestAvlTreePrune(t *testing.T) {
+
+	rnd := rand.New(rand.NewSource(3))
+
+	for run := 0; run < 200; run++ {
+		tree := NewAvlTree(WithDuplicates(AvlDupKeepRight))
+
+		n := 1 + rnd.Intn(300)
+		ns := newIntNodes(rnd.Perm(n)...)
+		for _, x := range ns {
+			tree.Insert(&x.avlHeader, x, cmpIntNode)
+		}
+
+		removed := 0
+		tree.Watch(func(op AvlOp, owner interface{}) {
+			removed++
+		})
+
+		victim := &ns[rnd.Intn(n)].avlHeader
+		want := victim.SubtreeSize()
+
+		pruned := AvlTreePrune(tree, victim)
+		prunedKeys := inOrderKeys(pruned.Root())
+
+		assert.NoError(t, tree.Validate(cmpIntNode))
+		assert.NoError(t, pruned.Validate(cmpIntNode))
+		assert.Equal(t, want, pruned.Len())
+		assert.Equal(t, n-want, tree.Len())
+		assert.Equal(t, want, removed)
+		assert.Len(t, prunedKeys, want)
+		assert.Equal(t, AvlDupKeepRight, pruned.dups)
+
+		// The two trees together still hold every key exactly once
+
+		all := append(inOrderKeys(tree.Root()), prunedKeys...)
+		assert.Len(t, all, n)
+		for _, k := range prunedKeys {
+			assert.Nil(t, tree.Lookup(k, cmpIntKey))
+		}
+	}
+}
+
+func TestAvlTreeSplitAt(t *testing.T) {
+
+	rnd := rand.New(rand.NewSource(1225))
+
+	for run := 0; run < 200; run++ {
+		sunk := 0
+		tree := NewAvlTree(WithDuplicates(AvlDupKeepLeft),
+			WithErrorSink(func(err error) { sunk++ }))
+
+		n := rnd.Intn(300)
+		for _, k := range rnd.Perm(n) {
+			x := &intNode{key: k}
+			tree.Insert(&x.avlHeader, x, cmpIntNode)
+		}
+
+		removed := 0
+		tree.Watch(func(op AvlOp, owner interface{}) {
+			removed++
+		})
+
+		k := rnd.Intn(n+3) - 1
+		want := min(max(k, 0), n)
+
+		first, rest := AvlTreeSplitAt(tree, k)
+		assert.Same(t, tree, first)
+		assert.NoError(t, first.Validate(cmpIntNode))
+		assert.NoError(t, rest.Validate(cmpIntNode))
+		assert.Equal(t, want, first.Len())
+		assert.Equal(t, n-want, rest.Len())
+		assert.Equal(t, n-want, removed)
+
+		// The rest keeps tree's duplicate policy and error sink
+
+		assert.Equal(t, AvlDupKeepLeft, rest.dups)
+		rest.Lookup(0, func(interface{}, interface{}) int { panic("boom") })
+		assert.Equal(t, min(1, n-want), sunk)
+
+		all := append(inOrderKeys(first.Root()), inOrderKeys(rest.Root())...)
+		assert.Len(t, all, n)
+		for i, key := range all {
+			assert.Equal(t, i, key)
+		}
+	}
+}
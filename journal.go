@@ -0,0 +1,170 @@
+package avl
+
+//
+// Undo and redo.  A journal attached to a tree records every insertion
+// and removal made through the tree's methods, with the node and owner,
+// so each can be reversed by the opposite operation: no copy of the
+// tree is ever taken.  A mutation that is several operations at once
+// (a replacement by InsertOrReplace, say) is undone and redone as one.
+//
+// Undoing a removal links the removed node back in, so a removed node
+// must not be reused, or its key changed, while the journal can still
+// undo its removal.  Changes the journal cannot see, such as an Upsert
+// callback modifying an owner in place, are not undone.  Operations
+// that replace the tree's contents wholesale (Swap, Prune, Freeze,
+// RestoreFrom and the like) clear the journal.
+//
+
+type avlJournalOp struct {
+	op    AvlOp
+	node  *AvlNode
+	owner interface{}
+	first bool // First operation of its mutation
+}
+
+// The undo and redo history of a tree
+
+type AvlJournal struct {
+	tree      *AvlTree
+	cmp       CmpFuncNode
+	done      []avlJournalOp
+	undone    []avlJournalOp
+	replaying bool
+	join      bool // The next operation is part of the last mutation
+	cleared   bool // Clear has been called
+}
+
+// Starts journaling the tree's mutations, ordering by cmp when removed
+// nodes are linked back in, and returns the journal.  A tree has at most
+// one journal; starting a new one discards the old
+
+func (tree *AvlTree) Journal(cmp CmpFuncNode) *AvlJournal {
+	tree.journal = &AvlJournal{tree: tree, cmp: cmp}
+	return tree.journal
+}
+
+// Stops journaling and discards the history
+
+func (j *AvlJournal) Close() {
+	if j.tree.journal == j {
+		j.tree.journal = nil
+	}
+	j.Clear()
+}
+
+// Discards the history
+
+func (j *AvlJournal) Clear() {
+	j.done = nil
+	j.undone = nil
+	j.cleared = true
+}
+
+func (j *AvlJournal) record(op AvlOp, node *AvlNode) {
+
+	if j.replaying {
+		return
+	}
+
+	j.done = append(j.done, avlJournalOp{
+		op:    op,
+		node:  node,
+		owner: node.owner,
+		first: !j.join,
+	})
+	j.join = false
+	j.undone = j.undone[:0]
+}
+
+// Counts the mutations in a history
+
+func avlJournalCount(ops []avlJournalOp) int {
+	n := 0
+	for _, op := range ops {
+		if op.first {
+			n++
+		}
+	}
+	return n
+}
+
+// Returns the number of mutations that can be undone
+
+func (j *AvlJournal) UndoLen() int {
+	return avlJournalCount(j.done)
+}
+
+// Returns the number of mutations that can be redone
+
+func (j *AvlJournal) RedoLen() int {
+	return avlJournalCount(j.undone)
+}
+
+// Applies op to the tree, or its inverse if invert is set
+
+func (j *AvlJournal) apply(op avlJournalOp, invert bool) {
+
+	tree := j.tree
+
+	if (op.op == AvlOpInsert) == invert {
+		tree.unlink(op.node)
+		return
+	}
+
+	dup := 0
+	if tree.keepsDups() {
+		dup = -1
+	}
+	avlTreeInsertNodeDup(&tree.root, op.node, op.owner, j.cmp, dup,
+		&tree.counts.Rotations)
+	tree.inserted(op.node)
+}
+
+// Undoes the last n mutations, most recent first, and returns how many
+// were undone, which is fewer than n if the history runs out.  Observers
+// see the reversing insertions and removals
+
+func (j *AvlJournal) Undo(n int) int {
+
+	j.replaying = true
+	defer func() { j.replaying = false }()
+
+	undone := 0
+	for ; undone < n && len(j.done) > 0; undone++ {
+		for {
+			op := j.done[len(j.done)-1]
+			j.done = j.done[:len(j.done)-1]
+			j.apply(op, true)
+			j.undone = append(j.undone, op)
+			if op.first {
+				break
+			}
+		}
+	}
+
+	return undone
+}
+
+// Redoes the last n undone mutations, and returns how many were redone.
+// Any new mutation of the tree discards what is left to redo
+
+func (j *AvlJournal) Redo(n int) int {
+
+	j.replaying = true
+	defer func() { j.replaying = false }()
+
+	redone := 0
+	for ; redone < n && len(j.undone) > 0; redone++ {
+		for i := 0; len(j.undone) > 0; i++ {
+			op := j.undone[len(j.undone)-1]
+			if i > 0 && op.first {
+				break
+			}
+			j.undone = j.undone[:len(j.undone)-1]
+			j.apply(op, false)
+			j.done = append(j.done, op)
+		}
+	}
+
+	return redone
+}
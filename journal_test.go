@@ -0,0 +1,110 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func keysOf(tree *AvlTree) []int {
+	var keys []int
+	tree.Range(nil, nil, cmpIntKey, func(owner interface{}) bool {
+		keys = append(keys, owner.(*intNode).key)
+		return true
+	})
+	return keys
+}
+
+func TestAvlJournal(t *testing.T) {
+
+	var tree AvlTree
+	j := tree.Journal(cmpIntNode)
+
+	rnd := rand.New(rand.NewSource(8))
+
+	// Record the contents after each mutation, then undo back through
+	// them all and redo forward again
+
+	history := [][]int{keysOf(&tree)}
+
+	for len(history) < 200 {
+		k := rnd.Intn(50)
+		switch rnd.Intn(3) {
+		case 0:
+			n := &intNode{key: k}
+			if tree.Insert(&n.avlHeader, n, cmpIntNode) != nil {
+				continue
+			}
+		case 1:
+			n := &intNode{key: k}
+			tree.InsertOrReplace(&n.avlHeader, n, cmpIntNode)
+		case 2:
+			if tree.Len() == 0 {
+				continue
+			}
+			tree.PopMin()
+		}
+		history = append(history, keysOf(&tree))
+	}
+
+	assert.Equal(t, 199, j.UndoLen())
+
+	for pos := len(history) - 1; pos > 0; {
+		undone := j.Undo(3)
+		assert.Equal(t, min(3, pos), undone)
+		pos -= undone
+		assert.Equal(t, history[pos], keysOf(&tree))
+	}
+	assert.Equal(t, 0, j.UndoLen())
+	assert.Equal(t, 0, j.Undo(1))
+	assert.NoError(t, tree.Validate(cmpIntNode))
+
+	for i := 1; i < len(history); i++ {
+		assert.Equal(t, 1, j.Redo(1))
+		assert.Equal(t, history[i], keysOf(&tree))
+	}
+	assert.Equal(t, 0, j.Redo(1))
+	assert.NoError(t, tree.Validate(cmpIntNode))
+}
+
+func TestAvlJournalReplacement(t *testing.T) {
+
+	var tree AvlTree
+	j := tree.Journal(cmpIntNode)
+
+	var ops []AvlOp
+	tree.Watch(func(op AvlOp, owner interface{}) {
+		ops = append(ops, op)
+	})
+
+	ns := newIntNodes(1, 1)
+	tree.Insert(&ns[0].avlHeader, ns[0], cmpIntNode)
+	tree.InsertOrReplace(&ns[1].avlHeader, ns[1], cmpIntNode)
+	assert.Equal(t, 2, j.UndoLen())
+
+	// The replacement is undone as one mutation, and observers see it
+
+	ops = nil
+	assert.Equal(t, 1, j.Undo(1))
+	assert.Equal(t, ns[0], tree.Lookup(1, cmpIntKey))
+	assert.True(t, ns[1].avlHeader.IsUnlinked())
+	assert.Equal(t, []AvlOp{AvlOpRemove, AvlOpInsert}, ops)
+
+	// A new mutation discards the redo history
+
+	n := &intNode{key: 2}
+	tree.Insert(&n.avlHeader, n, cmpIntNode)
+	assert.Equal(t, 0, j.RedoLen())
+	assert.Equal(t, 2, j.UndoLen())
+
+	// Wholesale replacements clear the journal, and Close stops it
+
+	var other AvlTree
+	AvlTreeSwap(&tree, &other)
+	assert.Equal(t, 0, j.UndoLen())
+
+	j.Close()
+	other.Remove(&n.avlHeader)
+	tree.Insert(&n.avlHeader, n, cmpIntNode)
+	assert.Equal(t, 0, j.UndoLen())
+}
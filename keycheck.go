@@ -0,0 +1,99 @@
+package avl
+
+import "fmt"
+
+//
+// Key mutation detection.  Changing the key of an owner while its node
+// is linked leaves the node out of order, and nothing notices until a
+// lookup misses it or an insertion lands in the wrong place.  A tree made
+// WithKeyCheck captures each owner's key as it is inserted, as a copy of
+// the key fields or a hash of them, and compares it with the owner's
+// current key whenever the node is looked at again: the nodes on the path
+// from an inserted or removed node to the root, and the owner an Upsert
+// handed to its callback.  Validate compares them all.  An owner whose
+// key has changed is reported as an error wrapping ErrKeyMutated.
+//
+// A key changed on a node that no insertion or removal passes is only
+// caught by Validate; the checks cost O(log n) calls of the key function
+// per operation, and a captured key per node.
+//
+
+type avlKeyCheck struct {
+	key  func(owner interface{}) interface{}
+	keys map[*AvlNode]interface{}
+}
+
+// Debugging option: capture the key of each owner inserted, as key
+// returns it, and panic (or tell the error sink; see WithErrorSink) when
+// an owner's key is found to have changed while it was in the tree.  key
+// must return a comparable value, such as a copy of the key fields or a
+// hash of them, that changes when the ordering of the owner would
+
+func WithKeyCheck(key func(owner interface{}) interface{}) AvlTreeOption {
+	return func(tree *AvlTree) {
+		tree.keyCheck = &avlKeyCheck{key: key, keys: map[*AvlNode]interface{}{}}
+		tree.keyCheck.capture(tree.root)
+	}
+}
+
+// Captures the keys of every node in the tree rooted at root, replacing
+// those captured before
+
+func (kc *avlKeyCheck) capture(root *AvlNode) {
+	clear(kc.keys)
+	for n := avlTreeFirstOrLastInOrder(root, -1); n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+		kc.keys[n] = kc.key(n.owner)
+	}
+}
+
+// Returns an error if node's key has changed since it was captured
+
+func (kc *avlKeyCheck) verify(node *AvlNode) error {
+
+	captured, ok := kc.keys[node]
+	if !ok {
+		return nil
+	}
+	if now := kc.key(node.owner); now != captured {
+		return fmt.Errorf("%w: %v, inserted with key %v, now has key %v",
+			ErrKeyMutated, node.owner, captured, now)
+	}
+
+	return nil
+}
+
+// Returns an error if the key of node, or of any of its ancestors, has
+// changed since it was captured
+
+func (kc *avlKeyCheck) verifyPath(node *AvlNode) error {
+	for n := node; n != nil; n = avlGetParent(n) {
+		if err := kc.verify(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns an error naming the first owner in the tree rooted at root
+// whose key has changed since it was captured
+
+func (kc *avlKeyCheck) verifyAll(root *AvlNode) error {
+	for n := avlTreeFirstOrLastInOrder(root, -1); n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+		if err := kc.verify(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Checks the keys on the path from node, which is in the tree, to the
+// root, reporting a changed one as the tree's self-check does
+
+func (tree *AvlTree) checkKeys(node *AvlNode) {
+	if tree.keyCheck == nil {
+		return
+	}
+	if err := tree.keyCheck.verifyPath(node); err != nil {
+		tree.fail(err)
+	}
+}
@@ -0,0 +1,76 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func intNodeKey(owner interface{}) interface{} {
+	return owner.(*intNode).key
+}
+
+func TestAvlTreeKeyCheck(t *testing.T) {
+
+	var errs []error
+	tree := NewAvlTree(WithKeyCheck(intNodeKey),
+		WithErrorSink(func(err error) { errs = append(errs, err) }))
+
+	ns := newIntNodes(10, 20, 30, 40, 50, 60, 70)
+	for _, n := range ns {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+	tree.Remove(&ns[6].avlHeader)
+	assert.NoError(t, tree.Validate(cmpIntNode))
+	assert.Empty(t, errs)
+
+	// The root is on every path, so the next insertion sees it changed
+
+	root := tree.Root()
+	root.owner.(*intNode).key = 99
+	err := tree.Validate(nil)
+	assert.ErrorIs(t, err, ErrKeyMutated)
+
+	n := &intNode{key: 5}
+	tree.Insert(&n.avlHeader, n, cmpIntNode)
+	assert.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrKeyMutated)
+
+	// Removing the changed node is caught too, and forgets its key
+
+	tree.Remove(root)
+	assert.Len(t, errs, 2)
+
+	m := &intNode{key: 99}
+	tree.Upsert(&m.avlHeader, m, cmpIntNode, func(existing interface{}) {})
+	assert.Len(t, errs, 2)
+
+	// An Upsert callback that changes the key
+
+	k := &intNode{key: 20}
+	tree.Upsert(&k.avlHeader, k, cmpIntNode, func(existing interface{}) {
+		existing.(*intNode).key = 21
+	})
+	assert.Len(t, errs, 3)
+	assert.ErrorIs(t, errs[2], ErrKeyMutated)
+}
+
+func TestAvlTreeKeyCheckSwap(t *testing.T) {
+
+	a := NewAvlTree(WithKeyCheck(intNodeKey))
+	var b AvlTree
+	ns := newIntNodes(1, 2, 3)
+	for _, n := range ns {
+		b.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	// Keys are captured for the contents a tree gets by a swap, and
+	// forgotten for those it gives up
+
+	AvlTreeSwap(a, &b)
+	ns[1].key = 7
+	assert.ErrorIs(t, a.Validate(nil), ErrKeyMutated)
+
+	AvlTreeSwap(a, &b)
+	assert.NoError(t, a.Validate(nil))
+	assert.Len(t, a.keyCheck.keys, 0)
+}
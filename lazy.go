@@ -0,0 +1,284 @@
+package avl
+
+import (
+	"context"
+	"iter"
+)
+
+//
+// Lazy deletion.  Removing a node from an AvlLazyTree only marks it dead
+// (a tombstone, kept in a spare byte of the node): lookups and iteration
+// skip it, but it stays linked, so a burst of removals does no
+// rebalancing at all.  Compact later unlinks the tombstones in bulk.
+// Inserting a key whose node is a tombstone puts the new node in the
+// tombstone's place, again without rebalancing.
+//
+// A removed node still belongs to the tree until it has been compacted
+// away (or replaced by an insertion of the same key), and must not be
+// inserted anywhere else before then.  Keys are unique.
+//
+
+const avlTombstone = 1 // In pad[0]
+
+// Below this share of dead nodes, Compact unlinks them one by one;
+// above it, it rebuilds the tree from the live ones
+
+const avlCompactRebuildShare = 8
+
+// A tree whose removals are deferred until Compact.  The zero value is an
+// empty tree ready to use
+
+type AvlLazyTree struct {
+	tree AvlTree
+	dead int
+}
+
+func avlIsDead(node *AvlNode) bool {
+	return node.pad[0]&avlTombstone != 0
+}
+
+// Returns the number of live nodes
+
+func (lt *AvlLazyTree) Len() int {
+	return lt.tree.size - lt.dead
+}
+
+// Returns the number of tombstones awaiting Compact
+
+func (lt *AvlLazyTree) Dead() int {
+	return lt.dead
+}
+
+// Registers an observer; see AvlTree.Watch.  Removals are reported when
+// the node is marked dead, not when it is compacted away
+
+func (lt *AvlLazyTree) Watch(obs AvlObserver) (cancel func()) {
+	return lt.tree.Watch(obs)
+}
+
+// Returns the tree's counts and shape, tombstones included in Size and
+// Height; see AvlTree.Stats
+
+func (lt *AvlLazyTree) Stats() AvlTreeStats {
+	return lt.tree.Stats()
+}
+
+// Insert a node into the tree.  Returns nil if item was inserted, or the
+// live owner already present under the same key
+
+func (lt *AvlLazyTree) Insert(item *AvlNode, owner interface{},
+	cmp CmpFuncNode) interface{} {
+
+	tree := &lt.tree
+
+	item.pad[0] &^= avlTombstone
+
+	existing := tree.insertNode(item, owner, cmp)
+	if existing == nil {
+		tree.inserted(item)
+		return nil
+	}
+	if !avlIsDead(existing) {
+		return existing.owner
+	}
+
+	// Take the tombstone's place
+
+	avlTreeReplaceNode(&tree.root, existing, item, owner)
+	existing.pad[0] &^= avlTombstone
+	existing.SetUnlinked()
+
+	if tree.first == existing {
+		tree.first = item
+	}
+	if tree.last == existing {
+		tree.last = item
+	}
+	lt.dead--
+
+	tree.gen.Add(1)
+	tree.counts.Inserts++
+	tree.check(AvlOpInsert)
+	tree.notify(AvlOpInsert, owner)
+
+	return nil
+}
+
+// Marks node, which must be in the tree, dead.  Removing a node twice
+// is harmless
+
+func (lt *AvlLazyTree) Remove(node *AvlNode) {
+
+	if avlIsDead(node) {
+		return
+	}
+
+	node.pad[0] |= avlTombstone
+	lt.dead++
+
+	lt.tree.gen.Add(1)
+	lt.tree.counts.Removes++
+	lt.tree.notify(AvlOpRemove, node.owner)
+}
+
+// Look up a specified key.  nil if not present, or dead
+
+func (lt *AvlLazyTree) Lookup(key interface{}, cmp CmpFuncKey) interface{} {
+
+	for cur := lt.tree.root; cur != nil; {
+		res := cmp(key, cur.owner)
+		if res < 0 {
+			cur = cur.left
+		} else if res > 0 {
+			cur = cur.right
+		} else if avlIsDead(cur) {
+			return nil
+		} else {
+			return cur.owner
+		}
+	}
+
+	return nil
+}
+
+// Returns node, or the first live node after it in direction sign
+
+func avlSkipDead(node *AvlNode, sign int) *AvlNode {
+	for node != nil && avlIsDead(node) {
+		node = avlTreeNextOrPrevInOrder(node, sign)
+	}
+	return node
+}
+
+// Returns the least live owner in the tree, or nil if there is none
+
+func (lt *AvlLazyTree) First() interface{} {
+	if n := avlSkipDead(lt.tree.first, 1); n != nil {
+		return n.owner
+	}
+	return nil
+}
+
+// Returns the greatest live owner in the tree, or nil if there is none
+
+func (lt *AvlLazyTree) Last() interface{} {
+	if n := avlSkipDead(lt.tree.last, -1); n != nil {
+		return n.owner
+	}
+	return nil
+}
+
+// Calls fn, in order, with each live owner whose key is in [lo, hi),
+// until fn returns false; see AvlTreeRange
+
+func (lt *AvlLazyTree) Range(lo, hi interface{}, cmp CmpFuncKey,
+	fn func(owner interface{}) bool) {
+
+	cur := lt.tree.first
+	if lo != nil {
+		cur = avlTreeFirstAtOrAfter(lt.tree.root, lo, cmp)
+	}
+
+	for cur = avlSkipDead(cur, 1); cur != nil; cur = avlSkipDead(avlTreeNextOrPrevInOrder(cur, 1), 1) {
+		if hi != nil && cmp(hi, cur.owner) <= 0 {
+			break
+		}
+		if !fn(cur.owner) {
+			break
+		}
+	}
+}
+
+// Yields the live owners in order.  The tree must not be modified while
+// the sequence is being iterated
+
+func (lt *AvlLazyTree) All() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		lt.Range(nil, nil, nil, yield)
+	}
+}
+
+// Unlinks every tombstone, marking their nodes unlinked, and returns how
+// many there were.  A few are unlinked one at a time, in O(d log n);
+// once they are more than an eighth of the tree it is rebuilt, perfectly
+// balanced, from the live nodes in O(n).  Observers are not notified
+
+func (lt *AvlLazyTree) Compact() int {
+
+	tree := &lt.tree
+	dead := lt.dead
+
+	if dead == 0 {
+		return 0
+	}
+
+	tree.labeled(context.Background(), "compact", func(context.Context) {
+		if dead*avlCompactRebuildShare < tree.size {
+			lt.unlinkDead()
+		} else {
+			lt.rebuild()
+		}
+	})
+
+	lt.dead = 0
+	tree.gen.Add(1)
+	tree.check(AvlOpRemove)
+
+	return dead
+}
+
+func (lt *AvlLazyTree) unlinkDead() {
+
+	tree := &lt.tree
+
+	var dead []*AvlNode
+	for n := tree.first; n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+		if avlIsDead(n) {
+			dead = append(dead, n)
+		}
+	}
+
+	for _, n := range dead {
+		if n == tree.first {
+			tree.first = avlTreeNextOrPrevInOrder(n, 1)
+		}
+		if n == tree.last {
+			tree.last = avlTreeNextOrPrevInOrder(n, -1)
+		}
+		avlTreeRemove(&tree.root, n, &tree.counts.Rotations)
+		n.pad[0] &^= avlTombstone
+		n.SetUnlinked()
+		tree.size--
+	}
+}
+
+func (lt *AvlLazyTree) rebuild() {
+
+	tree := &lt.tree
+
+	live := make([]*AvlNode, 0, tree.size-lt.dead)
+	dead := make([]*AvlNode, 0, lt.dead)
+	for n := tree.first; n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+		if avlIsDead(n) {
+			dead = append(dead, n)
+		} else {
+			live = append(live, n)
+		}
+	}
+
+	for _, n := range dead {
+		n.pad[0] &^= avlTombstone
+		n.SetUnlinked()
+	}
+
+	tree.reset(AvlTreeBuildSorted(len(live), func(i int) (*AvlNode, interface{}) {
+		return live[i], live[i].owner
+	}))
+}
+
+// Checks the invariants of the tree, tombstones included; see
+// AvlTree.Validate
+
+func (lt *AvlLazyTree) Validate(cmp CmpFuncNode) error {
+	return lt.tree.Validate(cmp)
+}
@@ -0,0 +1,106 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestAvlLazyTree(t *testing.T) {
+
+	for _, share := range []int{2, 20} {
+		var lt AvlLazyTree
+		model := map[int]*intNode{}
+
+		rnd := rand.New(rand.NewSource(7))
+
+		for i := 0; i < 4000; i++ {
+			k := rnd.Intn(300)
+			if rnd.Intn(share) == 0 {
+				n := &intNode{key: k}
+				if existing := lt.Insert(&n.avlHeader, n, cmpIntNode); existing != nil {
+					assert.Equal(t, model[k], existing)
+				} else {
+					model[k] = n
+				}
+			} else if n := model[k]; n != nil {
+				lt.Remove(&n.avlHeader)
+				delete(model, k)
+			}
+			if i%500 == 0 {
+				assert.Equal(t, lt.Dead() > 0, lt.Compact() > 0)
+				assert.NoError(t, lt.Validate(cmpIntNode))
+			}
+		}
+
+		check := func() {
+			assert.Equal(t, len(model), lt.Len())
+
+			var keys []int
+			for k, n := range model {
+				assert.Equal(t, n, lt.Lookup(k, cmpIntKey))
+				keys = append(keys, k)
+			}
+			sort.Ints(keys)
+
+			var got []int
+			for owner := range lt.All() {
+				got = append(got, owner.(*intNode).key)
+			}
+			assert.Equal(t, keys, got)
+
+			if len(keys) > 0 {
+				assert.Equal(t, keys[0], lt.First().(*intNode).key)
+				assert.Equal(t, keys[len(keys)-1], lt.Last().(*intNode).key)
+			}
+		}
+
+		check()
+
+		dead := lt.Dead()
+		assert.Equal(t, dead, lt.Compact())
+		assert.Equal(t, 0, lt.Dead())
+		assert.NoError(t, lt.Validate(cmpIntNode))
+		assert.Equal(t, len(model), lt.Stats().Size)
+		check()
+	}
+}
+
+func TestAvlLazyTreeNoRebalance(t *testing.T) {
+
+	var lt AvlLazyTree
+
+	ns := newIntNodes(1, 2, 3, 4, 5, 6, 7)
+	for _, n := range ns {
+		lt.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+	rotations := lt.Stats().Rotations
+
+	// Removing, and reinserting over the tombstone, leave the shape alone
+
+	lt.Remove(&ns[0].avlHeader)
+	lt.Remove(&ns[1].avlHeader)
+	lt.Remove(&ns[1].avlHeader)
+	assert.Equal(t, 2, lt.Dead())
+	assert.Nil(t, lt.Lookup(1, cmpIntKey))
+	assert.Equal(t, ns[2], lt.First())
+
+	n := &intNode{key: 2}
+	assert.Nil(t, lt.Insert(&n.avlHeader, n, cmpIntNode))
+	assert.Equal(t, 1, lt.Dead())
+	assert.True(t, ns[1].avlHeader.IsUnlinked())
+	assert.Equal(t, n, lt.First())
+	assert.Equal(t, rotations, lt.Stats().Rotations)
+
+	var got []interface{}
+	lt.Range(2, 5, cmpIntKey, func(owner interface{}) bool {
+		got = append(got, owner)
+		return true
+	})
+	assert.Equal(t, []interface{}{n, ns[2], ns[3]}, got)
+
+	assert.Equal(t, 1, lt.Compact())
+	assert.True(t, ns[0].avlHeader.IsUnlinked())
+	assert.Equal(t, 6, lt.Len())
+}
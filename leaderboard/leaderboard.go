@@ -0,0 +1,171 @@
+//
+// Copyright as per Creative Commons Legal Code license, which can
+// be found in the file COPYING
+//
+
+/*
+
+Package leaderboard ranks players by score, on an order-statistic AVL
+tree from the avl package, so that changing a score, finding a player's
+rank, and listing the top players or the players around one are all
+O(log n) (plus the length of the list):
+
+	board := leaderboard.New[string]()
+	board.SetScore("ann", 310)
+	board.SetScore("bob", 270)
+	rank, _ := board.RankOf("bob")    // 2
+	top := board.Top(10)
+
+Higher scores rank first, and players with equal scores are ranked by
+ID, lowest first, so every player has a distinct rank.  Ranks start at
+1.  A Board is not safe for concurrent use.
+
+*/
+
+package leaderboard
+
+import (
+	"cmp"
+	"github.com/danswartzendruber/avl"
+)
+
+// A player's place on the board
+
+type Entry[ID cmp.Ordered] struct {
+	ID    ID
+	Score float64
+	Rank  int
+}
+
+type player[ID cmp.Ordered] struct {
+	header avl.AvlNode
+	id     ID
+	score  float64
+}
+
+type Board[ID cmp.Ordered] struct {
+	tree    avl.AvlTree
+	players map[ID]*player[ID]
+}
+
+// Returns an empty board
+
+func New[ID cmp.Ordered]() *Board[ID] {
+	return &Board[ID]{players: make(map[ID]*player[ID])}
+}
+
+// Orders players best first
+
+func cmpPlayers[ID cmp.Ordered](a, b interface{}) int {
+
+	pa := a.(*player[ID])
+	pb := b.(*player[ID])
+
+	if c := cmp.Compare(pb.score, pa.score); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(pa.id, pb.id)
+}
+
+// Returns the number of players
+
+func (b *Board[ID]) Len() int {
+	return b.tree.Len()
+}
+
+// Sets the score of player id, adding the player if need be
+
+func (b *Board[ID]) SetScore(id ID, score float64) {
+
+	p := b.players[id]
+	if p == nil {
+		p = &player[ID]{id: id}
+		b.players[id] = p
+	} else {
+		b.tree.Remove(&p.header)
+	}
+
+	p.score = score
+	b.tree.Insert(&p.header, p, cmpPlayers[ID])
+}
+
+// Removes player id.  Returns false if there was no such player
+
+func (b *Board[ID]) Remove(id ID) bool {
+
+	p := b.players[id]
+	if p == nil {
+		return false
+	}
+
+	b.tree.Remove(&p.header)
+	delete(b.players, id)
+
+	return true
+}
+
+// Returns the score of player id, or false if there is no such player
+
+func (b *Board[ID]) Score(id ID) (float64, bool) {
+
+	if p := b.players[id]; p != nil {
+		return p.score, true
+	}
+
+	return 0, false
+}
+
+// Returns the rank of player id, or false if there is no such player
+
+func (b *Board[ID]) RankOf(id ID) (int, bool) {
+
+	p := b.players[id]
+	if p == nil {
+		return 0, false
+	}
+
+	return b.tree.CountLess(p, avl.CmpFuncKey(cmpPlayers[ID])) + 1, true
+}
+
+// Returns the entries ranked first to last, both 1-based and inclusive,
+// clipped to the board
+
+func (b *Board[ID]) entries(first, last int) []Entry[ID] {
+
+	first = max(first, 1)
+	last = min(last, b.tree.Len())
+
+	var out []Entry[ID]
+	if first > last {
+		return out
+	}
+
+	out = make([]Entry[ID], 0, last-first+1)
+	c := b.tree.Cursor()
+	for ok := c.SeekIndex(first - 1); ok && c.Index() < last; ok = c.Next() {
+		p := c.Owner().(*player[ID])
+		out = append(out, Entry[ID]{ID: p.id, Score: p.score, Rank: c.Index() + 1})
+	}
+
+	return out
+}
+
+// Returns the k best players, best first
+
+func (b *Board[ID]) Top(k int) []Entry[ID] {
+	return b.entries(1, k)
+}
+
+// Returns player id with up to k players ranked either side of it, best
+// first, or nil if there is no such player
+
+func (b *Board[ID]) Around(id ID, k int) []Entry[ID] {
+
+	rank, ok := b.RankOf(id)
+	if !ok {
+		return nil
+	}
+
+	return b.entries(rank-k, rank+k)
+}
@@ -0,0 +1,89 @@
+package leaderboard
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBoard(t *testing.T) {
+
+	board := New[string]()
+
+	board.SetScore("ann", 310)
+	board.SetScore("bob", 270)
+	board.SetScore("cat", 310)
+	board.SetScore("dan", 100)
+
+	rank, ok := board.RankOf("cat")
+	assert.True(t, ok)
+	assert.Equal(t, 2, rank)
+	_, ok = board.RankOf("eve")
+	assert.False(t, ok)
+
+	assert.Equal(t, []Entry[string]{
+		{"ann", 310, 1},
+		{"cat", 310, 2},
+	}, board.Top(2))
+
+	// Changing a score moves the player
+
+	board.SetScore("dan", 400)
+	assert.Equal(t, 4, board.Len())
+	rank, _ = board.RankOf("dan")
+	assert.Equal(t, 1, rank)
+
+	assert.Equal(t, []Entry[string]{
+		{"ann", 310, 2},
+		{"cat", 310, 3},
+		{"bob", 270, 4},
+	}, board.Around("cat", 1))
+	assert.Len(t, board.Around("dan", 5), 4)
+	assert.Nil(t, board.Around("eve", 1))
+	assert.Len(t, board.Top(10), 4)
+	assert.Empty(t, board.Top(0))
+
+	assert.True(t, board.Remove("ann"))
+	assert.False(t, board.Remove("ann"))
+	_, ok = board.Score("ann")
+	assert.False(t, ok)
+	rank, _ = board.RankOf("cat")
+	assert.Equal(t, 2, rank)
+}
+
+func TestBoardRandom(t *testing.T) {
+
+	board := New[int]()
+	scores := map[int]float64{}
+
+	rnd := rand.New(rand.NewSource(11))
+	for i := 0; i < 2000; i++ {
+		id := rnd.Intn(100)
+		s := float64(rnd.Intn(50))
+		board.SetScore(id, s)
+		scores[id] = s
+	}
+
+	ids := make([]int, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	for i, id := range ids {
+		rank, _ := board.RankOf(id)
+		assert.Equal(t, i+1, rank, fmt.Sprint(id))
+	}
+
+	top := board.Top(len(ids))
+	for i, e := range top {
+		assert.Equal(t, ids[i], e.ID)
+	}
+}
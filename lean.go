@@ -0,0 +1,393 @@
+package avl
+
+import (
+	"fmt"
+	"iter"
+)
+
+//
+// Lean trees.  An AvlLeanNode has no parent pointer and no subtree size,
+// so it is 8 bytes smaller than an AvlNode (40 bytes rather than 48 on
+// 64-bit platforms).  Without a parent a node cannot find its neighbours,
+// so there is no Next-from-node: iteration goes through an AvlLeanIter,
+// which keeps the path back to the root on a stack, and removal is by
+// key.  Insertion and removal remember their path on the stack too, so
+// nothing is recursive.
+//
+
+// Lean trees up to 2^64 nodes are at most 92 levels deep
+
+const avlLeanMaxHeight = 96
+
+type AvlLeanNode struct {
+	left, right *AvlLeanNode
+	owner       interface{}
+	height      int8
+}
+
+type AvlLeanTree struct {
+	root *AvlLeanNode
+	size int
+}
+
+// An in-order iterator over a lean tree, holding the nodes still to be
+// visited whose subtrees on the far side are yet to be entered
+
+type AvlLeanIter struct {
+	stack []*AvlLeanNode
+	sign  int
+	cur   *AvlLeanNode
+}
+
+func avlLeanHeight(node *AvlLeanNode) int8 {
+	if node == nil {
+		return 0
+	}
+	return node.height
+}
+
+func avlLeanChild(node *AvlLeanNode, sign int) *AvlLeanNode {
+	if sign < 0 {
+		return node.left
+	}
+	return node.right
+}
+
+func avlLeanSetChild(node *AvlLeanNode, sign int, child *AvlLeanNode) {
+	if sign < 0 {
+		node.left = child
+	} else {
+		node.right = child
+	}
+}
+
+func avlLeanUpdateHeight(node *AvlLeanNode) {
+	node.height = 1 + max(avlLeanHeight(node.left), avlLeanHeight(node.right))
+}
+
+// Rotates the subtree rooted at A, lifting its child on the sign side,
+// and returns the new subtree root
+
+func avlLeanRotate(A *AvlLeanNode, sign int) *AvlLeanNode {
+
+	B := avlLeanChild(A, sign)
+	avlLeanSetChild(A, sign, avlLeanChild(B, -sign))
+	avlLeanSetChild(B, -sign, A)
+
+	avlLeanUpdateHeight(A)
+	avlLeanUpdateHeight(B)
+
+	return B
+}
+
+// Restores the balance of the subtree rooted at node, whose subtrees
+// are balanced and differ in height by at most two, and returns its new
+// root
+
+func avlLeanBalance(node *AvlLeanNode) *AvlLeanNode {
+
+	bf := int(avlLeanHeight(node.right)) - int(avlLeanHeight(node.left))
+
+	if bf >= -1 && bf <= 1 {
+		avlLeanUpdateHeight(node)
+		return node
+	}
+
+	sign := 1
+	if bf < 0 {
+		sign = -1
+	}
+
+	child := avlLeanChild(node, sign)
+	if avlLeanHeight(avlLeanChild(child, -sign)) > avlLeanHeight(avlLeanChild(child, sign)) {
+		avlLeanSetChild(node, sign, avlLeanRotate(child, -sign))
+	}
+
+	return avlLeanRotate(node, sign)
+}
+
+// Points path[i]'s parent, or the root, at new instead of old
+
+func (tree *AvlLeanTree) relink(path []*AvlLeanNode, i int, old, new *AvlLeanNode) {
+
+	if i == 0 {
+		tree.root = new
+	} else if path[i-1].left == old {
+		path[i-1].left = new
+	} else {
+		path[i-1].right = new
+	}
+}
+
+// Rebalances the nodes on path, from the bottom up, stopping once a
+// subtree's height is unchanged
+
+func (tree *AvlLeanTree) retrace(path []*AvlLeanNode) {
+
+	for i := len(path) - 1; i >= 0; i-- {
+		node := path[i]
+		height := node.height
+
+		sub := avlLeanBalance(node)
+		if sub != node {
+			tree.relink(path, i, node, sub)
+		}
+		if sub.height == height {
+			break
+		}
+	}
+}
+
+// Returns the number of nodes in the tree
+
+func (tree *AvlLeanTree) Len() int {
+	return tree.size
+}
+
+// Looks up key, returning its owner or nil
+
+func (tree *AvlLeanTree) Lookup(key interface{}, cmp CmpFuncKey) interface{} {
+
+	for cur := tree.root; cur != nil; {
+		res := cmp(key, cur.owner)
+		if res < 0 {
+			cur = cur.left
+		} else if res > 0 {
+			cur = cur.right
+		} else {
+			return cur.owner
+		}
+	}
+
+	return nil
+}
+
+// Inserts item, whose owner is owner, unless a node comparing equal to
+// it is already in the tree, in which case the tree is left alone and
+// that node's owner is returned.  Returns nil if item was inserted
+
+func (tree *AvlLeanTree) Insert(item *AvlLeanNode, owner interface{},
+	cmp CmpFuncNode) interface{} {
+
+	var buf [avlLeanMaxHeight]*AvlLeanNode
+	path := buf[:0]
+	sign := 0
+
+	for cur := tree.root; cur != nil; cur = avlLeanChild(cur, sign) {
+		res := cmp(owner, cur.owner)
+		if res == 0 {
+			return cur.owner
+		}
+		path = append(path, cur)
+		if res < 0 {
+			sign = -1
+		} else {
+			sign = 1
+		}
+	}
+
+	*item = AvlLeanNode{owner: owner, height: 1}
+
+	if len(path) == 0 {
+		tree.root = item
+	} else {
+		avlLeanSetChild(path[len(path)-1], sign, item)
+		tree.retrace(path)
+	}
+	tree.size++
+
+	return nil
+}
+
+// Removes the node whose owner compares equal to key, and returns its
+// owner, or nil if there is none
+
+func (tree *AvlLeanTree) Remove(key interface{}, cmp CmpFuncKey) interface{} {
+
+	var buf [avlLeanMaxHeight]*AvlLeanNode
+	path := buf[:0]
+
+	node := tree.root
+	for node != nil {
+		res := cmp(key, node.owner)
+		if res == 0 {
+			break
+		}
+		path = append(path, node)
+		if res < 0 {
+			node = node.left
+		} else {
+			node = node.right
+		}
+	}
+
+	if node == nil {
+		return nil
+	}
+
+	if node.left != nil && node.right != nil {
+
+		// Unlink the successor and put it in the node's place
+
+		at := len(path)
+		path = append(path, node)
+
+		succ := node.right
+		for succ.left != nil {
+			path = append(path, succ)
+			succ = succ.left
+		}
+
+		if parent := path[len(path)-1]; parent == node {
+			node.right = succ.right
+		} else {
+			parent.left = succ.right
+		}
+
+		succ.left, succ.right, succ.height = node.left, node.right, node.height
+		tree.relink(path, at, node, succ)
+		path[at] = succ
+	} else {
+		child := node.left
+		if child == nil {
+			child = node.right
+		}
+		tree.relink(path, len(path), node, child)
+	}
+
+	tree.retrace(path)
+	tree.size--
+
+	owner := node.owner
+	*node = AvlLeanNode{}
+
+	return owner
+}
+
+// Pushes node and the nodes down its -sign side onto the stack
+
+func (it *AvlLeanIter) descend(node *AvlLeanNode) {
+	for ; node != nil; node = avlLeanChild(node, -it.sign) {
+		it.stack = append(it.stack, node)
+	}
+}
+
+// Returns an iterator over the tree in increasing order
+
+func (tree *AvlLeanTree) Iter() *AvlLeanIter {
+	it := &AvlLeanIter{sign: 1}
+	it.descend(tree.root)
+	return it
+}
+
+// Returns an iterator over the tree in decreasing order
+
+func (tree *AvlLeanTree) IterReverse() *AvlLeanIter {
+	it := &AvlLeanIter{sign: -1}
+	it.descend(tree.root)
+	return it
+}
+
+// Returns an iterator over the tree in increasing order, starting from
+// the first owner at or after key
+
+func (tree *AvlLeanTree) Seek(key interface{}, cmp CmpFuncKey) *AvlLeanIter {
+
+	it := &AvlLeanIter{sign: 1}
+
+	for cur := tree.root; cur != nil; {
+		if cmp(key, cur.owner) <= 0 {
+			it.stack = append(it.stack, cur)
+			cur = cur.left
+		} else {
+			cur = cur.right
+		}
+	}
+
+	return it
+}
+
+// Moves to the next owner, returning false once there are no more.  The
+// tree must not be modified while it is being iterated
+
+func (it *AvlLeanIter) Next() bool {
+
+	if len(it.stack) == 0 {
+		it.cur = nil
+		return false
+	}
+
+	it.cur = it.stack[len(it.stack)-1]
+	it.stack = it.stack[:len(it.stack)-1]
+	it.descend(avlLeanChild(it.cur, it.sign))
+
+	return true
+}
+
+// Returns the owner Next moved to
+
+func (it *AvlLeanIter) Owner() interface{} {
+	if it.cur == nil {
+		return nil
+	}
+	return it.cur.owner
+}
+
+// Yields the owners in increasing order
+
+func (tree *AvlLeanTree) All() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		for it := tree.Iter(); it.Next(); {
+			if !yield(it.Owner()) {
+				return
+			}
+		}
+	}
+}
+
+// Checks the tree's ordering, heights and balance
+
+func (tree *AvlLeanTree) Validate(cmp CmpFuncNode) error {
+
+	var prev interface{}
+	count := 0
+
+	var check func(node *AvlLeanNode) (int8, error)
+
+	check = func(node *AvlLeanNode) (int8, error) {
+		if node == nil {
+			return 0, nil
+		}
+
+		lh, err := check(node.left)
+		if err != nil {
+			return 0, err
+		}
+
+		if count > 0 && cmp(prev, node.owner) >= 0 {
+			return 0, fmt.Errorf("%w: %v is not less than %v", ErrInvalidTree, prev, node.owner)
+		}
+		prev = node.owner
+		count++
+
+		rh, err := check(node.right)
+		if err != nil {
+			return 0, err
+		}
+
+		if lh-rh > 1 || rh-lh > 1 || node.height != 1+max(lh, rh) {
+			return 0, fmt.Errorf("%w: %v is out of balance", ErrInvalidTree, node.owner)
+		}
+
+		return node.height, nil
+	}
+
+	if _, err := check(tree.root); err != nil {
+		return err
+	}
+	if count != tree.size {
+		return fmt.Errorf("%w: %d nodes, size %d", ErrInvalidTree, count, tree.size)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+	"unsafe"
+)
+
+type leanNode struct {
+	avlHeader AvlLeanNode
+	key       int
+}
+
+func cmpLeanKey(key interface{}, node interface{}) int {
+	return key.(int) - node.(*leanNode).key
+}
+
+func cmpLeanNode(node1 interface{}, node2 interface{}) int {
+	return node1.(*leanNode).key - node2.(*leanNode).key
+}
+
+func TestAvlLeanTree(t *testing.T) {
+
+	var tree AvlLeanTree
+	model := map[int]*leanNode{}
+
+	rnd := rand.New(rand.NewSource(6))
+
+	for i := 0; i < 5000; i++ {
+		k := rnd.Intn(400)
+		if rnd.Intn(2) == 0 {
+			n := &leanNode{key: k}
+			if existing := tree.Insert(&n.avlHeader, n, cmpLeanNode); existing != nil {
+				assert.Equal(t, model[k], existing)
+			} else {
+				model[k] = n
+			}
+		} else {
+			assert.Equal(t, model[k] != nil, tree.Remove(k, cmpLeanKey) != nil)
+			delete(model, k)
+		}
+		if i%100 == 0 {
+			assert.NoError(t, tree.Validate(cmpLeanNode))
+		}
+	}
+
+	assert.NoError(t, tree.Validate(cmpLeanNode))
+	assert.Equal(t, len(model), tree.Len())
+
+	var keys []int
+	for k, n := range model {
+		assert.Equal(t, n, tree.Lookup(k, cmpLeanKey))
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	var got []int
+	for owner := range tree.All() {
+		got = append(got, owner.(*leanNode).key)
+	}
+	assert.Equal(t, keys, got)
+
+	got = nil
+	for it := tree.IterReverse(); it.Next(); {
+		got = append([]int{it.Owner().(*leanNode).key}, got...)
+	}
+	assert.Equal(t, keys, got)
+
+	// Seek starts at the first key at or after the one given
+
+	i := sort.SearchInts(keys, 200)
+	it := tree.Seek(200, cmpLeanKey)
+	for _, k := range keys[i:] {
+		assert.True(t, it.Next())
+		assert.Equal(t, k, it.Owner().(*leanNode).key)
+	}
+	assert.False(t, it.Next())
+	assert.Nil(t, it.Owner())
+}
+
+func TestAvlLeanNodeSize(t *testing.T) {
+	assert.Equal(t, unsafe.Sizeof(AvlNode{})-8, unsafe.Sizeof(AvlLeanNode{}))
+}
@@ -0,0 +1,253 @@
+package avl
+
+import (
+	"context"
+	"iter"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+//
+// Bulk loading.  Building a tree of n owners by insertion is O(n log n)
+// comparisons, all on one goroutine, with a rebalancing after each.  An
+// AvlLoader instead reads the owners into chunks, sorts each chunk on a
+// goroutine of its own while the next is being read, merges the sorted
+// chunks pairwise, also in parallel, and builds the tree from the merged
+// run in O(n) with AvlTreeBuildSortedParallel.  The owners may come in
+// any order.  A Progress function, if set, hears how far each phase has
+// got, for startup logging.
+//
+
+// Owners read into one chunk, to be sorted together, by default
+
+const avlLoadChunkSize = 1 << 16
+
+// Configures a bulk load.  Header returns the owner's AvlNode, which must
+// not be linked into any tree, and Cmp orders the owners.  The other
+// fields are optional
+
+type AvlLoader struct {
+	Header    func(owner interface{}) *AvlNode
+	Cmp       CmpFuncNode
+	Workers   int             // Goroutines sorting and merging; GOMAXPROCS if <= 0
+	ChunkSize int             // Owners per sorted chunk; 65536 if <= 0
+	Options   []AvlTreeOption // Options for the tree built
+	Progress  func(p AvlLoadProgress)
+}
+
+// The phases of a load, in order
+
+type AvlLoadPhase int
+
+const (
+	AvlLoadReading AvlLoadPhase = iota
+	AvlLoadSorting
+	AvlLoadMerging
+	AvlLoadBuilding
+	AvlLoadDone
+)
+
+func (p AvlLoadPhase) String() string {
+	switch p {
+	case AvlLoadReading:
+		return "reading"
+	case AvlLoadSorting:
+		return "sorting"
+	case AvlLoadMerging:
+		return "merging"
+	case AvlLoadBuilding:
+		return "building"
+	case AvlLoadDone:
+		return "done"
+	default:
+		return "unknown"
+	}
+}
+
+// How far a load has got.  Reading counts Items as they are read; the
+// sorting and merging phases count chunks sorted and merge passes made,
+// in Done, out of Total.  Sorting overlaps reading, so reports of the
+// two phases interleave until reading is over
+
+type AvlLoadProgress struct {
+	Phase AvlLoadPhase
+	Items int
+	Done  int
+	Total int
+}
+
+// Reads every owner items yields, and returns a tree of them.  Unless the
+// tree's options keep duplicates (see WithDuplicates), only the first
+// owner read of each key goes into the tree.  Gives up, returning
+// ctx.Err(), if ctx is done before the tree is built; no owner has been
+// linked into a tree by then
+
+func (l *AvlLoader) Load(ctx context.Context,
+	items iter.Seq[interface{}]) (*AvlTree, error) {
+
+	workers := l.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	chunkSize := l.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = avlLoadChunkSize
+	}
+
+	var mu sync.Mutex
+	report := func(p AvlLoadProgress) {
+		if l.Progress != nil {
+			mu.Lock()
+			defer mu.Unlock()
+			l.Progress(p)
+		}
+	}
+
+	// Read and sort.  Each chunk is sorted stably, and chunks are merged
+	// earlier before later, so among equal owners the first read is first
+
+	var chunks [][]interface{}
+	var wg sync.WaitGroup
+	var sortedChunks int
+	sem := make(chan struct{}, workers)
+	read := 0
+
+	sortChunk := func(c []interface{}, items int) {
+		defer wg.Done()
+		sort.SliceStable(c, func(i, j int) bool {
+			return l.Cmp(c[i], c[j]) < 0
+		})
+		<-sem
+
+		mu.Lock()
+		sortedChunks++
+		done := sortedChunks
+		mu.Unlock()
+		report(AvlLoadProgress{Phase: AvlLoadSorting, Items: items, Done: done})
+	}
+
+	cur := make([]interface{}, 0, chunkSize)
+	flush := func() {
+		chunks = append(chunks, cur)
+		wg.Add(1)
+		sem <- struct{}{}
+		go sortChunk(cur, read)
+		cur = make([]interface{}, 0, chunkSize)
+	}
+
+	for owner := range items {
+		cur = append(cur, owner)
+		read++
+		if len(cur) == chunkSize {
+			flush()
+			report(AvlLoadProgress{Phase: AvlLoadReading, Items: read})
+			if ctx.Err() != nil {
+				break
+			}
+		}
+	}
+	if len(cur) > 0 && ctx.Err() == nil {
+		flush()
+	}
+	report(AvlLoadProgress{Phase: AvlLoadReading, Items: read})
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	report(AvlLoadProgress{Phase: AvlLoadSorting, Items: read,
+		Done: len(chunks), Total: len(chunks)})
+
+	// Merge pairwise until one run is left
+
+	passes := 0
+	for n := len(chunks); n > 1; n = (n + 1) / 2 {
+		passes++
+	}
+
+	for pass := 1; len(chunks) > 1; pass++ {
+		next := make([][]interface{}, (len(chunks)+1)/2)
+		for i := 0; i < len(chunks); i += 2 {
+			if i+1 == len(chunks) {
+				next[i/2] = chunks[i]
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int) {
+				defer wg.Done()
+				next[i/2] = avlLoadMerge(chunks[i], chunks[i+1], l.Cmp)
+				<-sem
+			}(i)
+		}
+		wg.Wait()
+		chunks = next
+
+		report(AvlLoadProgress{Phase: AvlLoadMerging, Items: read,
+			Done: pass, Total: passes})
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var all []interface{}
+	if len(chunks) > 0 {
+		all = chunks[0]
+	}
+
+	// Build
+
+	tree := NewAvlTree(l.Options...)
+	if !tree.keepsDups() {
+		all = avlLoadDedup(all, l.Cmp)
+	}
+
+	report(AvlLoadProgress{Phase: AvlLoadBuilding, Items: len(all), Total: 1})
+	root, err := AvlTreeBuildSortedParallelContext(ctx, len(all), workers,
+		func(i int) (*AvlNode, interface{}) {
+			return l.Header(all[i]), all[i]
+		})
+	if err != nil {
+		return nil, err
+	}
+	tree.reset(root)
+	report(AvlLoadProgress{Phase: AvlLoadDone, Items: len(all), Done: 1, Total: 1})
+
+	return tree, nil
+}
+
+// Merges the sorted runs a and b into a new one, taking from a first
+// among equal owners
+
+func avlLoadMerge(a, b []interface{}, cmp CmpFuncNode) []interface{} {
+
+	out := make([]interface{}, 0, len(a)+len(b))
+
+	for len(a) > 0 && len(b) > 0 {
+		if cmp(b[0], a[0]) < 0 {
+			out = append(out, b[0])
+			b = b[1:]
+		} else {
+			out = append(out, a[0])
+			a = a[1:]
+		}
+	}
+	out = append(out, a...)
+
+	return append(out, b...)
+}
+
+// Drops, in place, every owner of the sorted run that is equal to the
+// one before it
+
+func avlLoadDedup(run []interface{}, cmp CmpFuncNode) []interface{} {
+
+	out := run[:0]
+	for _, owner := range run {
+		if len(out) == 0 || cmp(out[len(out)-1], owner) != 0 {
+			out = append(out, owner)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,106 @@
+package avl
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func intNodeSeq(ns []*intNode) func(yield func(interface{}) bool) {
+	return func(yield func(interface{}) bool) {
+		for _, n := range ns {
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+func TestAvlLoader(t *testing.T) {
+
+	rnd := rand.New(rand.NewSource(1226))
+	keys := make([]int, 20000)
+	for i := range keys {
+		keys[i] = rnd.Intn(15000)
+	}
+	ns := newIntNodes(keys...)
+
+	var phases []AvlLoadPhase
+	l := &AvlLoader{
+		Header:    func(owner interface{}) *AvlNode { return &owner.(*intNode).avlHeader },
+		Cmp:       cmpIntNode,
+		Workers:   4,
+		ChunkSize: 1000,
+		Progress: func(p AvlLoadProgress) {
+			if len(phases) == 0 || phases[len(phases)-1] != p.Phase {
+				phases = append(phases, p.Phase)
+			}
+		},
+	}
+
+	tree, err := l.Load(context.Background(), intNodeSeq(ns))
+	assert.NoError(t, err)
+	assert.NoError(t, tree.Validate(cmpIntNode))
+	assert.Equal(t, AvlLoadDone, phases[len(phases)-1])
+	assert.Equal(t, AvlLoadBuilding, phases[len(phases)-2])
+	assert.Equal(t, AvlLoadMerging, phases[len(phases)-3])
+
+	// One owner per key, the first read
+
+	firsts := map[int]*intNode{}
+	for _, n := range ns {
+		if firsts[n.key] == nil {
+			firsts[n.key] = n
+		}
+	}
+	assert.Equal(t, len(firsts), tree.Len())
+	for k, n := range firsts {
+		assert.Same(t, n, tree.Lookup(k, cmpIntKey))
+	}
+
+	// Keeping duplicates, in the order read
+
+	dups := newIntNodes(3, 1, 3, 2, 3)
+	l.Options = []AvlTreeOption{WithDuplicates(AvlDupKeepRight)}
+	l.ChunkSize = 2
+	tree, err = l.Load(context.Background(), intNodeSeq(dups))
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{dups[1], dups[3], dups[0], dups[2], dups[4]},
+		AvlTreeWindow(&dups[1].avlHeader, 0, 4))
+
+	// Empty input, and a cancelled load
+
+	tree, err = (&AvlLoader{Cmp: cmpIntNode}).Load(context.Background(), intNodeSeq(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, tree.Len())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tree, err = l.Load(ctx, intNodeSeq(newIntNodes(1, 2, 3, 4, 5)))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, tree)
+}
+
+func TestAvlLoaderCancelBuild(t *testing.T) {
+
+	ns := newIntNodes(make([]int, 5000)...)
+	for i, n := range ns {
+		n.key = i
+	}
+
+	// Cancelled once everything is read, sorted and merged
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := &AvlLoader{Header: intNodeHeader, Cmp: cmpIntNode, ChunkSize: 1000,
+		Progress: func(p AvlLoadProgress) {
+			if p.Phase == AvlLoadBuilding {
+				cancel()
+			}
+		}}
+
+	tree, err := l.Load(ctx, intNodeSeq(ns))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, tree)
+}
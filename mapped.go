@@ -0,0 +1,262 @@
+package avl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+//
+// Mapped trees.  A read-only tree of byte string keys and values laid
+// out in a file so it can be mapped into memory and searched where it
+// lies, without reading it into the heap first.  Children are found by
+// file offset rather than by pointer:
+//
+//	"AVLM", a version byte (1), three zero bytes, the entry count (u64)
+//	and the offset of the root node (u64), then the nodes in pre-order,
+//	each as the offsets of its left and right children (u64, 0 for
+//	none), the lengths of its key and value (u32), the key and the value
+//
+// All integers are little-endian.  The tree is rebuilt perfectly
+// balanced when written, whatever the shape of the live tree.
+//
+
+const (
+	avlMappedMagic   = "AVLM"
+	avlMappedVersion = 1
+	avlMappedHeader  = 24
+	avlMappedNode    = 24
+)
+
+// A mapped tree, opened with AvlMappedOpen or AvlMappedFromBytes.  The
+// keys and values it returns point into the mapping, so must not be
+// modified, and are only valid until Close
+
+type AvlMapped struct {
+	data  []byte
+	cmp   func(a, b []byte) int
+	count int
+	root  uint64
+	unmap func() error
+}
+
+// Writes the tree rooted at root to w as a mapped tree, taking the key
+// and value of each owner from entry.  The keys must sort in the same
+// order under the comparison function the file will be opened with as
+// the owners do in the tree
+
+func AvlTreeWriteMapped(w io.Writer, root *AvlNode,
+	entry func(owner interface{}) (key, value []byte, err error)) error {
+
+	var keys, values [][]byte
+
+	for n := avlTreeFirstOrLastInOrder(root, -1); n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+		k, v, err := entry(n.owner)
+		if err != nil {
+			return err
+		}
+		if uint64(len(k)) > 1<<32-1 || uint64(len(v)) > 1<<32-1 {
+			return fmt.Errorf("avl: mapped entry too big")
+		}
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+
+	// sizes[i] is the number of bytes taken by the first i nodes, so a
+	// subtree over entries [lo, hi) takes sizes[hi] - sizes[lo]
+
+	sizes := make([]uint64, len(keys)+1)
+	for i := range keys {
+		sizes[i+1] = sizes[i] + avlMappedNode + uint64(len(keys[i])+len(values[i]))
+	}
+
+	bw := bufio.NewWriter(w)
+
+	var hdr [avlMappedHeader]byte
+	copy(hdr[:], avlMappedMagic)
+	hdr[4] = avlMappedVersion
+	binary.LittleEndian.PutUint64(hdr[8:], uint64(len(keys)))
+	if len(keys) > 0 {
+		binary.LittleEndian.PutUint64(hdr[16:], avlMappedHeader)
+	}
+	bw.Write(hdr[:])
+
+	// Pre-order: a node, then its left subtree, then its right one
+
+	var write func(lo, hi int, at uint64)
+
+	write = func(lo, hi int, at uint64) {
+		if lo >= hi {
+			return
+		}
+
+		mid := lo + (hi-lo)/2
+		left := at + avlMappedNode + uint64(len(keys[mid])+len(values[mid]))
+		right := left + sizes[mid] - sizes[lo]
+
+		var rec [avlMappedNode]byte
+		if mid > lo {
+			binary.LittleEndian.PutUint64(rec[0:], left)
+		}
+		if hi > mid+1 {
+			binary.LittleEndian.PutUint64(rec[8:], right)
+		}
+		binary.LittleEndian.PutUint32(rec[16:], uint32(len(keys[mid])))
+		binary.LittleEndian.PutUint32(rec[20:], uint32(len(values[mid])))
+		bw.Write(rec[:])
+		bw.Write(keys[mid])
+		bw.Write(values[mid])
+
+		write(lo, mid, left)
+		write(mid+1, hi, right)
+	}
+
+	write(0, len(keys), avlMappedHeader)
+
+	return bw.Flush()
+}
+
+// Opens a mapped tree held in data, ordering keys with cmp (nil means
+// bytes.Compare)
+
+func AvlMappedFromBytes(data []byte, cmp func(a, b []byte) int) (*AvlMapped, error) {
+
+	if cmp == nil {
+		cmp = bytes.Compare
+	}
+
+	if len(data) < avlMappedHeader || string(data[:4]) != avlMappedMagic ||
+		data[4] != avlMappedVersion {
+		return nil, ErrBadDump
+	}
+
+	m := &AvlMapped{
+		data:  data,
+		cmp:   cmp,
+		count: int(binary.LittleEndian.Uint64(data[8:])),
+		root:  binary.LittleEndian.Uint64(data[16:]),
+	}
+
+	if (m.count == 0) != (m.root == 0) {
+		return nil, ErrBadDump
+	}
+
+	return m, nil
+}
+
+// Returns the children, key and value of the node at off, or ok false
+// if the node does not fit in the file
+
+func (m *AvlMapped) node(off uint64) (left, right uint64, key, value []byte, ok bool) {
+
+	if off < avlMappedHeader || off > uint64(len(m.data))-avlMappedNode {
+		return
+	}
+
+	rec := m.data[off:]
+	kl := uint64(binary.LittleEndian.Uint32(rec[16:]))
+	vl := uint64(binary.LittleEndian.Uint32(rec[20:]))
+	if kl+vl > uint64(len(rec))-avlMappedNode {
+		return
+	}
+
+	left = binary.LittleEndian.Uint64(rec[0:])
+	right = binary.LittleEndian.Uint64(rec[8:])
+	key = rec[avlMappedNode : avlMappedNode+kl : avlMappedNode+kl]
+	value = rec[avlMappedNode+kl : avlMappedNode+kl+vl : avlMappedNode+kl+vl]
+
+	// Children follow their parent, so a malformed file cannot loop
+
+	if (left != 0 && left <= off) || (right != 0 && right <= off) {
+		return
+	}
+
+	return left, right, key, value, true
+}
+
+// Returns the number of entries
+
+func (m *AvlMapped) Len() int {
+	return m.count
+}
+
+// Looks up key.  Returns ErrBadDump if a malformed node is reached
+
+func (m *AvlMapped) Lookup(key []byte) (value []byte, found bool, err error) {
+
+	for off := m.root; off != 0; {
+		left, right, k, v, ok := m.node(off)
+		if !ok {
+			return nil, false, ErrBadDump
+		}
+
+		res := m.cmp(key, k)
+		if res < 0 {
+			off = left
+		} else if res > 0 {
+			off = right
+		} else {
+			return v, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// Calls fn, in order, with each entry whose key is in [lo, hi), until fn
+// returns false.  A nil bound leaves that end of the range open
+
+func (m *AvlMapped) Range(lo, hi []byte, fn func(key, value []byte) bool) error {
+	_, err := m.walk(m.root, lo, hi, fn)
+	return err
+}
+
+func (m *AvlMapped) walk(off uint64, lo, hi []byte,
+	fn func(key, value []byte) bool) (bool, error) {
+
+	if off == 0 {
+		return true, nil
+	}
+
+	left, right, k, v, ok := m.node(off)
+	if !ok {
+		return false, ErrBadDump
+	}
+
+	aboveLo := lo == nil || m.cmp(k, lo) >= 0
+	belowHi := hi == nil || m.cmp(k, hi) < 0
+
+	if aboveLo {
+		if more, err := m.walk(left, lo, hi, fn); !more || err != nil {
+			return false, err
+		}
+	}
+	if aboveLo && belowHi && !fn(k, v) {
+		return false, nil
+	}
+	if belowHi {
+		return m.walk(right, lo, hi, fn)
+	}
+
+	return true, nil
+}
+
+// Releases the mapping, if the tree was opened with AvlMappedOpen
+
+func (m *AvlMapped) Close() error {
+
+	m.data = nil
+	m.root = 0
+	m.count = 0
+
+	if m.unmap == nil {
+		return nil
+	}
+
+	unmap := m.unmap
+	m.unmap = nil
+
+	return unmap()
+}
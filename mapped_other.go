@@ -0,0 +1,19 @@
+//go:build !unix
+
+package avl
+
+import "os"
+
+// Reads the mapped tree in the file path and opens it, ordering keys
+// with cmp (nil means bytes.Compare).  Without mmap the whole file is
+// read into memory
+
+func AvlMappedOpen(path string, cmp func(a, b []byte) int) (*AvlMapped, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return AvlMappedFromBytes(data, cmp)
+}
@@ -0,0 +1,92 @@
+package avl
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAvlTreeWriteMapped(t *testing.T) {
+
+	var tree AvlTree
+
+	for i := 0; i < 1000; i += 3 {
+		n := &intNode{key: i}
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	entry := func(owner interface{}) ([]byte, []byte, error) {
+		k := owner.(*intNode).key
+		return []byte(fmt.Sprintf("%04d", k)), []byte(fmt.Sprint(k * 2)), nil
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, AvlTreeWriteMapped(&buf, tree.Root(), entry))
+
+	path := filepath.Join(t.TempDir(), "tree.avlm")
+	assert.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+
+	m, err := AvlMappedOpen(path, nil)
+	assert.NoError(t, err)
+	defer m.Close()
+
+	assert.Equal(t, tree.Len(), m.Len())
+
+	for i := 0; i < 1000; i++ {
+		v, ok, err := m.Lookup([]byte(fmt.Sprintf("%04d", i)))
+		assert.NoError(t, err)
+		assert.Equal(t, i%3 == 0, ok)
+		if ok {
+			assert.Equal(t, fmt.Sprint(i*2), string(v))
+		}
+	}
+
+	var keys []string
+	assert.NoError(t, m.Range([]byte("0100"), []byte("0112"), func(k, v []byte) bool {
+		keys = append(keys, string(k))
+		return true
+	}))
+	assert.Equal(t, []string{"0102", "0105", "0108", "0111"}, keys)
+
+	count := 0
+	m.Range(nil, nil, func(k, v []byte) bool {
+		count++
+		return count < 10
+	})
+	assert.Equal(t, 10, count)
+}
+
+func TestAvlMappedMalformed(t *testing.T) {
+
+	var buf bytes.Buffer
+	assert.NoError(t, AvlTreeWriteMapped(&buf, nil, nil))
+
+	m, err := AvlMappedFromBytes(buf.Bytes(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, m.Len())
+	_, ok, err := m.Lookup([]byte("x"))
+	assert.False(t, ok)
+	assert.NoError(t, err)
+
+	_, err = AvlMappedFromBytes([]byte("AVLD"), nil)
+	assert.ErrorIs(t, err, ErrBadDump)
+
+	// A truncated file is caught when the missing node is reached
+
+	var tree AvlTree
+	n := &intNode{key: 1}
+	tree.Insert(&n.avlHeader, n, cmpIntNode)
+
+	buf.Reset()
+	AvlTreeWriteMapped(&buf, tree.Root(), func(owner interface{}) ([]byte, []byte, error) {
+		return []byte("key"), []byte("value"), nil
+	})
+
+	m, err = AvlMappedFromBytes(buf.Bytes()[:buf.Len()-1], nil)
+	assert.NoError(t, err)
+	_, _, err = m.Lookup([]byte("key"))
+	assert.ErrorIs(t, err, ErrBadDump)
+}
@@ -0,0 +1,46 @@
+//go:build unix
+
+package avl
+
+import (
+	"os"
+	"syscall"
+)
+
+// Maps the mapped tree in the file path into memory, read-only, and
+// opens it, ordering keys with cmp (nil means bytes.Compare)
+
+func AvlMappedOpen(path string, cmp func(a, b []byte) int) (*AvlMapped, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() < avlMappedHeader || int64(int(fi.Size())) != fi.Size() {
+		return nil, ErrBadDump
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(fi.Size()),
+		syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := AvlMappedFromBytes(data, cmp)
+	if err != nil {
+		syscall.Munmap(data)
+		return nil, err
+	}
+
+	m.unmap = func() error {
+		return syscall.Munmap(data)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,87 @@
+package avl
+
+import "fmt"
+
+//
+// Misuse detection.  A tree made WithMisuseHandler checks the arguments
+// of its insertion and removal methods for the mistakes that would
+// otherwise corrupt it silently: inserting a node that is already in the
+// tree, removing a node that is not, and a comparison function that
+// disagrees with itself.  Each mistake is passed to the handler, as an
+// error, instead of being carried out; the method then does nothing and
+// returns zero values.  The handler decides the policy: log and count in
+// production, panic in tests.
+//
+// The checks walk from the node to the root, and make three extra calls
+// to the comparator per insertion, so they cost O(log n) per operation.
+// The comparator checks only the new owner against itself and against
+// the root's owner, which catches a comparator that ignores the order of
+// its arguments, not every inconsistency.
+//
+
+// Checks insertions and removals for misuse, and passes each one found
+// to handler instead of carrying out the operation
+
+func WithMisuseHandler(handler func(err error)) AvlTreeOption {
+	return func(tree *AvlTree) {
+		tree.onMisuse = handler
+	}
+}
+
+func avlSign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// True, after telling the handler, if inserting item would be misuse
+
+func (tree *AvlTree) misusedInsert(item *AvlNode, owner interface{},
+	cmp CmpFuncNode) bool {
+
+	if tree.onMisuse == nil {
+		return false
+	}
+
+	err := avlTreeCheckInsert(tree.root, item, cmp)
+	if err == nil {
+		if c := cmp(owner, owner); c != 0 {
+			err = fmt.Errorf("%w: %v compares %d with itself",
+				ErrInconsistentCmp, owner, c)
+		}
+	}
+	if err == nil && tree.root != nil {
+		other := tree.root.owner
+		if avlSign(cmp(owner, other)) != -avlSign(cmp(other, owner)) {
+			err = fmt.Errorf("%w: comparing %v with %v disagrees with "+
+				"comparing them the other way round", ErrInconsistentCmp,
+				owner, other)
+		}
+	}
+	if err != nil {
+		tree.onMisuse(err)
+		return true
+	}
+
+	return false
+}
+
+// True, after telling the handler, if removing node would be misuse
+
+func (tree *AvlTree) misusedRemove(node *AvlNode) bool {
+
+	if tree.onMisuse == nil {
+		return false
+	}
+
+	if err := AvlTreeAssertContains(tree.root, node); err != nil {
+		tree.onMisuse(err)
+		return true
+	}
+
+	return false
+}
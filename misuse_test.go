@@ -0,0 +1,59 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAvlTreeMisuseHandler(t *testing.T) {
+
+	var errs []error
+	tree := NewAvlTree(WithMisuseHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	var other AvlTree
+
+	ns := newIntNodes(1, 2, 3, 4)
+	for _, n := range ns[:3] {
+		assert.Nil(t, tree.Insert(&n.avlHeader, n, cmpIntNode))
+	}
+	other.Insert(&ns[3].avlHeader, ns[3], cmpIntNode)
+	assert.Empty(t, errs)
+
+	// Double insert
+
+	assert.Nil(t, tree.Insert(&ns[1].avlHeader, ns[1], cmpIntNode))
+	assert.False(t, tree.InsertIfAbsent(&ns[1].avlHeader, ns[1], cmpIntNode))
+	assert.Len(t, errs, 2)
+	assert.ErrorIs(t, errs[0], ErrAlreadyLinked)
+
+	// Foreign and repeated removal
+
+	assert.Nil(t, tree.Remove(&ns[3].avlHeader))
+	assert.Same(t, ns[0], tree.Remove(&ns[0].avlHeader))
+	assert.Nil(t, tree.Remove(&ns[0].avlHeader))
+	assert.Len(t, errs, 4)
+	assert.ErrorIs(t, errs[2], ErrNotInTree)
+	assert.ErrorIs(t, errs[3], ErrNotInTree)
+
+	// A comparator that ignores argument order
+
+	bad := func(a, b interface{}) int {
+		return 1
+	}
+	n := &intNode{key: 9}
+	_, inserted := tree.GetOrInsert(&n.avlHeader, n, bad)
+	assert.False(t, inserted)
+	assert.Len(t, errs, 5)
+	assert.ErrorIs(t, errs[4], ErrInconsistentCmp)
+
+	assert.Equal(t, 2, tree.Len())
+	assert.NoError(t, tree.Validate(cmpIntNode))
+	assert.NoError(t, other.Validate(cmpIntNode))
+	assert.Equal(t, 1, other.Len())
+
+	// A handler that panics escalates
+
+	strict := NewAvlTree(WithMisuseHandler(func(err error) { panic(err) }))
+	assert.Panics(t, func() { strict.Remove(&ns[3].avlHeader) })
+}
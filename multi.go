@@ -0,0 +1,133 @@
+package avl
+
+import "fmt"
+
+//
+// Multiple indexes over the same owners.  The usual kernel pattern for
+// an object that must be found by several keys is to embed one AvlNode
+// per key and link it into one tree per key.  AvlMultiIndex keeps those
+// trees together: Insert and Remove update every index, and either
+// succeed for all of them or change none.
+//
+
+// Describes one index: Header returns the owner's AvlNode for the
+// index, and Cmp orders the owners in it
+
+type AvlIndexDef struct {
+	Name   string
+	Header func(owner interface{}) *AvlNode
+	Cmp    CmpFuncNode
+}
+
+type AvlMultiIndex struct {
+	defs  []AvlIndexDef
+	trees []AvlTree
+}
+
+// Returns an empty multi-index with the given indexes, whose names must
+// be distinct
+
+func NewAvlMultiIndex(defs ...AvlIndexDef) *AvlMultiIndex {
+
+	for i := range defs {
+		for j := 0; j < i; j++ {
+			if defs[i].Name == defs[j].Name {
+				panic(fmt.Sprintf("avl: index %q defined twice", defs[i].Name))
+			}
+		}
+	}
+
+	return &AvlMultiIndex{
+		defs:  append([]AvlIndexDef(nil), defs...),
+		trees: make([]AvlTree, len(defs)),
+	}
+}
+
+// Returns the number of owners
+
+func (m *AvlMultiIndex) Len() int {
+	if len(m.trees) == 0 {
+		return 0
+	}
+	return m.trees[0].Len()
+}
+
+// Returns the tree of the named index, or nil if there is none.  The
+// tree is for reading only: modify the multi-index instead
+
+func (m *AvlMultiIndex) Index(name string) *AvlTree {
+	for i := range m.defs {
+		if m.defs[i].Name == name {
+			return &m.trees[i]
+		}
+	}
+	return nil
+}
+
+// Looks up key in the named index.  nil if not present, or if there is
+// no such index
+
+func (m *AvlMultiIndex) Lookup(name string, key interface{}, cmp CmpFuncKey) interface{} {
+	if tree := m.Index(name); tree != nil {
+		return tree.Lookup(key, cmp)
+	}
+	return nil
+}
+
+// Inserts owner into every index.  Returns an error wrapping
+// ErrAlreadyLinked or ErrKeyExists, naming the index, and inserts it
+// into none, if any index already holds the owner's node or key
+
+func (m *AvlMultiIndex) Insert(owner interface{}) error {
+
+	for i, d := range m.defs {
+		node := d.Header(owner)
+		if avlTreeContains(m.trees[i].root, node) {
+			return fmt.Errorf("%w: index %q: %v", ErrAlreadyLinked, d.Name, owner)
+		}
+		if m.trees[i].Lookup(owner, CmpFuncKey(d.Cmp)) != nil {
+			return fmt.Errorf("%w: index %q: %v", ErrKeyExists, d.Name, owner)
+		}
+	}
+
+	for i, d := range m.defs {
+		m.trees[i].Insert(d.Header(owner), owner, d.Cmp)
+	}
+
+	return nil
+}
+
+// Removes owner from every index.  Returns an error wrapping
+// ErrNotInTree, and removes it from none, unless every index holds it
+
+func (m *AvlMultiIndex) Remove(owner interface{}) error {
+
+	for i, d := range m.defs {
+		if !avlTreeContains(m.trees[i].root, d.Header(owner)) {
+			return fmt.Errorf("%w: index %q: %v", ErrNotInTree, d.Name, owner)
+		}
+	}
+
+	for i, d := range m.defs {
+		m.trees[i].Remove(d.Header(owner))
+	}
+
+	return nil
+}
+
+// Checks every index; see AvlTree.Validate
+
+func (m *AvlMultiIndex) Validate() error {
+
+	for i, d := range m.defs {
+		if err := m.trees[i].Validate(d.Cmp); err != nil {
+			return fmt.Errorf("index %q: %w", d.Name, err)
+		}
+		if m.trees[i].Len() != m.Len() {
+			return fmt.Errorf("%w: index %q has %d owners, not %d",
+				ErrInvalidTree, d.Name, m.trees[i].Len(), m.Len())
+		}
+	}
+
+	return nil
+}
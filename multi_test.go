@@ -0,0 +1,67 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type userNode struct {
+	byId   AvlNode
+	byName AvlNode
+	id     int
+	name   string
+}
+
+func newUserIndex() *AvlMultiIndex {
+	return NewAvlMultiIndex(
+		AvlIndexDef{
+			Name:   "id",
+			Header: func(owner interface{}) *AvlNode { return &owner.(*userNode).byId },
+			Cmp: func(a, b interface{}) int {
+				return a.(*userNode).id - b.(*userNode).id
+			},
+		},
+		AvlIndexDef{
+			Name:   "name",
+			Header: func(owner interface{}) *AvlNode { return &owner.(*userNode).byName },
+			Cmp: func(a, b interface{}) int {
+				return AvlCompareValues(a.(*userNode).name, b.(*userNode).name)
+			},
+		},
+	)
+}
+
+func TestAvlMultiIndex(t *testing.T) {
+
+	m := newUserIndex()
+
+	ann := &userNode{id: 1, name: "ann"}
+	bob := &userNode{id: 2, name: "bob"}
+	ann2 := &userNode{id: 3, name: "ann"}
+
+	assert.NoError(t, m.Insert(ann))
+	assert.NoError(t, m.Insert(bob))
+
+	// A clash in any index leaves every index alone
+
+	assert.ErrorIs(t, m.Insert(ann2), ErrKeyExists)
+	assert.ErrorIs(t, m.Insert(ann), ErrAlreadyLinked)
+	assert.Equal(t, 2, m.Len())
+	assert.Equal(t, 2, m.Index("id").Len())
+	assert.Nil(t, m.Lookup("id", 3, func(k, n interface{}) int { return k.(int) - n.(*userNode).id }))
+
+	byName := func(k, n interface{}) int { return AvlCompareValues(k, n.(*userNode).name) }
+	assert.Equal(t, bob, m.Lookup("name", "bob", byName))
+	assert.Nil(t, m.Lookup("nope", "bob", byName))
+	assert.Nil(t, m.Index("nope"))
+
+	assert.NoError(t, m.Remove(ann))
+	assert.ErrorIs(t, m.Remove(ann), ErrNotInTree)
+	assert.NoError(t, m.Insert(ann2))
+	assert.Equal(t, ann2, m.Lookup("name", "ann", byName))
+	assert.NoError(t, m.Validate())
+
+	assert.Panics(t, func() {
+		NewAvlMultiIndex(AvlIndexDef{Name: "x"}, AvlIndexDef{Name: "x"})
+	})
+}
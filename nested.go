@@ -0,0 +1,159 @@
+package avl
+
+import "iter"
+
+//
+// Nested indexes.  An AvlNestedIndex is a two-level index: an outer tree
+// of groups, one per outer key (a tenant, say), each holding an inner
+// AvlTree of the owners with that outer key (ordered by timestamp, say).
+// The owners embed the AvlNode of the inner tree; the groups belong to
+// the index, which creates one when the first owner with its outer key
+// is inserted and drops it when the last is removed.
+//
+
+type avlNestedGroup struct {
+	header AvlNode
+	key    interface{}
+	tree   AvlTree
+}
+
+type AvlNestedIndex struct {
+	groups   AvlTree
+	outerKey func(owner interface{}) interface{}
+	outerCmp func(a, b interface{}) int
+	innerCmp CmpFuncNode
+	size     int
+}
+
+// Returns an empty index.  outerKey returns the outer key of an owner,
+// which must not change while the owner is in the index; outerCmp orders
+// outer keys (nil means AvlCompareValues) and innerCmp orders the owners
+// within a group
+
+func NewAvlNestedIndex(outerKey func(owner interface{}) interface{},
+	outerCmp func(a, b interface{}) int, innerCmp CmpFuncNode) *AvlNestedIndex {
+
+	if outerCmp == nil {
+		outerCmp = AvlCompareValues
+	}
+
+	return &AvlNestedIndex{
+		outerKey: outerKey,
+		outerCmp: outerCmp,
+		innerCmp: innerCmp,
+	}
+}
+
+func (ix *AvlNestedIndex) cmpGroupKey(key interface{}, node interface{}) int {
+	return ix.outerCmp(key, node.(*avlNestedGroup).key)
+}
+
+func (ix *AvlNestedIndex) cmpGroups(a, b interface{}) int {
+	return ix.outerCmp(a.(*avlNestedGroup).key, b.(*avlNestedGroup).key)
+}
+
+func (ix *AvlNestedIndex) group(key interface{}) *avlNestedGroup {
+	if g := ix.groups.Lookup(key, ix.cmpGroupKey); g != nil {
+		return g.(*avlNestedGroup)
+	}
+	return nil
+}
+
+// Returns the number of owners in the index
+
+func (ix *AvlNestedIndex) Len() int {
+	return ix.size
+}
+
+// Returns the number of groups, i.e. of distinct outer keys
+
+func (ix *AvlNestedIndex) Groups() int {
+	return ix.groups.Len()
+}
+
+// Inserts item, whose owner is owner, into the group for owner's outer
+// key, creating the group if need be.  Returns nil if item was
+// inserted, or the owner already present in the group under the same
+// inner key
+
+func (ix *AvlNestedIndex) Insert(item *AvlNode, owner interface{}) interface{} {
+
+	key := ix.outerKey(owner)
+
+	g := ix.group(key)
+	if g == nil {
+		g = &avlNestedGroup{key: key}
+		ix.groups.Insert(&g.header, g, ix.cmpGroups)
+	}
+
+	if existing := g.tree.Insert(item, owner, ix.innerCmp); existing != nil {
+		return existing
+	}
+	ix.size++
+
+	return nil
+}
+
+// Removes item from the index, dropping its group if it was the last
+// owner in it.  item must be in the index
+
+func (ix *AvlNestedIndex) Remove(item *AvlNode) {
+
+	g := ix.group(ix.outerKey(item.owner))
+
+	g.tree.Remove(item)
+	ix.size--
+
+	if g.tree.Len() == 0 {
+		ix.groups.Remove(&g.header)
+	}
+}
+
+// Looks up the owner with outer key outer and inner key inner, compared
+// with the owners of the group by cmp.  nil if not present
+
+func (ix *AvlNestedIndex) Lookup(outer, inner interface{}, cmp CmpFuncKey) interface{} {
+
+	if g := ix.group(outer); g != nil {
+		return g.tree.Lookup(inner, cmp)
+	}
+
+	return nil
+}
+
+// Returns the inner tree of the group for outer, or nil if there is
+// none.  The tree is for reading only: modify the index instead
+
+func (ix *AvlNestedIndex) Group(outer interface{}) *AvlTree {
+
+	if g := ix.group(outer); g != nil {
+		return &g.tree
+	}
+
+	return nil
+}
+
+// Yields the outer key and owner of every owner in the index, ordered by
+// outer key and then within each group.  The index must not be modified
+// while the sequence is being iterated
+
+func (ix *AvlNestedIndex) All() iter.Seq2[interface{}, interface{}] {
+	return ix.Range(nil, nil)
+}
+
+// All, over the groups whose outer keys are in [lo, hi); a nil bound
+// leaves that end of the range open
+
+func (ix *AvlNestedIndex) Range(lo, hi interface{}) iter.Seq2[interface{}, interface{}] {
+	return func(yield func(interface{}, interface{}) bool) {
+		ix.groups.Range(lo, hi, ix.cmpGroupKey, func(owner interface{}) bool {
+			g := owner.(*avlNestedGroup)
+			for n := g.tree.first; n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+				if !yield(g.key, n.owner) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type eventNode struct {
+	avlHeader AvlNode
+	tenant    string
+	time      int
+}
+
+func cmpEventTime(key interface{}, node interface{}) int {
+	return key.(int) - node.(*eventNode).time
+}
+
+func cmpEvents(node1 interface{}, node2 interface{}) int {
+	return node1.(*eventNode).time - node2.(*eventNode).time
+}
+
+func TestAvlNestedIndex(t *testing.T) {
+
+	ix := NewAvlNestedIndex(func(owner interface{}) interface{} {
+		return owner.(*eventNode).tenant
+	}, nil, cmpEvents)
+
+	events := []*eventNode{
+		{tenant: "b", time: 3},
+		{tenant: "a", time: 2},
+		{tenant: "b", time: 1},
+		{tenant: "c", time: 5},
+		{tenant: "a", time: 4},
+	}
+	for _, e := range events {
+		assert.Nil(t, ix.Insert(&e.avlHeader, e))
+	}
+
+	dup := &eventNode{tenant: "a", time: 2}
+	assert.Equal(t, events[1], ix.Insert(&dup.avlHeader, dup))
+
+	assert.Equal(t, 5, ix.Len())
+	assert.Equal(t, 3, ix.Groups())
+	assert.Equal(t, events[2], ix.Lookup("b", 1, cmpEventTime))
+	assert.Nil(t, ix.Lookup("b", 2, cmpEventTime))
+	assert.Nil(t, ix.Lookup("z", 1, cmpEventTime))
+	assert.Equal(t, 2, ix.Group("a").Len())
+	assert.Nil(t, ix.Group("z"))
+
+	var got []string
+	for tenant, owner := range ix.All() {
+		got = append(got, tenant.(string)+string(rune('0'+owner.(*eventNode).time)))
+	}
+	assert.Equal(t, []string{"a2", "a4", "b1", "b3", "c5"}, got)
+
+	got = nil
+	for tenant := range ix.Range("b", nil) {
+		got = append(got, tenant.(string))
+	}
+	assert.Equal(t, []string{"b", "b", "c"}, got)
+
+	// Removing the last owner of a group drops the group
+
+	ix.Remove(&events[3].avlHeader)
+	assert.Equal(t, 2, ix.Groups())
+	assert.Nil(t, ix.Group("c"))
+
+	ix.Remove(&events[0].avlHeader)
+	assert.Equal(t, 2, ix.Groups())
+	assert.Equal(t, 3, ix.Len())
+}
@@ -0,0 +1,280 @@
+package avl
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+//
+// Order statistics, built on the subtree size kept in every node.
+//
+
+// Returns the number of nodes in the subtree rooted at n, n included
+
+func (n *AvlNode) SubtreeSize() int {
+	return avlGetSize(n)
+}
+
+// Counts the nodes ordered before key; with orEqual, the nodes equal to
+// key are counted too
+
+func avlTreeCountBefore(root *AvlNode, key interface{}, cmp CmpFuncKey,
+	orEqual bool) int {
+
+	count := 0
+
+	for cur := root; cur != nil; {
+		res := cmp(key, cur.owner)
+		if res > 0 || (orEqual && res == 0) {
+			count += avlGetSize(cur.left) + 1
+			cur = cur.right
+		} else {
+			cur = cur.left
+		}
+	}
+
+	return count
+}
+
+// Returns the in-order index of the first node whose key is >= key, or
+// the number of nodes if there is none; i.e. the position at which key
+// would be inserted, like Python's bisect_left.  O(log n)
+
+func AvlTreeIndexOfFirstGE(root *AvlNode, key interface{}, cmp CmpFuncKey) int {
+	return avlTreeCountBefore(root, key, cmp, false)
+}
+
+// Returns the in-order index of the first node whose key is > key, or
+// the number of nodes if there is none, like Python's bisect_right.
+// O(log n)
+
+func AvlTreeIndexOfFirstGT(root *AvlNode, key interface{}, cmp CmpFuncKey) int {
+	return avlTreeCountBefore(root, key, cmp, true)
+}
+
+// Returns the number of nodes whose key is < key.  O(log n)
+
+func AvlTreeCountLess(root *AvlNode, key interface{}, cmp CmpFuncKey) int {
+	return avlTreeCountBefore(root, key, cmp, false)
+}
+
+// Returns the number of nodes whose key is > key.  O(log n)
+
+func AvlTreeCountGreater(root *AvlNode, key interface{}, cmp CmpFuncKey) int {
+	return avlGetSize(root) - avlTreeCountBefore(root, key, cmp, true)
+}
+
+// Returns the node at in-order index i, or nil if i is out of range
+
+func avlTreeSelect(root *AvlNode, i int) *AvlNode {
+
+	if i < 0 {
+		return nil
+	}
+
+	cur := root
+	for cur != nil {
+		l := avlGetSize(cur.left)
+		if i < l {
+			cur = cur.left
+		} else if i > l {
+			i -= l + 1
+			cur = cur.right
+		} else {
+			break
+		}
+	}
+
+	return cur
+}
+
+// Returns the owner at in-order index i, or nil if i is out of range.
+// O(log n)
+
+func AvlTreeSelect(root *AvlNode, i int) interface{} {
+	if n := avlTreeSelect(root, i); n != nil {
+		return n.owner
+	}
+	return nil
+}
+
+// Returns the node k places after node in order (before it, for k < 0),
+// or nil if there is none.  Climbs only as far as the first ancestor
+// whose subtree holds the target, then descends to it
+
+func avlTreeAdvance(node *AvlNode, k int) *AvlNode {
+
+	// The target's index in the subtree rooted at n
+
+	n := node
+	i := avlGetSize(n.left) + k
+
+	for i < 0 || i >= avlGetSize(n) {
+		p := avlGetParent(n)
+		if p == nil {
+			return nil
+		}
+		if n == p.right {
+			i += avlGetSize(p.left) + 1
+		}
+		n = p
+	}
+
+	return avlTreeSelect(n, i)
+}
+
+// Returns the owner k places after node, which must be linked into a
+// tree, in order, or before it for negative k; nil if that is beyond
+// either end.  Uses the subtree sizes rather than k steps, so sampling
+// every kth owner is O(log n) per sample.  O(log n)
+
+func AvlTreeAdvance(node *AvlNode, k int) interface{} {
+	if n := avlTreeAdvance(node, k); n != nil {
+		return n.owner
+	}
+	return nil
+}
+
+// Returns the owners around node, which must be linked into a tree, in
+// order: up to before owners preceding it, node's own, and up to after
+// owners following it; fewer near either end of the tree.
+// O(before + after + log n)
+
+func AvlTreeWindow(node *AvlNode, before, after int) []interface{} {
+
+	first := node
+	for i := 0; i < before; i++ {
+		prev := avlTreeNextOrPrevInOrder(first, -1)
+		if prev == nil {
+			break
+		}
+		first = prev
+	}
+
+	window := make([]interface{}, 0, max(before, 0)+1+max(after, 0))
+	for n := first; n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+		window = append(window, n.owner)
+		if n == node {
+			break
+		}
+	}
+	for n, i := node, 0; i < after; i++ {
+		if n = avlTreeNextOrPrevInOrder(n, 1); n == nil {
+			break
+		}
+		window = append(window, n.owner)
+	}
+
+	return window
+}
+
+// Returns the in-order index of node, which must be linked into a tree:
+// the number of nodes before it.  Adds up the sizes of the left subtrees
+// passed on the way up to the root, so needs no comparisons.  O(log n)
+
+func AvlTreeNodeRank(node *AvlNode) int {
+
+	rank := avlGetSize(node.left)
+
+	for n := node; ; {
+		p := avlGetParent(n)
+		if p == nil {
+			return rank
+		}
+		if n == p.right {
+			rank += avlGetSize(p.left) + 1
+		}
+		n = p
+	}
+}
+
+// Returns the number of nodes strictly between a and b, which must be
+// linked into the same tree, whichever of the two comes first; 0 if they
+// are the same node or adjacent.  O(log n)
+
+func AvlTreeCountBetween(a, b *AvlNode) int {
+
+	d := AvlTreeNodeRank(b) - AvlTreeNodeRank(a)
+	if d < 0 {
+		d = -d
+	}
+
+	return max(d-1, 0)
+}
+
+// Returns the fraction of the nodes whose key is <= key: the empirical
+// cumulative distribution function of the tree at key.  0 for an empty
+// tree.  O(log n)
+
+func AvlTreeCDF(root *AvlNode, key interface{}, cmp CmpFuncKey) float64 {
+
+	n := avlGetSize(root)
+	if n == 0 {
+		return 0
+	}
+
+	return float64(avlTreeCountBefore(root, key, cmp, true)) / float64(n)
+}
+
+// The inverse of AvlTreeCDF: returns the least owner whose CDF is >= p,
+// i.e. the p-quantile of the tree, with p clamped to [0, 1].  nil for an
+// empty tree or a NaN p.  O(log n)
+
+func AvlTreeICDF(root *AvlNode, p float64) interface{} {
+
+	n := avlGetSize(root)
+	if n == 0 || math.IsNaN(p) {
+		return nil
+	}
+
+	p = min(max(p, 0), 1)
+
+	// The least i with (i+1)/n >= p, allowing for rounding in p*n
+
+	i := int(math.Ceil(p*float64(n))) - 1
+	if i > 0 && float64(i)/float64(n) >= p {
+		i--
+	}
+
+	return AvlTreeSelect(root, max(i, 0))
+}
+
+// Returns n distinct owners chosen uniformly at random using r (or the
+// math/rand default source if r is nil), in in-order order; all of them
+// if the tree has no more than n.  O(n log N)
+
+func AvlTreeSample(root *AvlNode, n int, r *rand.Rand) []interface{} {
+
+	size := avlGetSize(root)
+	n = min(max(n, 0), size)
+
+	intn := rand.Intn
+	if r != nil {
+		intn = r.Intn
+	}
+
+	// Floyd's algorithm: n distinct indexes, each set equally likely
+
+	chosen := make(map[int]bool, n)
+	for j := size - n; j < size; j++ {
+		if i := intn(j + 1); chosen[i] {
+			chosen[j] = true
+		} else {
+			chosen[i] = true
+		}
+	}
+
+	indexes := make([]int, 0, n)
+	for i := range chosen {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	owners := make([]interface{}, n)
+	for k, i := range indexes {
+		owners[k] = avlTreeSelect(root, i).owner
+	}
+
+	return owners
+}
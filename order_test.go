@@ -0,0 +1,246 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestAvlNodeSubtreeSize(t *testing.T) {
+
+	var r *AvlNode
+
+	assert.Equal(t, 0, r.SubtreeSize())
+
+	rnd := rand.New(rand.NewSource(1))
+	perm := rnd.Perm(1000)
+
+	r, ns := buildIntTree(perm...)
+	assert.Equal(t, 1000, r.SubtreeSize())
+
+	for i, n := range ns {
+		if i%2 == 0 {
+			AvlTreeRemove(&r, &n.avlHeader)
+		}
+	}
+	assert.Equal(t, 500, r.SubtreeSize())
+
+	// Validate checks every node's size against its children
+
+	assert.NoError(t, AvlTreeValidate(r, cmpIntNode))
+
+	for i, n := range ns {
+		if i%2 == 0 {
+			n2 := &intNode{key: n.key}
+			AvlTreeInsertOrReplace(&r, &n2.avlHeader, n2, cmpIntNode)
+		}
+	}
+	assert.Equal(t, 1000, r.SubtreeSize())
+	assert.NoError(t, AvlTreeValidate(r, cmpIntNode))
+
+	r.size++
+	assert.ErrorIs(t, AvlTreeValidate(r, cmpIntNode), ErrInvalidTree)
+}
+
+func TestAvlTreeIndexOfFirst(t *testing.T) {
+
+	var r *AvlNode
+
+	assert.Equal(t, 0, AvlTreeIndexOfFirstGE(r, 3, cmpIntKey))
+
+	rnd := rand.New(rand.NewSource(2))
+
+	var keys []int
+	for i := 0; i < 300; i++ {
+		keys = append(keys, rnd.Intn(1000)*2)
+	}
+	r, _ = buildIntTree(keys...)
+	sorted := inOrderKeys(r)
+
+	// Compare with sort.SearchInts over the keys, probing both present
+	// and absent keys (all keys are even)
+
+	for k := -1; k <= 2001; k++ {
+		ge := sort.SearchInts(sorted, k)
+		gt := sort.SearchInts(sorted, k+1)
+		assert.Equal(t, ge, AvlTreeIndexOfFirstGE(r, k, cmpIntKey))
+		assert.Equal(t, gt, AvlTreeIndexOfFirstGT(r, k, cmpIntKey))
+	}
+}
+
+func TestAvlTreeCountLessGreater(t *testing.T) {
+
+	var tree AvlTree
+
+	assert.Equal(t, 0, tree.CountGreater(3, cmpIntKey))
+
+	for _, n := range newIntNodes(10, 20, 30, 40, 50) {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	assert.Equal(t, 0, tree.CountLess(10, cmpIntKey))
+	assert.Equal(t, 1, tree.CountLess(11, cmpIntKey))
+	assert.Equal(t, 2, tree.CountLess(30, cmpIntKey))
+	assert.Equal(t, 2, tree.CountGreater(30, cmpIntKey))
+	assert.Equal(t, 3, tree.CountGreater(29, cmpIntKey))
+	assert.Equal(t, 0, tree.CountGreater(50, cmpIntKey))
+	assert.Equal(t, 5, tree.CountLess(99, cmpIntKey))
+}
+
+func TestAvlTreeCDF(t *testing.T) {
+
+	var tree AvlTree
+
+	assert.Equal(t, 0.0, tree.CDF(1, cmpIntKey))
+	assert.Nil(t, tree.ICDF(0.5))
+
+	for i := 1; i <= 10; i++ {
+		n := &intNode{key: i * 10}
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	assert.Equal(t, 30, tree.Select(2).(*intNode).key)
+	assert.Nil(t, tree.Select(10))
+	assert.Nil(t, tree.Select(-1))
+
+	assert.Equal(t, 0.0, tree.CDF(5, cmpIntKey))
+	assert.Equal(t, 0.3, tree.CDF(30, cmpIntKey))
+	assert.Equal(t, 0.3, tree.CDF(35, cmpIntKey))
+	assert.Equal(t, 1.0, tree.CDF(100, cmpIntKey))
+
+	assert.Equal(t, 10, tree.ICDF(0).(*intNode).key)
+	assert.Equal(t, 10, tree.ICDF(0.1).(*intNode).key)
+	assert.Equal(t, 20, tree.ICDF(0.11).(*intNode).key)
+	assert.Equal(t, 50, tree.ICDF(0.5).(*intNode).key)
+	assert.Equal(t, 100, tree.ICDF(1).(*intNode).key)
+	assert.Equal(t, 100, tree.ICDF(math.Inf(1)).(*intNode).key)
+	assert.Nil(t, tree.ICDF(math.NaN()))
+
+	// ICDF inverts CDF at every owner
+
+	for i := 1; i <= 10; i++ {
+		p := tree.CDF(i*10, cmpIntKey)
+		assert.Equal(t, i*10, tree.ICDF(p).(*intNode).key)
+	}
+}
+
+func TestAvlTreeSample(t *testing.T) {
+
+	var tree AvlTree
+
+	assert.Empty(t, tree.Sample(3, nil))
+
+	for i := 0; i < 10; i++ {
+		n := &intNode{key: i}
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	assert.Len(t, tree.Sample(20, nil), 10)
+	assert.Empty(t, tree.Sample(-1, nil))
+
+	// Distinct, in order, and every owner about equally likely
+
+	rnd := rand.New(rand.NewSource(9))
+	counts := make([]int, 10)
+
+	for trial := 0; trial < 10000; trial++ {
+		s := tree.Sample(3, rnd)
+		assert.Len(t, s, 3)
+		for i, owner := range s {
+			k := owner.(*intNode).key
+			if i > 0 {
+				assert.True(t, s[i-1].(*intNode).key < k)
+			}
+			counts[k]++
+		}
+	}
+
+	for _, c := range counts {
+		assert.InDelta(t, 3000, c, 200)
+	}
+}
+
+func TestAvlTreeAdvance(t *testing.T) {
+
+	keys := rand.New(rand.NewSource(1220)).Perm(300)
+	_, ns := buildIntTree(keys...)
+
+	for _, n := range ns[:40] {
+		for _, k := range []int{0, 1, -1, 7, -7, 150, -150, 299, -299, 300, -300} {
+			want := n.key + k
+			got := AvlTreeAdvance(&n.avlHeader, k)
+			if want < 0 || want >= 300 {
+				assert.Nil(t, got)
+			} else {
+				assert.Equal(t, want, got.(*intNode).key)
+			}
+		}
+	}
+
+	// Every 25th owner, from the first
+
+	r, _ := buildIntTree(keys...)
+	var got []int
+	for o := AvlTreeFirstInOrder(r); o != nil; o = AvlTreeAdvance(&o.(*intNode).avlHeader, 25) {
+		got = append(got, o.(*intNode).key)
+	}
+	assert.Equal(t, []int{0, 25, 50, 75, 100, 125, 150, 175, 200, 225, 250, 275}, got)
+}
+
+func TestAvlTreeNodeRank(t *testing.T) {
+
+	keys := rand.New(rand.NewSource(1221)).Perm(500)
+	r, ns := buildIntTree(keys...)
+
+	for _, n := range ns {
+		assert.Equal(t, n.key, AvlTreeNodeRank(&n.avlHeader))
+	}
+
+	for _, n := range ns[:250] {
+		AvlTreeRemove(&r, &n.avlHeader)
+	}
+	var i int
+	for o := AvlTreeFirstInOrder(r); o != nil; o = AvlTreeNextInOrder(&o.(*intNode).avlHeader) {
+		n := o.(*intNode)
+		assert.Equal(t, i, AvlTreeNodeRank(&n.avlHeader))
+		assert.Same(t, n, AvlTreeSelect(r, i))
+		i++
+	}
+}
+
+func TestAvlTreeWindow(t *testing.T) {
+
+	_, ns := buildIntTree(rand.New(rand.NewSource(1222)).Perm(20)...)
+	byKey := make([]*intNode, 20)
+	for _, n := range ns {
+		byKey[n.key] = n
+	}
+
+	keys := func(owners []interface{}) []int {
+		var ks []int
+		for _, o := range owners {
+			ks = append(ks, o.(*intNode).key)
+		}
+		return ks
+	}
+
+	assert.Equal(t, []int{7, 8, 9, 10, 11}, keys(AvlTreeWindow(&byKey[9].avlHeader, 2, 2)))
+	assert.Equal(t, []int{0, 1, 2, 3}, keys(AvlTreeWindow(&byKey[1].avlHeader, 5, 2)))
+	assert.Equal(t, []int{17, 18, 19}, keys(AvlTreeWindow(&byKey[19].avlHeader, 2, 5)))
+	assert.Equal(t, []int{4}, keys(AvlTreeWindow(&byKey[4].avlHeader, 0, -1)))
+}
+
+func TestAvlTreeCountBetween(t *testing.T) {
+
+	_, ns := buildIntTree(rand.New(rand.NewSource(1223)).Perm(100)...)
+	byKey := make([]*intNode, 100)
+	for _, n := range ns {
+		byKey[n.key] = n
+	}
+
+	for _, c := range [][3]int{{10, 20, 9}, {20, 10, 9}, {5, 5, 0}, {5, 6, 0}, {0, 99, 98}} {
+		assert.Equal(t, c[2], AvlTreeCountBetween(&byKey[c[0]].avlHeader, &byKey[c[1]].avlHeader), c)
+	}
+}
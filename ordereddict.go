@@ -0,0 +1,170 @@
+package avl
+
+import "iter"
+
+//
+// OrderedDict is an OrderedMap with a hash index beside the tree, from
+// each key to its entry, for keys that are comparable.  Get, Contains
+// and finding the entry to update or delete go through the index, in
+// O(1), and the tree keeps the keys in order for Min, Max and All.  Set
+// and Delete keep both up to date in one call.  The index costs a map
+// entry per key.
+//
+// The options are those of OrderedMap.  With WithNormalizer the index is
+// keyed by the normalized keys, so the normalized form of equal keys
+// must be equal under == as well as under the comparison function.
+//
+
+type OrderedDict[K comparable, V any] struct {
+	m     *OrderedMap[K, V]
+	index map[K]*orderedMapEntry[K, V]
+}
+
+// Returns an empty dict whose keys are ordered by cmp
+
+func NewOrderedDict[K comparable, V any](cmp func(a, b K) int,
+	opts ...OrderedMapOption) *OrderedDict[K, V] {
+
+	m := NewOrderedMap[K, V](cmp, opts...)
+
+	return &OrderedDict[K, V]{
+		m:     m,
+		index: make(map[K]*orderedMapEntry[K, V], max(m.Cap(), 0)),
+	}
+}
+
+// Returns the number of entries
+
+func (d *OrderedDict[K, V]) Len() int {
+	return d.m.Len()
+}
+
+// Returns the number of entries the dict can hold, or -1 if it has no
+// fixed capacity
+
+func (d *OrderedDict[K, V]) Cap() int {
+	return d.m.Cap()
+}
+
+// Returns the value for key, and whether it was present.  O(1)
+
+func (d *OrderedDict[K, V]) Get(key K) (V, bool) {
+
+	if e := d.index[d.m.normalize(key)]; e != nil {
+		return e.value, true
+	}
+
+	var zero V
+	return zero, false
+}
+
+// True if key is present.  O(1)
+
+func (d *OrderedDict[K, V]) Contains(key K) bool {
+	return d.index[d.m.normalize(key)] != nil
+}
+
+// Sets the value for key, adding an entry if key is not present: O(1) to
+// update, and O(log n) to add.  Returns ErrFull if the dict has a fixed
+// capacity and no room for the entry
+
+func (d *OrderedDict[K, V]) Set(key K, value V) error {
+
+	norm := d.m.normalize(key)
+
+	if e := d.index[norm]; e != nil {
+		e.value = value
+		return nil
+	}
+
+	e, err := d.m.add(key, norm, value)
+	if err != nil {
+		return err
+	}
+	d.index[norm] = e
+
+	return nil
+}
+
+// Returns the value for key and true if key is present; otherwise adds
+// key with value and returns value and false.  See OrderedMap.LoadOrStore
+
+func (d *OrderedDict[K, V]) LoadOrStore(key K,
+	value V) (existing V, loaded bool, err error) {
+
+	norm := d.m.normalize(key)
+
+	if e := d.index[norm]; e != nil {
+		return e.value, true, nil
+	}
+
+	e, err := d.m.add(key, norm, value)
+	if err != nil {
+		return existing, false, err
+	}
+	d.index[norm] = e
+
+	return value, false, nil
+}
+
+// Removes key.  Returns true if it was present
+
+func (d *OrderedDict[K, V]) Delete(key K) bool {
+
+	norm := d.m.normalize(key)
+
+	e := d.index[norm]
+	if e == nil {
+		return false
+	}
+
+	delete(d.index, norm)
+	d.m.drop(e)
+
+	return true
+}
+
+// Returns the least key and its value, or false if the dict is empty
+
+func (d *OrderedDict[K, V]) Min() (K, V, bool) {
+	return d.m.Min()
+}
+
+// Returns the greatest key and its value, or false if the dict is empty
+
+func (d *OrderedDict[K, V]) Max() (K, V, bool) {
+	return d.m.Max()
+}
+
+// Yields the keys and values in key order.  The dict must not be
+// modified while the sequence is being iterated
+
+func (d *OrderedDict[K, V]) All() iter.Seq2[K, V] {
+	return d.m.All()
+}
+
+// Returns the keys in order, in a slice allocated once
+
+func (d *OrderedDict[K, V]) Keys() []K {
+	return d.m.Keys()
+}
+
+// Returns the values in key order, in a slice allocated once
+
+func (d *OrderedDict[K, V]) Values() []V {
+	return d.m.Values()
+}
+
+// Yields the keys in order.  The dict must not be modified while the
+// sequence is being iterated
+
+func (d *OrderedDict[K, V]) KeysSeq() iter.Seq[K] {
+	return d.m.KeysSeq()
+}
+
+// Yields the values in key order.  The dict must not be modified while
+// the sequence is being iterated
+
+func (d *OrderedDict[K, V]) ValuesSeq() iter.Seq[V] {
+	return d.m.ValuesSeq()
+}
@@ -0,0 +1,89 @@
+package avl
+
+import (
+	"cmp"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestOrderedDict(t *testing.T) {
+
+	d := NewOrderedDict[int, int](cmp.Compare[int])
+	model := map[int]int{}
+
+	rnd := rand.New(rand.NewSource(13))
+	for i := 0; i < 5000; i++ {
+		k := rnd.Intn(500)
+		switch rnd.Intn(3) {
+		case 0, 1:
+			assert.NoError(t, d.Set(k, i))
+			model[k] = i
+		case 2:
+			_, ok := model[k]
+			assert.Equal(t, ok, d.Delete(k))
+			delete(model, k)
+		}
+		v, ok := d.Get(k)
+		mv, mok := model[k]
+		assert.Equal(t, mok, ok)
+		assert.Equal(t, mv, v)
+	}
+
+	assert.Equal(t, len(model), d.Len())
+	assert.Equal(t, len(model), len(d.index))
+
+	var keys []int
+	for k := range model {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	var got []int
+	for k, v := range d.All() {
+		got = append(got, k)
+		assert.Equal(t, model[k], v)
+	}
+	assert.Equal(t, keys, got)
+
+	k, _, _ := d.Min()
+	assert.Equal(t, keys[0], k)
+	k, _, _ = d.Max()
+	assert.Equal(t, keys[len(keys)-1], k)
+	assert.NoError(t, d.m.tree.Validate(d.m.cmpNode))
+}
+
+func TestOrderedDictOptions(t *testing.T) {
+
+	d := NewOrderedDict[string, int](strings.Compare, WithCapacity(2),
+		WithNormalizer(strings.ToLower))
+
+	v, loaded, err := d.LoadOrStore("Apple", 1)
+	assert.NoError(t, err)
+	assert.False(t, loaded)
+	assert.Equal(t, 1, v)
+	v, loaded, err = d.LoadOrStore("APPLE", 2)
+	assert.NoError(t, err)
+	assert.True(t, loaded)
+	assert.Equal(t, 1, v)
+
+	assert.NoError(t, d.Set("pear", 3))
+	assert.ErrorIs(t, d.Set("fig", 4), ErrFull)
+	_, loaded, err = d.LoadOrStore("fig", 4)
+	assert.ErrorIs(t, err, ErrFull)
+	assert.False(t, loaded)
+	assert.False(t, d.Contains("fig"))
+	assert.True(t, d.Contains("PEAR"))
+
+	assert.True(t, d.Delete("apple"))
+	assert.NoError(t, d.Set("fig", 4))
+	assert.Equal(t, 2, d.Len())
+	assert.Equal(t, 2, d.Cap())
+
+	k, _, _ := d.Min()
+	assert.Equal(t, "fig", k)
+	assert.Equal(t, []string{"fig", "pear"}, d.Keys())
+	assert.Equal(t, []int{4, 3}, d.Values())
+}
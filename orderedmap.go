@@ -0,0 +1,364 @@
+package avl
+
+import "iter"
+
+//
+// OrderedMap is a boxed, typed map on top of AvlTree, for when the
+// values to be ordered are not structs that can embed an AvlNode: each
+// entry is allocated by the map and holds the node, the key and the
+// value.  Keys are ordered by a comparison function and are unique.
+//
+// With WithCapacity the entries are all allocated up front, in one
+// slice, and reused as keys come and go, so no operation allocates; a
+// Set that would need more entries than that fails with ErrFull.
+//
+// The zero OrderedMap is an empty map ready to use, ordering its keys by
+// AvlCompareValues, so it can be a field of a struct that is decoded
+// from JSON.
+//
+// With WithNormalizer keys are compared by a normalized form, such as
+// the lower case or NFC form of a string.  Each entry caches the
+// normalized form of its key, computed once by Set, so a lookup
+// normalizes only the key it is given rather than every key it passes.
+// The map keeps the key as first given, and yields that.
+//
+
+type orderedMapEntry[K any, V any] struct {
+	header AvlNode
+	key    K
+	norm   K // key, normalized
+	value  V
+}
+
+type OrderedMap[K any, V any] struct {
+	tree    AvlTree
+	cmp     func(a, b K) int
+	cmpNode CmpFuncNode
+	norm    func(key K) K
+	fixed   bool
+	free    []*orderedMapEntry[K, V]
+}
+
+// Options for NewOrderedMap
+
+type OrderedMapOption func(cfg *orderedMapConfig)
+
+type orderedMapConfig struct {
+	capacity int
+	fixed    bool
+	norm     interface{} // func(key K) K
+}
+
+// Allocates room for n entries up front, and never allocates another:
+// Set returns ErrFull rather than go beyond n
+
+func WithCapacity(n int) OrderedMapOption {
+	return func(cfg *orderedMapConfig) {
+		cfg.capacity = n
+		cfg.fixed = true
+	}
+}
+
+// Orders and looks up keys by norm(key) rather than key, calling norm
+// once per key stored and once per lookup.  norm must be idempotent
+
+func WithNormalizer[K any](norm func(key K) K) OrderedMapOption {
+	return func(cfg *orderedMapConfig) {
+		cfg.norm = norm
+	}
+}
+
+// Returns an empty map whose keys are ordered by cmp
+
+func NewOrderedMap[K any, V any](cmp func(a, b K) int,
+	opts ...OrderedMapOption) *OrderedMap[K, V] {
+
+	var cfg orderedMapConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	m := &OrderedMap[K, V]{cmp: cmp, fixed: cfg.fixed}
+	m.init()
+	if cfg.norm != nil {
+		m.norm = cfg.norm.(func(key K) K)
+	}
+
+	if cfg.fixed {
+		entries := make([]orderedMapEntry[K, V], cfg.capacity)
+		m.free = make([]*orderedMapEntry[K, V], cfg.capacity)
+		for i := range entries {
+			m.free[cfg.capacity-1-i] = &entries[i]
+		}
+	}
+
+	return m
+}
+
+// Sets up the comparators, ordering by AvlCompareValues if the map has
+// no comparison function, as in the zero OrderedMap
+
+func (m *OrderedMap[K, V]) init() {
+	if m.cmp == nil {
+		m.cmp = func(a, b K) int {
+			return AvlCompareValues(a, b)
+		}
+	}
+	m.cmpNode = func(a, b interface{}) int {
+		return m.cmp(a.(*orderedMapEntry[K, V]).norm, b.(*orderedMapEntry[K, V]).norm)
+	}
+}
+
+// Returns the number of entries
+
+func (m *OrderedMap[K, V]) Len() int {
+	return m.tree.Len()
+}
+
+// Returns the number of entries the map can hold, or -1 if it has no
+// fixed capacity
+
+func (m *OrderedMap[K, V]) Cap() int {
+	if !m.fixed {
+		return -1
+	}
+	return m.tree.Len() + len(m.free)
+}
+
+// Returns key, normalized
+
+func (m *OrderedMap[K, V]) normalize(key K) K {
+	if m.norm != nil {
+		return m.norm(key)
+	}
+	return key
+}
+
+// Returns the entry for key, or nil
+
+func (m *OrderedMap[K, V]) lookup(key K) *orderedMapEntry[K, V] {
+	return m.find(m.normalize(key))
+}
+
+// Returns the entry whose normalized key is norm, or nil
+
+func (m *OrderedMap[K, V]) find(norm K) *orderedMapEntry[K, V] {
+
+	for cur := m.tree.root; cur != nil; {
+		e := cur.owner.(*orderedMapEntry[K, V])
+		res := m.cmp(norm, e.norm)
+		if res < 0 {
+			cur = cur.left
+		} else if res > 0 {
+			cur = cur.right
+		} else {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// Returns the value for key, and whether it was present
+
+func (m *OrderedMap[K, V]) Get(key K) (V, bool) {
+
+	if e := m.lookup(key); e != nil {
+		return e.value, true
+	}
+
+	var zero V
+	return zero, false
+}
+
+// True if key is present
+
+func (m *OrderedMap[K, V]) Contains(key K) bool {
+	return m.lookup(key) != nil
+}
+
+// Sets the value for key, adding an entry if key is not present.
+// Returns ErrFull if the map has a fixed capacity and no room for one
+
+func (m *OrderedMap[K, V]) Set(key K, value V) error {
+
+	norm := m.normalize(key)
+
+	if e := m.find(norm); e != nil {
+		e.value = value
+		return nil
+	}
+
+	_, err := m.add(key, norm, value)
+	return err
+}
+
+// Returns the value for key and true if key is present, like
+// sync.Map.LoadOrStore; otherwise adds key with value and returns value
+// and false.  Returns ErrFull, and adds nothing, if the map has a fixed
+// capacity and no room for the entry
+
+func (m *OrderedMap[K, V]) LoadOrStore(key K,
+	value V) (existing V, loaded bool, err error) {
+
+	norm := m.normalize(key)
+
+	if e := m.find(norm); e != nil {
+		return e.value, true, nil
+	}
+
+	if _, err := m.add(key, norm, value); err != nil {
+		return existing, false, err
+	}
+
+	return value, false, nil
+}
+
+// Adds and returns an entry for key, which is not present
+
+func (m *OrderedMap[K, V]) add(key K, norm K,
+	value V) (*orderedMapEntry[K, V], error) {
+
+	if m.cmpNode == nil {
+		m.init()
+	}
+
+	e, err := m.alloc()
+	if err != nil {
+		return nil, err
+	}
+
+	e.key = key
+	e.norm = norm
+	e.value = value
+	m.tree.Insert(&e.header, e, m.cmpNode)
+
+	return e, nil
+}
+
+func (m *OrderedMap[K, V]) alloc() (*orderedMapEntry[K, V], error) {
+
+	if !m.fixed {
+		m.tree.counts.Allocs++
+		return &orderedMapEntry[K, V]{}, nil
+	}
+	if len(m.free) == 0 {
+		return nil, ErrFull
+	}
+
+	e := m.free[len(m.free)-1]
+	m.free = m.free[:len(m.free)-1]
+
+	return e, nil
+}
+
+// Removes key.  Returns true if it was present
+
+func (m *OrderedMap[K, V]) Delete(key K) bool {
+
+	e := m.lookup(key)
+	if e == nil {
+		return false
+	}
+
+	m.drop(e)
+
+	return true
+}
+
+// Removes the entry e
+
+func (m *OrderedMap[K, V]) drop(e *orderedMapEntry[K, V]) {
+
+	m.tree.Remove(&e.header)
+
+	if m.fixed {
+		*e = orderedMapEntry[K, V]{}
+		m.free = append(m.free, e)
+	}
+}
+
+// Returns the least key and its value, or false if the map is empty
+
+func (m *OrderedMap[K, V]) Min() (K, V, bool) {
+	return m.entryOf(m.tree.first)
+}
+
+// Returns the greatest key and its value, or false if the map is empty
+
+func (m *OrderedMap[K, V]) Max() (K, V, bool) {
+	return m.entryOf(m.tree.last)
+}
+
+func (m *OrderedMap[K, V]) entryOf(n *AvlNode) (K, V, bool) {
+
+	if n == nil {
+		var k K
+		var v V
+		return k, v, false
+	}
+
+	e := n.owner.(*orderedMapEntry[K, V])
+
+	return e.key, e.value, true
+}
+
+// Yields the keys and values in key order.  The map must not be
+// modified while the sequence is being iterated
+
+func (m *OrderedMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for n := m.tree.first; n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+			e := n.owner.(*orderedMapEntry[K, V])
+			if !yield(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
+
+// Returns the keys in order, in a slice allocated once
+
+func (m *OrderedMap[K, V]) Keys() []K {
+	keys := make([]K, 0, m.tree.Len())
+	for n := m.tree.first; n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+		keys = append(keys, n.owner.(*orderedMapEntry[K, V]).key)
+	}
+	return keys
+}
+
+// Returns the values in key order, in a slice allocated once
+
+func (m *OrderedMap[K, V]) Values() []V {
+	values := make([]V, 0, m.tree.Len())
+	for n := m.tree.first; n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+		values = append(values, n.owner.(*orderedMapEntry[K, V]).value)
+	}
+	return values
+}
+
+// Yields the keys in order.  The map must not be modified while the
+// sequence is being iterated
+
+func (m *OrderedMap[K, V]) KeysSeq() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for k := range m.All() {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+// Yields the values in key order.  The map must not be modified while
+// the sequence is being iterated
+
+func (m *OrderedMap[K, V]) ValuesSeq() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, v := range m.All() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
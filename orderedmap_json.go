@@ -0,0 +1,152 @@
+package avl
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+//
+// JSON for OrderedMaps.  A map is encoded as a JSON object with its keys
+// in the map's order, so the same contents always encode to the same
+// bytes, which diff cleanly.  Keys become object member names following
+// the rules encoding/json has for the keys of Go maps: strings as they
+// are, integers in decimal, and types implementing encoding.TextMarshaler
+// as their text; floats are allowed too, in their shortest form.
+//
+
+// Returns the object member name for key.  As in encoding/json, a key
+// of string kind is used as it is even if it implements
+// encoding.TextMarshaler
+
+func orderedMapKeyText(key interface{}) (string, error) {
+
+	v := reflect.ValueOf(key)
+	if v.Kind() == reflect.String {
+		return v.String(), nil
+	}
+
+	if tm, ok := key.(encoding.TextMarshaler); ok {
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	}
+
+	return "", fmt.Errorf("avl: cannot use %T as a JSON object key", key)
+}
+
+// Parses the object member name text into key
+
+func orderedMapParseKey(text string, key interface{}) error {
+
+	if tu, ok := key.(encoding.TextUnmarshaler); ok {
+		return tu.UnmarshalText([]byte(text))
+	}
+
+	v := reflect.ValueOf(key).Elem()
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(text)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, v.Type().Bits())
+		v.SetInt(n)
+		return err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(text, 10, v.Type().Bits())
+		v.SetUint(n)
+		return err
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, v.Type().Bits())
+		v.SetFloat(f)
+		return err
+	}
+
+	return fmt.Errorf("avl: cannot use %v as a JSON object key", v.Type())
+}
+
+// Encodes the map as a JSON object, in key order
+
+func (m *OrderedMap[K, V]) MarshalJSON() ([]byte, error) {
+
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	for k, v := range m.All() {
+		text, err := orderedMapKeyText(k)
+		if err != nil {
+			return nil, err
+		}
+		name, _ := json.Marshal(text)
+		value, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// Decodes a JSON object into the map, setting an entry for each member
+// as Set does.  As with a Go map, entries already in the map stay unless
+// a member replaces them, and JSON null leaves the map alone
+
+func (m *OrderedMap[K, V]) UnmarshalJSON(data []byte) error {
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if tok != json.Delim('{') {
+		return fmt.Errorf("avl: cannot decode %v into an OrderedMap", tok)
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		var key K
+		if err := orderedMapParseKey(tok.(string), &key); err != nil {
+			return err
+		}
+		var value V
+		if err := dec.Decode(&value); err != nil {
+			return err
+		}
+
+		if err := m.Set(key, value); err != nil {
+			return err
+		}
+	}
+
+	_, err = dec.Token()
+
+	return err
+}
@@ -0,0 +1,65 @@
+package avl
+
+import (
+	"cmp"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// A string kind whose text form differs, which is ignored when it is
+// used as a key
+
+type shoutKey string
+
+func (k shoutKey) MarshalText() ([]byte, error) {
+	return []byte(strings.ToUpper(string(k))), nil
+}
+
+func TestOrderedMapJSON(t *testing.T) {
+
+	m := NewOrderedMap[string, int](cmp.Compare[string])
+	for i, k := range []string{"zeta", "alpha", "mu"} {
+		m.Set(k, i)
+	}
+
+	data, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"alpha":1,"mu":2,"zeta":0}`, string(data))
+
+	// Decoding merges into the map, and into a zero one
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"beta":5,"alpha":7}`), m))
+	assert.Equal(t, []string{"alpha", "beta", "mu", "zeta"}, m.Keys())
+	v, _ := m.Get("alpha")
+	assert.Equal(t, 7, v)
+
+	var config struct {
+		Limits OrderedMap[int, []string] `json:"limits"`
+		Hosts  *OrderedMap[netip.Addr, bool]
+	}
+	config.Hosts = NewOrderedMap[netip.Addr, bool](netip.Addr.Compare)
+	in := `{"limits":{"10":["a"],"2":[],"-1":null},"Hosts":{"10.0.0.2":true,"10.0.0.1":false}}`
+	assert.NoError(t, json.Unmarshal([]byte(in), &config))
+	assert.Equal(t, []int{-1, 2, 10}, config.Limits.Keys())
+	assert.Equal(t, 2, config.Hosts.Len())
+
+	data, err = json.Marshal(&config)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"limits":{"-1":null,"2":[],"10":["a"]},"Hosts":{"10.0.0.1":false,"10.0.0.2":true}}`, string(data))
+
+	assert.Error(t, json.Unmarshal([]byte(`[1]`), m))
+	assert.Error(t, json.Unmarshal([]byte(`{"x":1}`), &config.Limits))
+	assert.NoError(t, json.Unmarshal([]byte(`null`), m))
+	assert.Equal(t, 4, m.Len())
+
+	// Keys of string kind are used as they are, text form or not
+
+	shout := NewOrderedMap[shoutKey, int](cmp.Compare[shoutKey])
+	shout.Set("hi", 1)
+	data, err = json.Marshal(shout)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"hi":1}`, string(data))
+}
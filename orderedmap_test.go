@@ -0,0 +1,177 @@
+package avl
+
+import (
+	"cmp"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestOrderedMap(t *testing.T) {
+
+	m := NewOrderedMap[int, string](cmp.Compare[int])
+
+	_, _, ok := m.Min()
+	assert.False(t, ok)
+	assert.Equal(t, -1, m.Cap())
+
+	for _, k := range []int{5, 1, 9, 3} {
+		assert.NoError(t, m.Set(k, string(rune('a'+k))))
+	}
+	assert.NoError(t, m.Set(3, "three"))
+
+	assert.Equal(t, 4, m.Len())
+	v, ok := m.Get(3)
+	assert.True(t, ok)
+	assert.Equal(t, "three", v)
+	_, ok = m.Get(4)
+	assert.False(t, ok)
+
+	var keys []int
+	for k := range m.All() {
+		keys = append(keys, k)
+	}
+	assert.Equal(t, []int{1, 3, 5, 9}, keys)
+
+	k, v, _ := m.Max()
+	assert.Equal(t, 9, k)
+	assert.Equal(t, "j", v)
+
+	assert.True(t, m.Delete(1))
+	assert.False(t, m.Delete(1))
+	assert.False(t, m.Contains(1))
+	k, _, _ = m.Min()
+	assert.Equal(t, 3, k)
+}
+
+func TestOrderedMapCapacity(t *testing.T) {
+
+	m := NewOrderedMap[int, int](cmp.Compare[int], WithCapacity(100))
+	assert.Equal(t, 100, m.Cap())
+
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, m.Set(i, i))
+	}
+	assert.ErrorIs(t, m.Set(100, 100), ErrFull)
+	assert.NoError(t, m.Set(50, -50))
+
+	// Once full, churn allocates nothing
+
+	rnd := rand.New(rand.NewSource(10))
+	keys := rnd.Perm(100)
+
+	allocs := testing.AllocsPerRun(10, func() {
+		for _, k := range keys {
+			m.Delete(k)
+			m.Set(k+1000, k)
+			m.Get(k)
+		}
+		for _, k := range keys {
+			m.Delete(k + 1000)
+			m.Set(k, k)
+		}
+	})
+	assert.Equal(t, 0.0, allocs)
+	assert.Equal(t, 100, m.Len())
+	assert.NoError(t, m.tree.Validate(m.cmpNode))
+}
+
+func TestOrderedMapNormalizer(t *testing.T) {
+
+	calls := 0
+	fold := func(key string) string {
+		calls++
+		return strings.ToLower(key)
+	}
+
+	m := NewOrderedMap[string, int](strings.Compare, WithNormalizer(fold))
+
+	for i, k := range []string{"Banana", "apple", "Cherry", "date", "Elder"} {
+		assert.NoError(t, m.Set(k, i))
+	}
+	assert.NoError(t, m.Set("BANANA", 10))
+	assert.Equal(t, 5, m.Len())
+
+	// Each lookup normalizes the probe alone
+
+	calls = 0
+	v, ok := m.Get("bAnAnA")
+	assert.True(t, ok)
+	assert.Equal(t, 10, v)
+	assert.Equal(t, 1, calls)
+
+	var keys []string
+	for k := range m.All() {
+		keys = append(keys, k)
+	}
+	assert.Equal(t, []string{"apple", "Banana", "Cherry", "date", "Elder"}, keys)
+
+	assert.True(t, m.Delete("CHERRY"))
+	assert.False(t, m.Contains("cherry"))
+	assert.NoError(t, m.tree.Validate(m.cmpNode))
+}
+
+func TestOrderedMapLoadOrStore(t *testing.T) {
+
+	m := NewOrderedMap[int, string](cmp.Compare[int], WithCapacity(2))
+
+	v, loaded, err := m.LoadOrStore(1, "one")
+	assert.NoError(t, err)
+	assert.False(t, loaded)
+	assert.Equal(t, "one", v)
+
+	v, loaded, err = m.LoadOrStore(1, "uno")
+	assert.NoError(t, err)
+	assert.True(t, loaded)
+	assert.Equal(t, "one", v)
+
+	_, loaded, err = m.LoadOrStore(2, "two")
+	assert.NoError(t, err)
+	assert.False(t, loaded)
+	assert.Equal(t, 2, m.Len())
+
+	v, loaded, err = m.LoadOrStore(3, "three")
+	assert.ErrorIs(t, err, ErrFull)
+	assert.False(t, loaded)
+	assert.Equal(t, "", v)
+	assert.Equal(t, 2, m.Len())
+
+	v, loaded, err = m.LoadOrStore(2, "dos")
+	assert.NoError(t, err)
+	assert.True(t, loaded)
+	assert.Equal(t, "two", v)
+}
+
+func TestOrderedMapKeysValues(t *testing.T) {
+
+	m := NewOrderedMap[string, int](strings.Compare)
+
+	assert.Empty(t, m.Keys())
+	assert.NotNil(t, m.Keys())
+
+	for i, k := range []string{"pear", "apple", "fig"} {
+		m.Set(k, i)
+	}
+
+	assert.Equal(t, []string{"apple", "fig", "pear"}, m.Keys())
+	assert.Equal(t, []int{1, 2, 0}, m.Values())
+
+	var keys []string
+	for k := range m.KeysSeq() {
+		keys = append(keys, k)
+		if len(keys) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []string{"apple", "fig"}, keys)
+
+	var values []int
+	for v := range m.ValuesSeq() {
+		values = append(values, v)
+	}
+	assert.Equal(t, []int{1, 2, 0}, values)
+
+	allocs := testing.AllocsPerRun(10, func() { m.Keys() })
+	assert.Equal(t, 1.0, allocs)
+}
@@ -0,0 +1,118 @@
+package avl
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+//
+// YAML for OrderedMaps and OrderedDicts, without depending on a YAML
+// package.  The methods have the signatures of the yaml.Marshaler and
+// obsolete yaml.Unmarshaler interfaces, which gopkg.in/yaml.v2 and v3
+// (and the packages derived from them) all look for.
+//
+// A YAML library sorts the keys of a Go map by its own rules, so
+// MarshalYAML returns a struct instead, made for the occasion with
+// reflect.StructOf, with one field per entry tagged with the key; the
+// libraries emit struct fields in order, so the mapping comes out in the
+// map's key order.  A key that a struct tag cannot carry (an empty one,
+// or one with a comma in it) falls back to a Go map.  Keys are written
+// as JSON object keys are; see orderedmap_json.go.
+//
+
+// Returns a value that a YAML library encodes as a mapping of the keys
+// to the values, in order
+
+func avlYAMLMapping(names []string, values []interface{}) interface{} {
+
+	fields := make([]reflect.StructField, len(names))
+	for i, name := range names {
+		if name == "" || strings.Contains(name, ",") {
+			m := make(map[string]interface{}, len(names))
+			for i, name := range names {
+				m[name] = values[i]
+			}
+			return m
+		}
+		if name == "-" {
+			name = "-,"
+		}
+		fields[i] = reflect.StructField{
+			Name: "F" + strconv.Itoa(i),
+			Type: reflect.TypeFor[interface{}](),
+			Tag:  reflect.StructTag("yaml:" + strconv.Quote(name)),
+		}
+	}
+
+	v := reflect.New(reflect.StructOf(fields)).Elem()
+	for i, value := range values {
+		if value != nil {
+			v.Field(i).Set(reflect.ValueOf(value))
+		}
+	}
+
+	return v.Interface()
+}
+
+// Returns the map as a value that a YAML library encodes as a mapping,
+// in key order
+
+func (m *OrderedMap[K, V]) MarshalYAML() (interface{}, error) {
+
+	names := make([]string, 0, m.Len())
+	values := make([]interface{}, 0, m.Len())
+
+	for k, v := range m.All() {
+		name, err := orderedMapKeyText(k)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		values = append(values, v)
+	}
+
+	return avlYAMLMapping(names, values), nil
+}
+
+// Decodes a YAML mapping into the map, setting an entry for each key as
+// Set does; entries already in the map stay unless a key replaces them
+
+func (m *OrderedMap[K, V]) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return avlUnmarshalYAML(unmarshal, m.Set)
+}
+
+// Decodes a YAML mapping with unmarshal, passing each entry to set
+
+func avlUnmarshalYAML[K any, V any](unmarshal func(interface{}) error,
+	set func(key K, value V) error) error {
+
+	var entries map[string]V
+	if err := unmarshal(&entries); err != nil {
+		return err
+	}
+
+	for name, value := range entries {
+		var key K
+		if err := orderedMapParseKey(name, &key); err != nil {
+			return err
+		}
+		if err := set(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// See OrderedMap.MarshalYAML
+
+func (d *OrderedDict[K, V]) MarshalYAML() (interface{}, error) {
+	return d.m.MarshalYAML()
+}
+
+// See OrderedMap.UnmarshalYAML
+
+func (d *OrderedDict[K, V]) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	return avlUnmarshalYAML(unmarshal, d.Set)
+}
@@ -0,0 +1,79 @@
+package avl
+
+import (
+	"cmp"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The keys and values of a struct returned by MarshalYAML, as a YAML
+// library would see them
+
+func yamlFields(v interface{}) ([]string, []interface{}) {
+
+	var names []string
+	var values []interface{}
+
+	rv := reflect.ValueOf(v)
+	for i := 0; i < rv.NumField(); i++ {
+		names = append(names, rv.Type().Field(i).Tag.Get("yaml"))
+		values = append(values, rv.Field(i).Interface())
+	}
+
+	return names, values
+}
+
+func TestOrderedMapMarshalYAML(t *testing.T) {
+
+	m := NewOrderedMap[int, string](cmp.Compare[int])
+	for _, k := range []int{10, 9, -3} {
+		m.Set(k, strings.Repeat("x", k+4))
+	}
+
+	v, err := m.MarshalYAML()
+	assert.NoError(t, err)
+	names, values := yamlFields(v)
+	assert.Equal(t, []string{"-3", "9", "10"}, names)
+	assert.Equal(t, []interface{}{"x", "xxxxxxxxxxxxx", "xxxxxxxxxxxxxx"}, values)
+
+	// Awkward keys
+
+	s := NewOrderedMap[string, interface{}](strings.Compare)
+	s.Set("-", nil)
+	s.Set(`"q"`, 1)
+	v, _ = s.MarshalYAML()
+	names, values = yamlFields(v)
+	assert.Equal(t, []string{`"q"`, "-,"}, names)
+	assert.Equal(t, []interface{}{1, nil}, values)
+
+	s.Set("a,b", 2)
+	v, _ = s.MarshalYAML()
+	assert.Equal(t, map[string]interface{}{"-": nil, `"q"`: 1, "a,b": 2}, v)
+}
+
+func TestOrderedMapUnmarshalYAML(t *testing.T) {
+
+	// Stands in for a YAML library decoding {"2": 20, "1": 10}
+
+	unmarshal := func(out interface{}) error {
+		return json.Unmarshal([]byte(`{"2": 20, "1": 10}`), out)
+	}
+
+	m := NewOrderedMap[int, int](cmp.Compare[int])
+	assert.NoError(t, m.UnmarshalYAML(unmarshal))
+	assert.Equal(t, []int{1, 2}, m.Keys())
+	assert.Equal(t, []int{10, 20}, m.Values())
+
+	d := NewOrderedDict[int, int](cmp.Compare[int])
+	assert.NoError(t, d.UnmarshalYAML(unmarshal))
+	v, _ := d.Get(2)
+	assert.Equal(t, 20, v)
+
+	bad := NewOrderedMap[int, int](cmp.Compare[int])
+	assert.Error(t, bad.UnmarshalYAML(func(out interface{}) error {
+		return json.Unmarshal([]byte(`{"x": 1}`), out)
+	}))
+}
@@ -0,0 +1,632 @@
+//
+// Copyright as per Creative Commons Legal Code license, which can
+// be found in the file COPYING
+//
+
+/*
+
+Package paged is an AVL tree whose nodes live in fixed-size pages rather
+than in memory, for ordered indexes larger than RAM.  Keys and values are
+byte strings, ordered by a comparison function (bytes.Compare by
+default).  Each node takes one page, holding its links, height, key and
+value, so a key and value together must fit in a page less the node
+header.  Page 0 holds the root, count and free list.
+
+Pages are read and written through a Pager: FilePager keeps them in a
+file, and CachedPager keeps the most recently used ones in memory, which
+for a balanced tree means the top levels stay cached:
+
+	f, _ := os.OpenFile("index.avl", os.O_RDWR|os.O_CREATE, 0o644)
+	tree, err := paged.Open(paged.NewCachedPager(paged.NewFilePager(f, 4096), 1024), nil)
+
+The tree is not safe for concurrent use, and changes are only durable
+once Sync has returned.
+
+*/
+
+package paged
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	// The pages are not a tree, or not one written by this package
+	ErrBadFormat = errors.New("paged: not a paged tree")
+
+	// A key and value are too big for a page
+	ErrTooBig = errors.New("paged: entry too big for a page")
+)
+
+const (
+	magic   = "AVLP"
+	version = 1
+
+	// Node header: left, right, height, key length, value length
+
+	nodeHeader = 8 + 8 + 1 + 2 + 2
+
+	// Smallest page that holds the metadata
+
+	minPageSize = 64
+)
+
+// Metadata, in page 0
+
+type meta struct {
+	root  PageID
+	count uint64
+	next  PageID // First page never allocated
+	free  PageID // Head of the free list, linked through the pages
+}
+
+// A node, as read from its page
+
+type node struct {
+	id          PageID
+	left, right PageID
+	height      int
+	key, value  []byte
+}
+
+// A tree of pages
+
+type Tree struct {
+	pager Pager
+	cmp   func(a, b []byte) int
+	meta  meta
+	buf   []byte
+}
+
+// Opens the tree stored in p, or creates an empty one if p is empty.
+// cmp orders the keys; nil means bytes.Compare
+
+func Open(p Pager, cmp func(a, b []byte) int) (*Tree, error) {
+
+	if cmp == nil {
+		cmp = bytes.Compare
+	}
+	if p.PageSize() < minPageSize {
+		return nil, fmt.Errorf("paged: page size %d is below %d", p.PageSize(), minPageSize)
+	}
+
+	t := &Tree{pager: p, cmp: cmp, buf: make([]byte, p.PageSize())}
+
+	if err := p.Read(0, t.buf); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case string(t.buf[:4]) == magic:
+		if t.buf[4] != version ||
+			binary.LittleEndian.Uint32(t.buf[5:]) != uint32(p.PageSize()) {
+			return nil, ErrBadFormat
+		}
+		t.meta = meta{
+			root:  PageID(binary.LittleEndian.Uint64(t.buf[9:])),
+			count: binary.LittleEndian.Uint64(t.buf[17:]),
+			next:  PageID(binary.LittleEndian.Uint64(t.buf[25:])),
+			free:  PageID(binary.LittleEndian.Uint64(t.buf[33:])),
+		}
+	case bytes.Count(t.buf, []byte{0}) == len(t.buf):
+		t.meta = meta{next: 1}
+		if err := t.writeMeta(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrBadFormat
+	}
+
+	return t, nil
+}
+
+func (t *Tree) writeMeta() error {
+
+	buf := make([]byte, t.pager.PageSize())
+	copy(buf, magic)
+	buf[4] = version
+	binary.LittleEndian.PutUint32(buf[5:], uint32(t.pager.PageSize()))
+	binary.LittleEndian.PutUint64(buf[9:], uint64(t.meta.root))
+	binary.LittleEndian.PutUint64(buf[17:], t.meta.count)
+	binary.LittleEndian.PutUint64(buf[25:], uint64(t.meta.next))
+	binary.LittleEndian.PutUint64(buf[33:], uint64(t.meta.free))
+
+	return t.pager.Write(0, buf)
+}
+
+// Writes the metadata and syncs the pager
+
+func (t *Tree) Sync() error {
+
+	if err := t.writeMeta(); err != nil {
+		return err
+	}
+
+	return t.pager.Sync()
+}
+
+// Returns the number of entries
+
+func (t *Tree) Len() int {
+	return int(t.meta.count)
+}
+
+func (t *Tree) read(id PageID) (*node, error) {
+
+	if err := t.pager.Read(id, t.buf); err != nil {
+		return nil, err
+	}
+
+	b := t.buf
+	n := &node{
+		id:     id,
+		left:   PageID(binary.LittleEndian.Uint64(b[0:])),
+		right:  PageID(binary.LittleEndian.Uint64(b[8:])),
+		height: int(b[16]),
+	}
+
+	kl := int(binary.LittleEndian.Uint16(b[17:]))
+	vl := int(binary.LittleEndian.Uint16(b[19:]))
+	if n.height == 0 || nodeHeader+kl+vl > len(b) {
+		return nil, fmt.Errorf("%w: bad node in page %d", ErrBadFormat, id)
+	}
+
+	n.key = append([]byte(nil), b[nodeHeader:nodeHeader+kl]...)
+	n.value = append([]byte(nil), b[nodeHeader+kl:nodeHeader+kl+vl]...)
+
+	return n, nil
+}
+
+func (t *Tree) write(n *node) error {
+
+	b := t.buf
+	for i := range b {
+		b[i] = 0
+	}
+
+	binary.LittleEndian.PutUint64(b[0:], uint64(n.left))
+	binary.LittleEndian.PutUint64(b[8:], uint64(n.right))
+	b[16] = byte(n.height)
+	binary.LittleEndian.PutUint16(b[17:], uint16(len(n.key)))
+	binary.LittleEndian.PutUint16(b[19:], uint16(len(n.value)))
+	copy(b[nodeHeader:], n.key)
+	copy(b[nodeHeader+len(n.key):], n.value)
+
+	return t.pager.Write(n.id, b)
+}
+
+// Allocates a page, from the free list if it isn't empty
+
+func (t *Tree) alloc() (PageID, error) {
+
+	if t.meta.free == 0 {
+		id := t.meta.next
+		t.meta.next++
+		return id, nil
+	}
+
+	id := t.meta.free
+	if err := t.pager.Read(id, t.buf); err != nil {
+		return 0, err
+	}
+	t.meta.free = PageID(binary.LittleEndian.Uint64(t.buf))
+
+	return id, nil
+}
+
+func (t *Tree) release(id PageID) error {
+
+	for i := range t.buf {
+		t.buf[i] = 0
+	}
+	binary.LittleEndian.PutUint64(t.buf, uint64(t.meta.free))
+	t.meta.free = id
+
+	return t.pager.Write(id, t.buf)
+}
+
+func (t *Tree) height(id PageID) (int, error) {
+
+	if id == 0 {
+		return 0, nil
+	}
+
+	n, err := t.read(id)
+	if err != nil {
+		return 0, err
+	}
+
+	return n.height, nil
+}
+
+// Recomputes n's height from its children's
+
+func (t *Tree) fix(n *node) (lh, rh int, err error) {
+
+	if lh, err = t.height(n.left); err != nil {
+		return
+	}
+	if rh, err = t.height(n.right); err != nil {
+		return
+	}
+
+	n.height = 1 + max(lh, rh)
+
+	return
+}
+
+// Rotates the subtree rooted at n towards the lighter side (dir -1 for a
+// right rotation, lifting the left child, +1 for a left rotation), and
+// returns the new subtree root.  Both nodes are written
+
+func (t *Tree) rotate(n *node, dir int) (*node, error) {
+
+	var c *node
+	var err error
+
+	if dir < 0 {
+		if c, err = t.read(n.left); err != nil {
+			return nil, err
+		}
+		n.left, c.right = c.right, n.id
+	} else {
+		if c, err = t.read(n.right); err != nil {
+			return nil, err
+		}
+		n.right, c.left = c.left, n.id
+	}
+
+	if _, _, err = t.fix(n); err != nil {
+		return nil, err
+	}
+	if err = t.write(n); err != nil {
+		return nil, err
+	}
+	if _, _, err = t.fix(c); err != nil {
+		return nil, err
+	}
+	if err = t.write(c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Restores the balance of the subtree rooted at n, whose children are
+// balanced and differ in height by at most two, writes it, and returns
+// the id of its root
+
+func (t *Tree) balance(n *node) (PageID, error) {
+
+	lh, rh, err := t.fix(n)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case lh-rh > 1:
+		l, err := t.read(n.left)
+		if err != nil {
+			return 0, err
+		}
+		llh, lrh, err := t.fix(l)
+		if err != nil {
+			return 0, err
+		}
+		if lrh > llh {
+			if l, err = t.rotate(l, +1); err != nil {
+				return 0, err
+			}
+			n.left = l.id
+		}
+		if n, err = t.rotate(n, -1); err != nil {
+			return 0, err
+		}
+		return n.id, nil
+
+	case rh-lh > 1:
+		r, err := t.read(n.right)
+		if err != nil {
+			return 0, err
+		}
+		rlh, rrh, err := t.fix(r)
+		if err != nil {
+			return 0, err
+		}
+		if rlh > rrh {
+			if r, err = t.rotate(r, -1); err != nil {
+				return 0, err
+			}
+			n.right = r.id
+		}
+		if n, err = t.rotate(n, +1); err != nil {
+			return 0, err
+		}
+		return n.id, nil
+	}
+
+	return n.id, t.write(n)
+}
+
+// Looks up key
+
+func (t *Tree) Lookup(key []byte) (value []byte, found bool, err error) {
+
+	for id := t.meta.root; id != 0; {
+		n, err := t.read(id)
+		if err != nil {
+			return nil, false, err
+		}
+
+		res := t.cmp(key, n.key)
+		if res < 0 {
+			id = n.left
+		} else if res > 0 {
+			id = n.right
+		} else {
+			return n.value, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// Inserts key with value.  Returns false, and leaves the tree alone, if
+// key is already present
+
+func (t *Tree) Insert(key, value []byte) (bool, error) {
+	return t.put(key, value, false)
+}
+
+// Inserts key with value, replacing the value of key if it is already
+// present.  Returns true if key was not already present
+
+func (t *Tree) InsertOrReplace(key, value []byte) (bool, error) {
+	return t.put(key, value, true)
+}
+
+func (t *Tree) put(key, value []byte, replace bool) (bool, error) {
+
+	if nodeHeader+len(key)+len(value) > t.pager.PageSize() {
+		return false, ErrTooBig
+	}
+
+	root, inserted, err := t.insert(t.meta.root, key, value, replace)
+	if err != nil {
+		return false, err
+	}
+
+	t.meta.root = root
+	if inserted {
+		t.meta.count++
+	}
+
+	return inserted, nil
+}
+
+// Inserts into the subtree rooted at id, returning its new root
+
+func (t *Tree) insert(id PageID, key, value []byte, replace bool) (PageID, bool, error) {
+
+	if id == 0 {
+		id, err := t.alloc()
+		if err != nil {
+			return 0, false, err
+		}
+		return id, true, t.write(&node{id: id, height: 1, key: key, value: value})
+	}
+
+	n, err := t.read(id)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var inserted bool
+
+	res := t.cmp(key, n.key)
+	switch {
+	case res < 0:
+		if n.left, inserted, err = t.insert(n.left, key, value, replace); err != nil {
+			return 0, false, err
+		}
+	case res > 0:
+		if n.right, inserted, err = t.insert(n.right, key, value, replace); err != nil {
+			return 0, false, err
+		}
+	default:
+		if replace {
+			n.value = value
+			return id, false, t.write(n)
+		}
+		return id, false, nil
+	}
+
+	if !inserted {
+		return id, false, t.write(n)
+	}
+
+	id, err = t.balance(n)
+
+	return id, true, err
+}
+
+// Removes key.  Returns true if it was present
+
+func (t *Tree) Remove(key []byte) (bool, error) {
+
+	root, removed, err := t.remove(t.meta.root, key)
+	if err != nil {
+		return false, err
+	}
+
+	t.meta.root = root
+	if removed {
+		t.meta.count--
+	}
+
+	return removed, nil
+}
+
+// Removes key from the subtree rooted at id, returning its new root
+
+func (t *Tree) remove(id PageID, key []byte) (PageID, bool, error) {
+
+	if id == 0 {
+		return 0, false, nil
+	}
+
+	n, err := t.read(id)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var removed bool
+
+	res := t.cmp(key, n.key)
+	switch {
+	case res < 0:
+		if n.left, removed, err = t.remove(n.left, key); err != nil {
+			return 0, false, err
+		}
+	case res > 0:
+		if n.right, removed, err = t.remove(n.right, key); err != nil {
+			return 0, false, err
+		}
+	default:
+		removed = true
+
+		if n.left == 0 || n.right == 0 {
+			child := n.left
+			if child == 0 {
+				child = n.right
+			}
+			return child, true, t.release(id)
+		}
+
+		// Two children: take over the successor's entry, and remove
+		// the successor from the right subtree instead
+
+		succ, err := t.read(n.right)
+		if err != nil {
+			return 0, false, err
+		}
+		for succ.left != 0 {
+			if succ, err = t.read(succ.left); err != nil {
+				return 0, false, err
+			}
+		}
+
+		n.key, n.value = succ.key, succ.value
+		if n.right, _, err = t.remove(n.right, succ.key); err != nil {
+			return 0, false, err
+		}
+	}
+
+	if !removed {
+		return id, false, nil
+	}
+
+	id, err = t.balance(n)
+
+	return id, true, err
+}
+
+// Calls fn, in order, with each entry whose key is in [lo, hi), until fn
+// returns false.  A nil bound leaves that end of the range open.  fn must
+// not modify the tree
+
+func (t *Tree) Range(lo, hi []byte, fn func(key, value []byte) bool) error {
+	_, err := t.walk(t.meta.root, lo, hi, fn)
+	return err
+}
+
+// Walks the subtree rooted at id; returns false once fn has
+
+func (t *Tree) walk(id PageID, lo, hi []byte, fn func(key, value []byte) bool) (bool, error) {
+
+	if id == 0 {
+		return true, nil
+	}
+
+	n, err := t.read(id)
+	if err != nil {
+		return false, err
+	}
+
+	aboveLo := lo == nil || t.cmp(n.key, lo) >= 0
+	belowHi := hi == nil || t.cmp(n.key, hi) < 0
+
+	if aboveLo {
+		if more, err := t.walk(n.left, lo, hi, fn); !more || err != nil {
+			return false, err
+		}
+	}
+	if aboveLo && belowHi {
+		if !fn(n.key, n.value) {
+			return false, nil
+		}
+	}
+	if belowHi {
+		return t.walk(n.right, lo, hi, fn)
+	}
+
+	return true, nil
+}
+
+// Checks the tree's ordering, balance and count
+
+func (t *Tree) Validate() error {
+
+	count := uint64(0)
+	var prev []byte
+
+	var check func(id PageID, depth int) (int, error)
+
+	check = func(id PageID, depth int) (int, error) {
+		if id == 0 {
+			return 0, nil
+		}
+		if depth > 128 {
+			return 0, fmt.Errorf("%w: cycle", ErrBadFormat)
+		}
+
+		n, err := t.read(id)
+		if err != nil {
+			return 0, err
+		}
+
+		lh, err := check(n.left, depth+1)
+		if err != nil {
+			return 0, err
+		}
+
+		if prev != nil && t.cmp(prev, n.key) >= 0 {
+			return 0, fmt.Errorf("%w: %q is not less than %q", ErrBadFormat, prev, n.key)
+		}
+		prev = n.key
+		count++
+
+		rh, err := check(n.right, depth+1)
+		if err != nil {
+			return 0, err
+		}
+
+		if lh-rh > 1 || rh-lh > 1 || n.height != 1+max(lh, rh) {
+			return 0, fmt.Errorf("%w: page %d is out of balance", ErrBadFormat, id)
+		}
+
+		return n.height, nil
+	}
+
+	if _, err := check(t.meta.root, 0); err != nil {
+		return err
+	}
+	if count != t.meta.count {
+		return fmt.Errorf("%w: count %d, found %d", ErrBadFormat, t.meta.count, count)
+	}
+
+	return nil
+}
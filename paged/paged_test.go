@@ -0,0 +1,131 @@
+package paged
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func openFile(t *testing.T, path string, cache int) (*Tree, Pager, *os.File) {
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	assert.NoError(t, err)
+
+	var p Pager = NewFilePager(f, 256)
+	if cache > 0 {
+		p = NewCachedPager(p, cache)
+	}
+
+	tree, err := Open(p, nil)
+	assert.NoError(t, err)
+
+	return tree, p, f
+}
+
+func TestTree(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "tree.avl")
+	tree, _, f := openFile(t, path, 16)
+
+	rnd := rand.New(rand.NewSource(1))
+	model := map[string]string{}
+
+	for i := 0; i < 3000; i++ {
+		k := fmt.Sprintf("key%04d", rnd.Intn(1000))
+		v := fmt.Sprintf("v%d", i)
+
+		switch rnd.Intn(3) {
+		case 0:
+			ok, err := tree.Insert([]byte(k), []byte(v))
+			assert.NoError(t, err)
+			_, had := model[k]
+			assert.Equal(t, !had, ok)
+			if !had {
+				model[k] = v
+			}
+		case 1:
+			_, err := tree.InsertOrReplace([]byte(k), []byte(v))
+			assert.NoError(t, err)
+			model[k] = v
+		case 2:
+			ok, err := tree.Remove([]byte(k))
+			assert.NoError(t, err)
+			_, had := model[k]
+			assert.Equal(t, had, ok)
+			delete(model, k)
+		}
+	}
+
+	assert.NoError(t, tree.Validate())
+	assert.Equal(t, len(model), tree.Len())
+	assert.NoError(t, tree.Sync())
+	f.Close()
+
+	// Reopen without a cache and check every entry, and a range
+
+	tree, _, f = openFile(t, path, 0)
+	defer f.Close()
+
+	assert.NoError(t, tree.Validate())
+	assert.Equal(t, len(model), tree.Len())
+
+	var keys []string
+	for k, v := range model {
+		got, ok, err := tree.Lookup([]byte(k))
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, v, string(got))
+		if k >= "key0200" && k < "key0300" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var ranged []string
+	assert.NoError(t, tree.Range([]byte("key0200"), []byte("key0300"), func(k, v []byte) bool {
+		ranged = append(ranged, string(k))
+		return true
+	}))
+	assert.Equal(t, keys, ranged)
+
+	_, ok, err := tree.Lookup([]byte("nope"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestTreeLimits(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "tree.avl")
+	tree, _, f := openFile(t, path, 4)
+	defer f.Close()
+
+	_, err := tree.Insert(make([]byte, 200), make([]byte, 100))
+	assert.ErrorIs(t, err, ErrTooBig)
+
+	// Freed pages are reused
+
+	for i := 0; i < 100; i++ {
+		tree.Insert([]byte{byte(i)}, nil)
+	}
+	next := tree.meta.next
+	for i := 0; i < 100; i++ {
+		tree.Remove([]byte{byte(i)})
+	}
+	for i := 0; i < 100; i++ {
+		tree.Insert([]byte{byte(i)}, nil)
+	}
+	assert.Equal(t, next, tree.meta.next)
+	assert.NoError(t, tree.Validate())
+
+	// Pages that aren't a tree
+
+	g, _ := os.Create(filepath.Join(t.TempDir(), "junk"))
+	defer g.Close()
+	g.WriteString("junk")
+	_, err = Open(NewFilePager(g, 256), nil)
+	assert.ErrorIs(t, err, ErrBadFormat)
+}
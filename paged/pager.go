@@ -0,0 +1,179 @@
+package paged
+
+import (
+	"container/list"
+	"io"
+)
+
+// Identifies a page.  Page 0 holds the tree's metadata, so as a link 0
+// means no node
+
+type PageID uint64
+
+// Reads and writes fixed-size pages
+
+type Pager interface {
+	PageSize() int
+
+	// Reads page id into buf, which is PageSize bytes.  A page never
+	// written reads as zeroes
+	Read(id PageID, buf []byte) error
+
+	// Writes buf, which is PageSize bytes, to page id
+	Write(id PageID, buf []byte) error
+
+	// Makes the pages written so far durable
+	Sync() error
+}
+
+// The file operations a FilePager needs; *os.File has them
+
+type File interface {
+	io.ReaderAt
+	io.WriterAt
+	Sync() error
+}
+
+// Pages stored back to back in a file
+
+type FilePager struct {
+	f        File
+	pageSize int
+}
+
+func NewFilePager(f File, pageSize int) *FilePager {
+	return &FilePager{f: f, pageSize: pageSize}
+}
+
+func (p *FilePager) PageSize() int {
+	return p.pageSize
+}
+
+func (p *FilePager) Read(id PageID, buf []byte) error {
+
+	n, err := p.f.ReadAt(buf, int64(id)*int64(p.pageSize))
+	if err == io.EOF {
+		for i := n; i < len(buf); i++ {
+			buf[i] = 0
+		}
+		err = nil
+	}
+
+	return err
+}
+
+func (p *FilePager) Write(id PageID, buf []byte) error {
+	_, err := p.f.WriteAt(buf, int64(id)*int64(p.pageSize))
+	return err
+}
+
+func (p *FilePager) Sync() error {
+	return p.f.Sync()
+}
+
+// A write-back cache of the most recently used pages of another pager
+
+type CachedPager struct {
+	p     Pager
+	max   int
+	lru   *list.List // Of *cachedPage, most recently used first
+	pages map[PageID]*list.Element
+}
+
+type cachedPage struct {
+	id    PageID
+	buf   []byte
+	dirty bool
+}
+
+// Caches up to pages pages of p in memory
+
+func NewCachedPager(p Pager, pages int) *CachedPager {
+	if pages < 1 {
+		pages = 1
+	}
+	return &CachedPager{
+		p:     p,
+		max:   pages,
+		lru:   list.New(),
+		pages: make(map[PageID]*list.Element),
+	}
+}
+
+func (c *CachedPager) PageSize() int {
+	return c.p.PageSize()
+}
+
+// Returns the cached page id, reading it in if load is set
+
+func (c *CachedPager) get(id PageID, load bool) (*cachedPage, error) {
+
+	if e, ok := c.pages[id]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(*cachedPage), nil
+	}
+
+	if c.lru.Len() >= c.max {
+		old := c.lru.Back().Value.(*cachedPage)
+		if old.dirty {
+			if err := c.p.Write(old.id, old.buf); err != nil {
+				return nil, err
+			}
+		}
+		c.lru.Remove(c.lru.Back())
+		delete(c.pages, old.id)
+	}
+
+	cp := &cachedPage{id: id, buf: make([]byte, c.p.PageSize())}
+	if load {
+		if err := c.p.Read(id, cp.buf); err != nil {
+			return nil, err
+		}
+	}
+
+	c.pages[id] = c.lru.PushFront(cp)
+
+	return cp, nil
+}
+
+func (c *CachedPager) Read(id PageID, buf []byte) error {
+
+	cp, err := c.get(id, true)
+	if err != nil {
+		return err
+	}
+
+	copy(buf, cp.buf)
+
+	return nil
+}
+
+func (c *CachedPager) Write(id PageID, buf []byte) error {
+
+	cp, err := c.get(id, false)
+	if err != nil {
+		return err
+	}
+
+	copy(cp.buf, buf)
+	cp.dirty = true
+
+	return nil
+}
+
+// Writes back the dirty pages, then syncs the underlying pager
+
+func (c *CachedPager) Sync() error {
+
+	for e := c.lru.Back(); e != nil; e = e.Prev() {
+		cp := e.Value.(*cachedPage)
+		if cp.dirty {
+			if err := c.p.Write(cp.id, cp.buf); err != nil {
+				return err
+			}
+			cp.dirty = false
+		}
+	}
+
+	return c.p.Sync()
+}
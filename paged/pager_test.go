@@ -0,0 +1,50 @@
+package paged
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCachedPager(t *testing.T) {
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "pages"))
+	assert.NoError(t, err)
+	defer f.Close()
+
+	fp := NewFilePager(f, 64)
+	c := NewCachedPager(fp, 2)
+
+	page := func(b byte) []byte {
+		buf := make([]byte, 64)
+		buf[0] = b
+		return buf
+	}
+
+	buf := make([]byte, 64)
+
+	// Writes stay in the cache until evicted or synced
+
+	assert.NoError(t, c.Write(1, page(1)))
+	assert.NoError(t, c.Write(2, page(2)))
+	assert.NoError(t, fp.Read(1, buf))
+	assert.Equal(t, byte(0), buf[0])
+
+	assert.NoError(t, c.Write(3, page(3)))
+	assert.NoError(t, fp.Read(1, buf))
+	assert.Equal(t, byte(1), buf[0])
+	assert.NoError(t, fp.Read(3, buf))
+	assert.Equal(t, byte(0), buf[0])
+
+	assert.NoError(t, c.Sync())
+	assert.NoError(t, fp.Read(3, buf))
+	assert.Equal(t, byte(3), buf[0])
+
+	// Evicted pages are read back, and unwritten ones read as zeroes
+
+	assert.NoError(t, c.Read(1, buf))
+	assert.Equal(t, byte(1), buf[0])
+	assert.NoError(t, c.Read(9, buf))
+	assert.Equal(t, byte(0), buf[0])
+}
@@ -0,0 +1,197 @@
+package avl
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+//
+// Parallel scans.  The nodes to visit are split by in-order index into
+// one contiguous run per worker, using the subtree sizes to find where
+// each run starts, so the runs are disjoint and about equal in length.
+// fn is called concurrently and must be safe for that, and the tree
+// must not be modified until the call returns.
+//
+
+// Calls fn on up to workers goroutines (GOMAXPROCS if workers <= 0) for
+// the nodes with in-order index in [lo, hi), and waits for them all.
+// If abort is not nil, each worker polls it every so often and stops
+// once it returns true.  A worker that runs off the end of the tree,
+// which only a concurrent mutation can make it do, stops there.  If fn
+// panics the other workers stop, and once they have the first panic is
+// raised again on the calling goroutine, where a tree's error sink can
+// recover it
+
+func avlTreeScanParallel(root *AvlNode, lo, hi, workers int,
+	abort func() bool, fn func(owner interface{})) {
+
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	count := hi - lo
+	if count <= 0 {
+		return
+	}
+	if workers > count {
+		workers = count
+	}
+
+	chunk := (count + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	var panicked atomic.Bool
+	var once sync.Once
+	var first interface{}
+
+	for start := lo; start < hi; start += chunk {
+		end := start + chunk
+		if end > hi {
+			end = hi
+		}
+
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					once.Do(func() { first = r })
+					panicked.Store(true)
+				}
+			}()
+			n := avlTreeSelect(root, start)
+			for i := start; i < end && n != nil && !panicked.Load(); i++ {
+				if abort != nil && (i-start)%avlContextCheckInterval == 0 &&
+					abort() {
+					return
+				}
+				fn(n.owner)
+				n = avlTreeNextOrPrevInOrder(n, 1)
+			}
+		}(start, end)
+	}
+
+	wg.Wait()
+
+	if panicked.Load() {
+		panic(first)
+	}
+}
+
+// Calls fn with each owner whose key is in [lo, hi), as AvlTreeRange
+// does, but on up to workers goroutines and in no particular order.  A
+// nil bound leaves that end of the range open
+
+func AvlTreeRangeParallel(root *AvlNode, lo, hi interface{}, cmp CmpFuncKey,
+	workers int, fn func(owner interface{})) {
+
+	AvlTreeRangeParallelContext(context.Background(), root, lo, hi, cmp,
+		workers, fn)
+}
+
+// Like AvlTreeRangeParallel, but gives up and returns ctx.Err() once ctx
+// is done, when fn may have seen only some of the owners
+
+func AvlTreeRangeParallelContext(ctx context.Context, root *AvlNode,
+	lo, hi interface{}, cmp CmpFuncKey, workers int,
+	fn func(owner interface{})) error {
+
+	first := 0
+	if lo != nil {
+		first = AvlTreeIndexOfFirstGE(root, lo, cmp)
+	}
+
+	last := avlGetSize(root)
+	if hi != nil {
+		last = AvlTreeIndexOfFirstGE(root, hi, cmp)
+	}
+
+	avlTreeScanParallel(root, first, last, workers, func() bool {
+		return ctx.Err() != nil
+	}, fn)
+
+	return ctx.Err()
+}
+
+// See AvlTreeRangeParallel
+
+func (tree *AvlTree) RangeParallel(lo, hi interface{}, cmp CmpFuncKey,
+	workers int, fn func(owner interface{})) {
+
+	tree.RangeParallelContext(context.Background(), lo, hi, cmp, workers, fn)
+}
+
+// See AvlTreeRangeParallelContext
+
+func (tree *AvlTree) RangeParallelContext(ctx context.Context,
+	lo, hi interface{}, cmp CmpFuncKey, workers int,
+	fn func(owner interface{})) (err error) {
+
+	defer tree.recoverToErr(&err)
+
+	tree.labeled(ctx, "range", func(ctx context.Context) {
+		err = AvlTreeRangeParallelContext(ctx, tree.root, lo, hi, cmp,
+			workers, fn)
+	})
+
+	return err
+}
+
+// Calls fn with every owner in the tree, on up to workers goroutines
+// (GOMAXPROCS if workers <= 0) and in no particular order.  The tree's
+// generation, which every mutation through its methods advances, is
+// checked as the walk goes and again at the end; if it has moved, the
+// walk stops early and ErrConcurrentModification is returned, and fn may
+// have seen some owners twice or not at all.  Mutations made with the
+// raw functions are not detected
+
+func AvlTreeForEachParallel(tree *AvlTree, workers int,
+	fn func(owner interface{})) error {
+
+	return AvlTreeForEachParallelContext(context.Background(), tree,
+		workers, fn)
+}
+
+// Like AvlTreeForEachParallel, but also gives up and returns ctx.Err()
+// once ctx is done
+
+func AvlTreeForEachParallelContext(ctx context.Context, tree *AvlTree,
+	workers int, fn func(owner interface{})) (err error) {
+
+	defer tree.recoverToErr(&err)
+
+	gen := tree.gen.Load()
+	changed := func() bool {
+		return tree.gen.Load() != gen
+	}
+
+	tree.labeled(ctx, "foreach", func(ctx context.Context) {
+		avlTreeScanParallel(tree.root, 0, tree.size, workers, func() bool {
+			return changed() || ctx.Err() != nil
+		}, fn)
+	})
+
+	if changed() {
+		return ErrConcurrentModification
+	}
+
+	return ctx.Err()
+}
+
+// See AvlTreeForEachParallel
+
+func (tree *AvlTree) ForEachParallel(workers int,
+	fn func(owner interface{})) error {
+
+	return AvlTreeForEachParallel(tree, workers, fn)
+}
+
+// See AvlTreeForEachParallelContext
+
+func (tree *AvlTree) ForEachParallelContext(ctx context.Context, workers int,
+	fn func(owner interface{})) error {
+
+	return AvlTreeForEachParallelContext(ctx, tree, workers, fn)
+}
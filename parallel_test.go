@@ -0,0 +1,140 @@
+package avl
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestAvlTreeRangeParallel(t *testing.T) {
+
+	keys := make([]int, 10000)
+	for i := range keys {
+		keys[i] = i * 2
+	}
+	r, _ := buildIntTree(keys...)
+
+	for _, c := range []struct {
+		lo, hi  interface{}
+		workers int
+	}{
+		{nil, nil, 4},
+		{100, 5001, 3},
+		{101, 101, 8},
+		{19990, nil, 16},
+		{nil, 7, 0},
+		{-50, 50000, 1},
+	} {
+		var mu sync.Mutex
+		seen := map[int]int{}
+
+		AvlTreeRangeParallel(r, c.lo, c.hi, cmpIntKey, c.workers, func(owner interface{}) {
+			mu.Lock()
+			seen[owner.(*intNode).key]++
+			mu.Unlock()
+		})
+
+		want := map[int]int{}
+		AvlTreeRange(r, c.lo, c.hi, cmpIntKey, func(owner interface{}) bool {
+			want[owner.(*intNode).key]++
+			return true
+		})
+
+		assert.Equal(t, want, seen)
+	}
+}
+
+func TestAvlTreeForEachParallel(t *testing.T) {
+
+	var tree AvlTree
+
+	ns := newIntNodes(make([]int, 5000)...)
+	for i, n := range ns {
+		n.key = i
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	var mu sync.Mutex
+	sum := 0
+
+	assert.NoError(t, tree.ForEachParallel(4, func(owner interface{}) {
+		mu.Lock()
+		sum += owner.(*intNode).key
+		mu.Unlock()
+	}))
+	assert.Equal(t, 4999*5000/2, sum)
+
+	// A mutation during the walk is reported.  With one worker fn runs
+	// on a single goroutine, so it can safely insert into the tree
+
+	extra := &intNode{key: 10000}
+	visited := 0
+
+	err := AvlTreeForEachParallel(&tree, 1, func(owner interface{}) {
+		if visited == 0 {
+			tree.Insert(&extra.avlHeader, extra, cmpIntNode)
+		}
+		visited++
+	})
+	assert.ErrorIs(t, err, ErrConcurrentModification)
+	assert.True(t, visited < 5000)
+}
+
+func TestAvlTreeForEachParallelShrinking(t *testing.T) {
+
+	var tree AvlTree
+
+	ns := newIntNodes(make([]int, 100)...)
+	for i, n := range ns {
+		n.key = i
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	// Removing the rest of the tree under the walk, between its checks,
+	// stops it at the new end rather than crashing
+
+	visited := 0
+	err := AvlTreeForEachParallel(&tree, 1, func(owner interface{}) {
+		if visited == 1 {
+			for tree.Len() > 2 {
+				tree.PopMax()
+			}
+		}
+		visited++
+	})
+	assert.ErrorIs(t, err, ErrConcurrentModification)
+	assert.Equal(t, 2, visited)
+}
+
+func TestAvlTreeParallelContext(t *testing.T) {
+
+	var tree AvlTree
+
+	ns := newIntNodes(make([]int, 5000)...)
+	for i, n := range ns {
+		n.key = i
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	visited := 0
+	count := func(owner interface{}) { visited++ }
+
+	assert.NoError(t, tree.RangeParallelContext(context.Background(), 100, 200,
+		cmpIntKey, 1, count))
+	assert.Equal(t, 100, visited)
+	assert.NoError(t, tree.ForEachParallelContext(context.Background(), 1, count))
+	assert.Equal(t, 5100, visited)
+
+	// Each worker checks the context before it starts
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	visited = 0
+
+	assert.ErrorIs(t, tree.RangeParallelContext(ctx, nil, nil, cmpIntKey, 1,
+		count), context.Canceled)
+	assert.ErrorIs(t, tree.ForEachParallelContext(ctx, 1, count),
+		context.Canceled)
+	assert.Equal(t, 0, visited)
+}
@@ -0,0 +1,130 @@
+package avl
+
+//
+// Queries about the positions of nodes relative to each other, using
+// only the parent pointers.  None of these need the comparison function.
+//
+
+// Returns the number of edges from node up to the root of its tree
+
+func avlDepth(node *AvlNode) int {
+
+	depth := 0
+
+	for p := avlGetParent(node); p != nil; p = avlGetParent(p) {
+		depth++
+	}
+
+	return depth
+}
+
+// Returns the node's distance from the root of its tree: 0 for the root,
+// 1 for its children, and so on.  O(log n)
+
+func (n *AvlNode) Depth() int {
+	return avlDepth(n)
+}
+
+// Returns the lowest common ancestor of two nodes in the same tree: the
+// deepest node that has both of them in its subtree (which is one of
+// them, if one is an ancestor of the other).  Returns nil if the nodes
+// are not in the same tree.  O(log n)
+
+func AvlTreeLCA(a, b *AvlNode) *AvlNode {
+
+	da := avlDepth(a)
+	db := avlDepth(b)
+
+	// Bring the deeper node up to the depth of the other, then climb
+	// in step until the two paths meet
+
+	for ; da > db; da-- {
+		a = avlGetParent(a)
+	}
+	for ; db > da; db-- {
+		b = avlGetParent(b)
+	}
+
+	for a != b {
+		a = avlGetParent(a)
+		b = avlGetParent(b)
+	}
+
+	return a
+}
+
+// Returns the owners on the path from the root of node's tree down to
+// node, root first and node's own owner last.  This is the sequence of
+// owners a lookup of node's key compares against
+
+func AvlTreePath(node *AvlNode) []interface{} {
+
+	path := make([]interface{}, avlDepth(node)+1)
+
+	for i, n := len(path)-1, node; n != nil; i, n = i-1, avlGetParent(n) {
+		path[i] = n.owner
+	}
+
+	return path
+}
+
+// Returns the number of edges on the path between two nodes of the same
+// tree (0 if they are the same node), or -1 if they are in different
+// trees.  O(log n)
+
+func AvlTreeDistance(a, b *AvlNode) int {
+
+	lca := AvlTreeLCA(a, b)
+	if lca == nil {
+		return -1
+	}
+
+	d := avlDepth(lca)
+
+	return avlDepth(a) - d + avlDepth(b) - d
+}
+
+// True if a comes before b in order, in the same tree; false if b comes
+// first, if a and b are the same node, or if they are in different
+// trees.  Finds the lowest common ancestor as AvlTreeLCA does,
+// remembering the child each climb came from, so it calls no comparison
+// function.  O(log n)
+
+func AvlTreeIsBefore(a, b *AvlNode) bool {
+
+	if a == b {
+		return false
+	}
+
+	da := avlDepth(a)
+	db := avlDepth(b)
+
+	// The child of a, and of b, from which each last climbed
+
+	var ca, cb *AvlNode
+
+	for ; da > db; da-- {
+		ca, a = a, avlGetParent(a)
+	}
+	for ; db > da; db-- {
+		cb, b = b, avlGetParent(b)
+	}
+
+	for a != b {
+		ca, a = a, avlGetParent(a)
+		cb, b = b, avlGetParent(b)
+	}
+	if a == nil {
+		return false
+	}
+
+	// a is now the common ancestor.  If it is the original a, b is below
+	// it and comes after it if it is on the right; if it is the original
+	// b, the original a comes before it if it is on the left
+
+	if ca == nil {
+		return cb == a.right
+	}
+
+	return ca == a.left
+}
@@ -0,0 +1,119 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestAvlTreeLCA(t *testing.T) {
+
+	ns := newIntNodes(0, 1, 2, 3, 4, 5, 6)
+
+	//
+	//          3
+	//        /   \
+	//       1     5
+	//      / \   / \
+	//     0   2 4   6
+	//
+
+	r, err := AvlTreeFromShape(inner(ns[3],
+		inner(ns[1], leaf(ns[0]), leaf(ns[2])),
+		inner(ns[5], leaf(ns[4]), leaf(ns[6]))))
+	assert.NoError(t, err)
+
+	lca := func(a, b int) *AvlNode {
+		return AvlTreeLCA(&ns[a].avlHeader, &ns[b].avlHeader)
+	}
+
+	assert.Equal(t, &ns[1].avlHeader, lca(0, 2))
+	assert.Equal(t, r, lca(0, 6))
+	assert.Equal(t, r, lca(2, 4))
+	assert.Equal(t, &ns[5].avlHeader, lca(5, 6))
+	assert.Equal(t, &ns[4].avlHeader, lca(4, 4))
+	assert.Equal(t, r, lca(3, 0))
+
+	other := &intNode{key: 9}
+	var r2 *AvlNode
+	AvlTreeInsert(&r2, &other.avlHeader, other, cmpIntNode)
+	assert.Nil(t, AvlTreeLCA(&ns[0].avlHeader, &other.avlHeader))
+}
+
+func TestAvlNodeDepth(t *testing.T) {
+
+	r, ns := buildIntTree(1, 2, 3, 4, 5, 6, 7)
+
+	assert.Equal(t, 0, r.Depth())
+
+	maxDepth := 0
+	for _, n := range ns {
+		d := n.avlHeader.Depth()
+		if p := n.avlHeader.Parent(); p != nil {
+			assert.Equal(t, p.Depth()+1, d)
+		}
+		if d > maxDepth {
+			maxDepth = d
+		}
+	}
+	assert.Equal(t, 2, maxDepth)
+}
+
+func TestAvlTreePath(t *testing.T) {
+
+	r, ns := buildIntTree(1, 2, 3, 4, 5, 6, 7)
+
+	assert.Equal(t, []interface{}{r.Owner()}, AvlTreePath(r))
+
+	// The path must be exactly the owners a lookup visits
+
+	for _, n := range ns {
+		var visited []interface{}
+		AvlTreeLookup(r, n.key, func(key interface{}, node interface{}) int {
+			visited = append(visited, node)
+			return cmpIntKey(key, node)
+		})
+		assert.Equal(t, visited, AvlTreePath(&n.avlHeader))
+	}
+}
+
+func TestAvlTreeDistance(t *testing.T) {
+
+	ns := newIntNodes(0, 1, 2, 3, 4, 5, 6)
+
+	_, err := AvlTreeFromShape(inner(ns[3],
+		inner(ns[1], leaf(ns[0]), leaf(ns[2])),
+		inner(ns[5], leaf(ns[4]), leaf(ns[6]))))
+	assert.NoError(t, err)
+
+	dist := func(a, b int) int {
+		return AvlTreeDistance(&ns[a].avlHeader, &ns[b].avlHeader)
+	}
+
+	assert.Equal(t, 0, dist(2, 2))
+	assert.Equal(t, 1, dist(1, 0))
+	assert.Equal(t, 2, dist(0, 2))
+	assert.Equal(t, 4, dist(0, 6))
+	assert.Equal(t, 3, dist(6, 1))
+
+	other := &intNode{key: 9}
+	var r2 *AvlNode
+	AvlTreeInsert(&r2, &other.avlHeader, other, cmpIntNode)
+	assert.Equal(t, -1, AvlTreeDistance(&ns[0].avlHeader, &other.avlHeader))
+}
+
+func TestAvlTreeIsBefore(t *testing.T) {
+
+	_, ns := buildIntTree(rand.New(rand.NewSource(1224)).Perm(64)...)
+
+	for _, a := range ns {
+		for _, b := range ns {
+			assert.Equal(t, a.key < b.key, AvlTreeIsBefore(&a.avlHeader, &b.avlHeader))
+		}
+	}
+
+	other := &intNode{key: 9}
+	var r2 *AvlNode
+	AvlTreeInsert(&r2, &other.avlHeader, other, cmpIntNode)
+	assert.False(t, AvlTreeIsBefore(&ns[0].avlHeader, &other.avlHeader))
+}
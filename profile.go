@@ -0,0 +1,37 @@
+package avl
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+//
+// Profiler labels.  In a process with many trees, a CPU profile of the
+// package's functions doesn't say which tree the time went on.  A tree
+// named with WithProfileLabels runs its bulk operations (the ones that
+// visit a large part of the tree: validation, parallel scans, freezing
+// and pruning) under the pprof labels avl_tree=<name> and
+// avl_op=<operation>, which the goroutines started for the operation
+// inherit.
+//
+
+// Names the tree for profiling
+
+func WithProfileLabels(name string) AvlTreeOption {
+	return func(tree *AvlTree) {
+		tree.profileName = name
+	}
+}
+
+// Runs fn, under the tree's profiler labels if it has a name
+
+func (tree *AvlTree) labeled(ctx context.Context, op string,
+	fn func(ctx context.Context)) {
+
+	if tree.profileName == "" {
+		fn(ctx)
+		return
+	}
+
+	pprof.Do(ctx, pprof.Labels("avl_tree", tree.profileName, "avl_op", op), fn)
+}
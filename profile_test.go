@@ -0,0 +1,37 @@
+package avl
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"runtime/pprof"
+	"testing"
+)
+
+func TestWithProfileLabels(t *testing.T) {
+
+	tree := NewAvlTree(WithProfileLabels("users"))
+
+	var name, op string
+	tree.labeled(context.Background(), "validate", func(ctx context.Context) {
+		name, _ = pprof.Label(ctx, "avl_tree")
+		op, _ = pprof.Label(ctx, "avl_op")
+	})
+	assert.Equal(t, "users", name)
+	assert.Equal(t, "validate", op)
+
+	// Unnamed trees add no labels
+
+	var ok bool
+	NewAvlTree().labeled(context.Background(), "validate", func(ctx context.Context) {
+		_, ok = pprof.Label(ctx, "avl_tree")
+	})
+	assert.False(t, ok)
+
+	assert.NoError(t, tree.Validate(cmpIntNode))
+}
+
+func TestAvlTreeAllocs(t *testing.T) {
+
+	tree := Build().Keys(3, 1, 2, 1).Tree()
+	assert.Equal(t, uint64(3), tree.Stats().Allocs)
+}
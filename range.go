@@ -0,0 +1,205 @@
+package avl
+
+//
+// Iteration over ranges of keys.  A range is given by an inclusive lower
+// bound and an exclusive upper bound, compared against the owners with
+// a CmpFuncKey; a nil bound leaves that end of the range open.
+//
+
+// Returns the first node whose owner orders at or after key, or nil if
+// there is none
+
+func avlTreeFirstAtOrAfter(root *AvlNode, key interface{},
+	cmp CmpFuncKey) *AvlNode {
+
+	var found *AvlNode
+
+	for cur := root; cur != nil; {
+		if cmp(key, cur.owner) <= 0 {
+			found = cur
+			cur = cur.left
+		} else {
+			cur = cur.right
+		}
+	}
+
+	return found
+}
+
+// Returns the last node whose owner orders at or before key, or nil if
+// there is none
+
+func avlTreeLastAtOrBefore(root *AvlNode, key interface{},
+	cmp CmpFuncKey) *AvlNode {
+
+	var found *AvlNode
+
+	for cur := root; cur != nil; {
+		if cmp(key, cur.owner) >= 0 {
+			found = cur
+			cur = cur.right
+		} else {
+			cur = cur.left
+		}
+	}
+
+	return found
+}
+
+// Returns the greatest owner whose key is <= key, or nil if there is
+// none.  O(log n)
+
+func AvlTreeFloor(root *AvlNode, key interface{}, cmp CmpFuncKey) interface{} {
+	if n := avlTreeLastAtOrBefore(root, key, cmp); n != nil {
+		return n.owner
+	}
+	return nil
+}
+
+// Returns the least owner whose key is >= key, or nil if there is none.
+// O(log n)
+
+func AvlTreeCeiling(root *AvlNode, key interface{}, cmp CmpFuncKey) interface{} {
+	if n := avlTreeFirstAtOrAfter(root, key, cmp); n != nil {
+		return n.owner
+	}
+	return nil
+}
+
+// Calls fn, in order, with each owner whose key is in [lo, hi), until fn
+// returns false
+
+func AvlTreeRange(root *AvlNode, lo, hi interface{}, cmp CmpFuncKey,
+	fn func(owner interface{}) bool) {
+
+	var cur *AvlNode
+
+	if lo != nil {
+		cur = avlTreeFirstAtOrAfter(root, lo, cmp)
+	} else {
+		cur = avlTreeFirstOrLastInOrder(root, -1)
+	}
+
+	for ; cur != nil; cur = avlTreeNextOrPrevInOrder(cur, 1) {
+		if hi != nil && cmp(hi, cur.owner) <= 0 {
+			break
+		}
+		if !fn(cur.owner) {
+			break
+		}
+	}
+}
+
+// Returns the least string greater than every string that starts with
+// prefix, or false if there is none (prefix is empty or all 0xff bytes)
+
+func prefixEnd(prefix string) (string, bool) {
+
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			return prefix[:i] + string([]byte{prefix[i] + 1}), true
+		}
+	}
+
+	return "", false
+}
+
+// Calls fn, in order, with each owner whose key starts with prefix, until
+// fn returns false.  The keys must be strings, ordered bytewise, and cmp
+// is called with string keys; the scan is the range [prefix, end), where
+// end is the first string past every one with the prefix
+
+func AvlTreePrefix(root *AvlNode, prefix string, cmp CmpFuncKey,
+	fn func(owner interface{}) bool) {
+
+	if end, ok := prefixEnd(prefix); ok {
+		AvlTreeRange(root, prefix, end, cmp, fn)
+	} else {
+		AvlTreeRange(root, prefix, nil, cmp, fn)
+	}
+}
+
+// See AvlTreeRange
+
+func (tree *AvlTree) Range(lo, hi interface{}, cmp CmpFuncKey,
+	fn func(owner interface{}) bool) {
+
+	defer tree.recoverTo()
+
+	AvlTreeRange(tree.root, lo, hi, cmp, fn)
+}
+
+// Reduces the tree in order: calls fn with the accumulator, starting at
+// init, and each owner in turn, and returns the final accumulator
+
+func AvlTreeFold(root *AvlNode, init interface{},
+	fn func(acc, owner interface{}) interface{}) interface{} {
+
+	acc := init
+
+	for cur := avlTreeFirstOrLastInOrder(root, -1); cur != nil; cur = avlTreeNextOrPrevInOrder(cur, 1) {
+		acc = fn(acc, cur.owner)
+	}
+
+	return acc
+}
+
+// AvlTreeFold over the owners whose keys are in [lo, hi); a nil bound
+// leaves that end of the range open
+
+func AvlTreeReduceRange(root *AvlNode, lo, hi interface{}, cmp CmpFuncKey,
+	init interface{}, fn func(acc, owner interface{}) interface{}) interface{} {
+
+	acc := init
+
+	AvlTreeRange(root, lo, hi, cmp, func(owner interface{}) bool {
+		acc = fn(acc, owner)
+		return true
+	})
+
+	return acc
+}
+
+// See AvlTreePrefix
+
+func (tree *AvlTree) Prefix(prefix string, cmp CmpFuncKey,
+	fn func(owner interface{}) bool) {
+
+	defer tree.recoverTo()
+
+	AvlTreePrefix(tree.root, prefix, cmp, fn)
+}
+
+// See AvlTreeFloor
+
+func (tree *AvlTree) Floor(key interface{}, cmp CmpFuncKey) interface{} {
+	defer tree.recoverTo()
+	return AvlTreeFloor(tree.root, key, cmp)
+}
+
+// See AvlTreeCeiling
+
+func (tree *AvlTree) Ceiling(key interface{}, cmp CmpFuncKey) interface{} {
+	defer tree.recoverTo()
+	return AvlTreeCeiling(tree.root, key, cmp)
+}
+
+// See AvlTreeFold
+
+func (tree *AvlTree) Fold(init interface{},
+	fn func(acc, owner interface{}) interface{}) interface{} {
+
+	defer tree.recoverTo()
+
+	return AvlTreeFold(tree.root, init, fn)
+}
+
+// See AvlTreeReduceRange
+
+func (tree *AvlTree) ReduceRange(lo, hi interface{}, cmp CmpFuncKey,
+	init interface{}, fn func(acc, owner interface{}) interface{}) interface{} {
+
+	defer tree.recoverTo()
+
+	return AvlTreeReduceRange(tree.root, lo, hi, cmp, init, fn)
+}
@@ -0,0 +1,136 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func collectRange(rng func(lo, hi interface{}, cmp CmpFuncKey,
+	fn func(owner interface{}) bool), lo, hi interface{}) []int {
+
+	var keys []int
+	rng(lo, hi, cmpIntKey, func(owner interface{}) bool {
+		keys = append(keys, owner.(*intNode).key)
+		return true
+	})
+	return keys
+}
+
+func TestAvlTreeRange(t *testing.T) {
+
+	var tree AvlTree
+
+	for _, n := range newIntNodes(5, 1, 9, 3, 7) {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	assert.Equal(t, []int{1, 3, 5, 7, 9}, collectRange(tree.Range, nil, nil))
+	assert.Equal(t, []int{3, 5}, collectRange(tree.Range, 3, 7))
+	assert.Equal(t, []int{5, 7}, collectRange(tree.Range, 4, 8))
+	assert.Equal(t, []int{7, 9}, collectRange(tree.Range, 6, nil))
+	assert.Equal(t, []int{1}, collectRange(tree.Range, nil, 2))
+	assert.Empty(t, collectRange(tree.Range, 10, nil))
+	assert.Empty(t, collectRange(tree.Range, 5, 5))
+
+	var keys []int
+	tree.Range(nil, nil, cmpIntKey, func(owner interface{}) bool {
+		keys = append(keys, owner.(*intNode).key)
+		return len(keys) < 2
+	})
+	assert.Equal(t, []int{1, 3}, keys)
+}
+
+func TestAvlTreeFold(t *testing.T) {
+
+	var tree AvlTree
+
+	for _, n := range newIntNodes(5, 1, 9, 3, 7) {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	sum := func(acc, owner interface{}) interface{} {
+		return acc.(int) + owner.(*intNode).key
+	}
+
+	assert.Equal(t, 25, tree.Fold(0, sum))
+	assert.Equal(t, 15, tree.ReduceRange(3, 9, cmpIntKey, 0, sum))
+	assert.Equal(t, 16, tree.ReduceRange(6, nil, cmpIntKey, 0, sum))
+	assert.Equal(t, "x", AvlTreeFold(nil, "x", sum))
+
+	// The order is in-order
+
+	digits := tree.Fold("", func(acc, owner interface{}) interface{} {
+		return acc.(string) + string(rune('0'+owner.(*intNode).key))
+	})
+	assert.Equal(t, "13579", digits)
+}
+
+func TestAvlTreeFloorCeiling(t *testing.T) {
+
+	var tree AvlTree
+
+	assert.Nil(t, tree.Floor(3, cmpIntKey))
+
+	for _, n := range newIntNodes(5, 1, 9, 3, 7) {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	key := func(owner interface{}) interface{} {
+		if owner == nil {
+			return nil
+		}
+		return owner.(*intNode).key
+	}
+
+	assert.Equal(t, 3, key(tree.Floor(3, cmpIntKey)))
+	assert.Equal(t, 3, key(tree.Floor(4, cmpIntKey)))
+	assert.Equal(t, 9, key(tree.Floor(100, cmpIntKey)))
+	assert.Nil(t, tree.Floor(0, cmpIntKey))
+
+	assert.Equal(t, 3, key(tree.Ceiling(3, cmpIntKey)))
+	assert.Equal(t, 5, key(tree.Ceiling(4, cmpIntKey)))
+	assert.Equal(t, 1, key(tree.Ceiling(-5, cmpIntKey)))
+	assert.Nil(t, tree.Ceiling(10, cmpIntKey))
+}
+
+type strNode struct {
+	avlHeader AvlNode
+	key       string
+}
+
+func cmpStrKey(key interface{}, node interface{}) int {
+	return strings.Compare(key.(string), node.(*strNode).key)
+}
+
+func cmpStrNode(node1 interface{}, node2 interface{}) int {
+	return strings.Compare(node1.(*strNode).key, node2.(*strNode).key)
+}
+
+func TestAvlTreePrefix(t *testing.T) {
+
+	var tree AvlTree
+
+	for _, k := range []string{"car", "cart", "carbon", "cat", "ca",
+		"dog", "c", "\xff", "\xff\xff", "\xff\xffa", "caz\xff", "cb"} {
+		n := &strNode{key: k}
+		tree.Insert(&n.avlHeader, n, cmpStrNode)
+	}
+
+	prefixed := func(prefix string, limit int) []string {
+		var out []string
+		tree.Prefix(prefix, cmpStrKey, func(owner interface{}) bool {
+			out = append(out, owner.(*strNode).key)
+			return len(out) < limit
+		})
+		return out
+	}
+
+	assert.Equal(t, []string{"car", "carbon", "cart"}, prefixed("car", 10))
+	assert.Equal(t, []string{"ca", "car", "carbon", "cart", "cat", "caz\xff"}, prefixed("ca", 10))
+	assert.Equal(t, []string{"ca", "car"}, prefixed("ca", 2))
+	assert.Equal(t, []string{"caz\xff"}, prefixed("caz", 10))
+	assert.Equal(t, []string{"\xff\xff", "\xff\xffa"}, prefixed("\xff\xff", 10))
+	assert.Nil(t, prefixed("cartwheel", 10))
+	assert.Len(t, prefixed("", 100), 12)
+}
@@ -0,0 +1,70 @@
+package avl
+
+import "fmt"
+
+//
+// Panic-free trees.  Left to itself an AvlTree panics when it finds it
+// has been misused: a failed self-check, transactions finished out of
+// order.  So does a comparator handed keys it cannot compare, inside a
+// tree method.  A long-running process may rather log the problem and
+// carry on; with WithErrorSink those panics become errors passed to the
+// sink, and the method that hit one returns zero values, or, if it
+// returns an error, the error passed to the sink.
+//
+// A comparator panics before anything is linked or unlinked, so the tree
+// is left as it was.  An observer that panics does so after the change,
+// so the tree holds the change, but the observers after it, and any
+// eviction the change called for, have not run.
+//
+
+// Sends the errors the tree would otherwise panic with, and the panics
+// of the comparators and observers called by its methods, to sink
+
+func WithErrorSink(sink func(err error)) AvlTreeOption {
+	return func(tree *AvlTree) {
+		tree.errSink = sink
+	}
+}
+
+// Reports err to the sink, or panics with it if there is none
+
+func (tree *AvlTree) fail(err error) {
+	if tree.errSink == nil {
+		panic(err)
+	}
+	tree.errSink(err)
+}
+
+// Deferred by the tree's methods: with an error sink, turns a panic in
+// the method into an error wrapping ErrPanic for the sink
+
+func (tree *AvlTree) recoverTo() {
+	if tree.errSink == nil {
+		return
+	}
+	if r := recover(); r != nil {
+		tree.errSink(avlPanicError(r))
+	}
+}
+
+// recoverTo for the methods that return an error, which also return the
+// error passed to the sink
+
+func (tree *AvlTree) recoverToErr(err *error) {
+	if tree.errSink == nil {
+		return
+	}
+	if r := recover(); r != nil {
+		*err = avlPanicError(r)
+		tree.errSink(*err)
+	}
+}
+
+// Wraps the value recovered from a panic in ErrPanic
+
+func avlPanicError(r interface{}) error {
+	if err, ok := r.(error); ok {
+		return fmt.Errorf("%w: %w", ErrPanic, err)
+	}
+	return fmt.Errorf("%w: %v", ErrPanic, r)
+}
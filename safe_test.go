@@ -0,0 +1,192 @@
+package avl
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestAvlTreeErrorSink(t *testing.T) {
+
+	var errs []error
+	tree := NewAvlTree(WithSelfCheck(cmpIntNode), WithErrorSink(func(err error) {
+		errs = append(errs, err)
+	}))
+
+	ns := newIntNodes(1, 2, 3, 4)
+	for _, n := range ns[:3] {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	// A failed self-check is reported rather than panicking
+
+	ns[0].key = 5
+	assert.NotPanics(t, func() {
+		tree.Insert(&ns[3].avlHeader, ns[3], cmpIntNode)
+	})
+	assert.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrInvalidTree)
+	ns[0].key = 1
+
+	// So is a comparator's panic, leaving the tree alone
+
+	errs = nil
+	tree = NewAvlTree(WithErrorSink(func(err error) {
+		errs = append(errs, err)
+	}))
+	tree.Insert(&ns[0].avlHeader, ns[0], cmpIntNode)
+	assert.Nil(t, tree.Lookup("one", CmpFuncKey(func(key, owner interface{}) int {
+		return AvlCompareValues(key, owner.(*intNode).key)
+	})))
+	assert.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrPanic)
+	assert.Equal(t, 1, tree.Len())
+
+	// And an observer's, after the change
+
+	tree.Watch(func(op AvlOp, owner interface{}) {
+		panic("observer")
+	})
+	assert.NotPanics(t, func() {
+		tree.Remove(&ns[0].avlHeader)
+	})
+	assert.Len(t, errs, 2)
+	assert.Contains(t, errs[1].Error(), "observer")
+	assert.Equal(t, 0, tree.Len())
+}
+
+func TestAvlTreeErrorSinkTxnOrder(t *testing.T) {
+
+	var errs []error
+	tree := NewAvlTree(WithErrorSink(func(err error) {
+		errs = append(errs, err)
+	}))
+
+	outer := tree.Begin(cmpIntNode)
+	inner := tree.Begin(cmpIntNode)
+
+	assert.ErrorIs(t, outer.Commit(), ErrTxnOrder)
+	assert.Len(t, errs, 1)
+	assert.NoError(t, inner.Commit())
+	assert.NoError(t, outer.Commit())
+
+	// Without a sink the misuse still panics
+
+	var plain AvlTree
+	outer = plain.Begin(cmpIntNode)
+	plain.Begin(cmpIntNode)
+	assert.Panics(t, func() { outer.Commit() })
+}
+
+func TestAvlTreeErrorSinkEntryPoints(t *testing.T) {
+
+	var errs []error
+	tree := NewAvlTree(WithErrorSink(func(err error) {
+		errs = append(errs, err)
+	}))
+
+	ns := newIntNodes(1, 2, 3)
+	for _, n := range ns {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	// Comparators that cannot compare the keys they are given
+
+	badKey := CmpFuncKey(func(key, owner interface{}) int {
+		return AvlCompareValues(key, owner.(*intNode).key)
+	})
+	badNode := func(node1, node2 interface{}) int {
+		panic("cmp")
+	}
+	visit := func(owner interface{}) bool { return true }
+
+	assert.NotPanics(t, func() {
+		assert.Nil(t, tree.LookupOr("two", badKey, "none"))
+		tree.Search(func(owner interface{}) bool { panic("pred") })
+		tree.IndexOfFirstGE("two", badKey)
+		tree.IndexOfFirstGT("two", badKey)
+		tree.CountLess("two", badKey)
+		tree.CountGreater("two", badKey)
+		tree.CDF("two", badKey)
+		tree.Floor("two", badKey)
+		tree.Ceiling("two", badKey)
+		tree.Range("one", "two", badKey, visit)
+		tree.Prefix("t", badKey, visit)
+		tree.Fold(nil, func(acc, owner interface{}) interface{} { panic("fold") })
+		tree.ReduceRange("one", nil, badKey, nil, nil)
+	})
+	assert.Len(t, errs, 13)
+	assert.Equal(t, 3, tree.Len())
+
+	// The methods that return an error return the sink's too
+
+	errs = nil
+	assert.ErrorIs(t, tree.Validate(badNode), ErrPanic)
+	n := &intNode{key: 4}
+	assert.ErrorIs(t, tree.ApplyBatch([]AvlMutation{{Op: AvlOpInsert,
+		Node: &n.avlHeader, Owner: n}}, badNode), ErrPanic)
+	var other AvlTree
+	assert.ErrorIs(t, AvlTreeMove(tree, &other, &n.avlHeader, badNode),
+		ErrNotInTree)
+	other.Insert(&n.avlHeader, n, cmpIntNode)
+	assert.ErrorIs(t, AvlTreeMove(tree, &other, &n.avlHeader, badNode), ErrPanic)
+	assert.Len(t, errs, 3)
+	assert.Equal(t, 3, tree.Len())
+
+	// TryInsert does not report a panic as a successful insertion
+
+	m := &intNode{key: 5}
+	existing, err := tree.TryInsert(&m.avlHeader, m, badNode)
+	assert.Nil(t, existing)
+	assert.ErrorIs(t, err, ErrPanic)
+	assert.Len(t, errs, 4)
+	assert.Equal(t, 3, tree.Len())
+
+	tree.Watch(func(op AvlOp, owner interface{}) {
+		panic("observer")
+	})
+	assert.ErrorIs(t, tree.TryRemove(&ns[0].avlHeader), ErrPanic)
+	assert.NotPanics(t, func() { tree.PopMax() })
+	assert.Len(t, errs, 6)
+	assert.Equal(t, 1, tree.Len())
+}
+
+func TestAvlTreeErrorSinkParallel(t *testing.T) {
+
+	var mu sync.Mutex
+	var errs []error
+	tree := NewAvlTree(WithErrorSink(func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}))
+
+	ns := newIntNodes(make([]int, 5000)...)
+	for i, n := range ns {
+		n.key = i
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	// A panic on a worker goroutine comes back to the caller's
+
+	fn := func(owner interface{}) {
+		if owner.(*intNode).key == 2500 {
+			panic("worker")
+		}
+	}
+
+	assert.ErrorIs(t, tree.ForEachParallel(4, fn), ErrPanic)
+	assert.ErrorIs(t, tree.RangeParallelContext(context.Background(), nil, nil,
+		cmpIntKey, 4, fn), ErrPanic)
+	assert.Len(t, errs, 2)
+	assert.Contains(t, errs[0].Error(), "worker")
+
+	// Without a sink it panics there
+
+	plain := NewAvlTree()
+	for _, n := range newIntNodes(1, 2500) {
+		plain.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+	assert.Panics(t, func() { plain.ForEachParallel(2, fn) })
+}
@@ -0,0 +1,359 @@
+//
+// Copyright as per Creative Commons Legal Code license, which can
+// be found in the file COPYING
+//
+
+/*
+
+Package semver orders semantic versions (https://semver.org) by their
+precedence rather than lexically, so that 1.10.0 follows 1.9.0 and
+1.0.0-rc.1 precedes 1.0.0, and keeps a set of them in an AVL tree from
+the avl package for the queries a package registry makes:
+
+	var releases semver.Set[string]
+	releases.Add(semver.MustParse("1.9.0"), "sha-a")
+	releases.Add(semver.MustParse("1.10.0"), "sha-b")
+	v, _, ok := releases.Floor(semver.MustParse("1.9.9"))    // 1.9.0
+	v, _, ok = releases.LatestIn(semver.MustParse("1.0.0"),
+		semver.MustParse("2.0.0"))                         // 1.10.0
+
+Build metadata is kept but, as the specification says, ignored when
+ordering.  A Set is not safe for concurrent use.
+
+*/
+
+package semver
+
+import (
+	"errors"
+	"fmt"
+	"github.com/danswartzendruber/avl"
+	"iter"
+	"strconv"
+	"strings"
+)
+
+// The string is not a semantic version
+var ErrSyntax = errors.New("semver: invalid version")
+
+// A semantic version: MAJOR.MINOR.PATCH[-PRERELEASE][+BUILD]
+
+type Version struct {
+	Major, Minor, Patch uint64
+	Pre                 []string // Dot-separated prerelease identifiers
+	Build               string
+}
+
+// Parses s, with or without a leading "v"
+
+func Parse(s string) (Version, error) {
+
+	var v Version
+
+	bad := func() (Version, error) {
+		return Version{}, fmt.Errorf("%w: %q", ErrSyntax, s)
+	}
+
+	rest := strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		v.Build = rest[i+1:]
+		rest = rest[:i]
+		if !validIdents(v.Build, false) {
+			return bad()
+		}
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		pre := rest[i+1:]
+		rest = rest[:i]
+		if !validIdents(pre, true) {
+			return bad()
+		}
+		v.Pre = strings.Split(pre, ".")
+	}
+
+	parts := strings.Split(rest, ".")
+	if len(parts) != 3 {
+		return bad()
+	}
+	nums := []*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		if !isNumeric(p) || (len(p) > 1 && p[0] == '0') {
+			return bad()
+		}
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return bad()
+		}
+		*nums[i] = n
+	}
+
+	return v, nil
+}
+
+// Like Parse, but panics if s is not a version
+
+func MustParse(s string) Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// True if s is dot-separated, non-empty identifiers of [0-9A-Za-z-],
+// where numeric prerelease identifiers have no leading zeroes
+
+func validIdents(s string, pre bool) bool {
+
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		for i := 0; i < len(id); i++ {
+			c := id[i]
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' ||
+				c >= 'A' && c <= 'Z' || c == '-') {
+				return false
+			}
+		}
+		if pre && isNumeric(id) && len(id) > 1 && id[0] == '0' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (v Version) String() string {
+
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Pre) > 0 {
+		s += "-" + strings.Join(v.Pre, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+
+	return s
+}
+
+// True if v has prerelease identifiers
+
+func (v Version) IsPrerelease() bool {
+	return len(v.Pre) > 0
+}
+
+func cmpUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Compares a and b by precedence, returning <0, 0 or >0.  Build metadata
+// is ignored
+
+func Compare(a, b Version) int {
+
+	if c := cmpUint(a.Major, b.Major); c != 0 {
+		return c
+	}
+	if c := cmpUint(a.Minor, b.Minor); c != 0 {
+		return c
+	}
+	if c := cmpUint(a.Patch, b.Patch); c != 0 {
+		return c
+	}
+
+	// A release follows its prereleases
+
+	switch {
+	case len(a.Pre) == 0 && len(b.Pre) == 0:
+		return 0
+	case len(a.Pre) == 0:
+		return 1
+	case len(b.Pre) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(a.Pre) && i < len(b.Pre); i++ {
+		x, y := a.Pre[i], b.Pre[i]
+		xn, yn := isNumeric(x), isNumeric(y)
+		switch {
+		case xn && yn:
+			if len(x) != len(y) {
+				return len(x) - len(y)
+			}
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+		case xn:
+			return -1
+		case yn:
+			return 1
+		default:
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return len(a.Pre) - len(b.Pre)
+}
+
+// Returns comparators for trees whose owners hold a Version, which ver
+// extracts: key compares a Version with an owner, and node two owners
+
+func CmpFuncs(ver func(owner interface{}) Version) (key avl.CmpFuncKey,
+	node avl.CmpFuncNode) {
+
+	key = func(k interface{}, owner interface{}) int {
+		return Compare(k.(Version), ver(owner))
+	}
+	node = func(a interface{}, b interface{}) int {
+		return Compare(ver(a), ver(b))
+	}
+
+	return key, node
+}
+
+type release[V any] struct {
+	header avl.AvlNode
+	ver    Version
+	val    V
+}
+
+// A set of versions, each with a value.  The zero value is an empty set
+// ready to use
+
+type Set[V any] struct {
+	tree avl.AvlTree
+}
+
+func cmpKey[V any](key interface{}, owner interface{}) int {
+	return Compare(key.(Version), owner.(*release[V]).ver)
+}
+
+func cmpNode[V any](a interface{}, b interface{}) int {
+	return Compare(a.(*release[V]).ver, b.(*release[V]).ver)
+}
+
+// A key ordering just before its version, so that Floor finds the
+// greatest version strictly below it
+type below Version
+
+func cmpBelow[V any](key interface{}, owner interface{}) int {
+	if Compare(Version(key.(below)), owner.(*release[V]).ver) <= 0 {
+		return -1
+	}
+	return 1
+}
+
+// Returns the number of versions
+
+func (s *Set[V]) Len() int {
+	return s.tree.Len()
+}
+
+// Adds ver with value val, replacing a version of equal precedence.
+// Returns true if one was replaced
+
+func (s *Set[V]) Add(ver Version, val V) bool {
+	r := &release[V]{ver: ver, val: val}
+	return s.tree.InsertOrReplace(&r.header, r, cmpNode[V]) != nil
+}
+
+// Returns the value of ver, or false if it is not in the set
+
+func (s *Set[V]) Get(ver Version) (V, bool) {
+	if r, ok := s.tree.Lookup(ver, cmpKey[V]).(*release[V]); ok {
+		return r.val, true
+	}
+	var val V
+	return val, false
+}
+
+// Removes ver.  Returns false if it was not in the set
+
+func (s *Set[V]) Remove(ver Version) bool {
+	r, ok := s.tree.Lookup(ver, cmpKey[V]).(*release[V])
+	if ok {
+		s.tree.Remove(&r.header)
+	}
+	return ok
+}
+
+func found[V any](owner interface{}) (Version, V, bool) {
+	if r, ok := owner.(*release[V]); ok {
+		return r.ver, r.val, true
+	}
+	var val V
+	return Version{}, val, false
+}
+
+// Returns the greatest version, or false if the set is empty
+
+func (s *Set[V]) Latest() (Version, V, bool) {
+	return found[V](s.tree.Last())
+}
+
+// Returns the greatest version <= max, or false if there is none
+
+func (s *Set[V]) Floor(max Version) (Version, V, bool) {
+	return found[V](s.tree.Floor(max, cmpKey[V]))
+}
+
+// Returns the greatest version < max, or false if there is none
+
+func (s *Set[V]) Below(max Version) (Version, V, bool) {
+	return found[V](s.tree.Floor(below(max), cmpBelow[V]))
+}
+
+// Returns the greatest version in [lo, hi), or false if there is none.
+// ^1.2.3 is [1.2.3, 2.0.0), and ~1.2.3 is [1.2.3, 1.3.0)
+
+func (s *Set[V]) LatestIn(lo, hi Version) (Version, V, bool) {
+	ver, val, ok := s.Below(hi)
+	if ok && Compare(ver, lo) >= 0 {
+		return ver, val, true
+	}
+	var zero V
+	return Version{}, zero, false
+}
+
+// Yields the versions in [lo, hi), in order, with their values.  The set
+// must not be modified while the sequence is being iterated
+
+func (s *Set[V]) Range(lo, hi Version) iter.Seq2[Version, V] {
+	return s.scan(lo, hi)
+}
+
+// Yields every version, in order, with its value
+
+func (s *Set[V]) All() iter.Seq2[Version, V] {
+	return s.scan(nil, nil)
+}
+
+func (s *Set[V]) scan(lo, hi interface{}) iter.Seq2[Version, V] {
+	return func(yield func(Version, V) bool) {
+		s.tree.Range(lo, hi, cmpKey[V], func(owner interface{}) bool {
+			r := owner.(*release[V])
+			return yield(r.ver, r.val)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package semver
+
+import (
+	"github.com/danswartzendruber/avl"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+
+	v, err := Parse("v1.2.3-rc.1+build.5")
+	assert.NoError(t, err)
+	assert.Equal(t, Version{1, 2, 3, []string{"rc", "1"}, "build.5"}, v)
+	assert.Equal(t, "1.2.3-rc.1+build.5", v.String())
+	assert.True(t, v.IsPrerelease())
+
+	for _, s := range []string{"", "1.2", "1.2.3.4", "01.2.3", "1.2.x",
+		"1.2.3-", "1.2.3-a..b", "1.2.3-01", "1.2.3+", "1.2.3-a_b"} {
+		_, err := Parse(s)
+		assert.ErrorIs(t, err, ErrSyntax, s)
+	}
+}
+
+func TestCompare(t *testing.T) {
+
+	// In increasing order, from the specification's examples
+
+	order := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta",
+		"1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1",
+		"1.0.0", "1.9.0", "1.10.0", "2.0.0"}
+
+	for i := range order {
+		for j := range order {
+			c := Compare(MustParse(order[i]), MustParse(order[j]))
+			switch {
+			case i < j:
+				assert.True(t, c < 0, order[i]+" < "+order[j])
+			case i > j:
+				assert.True(t, c > 0, order[i]+" > "+order[j])
+			default:
+				assert.Equal(t, 0, c)
+			}
+		}
+	}
+
+	assert.Equal(t, 0, Compare(MustParse("1.0.0+a"), MustParse("1.0.0+b")))
+}
+
+func TestCmpFuncs(t *testing.T) {
+
+	type pkg struct {
+		header avl.AvlNode
+		ver    Version
+	}
+
+	key, node := CmpFuncs(func(owner interface{}) Version {
+		return owner.(*pkg).ver
+	})
+
+	var tree avl.AvlTree
+	for _, s := range []string{"1.10.0", "1.9.0", "1.2.0"} {
+		p := &pkg{ver: MustParse(s)}
+		tree.Insert(&p.header, p, node)
+	}
+
+	assert.Equal(t, "1.10.0", tree.Last().(*pkg).ver.String())
+	assert.Equal(t, "1.9.0", tree.Floor(MustParse("1.9.5"), key).(*pkg).ver.String())
+}
+
+func TestSet(t *testing.T) {
+
+	var set Set[int]
+
+	_, _, ok := set.Latest()
+	assert.False(t, ok)
+
+	for i, s := range []string{"1.9.0", "1.10.0", "2.0.0-rc.1", "2.0.0",
+		"1.2.3", "0.9.0"} {
+		assert.False(t, set.Add(MustParse(s), i))
+	}
+	assert.True(t, set.Add(MustParse("1.2.3+rebuild"), 99))
+	assert.Equal(t, 6, set.Len())
+
+	val, ok := set.Get(MustParse("1.2.3"))
+	assert.True(t, ok)
+	assert.Equal(t, 99, val)
+
+	ver, _, _ := set.Latest()
+	assert.Equal(t, "2.0.0", ver.String())
+
+	ver, _, _ = set.Floor(MustParse("1.9.9"))
+	assert.Equal(t, "1.9.0", ver.String())
+	ver, _, _ = set.Floor(MustParse("1.10.0"))
+	assert.Equal(t, "1.10.0", ver.String())
+	ver, _, _ = set.Below(MustParse("2.0.0"))
+	assert.Equal(t, "2.0.0-rc.1", ver.String())
+	_, _, ok = set.Below(MustParse("0.9.0"))
+	assert.False(t, ok)
+
+	// ^1.2.0 and ~1.9.0
+
+	ver, val, ok = set.LatestIn(MustParse("1.2.0"), MustParse("2.0.0-0"))
+	assert.True(t, ok)
+	assert.Equal(t, "1.10.0", ver.String())
+	assert.Equal(t, 1, val)
+	ver, _, _ = set.LatestIn(MustParse("1.9.0"), MustParse("1.10.0-0"))
+	assert.Equal(t, "1.9.0", ver.String())
+	_, _, ok = set.LatestIn(MustParse("3.0.0"), MustParse("4.0.0"))
+	assert.False(t, ok)
+
+	var vers []string
+	for v := range set.Range(MustParse("1.0.0"), MustParse("2.0.0")) {
+		vers = append(vers, v.String())
+	}
+	assert.Equal(t, []string{"1.2.3+rebuild", "1.9.0", "1.10.0", "2.0.0-rc.1"}, vers)
+
+	assert.True(t, set.Remove(MustParse("2.0.0")))
+	assert.False(t, set.Remove(MustParse("2.0.0")))
+
+	vers = nil
+	for v := range set.All() {
+		vers = append(vers, v.String())
+	}
+	assert.Equal(t, []string{"0.9.0", "1.2.3+rebuild", "1.9.0", "1.10.0", "2.0.0-rc.1"}, vers)
+}
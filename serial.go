@@ -0,0 +1,524 @@
+package avl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+//
+// Serialized trees.  A dump records the shape of a tree and the key of
+// each node, encoded by the caller as a JSON value, so a dump can be
+// looked at (see cmd/avlviz) without the code that made it, and the
+// tree can be rebuilt in exactly the same shape.  There are two formats
+// with the same content:
+//
+// JSON
+//	{"format": "avl-tree", "version": 1,
+//	 "root": {"key": 5, "left": {"key": 2}, "right": {"key": 8}}}
+//
+// Binary
+//	"AVLD", a version byte (1), a byte that is 1 if the tree is
+//	non-empty, then the nodes in pre-order, each as a flags byte
+//	(1 = has a left child, 2 = has a right child), the uvarint length
+//	of the key and the key.
+//
+// Each function has an XxxContext variant that gives up, returning
+// ctx.Err(), once ctx is done.
+//
+
+// One node of a dumped tree, with its subtrees
+
+type AvlDump struct {
+	Key   json.RawMessage `json:"key"`
+	Left  *AvlDump        `json:"left,omitempty"`
+	Right *AvlDump        `json:"right,omitempty"`
+}
+
+type avlDumpFile struct {
+	Format  string   `json:"format"`
+	Version int      `json:"version"`
+	Root    *AvlDump `json:"root"`
+}
+
+const (
+	avlDumpFormat  = "avl-tree"
+	avlDumpVersion = 1
+	avlDumpMagic   = "AVLD"
+
+	avlDumpHasLeft  = 1
+	avlDumpHasRight = 2
+
+	avlDumpMaxKey = 1 << 24
+)
+
+// Dumps the tree rooted at root, encoding the key of each owner as JSON
+// with encodeKey.  Returns nil for an empty tree
+
+func AvlTreeDump(root *AvlNode,
+	encodeKey func(owner interface{}) ([]byte, error)) (*AvlDump, error) {
+
+	return AvlTreeDumpContext(context.Background(), root, encodeKey)
+}
+
+// Like AvlTreeDump, but gives up and returns ctx.Err() once ctx is done
+
+func AvlTreeDumpContext(ctx context.Context, root *AvlNode,
+	encodeKey func(owner interface{}) ([]byte, error)) (*AvlDump, error) {
+
+	return avlTreeDump(&avlContextTicker{ctx: ctx}, root, encodeKey)
+}
+
+func avlTreeDump(t *avlContextTicker, root *AvlNode,
+	encodeKey func(owner interface{}) ([]byte, error)) (*AvlDump, error) {
+
+	if root == nil {
+		return nil, nil
+	}
+	if err := t.tick(); err != nil {
+		return nil, err
+	}
+
+	key, err := avlDumpKey(root.owner, encodeKey)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &AvlDump{Key: key}
+
+	if d.Left, err = avlTreeDump(t, root.left, encodeKey); err != nil {
+		return nil, err
+	}
+	if d.Right, err = avlTreeDump(t, root.right, encodeKey); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Encodes the key of owner with encodeKey, and checks that it is JSON
+
+func avlDumpKey(owner interface{},
+	encodeKey func(owner interface{}) ([]byte, error)) ([]byte, error) {
+
+	key, err := encodeKey(owner)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(key) {
+		return nil, fmt.Errorf("avl: key of %v is not valid JSON", owner)
+	}
+
+	return key, nil
+}
+
+// Rebuilds a dumped tree in the same shape and returns its root.
+// decodeKey returns the header and owner for a key.  Returns an error
+// wrapping ErrInvalidTree if the dump is not of a valid AVL tree
+
+func AvlTreeFromDump(d *AvlDump,
+	decodeKey func(key []byte) (*AvlNode, interface{}, error)) (*AvlNode, error) {
+
+	return AvlTreeFromDumpContext(context.Background(), d, decodeKey)
+}
+
+// Like AvlTreeFromDump, but gives up and returns ctx.Err() once ctx is
+// done.  The nodes decoded by then are not linked
+
+func AvlTreeFromDumpContext(ctx context.Context, d *AvlDump,
+	decodeKey func(key []byte) (*AvlNode, interface{}, error)) (*AvlNode, error) {
+
+	shape, err := avlDumpShape(&avlContextTicker{ctx: ctx}, d, decodeKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return AvlTreeFromShape(shape)
+}
+
+func avlDumpShape(t *avlContextTicker, d *AvlDump,
+	decodeKey func(key []byte) (*AvlNode, interface{}, error)) (*AvlShape, error) {
+
+	if d == nil {
+		return nil, nil
+	}
+	if err := t.tick(); err != nil {
+		return nil, err
+	}
+
+	node, owner, err := decodeKey(d.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &AvlShape{Node: node, Owner: owner}
+
+	if s.Left, err = avlDumpShape(t, d.Left, decodeKey); err != nil {
+		return nil, err
+	}
+	if s.Right, err = avlDumpShape(t, d.Right, decodeKey); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Writes d in the JSON format
+
+func AvlDumpWriteJSON(w io.Writer, d *AvlDump) error {
+	return AvlDumpWriteJSONContext(context.Background(), w, d)
+}
+
+// Like AvlDumpWriteJSON, but gives up and returns ctx.Err() once ctx is
+// done.  Nothing is written by then
+
+func AvlDumpWriteJSONContext(ctx context.Context, w io.Writer,
+	d *AvlDump) error {
+
+	// Written compact, a node at a time, then indented as
+	// json.Encoder.SetIndent("", "  ") would
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `{"format":%q,"version":%d,"root":`, avlDumpFormat,
+		avlDumpVersion)
+	if d == nil {
+		buf.WriteString("null")
+	} else if err := avlDumpWriteJSONNode(&avlContextTicker{ctx: ctx}, &buf, d); err != nil {
+		return err
+	}
+	buf.WriteByte('}')
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return fmt.Errorf("avl: dump is not valid JSON: %w", err)
+	}
+	out.WriteByte('\n')
+
+	_, err := out.WriteTo(w)
+
+	return err
+}
+
+func avlDumpWriteJSONNode(t *avlContextTicker, buf *bytes.Buffer,
+	d *AvlDump) error {
+
+	if err := t.tick(); err != nil {
+		return err
+	}
+
+	buf.WriteString(`{"key":`)
+	if len(d.Key) == 0 {
+		buf.WriteString("null")
+	} else {
+		json.HTMLEscape(buf, d.Key)
+	}
+
+	if d.Left != nil {
+		buf.WriteString(`,"left":`)
+		if err := avlDumpWriteJSONNode(t, buf, d.Left); err != nil {
+			return err
+		}
+	}
+	if d.Right != nil {
+		buf.WriteString(`,"right":`)
+		if err := avlDumpWriteJSONNode(t, buf, d.Right); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+
+	return nil
+}
+
+// Writes d in the binary format
+
+func AvlDumpWriteBinary(w io.Writer, d *AvlDump) error {
+	return AvlDumpWriteBinaryContext(context.Background(), w, d)
+}
+
+// Like AvlDumpWriteBinary, but gives up and returns ctx.Err() once ctx is
+// done.  Part of the dump may have been written by then
+
+func AvlDumpWriteBinaryContext(ctx context.Context, w io.Writer,
+	d *AvlDump) error {
+
+	bw := bufio.NewWriter(w)
+
+	bw.WriteString(avlDumpMagic)
+	bw.WriteByte(avlDumpVersion)
+
+	if d == nil {
+		bw.WriteByte(0)
+	} else {
+		bw.WriteByte(1)
+		if err := avlDumpWriteNode(&avlContextTicker{ctx: ctx}, bw, d); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+func avlDumpWriteNode(t *avlContextTicker, bw *bufio.Writer, d *AvlDump) error {
+
+	if err := t.tick(); err != nil {
+		return err
+	}
+
+	var flags byte
+	if d.Left != nil {
+		flags |= avlDumpHasLeft
+	}
+	if d.Right != nil {
+		flags |= avlDumpHasRight
+	}
+	bw.WriteByte(flags)
+
+	var n [binary.MaxVarintLen64]byte
+	bw.Write(n[:binary.PutUvarint(n[:], uint64(len(d.Key)))])
+	bw.Write(d.Key)
+
+	if d.Left != nil {
+		if err := avlDumpWriteNode(t, bw, d.Left); err != nil {
+			return err
+		}
+	}
+	if d.Right != nil {
+		if err := avlDumpWriteNode(t, bw, d.Right); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Reads a dump in either format
+
+func AvlDumpRead(r io.Reader) (*AvlDump, error) {
+	return AvlDumpReadContext(context.Background(), r)
+}
+
+// Like AvlDumpRead, but gives up and returns ctx.Err() once ctx is done
+
+func AvlDumpReadContext(ctx context.Context, r io.Reader) (*AvlDump, error) {
+
+	br := bufio.NewReader(r)
+	t := &avlContextTicker{ctx: ctx}
+
+	magic, err := br.Peek(len(avlDumpMagic))
+	if err == nil && string(magic) == avlDumpMagic {
+		return avlDumpReadBinary(t, br)
+	}
+
+	return avlDumpReadJSON(t, json.NewDecoder(br))
+}
+
+// Reads the JSON format a token at a time, so that the context can be
+// checked as the nodes are read.  Unknown fields are skipped, as
+// json.Unmarshal would
+
+func avlDumpReadJSON(t *avlContextTicker, dec *json.Decoder) (*AvlDump, error) {
+
+	var f avlDumpFile
+	var raw json.RawMessage
+
+	err := avlDumpReadJSONObject(dec, func(name string) error {
+		var err error
+		switch name {
+		case "format":
+			err = dec.Decode(&f.Format)
+		case "version":
+			err = dec.Decode(&f.Version)
+		case "root":
+			f.Root, err = avlDumpReadJSONNode(t, dec, 0)
+		default:
+			err = dec.Decode(&raw)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, avlDumpReadError(err)
+	}
+	if f.Format != avlDumpFormat || f.Version != avlDumpVersion {
+		return nil, fmt.Errorf("%w: format %q version %d", ErrBadDump,
+			f.Format, f.Version)
+	}
+
+	return f.Root, nil
+}
+
+func avlDumpReadJSONNode(t *avlContextTicker, dec *json.Decoder,
+	depth int) (*AvlDump, error) {
+
+	if depth >= avlDumpMaxDepth {
+		return nil, fmt.Errorf("%w: too deep", ErrBadDump)
+	}
+	if err := t.tick(); err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	d := &AvlDump{}
+
+	err := avlDumpReadJSONObject(dec, func(name string) error {
+		var err error
+		switch name {
+		case "key":
+			err = dec.Decode(&d.Key)
+		case "left":
+			d.Left, err = avlDumpReadJSONNode(t, dec, depth+1)
+		case "right":
+			d.Right, err = avlDumpReadJSONNode(t, dec, depth+1)
+		default:
+			err = dec.Decode(&raw)
+		}
+		return err
+	})
+	switch {
+	case err == errAvlDumpNull:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Returned by avlDumpReadJSONObject for a null in place of an object
+
+var errAvlDumpNull = fmt.Errorf("%w: null", ErrBadDump)
+
+// Reads an object, or null, calling field with the name of each field
+// with the decoder at its value, which field must read
+
+func avlDumpReadJSONObject(dec *json.Decoder, field func(name string) error) error {
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return errAvlDumpNull
+	}
+	if tok != json.Delim('{') {
+		return fmt.Errorf("%w: unexpected %v", ErrBadDump, tok)
+	}
+
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if err := field(tok.(string)); err != nil {
+			return err
+		}
+	}
+
+	_, err = dec.Token()
+
+	return err
+}
+
+// Wraps an error reading a JSON dump in ErrBadDump, if it is not one
+// already or a context error
+
+func avlDumpReadError(err error) error {
+	if errors.Is(err, ErrBadDump) || errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrBadDump, err)
+}
+
+func avlDumpReadBinary(t *avlContextTicker, br *bufio.Reader) (*AvlDump, error) {
+
+	var hdr [len(avlDumpMagic) + 2]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadDump, err)
+	}
+	if hdr[len(avlDumpMagic)] != avlDumpVersion {
+		return nil, fmt.Errorf("%w: version %d", ErrBadDump,
+			hdr[len(avlDumpMagic)])
+	}
+
+	switch hdr[len(avlDumpMagic)+1] {
+	case 0:
+		return nil, nil
+	case 1:
+		return avlDumpReadNode(t, br, 0)
+	default:
+		return nil, ErrBadDump
+	}
+}
+
+// No AVL tree that fits in memory comes close to this height
+
+const avlDumpMaxDepth = 128
+
+func avlDumpReadNode(t *avlContextTicker, br *bufio.Reader,
+	depth int) (*AvlDump, error) {
+
+	if depth >= avlDumpMaxDepth {
+		return nil, fmt.Errorf("%w: too deep", ErrBadDump)
+	}
+	if err := t.tick(); err != nil {
+		return nil, err
+	}
+
+	flags, err := br.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadDump, io.ErrUnexpectedEOF)
+	}
+	if flags&^(avlDumpHasLeft|avlDumpHasRight) != 0 {
+		return nil, fmt.Errorf("%w: bad flags %#x", ErrBadDump, flags)
+	}
+
+	n, err := binary.ReadUvarint(br)
+	if err != nil || n > avlDumpMaxKey {
+		return nil, fmt.Errorf("%w: bad key length", ErrBadDump)
+	}
+
+	var key bytes.Buffer
+	if _, err := io.CopyN(&key, br, int64(n)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadDump, io.ErrUnexpectedEOF)
+	}
+
+	d := &AvlDump{Key: key.Bytes()}
+
+	if flags&avlDumpHasLeft != 0 {
+		if d.Left, err = avlDumpReadNode(t, br, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	if flags&avlDumpHasRight != 0 {
+		if d.Right, err = avlDumpReadNode(t, br, depth+1); err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+// Key encoder for trees of AvlItems, for AvlTreeDump
+
+func AvlItemEncodeKey(owner interface{}) ([]byte, error) {
+	return json.Marshal(owner.(*AvlItem).Key)
+}
+
+// Key decoder for trees of AvlItems, for AvlTreeFromDump.  JSON numbers
+// come back as float64
+
+func AvlItemDecodeKey(key []byte) (*AvlNode, interface{}, error) {
+
+	it := &AvlItem{}
+	if err := json.Unmarshal(key, &it.Key); err != nil {
+		return nil, nil, err
+	}
+
+	return &it.Header, it, nil
+}
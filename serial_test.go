@@ -0,0 +1,134 @@
+package avl
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAvlTreeDump(t *testing.T) {
+
+	tree := Build().Keys(5, 2, 8, 1, 9, 3).Tree()
+
+	d, err := AvlTreeDump(tree.Root(), AvlItemEncodeKey)
+	assert.NoError(t, err)
+
+	for _, write := range []func(*bytes.Buffer, *AvlDump) error{
+		func(b *bytes.Buffer, d *AvlDump) error { return AvlDumpWriteJSON(b, d) },
+		func(b *bytes.Buffer, d *AvlDump) error { return AvlDumpWriteBinary(b, d) },
+	} {
+		var buf bytes.Buffer
+		assert.NoError(t, write(&buf, d))
+
+		got, err := AvlDumpRead(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, d, got)
+
+		root, err := AvlTreeFromDump(got, AvlItemDecodeKey)
+		assert.NoError(t, err)
+		assert.NoError(t, AvlTreeValidate(root, AvlItemCmpNode))
+		assert.Equal(t, 6, root.SubtreeSize())
+
+		// Same shape: the same key at the root and the same depths
+
+		assert.Equal(t, 5.0, root.Owner().(*AvlItem).Key)
+		assert.Equal(t, tree.Root().left.Depth(), root.left.Depth())
+	}
+
+	// Empty trees
+
+	for _, write := range []func(*bytes.Buffer) error{
+		func(b *bytes.Buffer) error { return AvlDumpWriteJSON(b, nil) },
+		func(b *bytes.Buffer) error { return AvlDumpWriteBinary(b, nil) },
+	} {
+		var buf bytes.Buffer
+		assert.NoError(t, write(&buf))
+		got, err := AvlDumpRead(&buf)
+		assert.NoError(t, err)
+		assert.Nil(t, got)
+	}
+}
+
+func TestAvlDumpReadBad(t *testing.T) {
+
+	for _, in := range []string{
+		"",
+		"AVLD",
+		"AVLD\x02\x00",
+		"AVLD\x01\x01\x04",
+		"AVLD\x01\x01\x01\x01",
+		"AVLD\x01\x01\x00\x05ab",
+		`{"format": "other", "version": 1}`,
+		`{"format": "avl-tree", "version": 2}`,
+	} {
+		_, err := AvlDumpRead(bytes.NewReader([]byte(in)))
+		assert.ErrorIs(t, err, ErrBadDump, in)
+	}
+
+	// A dump that is not an AVL tree
+
+	d := &AvlDump{Key: []byte("1"), Left: &AvlDump{Key: []byte("0"),
+		Left: &AvlDump{Key: []byte("-1")}}}
+	_, err := AvlTreeFromDump(d, AvlItemDecodeKey)
+	assert.ErrorIs(t, err, ErrInvalidTree)
+
+	_, err = AvlTreeDump(Build().Keys(1).Tree().Root(), func(interface{}) ([]byte, error) {
+		return []byte("not json"), nil
+	})
+	assert.Error(t, err)
+}
+
+func TestAvlDumpReadJSONFields(t *testing.T) {
+
+	// Unknown fields are skipped and null children are absent
+
+	in := `{"version": 1, "extra": [1, {"a": 2}], "format": "avl-tree",
+		"root": {"key": 2, "left": {"key": 1, "note": "x"}, "right": null}}`
+	d, err := AvlDumpRead(bytes.NewReader([]byte(in)))
+	assert.NoError(t, err)
+	assert.Equal(t, &AvlDump{Key: []byte("2"), Left: &AvlDump{Key: []byte("1")}}, d)
+
+	for _, in := range []string{
+		`null`,
+		`[]`,
+		`{"format": "avl-tree", "version": 1, "root": 5}`,
+		`{"format": "avl-tree", "version": 1, "root": {"key": 1`,
+	} {
+		_, err := AvlDumpRead(bytes.NewReader([]byte(in)))
+		assert.ErrorIs(t, err, ErrBadDump, in)
+	}
+}
+
+func TestAvlTreeDumpContext(t *testing.T) {
+
+	keys := make([]interface{}, 3000)
+	for i := range keys {
+		keys[i] = i
+	}
+	root := Build().Keys(keys...).Tree().Root()
+
+	d, err := AvlTreeDump(root, AvlItemEncodeKey)
+	assert.NoError(t, err)
+	var js, bin bytes.Buffer
+	assert.NoError(t, AvlDumpWriteJSON(&js, d))
+	assert.NoError(t, AvlDumpWriteBinary(&bin, d))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = AvlTreeDumpContext(ctx, root, AvlItemEncodeKey)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = AvlTreeFromDumpContext(ctx, d, AvlItemDecodeKey)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	var out bytes.Buffer
+	assert.ErrorIs(t, AvlDumpWriteJSONContext(ctx, &out, d), context.Canceled)
+	assert.Equal(t, 0, out.Len())
+	assert.ErrorIs(t, AvlDumpWriteBinaryContext(ctx, &out, d), context.Canceled)
+
+	for _, in := range []*bytes.Buffer{&js, &bin} {
+		_, err = AvlDumpReadContext(ctx, in)
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+}
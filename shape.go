@@ -0,0 +1,98 @@
+package avl
+
+import "fmt"
+
+//
+// Building a tree of an exact shape, bypassing insertion.  This is for
+// tests that need to start from a particular configuration (say, the
+// one that makes a deletion do a double rotation) without working out
+// an insertion order that happens to produce it.
+//
+
+// One node of a shape specification.  Node is the header to link and
+// Owner the value to store in it; Left and Right are the subtrees, nil
+// where there is no child
+
+type AvlShape struct {
+	Node  *AvlNode
+	Owner interface{}
+	Left  *AvlShape
+	Right *AvlShape
+}
+
+// Links the nodes of shape into a tree of exactly that shape and returns
+// its root.  Balance factors are computed from the subtree heights, which
+// determine them uniquely, and subtree sizes are counted.  Returns an error wrapping ErrInvalidTree if
+// the shape is not a valid AVL tree (some node would have a balance
+// factor outside [-1, +1]) or a node appears in it more than once.
+// Ordering is not checked; pass the result to AvlTreeValidate for that
+
+func AvlTreeFromShape(shape *AvlShape) (*AvlNode, error) {
+
+	if shape == nil {
+		return nil, nil
+	}
+
+	seen := make(map[*AvlNode]bool)
+
+	if _, err := avlLinkShape(shape, nil, seen); err != nil {
+		return nil, err
+	}
+
+	return shape.Node, nil
+}
+
+// Links the subtree described by shape under parent and returns its
+// height
+
+func avlLinkShape(shape, parent *AvlShape, seen map[*AvlNode]bool) (int, error) {
+
+	if shape == nil {
+		return 0, nil
+	}
+
+	n := shape.Node
+	if n == nil {
+		return 0, ErrNilNode
+	}
+	if seen[n] {
+		return 0, fmt.Errorf("%w: %v appears twice in shape",
+			ErrInvalidTree, shape.Owner)
+	}
+	seen[n] = true
+
+	lh, err := avlLinkShape(shape.Left, shape, seen)
+	if err != nil {
+		return 0, err
+	}
+	rh, err := avlLinkShape(shape.Right, shape, seen)
+	if err != nil {
+		return 0, err
+	}
+
+	if rh-lh < -1 || rh-lh > 1 {
+		return 0, fmt.Errorf("%w: %v would have balance factor %d",
+			ErrInvalidTree, shape.Owner, rh-lh)
+	}
+
+	var p *AvlNode
+	if parent != nil {
+		p = parent.Node
+	}
+	avlSetParentBalance(n, p, rh-lh)
+	n.owner = shape.Owner
+	n.left = nil
+	n.right = nil
+	if shape.Left != nil {
+		n.left = shape.Left.Node
+	}
+	if shape.Right != nil {
+		n.right = shape.Right.Node
+	}
+	avlUpdateSize(n)
+
+	if lh > rh {
+		return lh + 1, nil
+	}
+	return rh + 1, nil
+}
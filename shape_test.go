@@ -0,0 +1,79 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func leaf(n *intNode) *AvlShape {
+	return &AvlShape{Node: &n.avlHeader, Owner: n}
+}
+
+func inner(n *intNode, left, right *AvlShape) *AvlShape {
+	return &AvlShape{Node: &n.avlHeader, Owner: n, Left: left, Right: right}
+}
+
+func TestAvlTreeFromShape(t *testing.T) {
+
+	ns := newIntNodes(0, 1, 2, 3, 4, 5, 6)
+
+	//
+	//          1
+	//        /   \
+	//       0     4
+	//            / \
+	//           3   5
+	//          /
+	//         2
+	//
+	// is not AVL: 1 would be right-heavy by 2
+	//
+
+	_, err := AvlTreeFromShape(inner(ns[1], leaf(ns[0]),
+		inner(ns[4], inner(ns[3], leaf(ns[2]), nil), leaf(ns[5]))))
+	assert.ErrorIs(t, err, ErrInvalidTree)
+
+	//
+	//          1
+	//        /   \
+	//       0     4
+	//            / \
+	//           3   5
+	//
+	// Removing 0 leaves 1 right-heavy by 2 with a perfectly balanced
+	// right child: the balance == 0 case of avlHandleSubtreeShrink
+	//
+
+	r, err := AvlTreeFromShape(inner(ns[1], leaf(ns[0]),
+		inner(ns[4], leaf(ns[3]), leaf(ns[5]))))
+	assert.NoError(t, err)
+	assert.Equal(t, &ns[1].avlHeader, r)
+	assert.Equal(t, 1, AvlGetBalanceFactor(r))
+	assert.NoError(t, AvlTreeValidate(r, cmpIntNode))
+
+	AvlTreeRemove(&r, &ns[0].avlHeader)
+	assert.Equal(t, &ns[4].avlHeader, r)
+	assert.NoError(t, AvlTreeValidate(r, cmpIntNode))
+
+	//
+	//          2
+	//        /   \
+	//       0     3
+	//        \
+	//         1
+	//
+	// Removing 3 makes 2 left-heavy by 2 with a right-heavy left child:
+	// the double rotation on delete
+	//
+
+	r, err = AvlTreeFromShape(inner(ns[2], inner(ns[0], nil, leaf(ns[1])),
+		leaf(ns[3])))
+	assert.NoError(t, err)
+
+	AvlTreeRemove(&r, &ns[3].avlHeader)
+	assert.Equal(t, &ns[1].avlHeader, r)
+	assert.NoError(t, AvlTreeValidate(r, cmpIntNode))
+
+	_, err = AvlTreeFromShape(inner(ns[1], leaf(ns[0]), leaf(ns[0])))
+	assert.ErrorIs(t, err, ErrInvalidTree)
+}
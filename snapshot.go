@@ -0,0 +1,173 @@
+package avl
+
+import "context"
+
+//
+// Snapshots.  A snapshot records which owners a tree held, in order, at
+// one point in time, by copying a pointer per node: O(n), but cheap
+// enough to take under the lock that writers hold, after which it can be
+// serialized at leisure while writers carry on.  Restoring a snapshot
+// links the same nodes back into the tree, so the owners must still
+// exist and their keys must not have changed.
+//
+
+type AvlSnapshot struct {
+	owners []interface{}
+	nodes  []*AvlNode
+}
+
+// Captures the owners now in the tree
+
+func (tree *AvlTree) Snapshot() *AvlSnapshot {
+	s, _ := tree.SnapshotContext(context.Background())
+	return s
+}
+
+// Like Snapshot, but gives up and returns ctx.Err() once ctx is done
+
+func (tree *AvlTree) SnapshotContext(ctx context.Context) (*AvlSnapshot, error) {
+
+	s := &AvlSnapshot{
+		owners: make([]interface{}, 0, tree.size),
+		nodes:  make([]*AvlNode, 0, tree.size),
+	}
+
+	var err error
+
+	tree.labeled(ctx, "snapshot", func(ctx context.Context) {
+		t := &avlContextTicker{ctx: ctx}
+		for n := tree.first; n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+			if err = t.tick(); err != nil {
+				return
+			}
+			s.owners = append(s.owners, n.owner)
+			s.nodes = append(s.nodes, n)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Returns the number of owners in the snapshot
+
+func (s *AvlSnapshot) Len() int {
+	return len(s.owners)
+}
+
+// Replaces the contents of the tree with the owners in the snapshot, in
+// a perfectly balanced tree.  Nodes in the tree but not in the snapshot
+// are marked unlinked.  Observers are not notified.  O(n)
+
+func (tree *AvlTree) RestoreFrom(s *AvlSnapshot) {
+	tree.RestoreFromContext(context.Background(), s)
+}
+
+// Like RestoreFrom, but gives up and returns ctx.Err() once ctx is done.
+// The tree then holds the owners it held before, though not in the same
+// shape, which takes another O(n) to put back
+
+func (tree *AvlTree) RestoreFromContext(ctx context.Context,
+	s *AvlSnapshot) (err error) {
+
+	defer tree.recoverToErr(&err)
+
+	tree.labeled(ctx, "restore", func(ctx context.Context) {
+		var old []*AvlNode
+		var owners []interface{}
+		for n := tree.first; n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+			old = append(old, n)
+			owners = append(owners, n.owner)
+		}
+		for _, n := range old {
+			n.SetUnlinked()
+		}
+
+		var root *AvlNode
+		root, err = AvlTreeBuildSortedContext(ctx, len(s.nodes),
+			func(i int) (*AvlNode, interface{}) {
+				return s.nodes[i], s.owners[i]
+			})
+		if err != nil {
+			root = AvlTreeBuildSorted(len(old),
+				func(i int) (*AvlNode, interface{}) {
+					return old[i], owners[i]
+				})
+		}
+
+		tree.reset(root)
+	})
+	if err != nil {
+		return err
+	}
+
+	tree.check(AvlOpInsert)
+
+	return nil
+}
+
+// Dumps the snapshot for serialization, in the shape RestoreFrom would
+// give it; see AvlTreeDump
+
+func (s *AvlSnapshot) Dump(encodeKey func(owner interface{}) ([]byte, error)) (*AvlDump, error) {
+	return s.DumpContext(context.Background(), encodeKey)
+}
+
+// Like Dump, but gives up and returns ctx.Err() once ctx is done
+
+func (s *AvlSnapshot) DumpContext(ctx context.Context,
+	encodeKey func(owner interface{}) ([]byte, error)) (*AvlDump, error) {
+
+	return s.dumpRange(&avlContextTicker{ctx: ctx}, 0, len(s.owners), encodeKey)
+}
+
+func (s *AvlSnapshot) dumpRange(t *avlContextTicker, lo, hi int,
+	encodeKey func(owner interface{}) ([]byte, error)) (*AvlDump, error) {
+
+	if lo >= hi {
+		return nil, nil
+	}
+	if err := t.tick(); err != nil {
+		return nil, err
+	}
+
+	mid := lo + (hi-lo)/2
+
+	key, err := avlDumpKey(s.owners[mid], encodeKey)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &AvlDump{Key: key}
+
+	if d.Left, err = s.dumpRange(t, lo, mid, encodeKey); err != nil {
+		return nil, err
+	}
+	if d.Right, err = s.dumpRange(t, mid+1, hi, encodeKey); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Makes a snapshot of the owners in a dump, created by decodeKey; see
+// AvlTreeFromDump
+
+func AvlSnapshotFromDump(d *AvlDump,
+	decodeKey func(key []byte) (*AvlNode, interface{}, error)) (*AvlSnapshot, error) {
+
+	root, err := AvlTreeFromDump(d, decodeKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &AvlSnapshot{}
+	for n := avlTreeFirstOrLastInOrder(root, -1); n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+		s.owners = append(s.owners, n.owner)
+		s.nodes = append(s.nodes, n)
+	}
+
+	return s, nil
+}
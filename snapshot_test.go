@@ -0,0 +1,104 @@
+package avl
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAvlTreeSnapshot(t *testing.T) {
+
+	var tree AvlTree
+
+	ns := newIntNodes(1, 2, 3, 4, 5)
+	for _, n := range ns[:4] {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	snap := tree.Snapshot()
+	assert.Equal(t, 4, snap.Len())
+
+	// Writers carry on after the snapshot
+
+	tree.Remove(&ns[1].avlHeader)
+	tree.Insert(&ns[4].avlHeader, ns[4], cmpIntNode)
+
+	tree.RestoreFrom(snap)
+	assert.Equal(t, 4, tree.Len())
+	assert.Equal(t, []int{1, 2, 3, 4}, inOrderKeys(tree.Root()))
+	assert.Equal(t, ns[0], tree.First())
+	assert.Equal(t, ns[3], tree.Last())
+	assert.True(t, ns[4].avlHeader.IsUnlinked())
+	assert.False(t, ns[1].avlHeader.IsUnlinked())
+	assert.NoError(t, tree.Validate(cmpIntNode))
+}
+
+func TestAvlSnapshotDump(t *testing.T) {
+
+	tree := Build().Keys("d", "a", "c", "b", "e").Tree()
+
+	d, err := tree.Snapshot().Dump(AvlItemEncodeKey)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, AvlDumpWriteBinary(&buf, d))
+
+	d2, err := AvlDumpRead(&buf)
+	assert.NoError(t, err)
+
+	snap, err := AvlSnapshotFromDump(d2, AvlItemDecodeKey)
+	assert.NoError(t, err)
+
+	restored := NewAvlTree()
+	restored.RestoreFrom(snap)
+	assert.Equal(t, []interface{}{"a", "b", "c", "d", "e"}, itemKeys(restored))
+	assert.Equal(t, "e", restored.Last().(*AvlItem).Key)
+	assert.NoError(t, restored.Validate(AvlItemCmpNode))
+
+	// Keys that are not JSON are refused, as by AvlTreeDump
+
+	_, err = tree.Snapshot().Dump(func(interface{}) ([]byte, error) {
+		return []byte("not json"), nil
+	})
+	assert.Error(t, err)
+}
+
+func TestAvlTreeSnapshotContext(t *testing.T) {
+
+	var tree AvlTree
+
+	ns := newIntNodes(make([]int, 3000)...)
+	for i, n := range ns {
+		n.key = i
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	snap, err := tree.SnapshotContext(context.Background())
+	assert.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		tree.PopMax()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = tree.SnapshotContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = snap.DumpContext(ctx, func(owner interface{}) ([]byte, error) {
+		return []byte("0"), nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// A cancelled restore leaves the tree holding what it held
+
+	assert.ErrorIs(t, tree.RestoreFromContext(ctx, snap), context.Canceled)
+	assert.Equal(t, 2000, tree.Len())
+	assert.Equal(t, ns[1999], tree.Last())
+	assert.False(t, avlTreeContains(tree.Root(), &ns[2500].avlHeader))
+	assert.NoError(t, tree.Validate(cmpIntNode))
+
+	assert.NoError(t, tree.RestoreFromContext(context.Background(), snap))
+	assert.Equal(t, 3000, tree.Len())
+	assert.NoError(t, tree.Validate(cmpIntNode))
+}
@@ -0,0 +1,261 @@
+//
+// Copyright as per Creative Commons Legal Code license, which can
+// be found in the file COPYING
+//
+
+/*
+
+Package stats keeps a running multiset of samples on an order-statistic
+AVL tree from the avl package, so that adding or removing a sample and
+reading the median or any other quantile are all O(log n):
+
+	s := stats.New()
+	s.Insert(12.5)
+	s.Insert(3)
+	s.Insert(7)
+	median, _ := s.Median() // 7
+
+With WithWindow only the most recent samples are kept, the oldest being
+evicted as new ones come in; with WithMaxAge only those inserted within
+the last so long.  The two may be combined.  Ages are measured by the
+clock given with WithClock, time.Now by default, and expired samples are
+evicted by every method, so a read sees only the current window even if
+nothing has been inserted for a while.  Samples are evicted in the order
+they were inserted; one removed by Remove is simply skipped.
+
+A Window aggregates timestamped values over the last span of time,
+answering their count, sum, mean, minimum, maximum and quantiles, for
+rate and latency monitoring:
+
+	w := stats.NewWindow(time.Minute)
+	w.Add(time.Now(), latency.Seconds())
+	p99, _ := w.Quantile(0.99)
+
+Neither a Samples nor a Window is safe for concurrent use.
+
+*/
+
+package stats
+
+import (
+	"cmp"
+	"github.com/danswartzendruber/avl"
+	"math"
+	"time"
+)
+
+type sample struct {
+	header  avl.AvlNode
+	value   float64
+	seq     uint64 // Insertion order, telling equal values apart
+	at      time.Time
+	removed bool
+}
+
+type Samples struct {
+	tree   avl.AvlTree
+	seq    uint64
+	window int
+	maxAge time.Duration
+	now    func() time.Time
+
+	// The samples in insertion order, for eviction, from head on
+
+	queue []*sample
+	head  int
+}
+
+// Options for New
+
+type Option func(s *Samples)
+
+// Keeps only the n most recently inserted samples
+
+func WithWindow(n int) Option {
+	return func(s *Samples) {
+		s.window = n
+	}
+}
+
+// Keeps only the samples inserted within the last d
+
+func WithMaxAge(d time.Duration) Option {
+	return func(s *Samples) {
+		s.maxAge = d
+	}
+}
+
+// Measures the ages of samples by now rather than time.Now
+
+func WithClock(now func() time.Time) Option {
+	return func(s *Samples) {
+		s.now = now
+	}
+}
+
+// Returns an empty set of samples
+
+func New(opts ...Option) *Samples {
+
+	s := &Samples{now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Orders samples by value, NaN first, and equal values by insertion
+
+func cmpSamples(a, b interface{}) int {
+
+	sa := a.(*sample)
+	sb := b.(*sample)
+
+	if c := cmp.Compare(sa.value, sb.value); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(sa.seq, sb.seq)
+}
+
+// Compares a value with a sample, ignoring the insertion order
+
+func cmpValue(key, node interface{}) int {
+	return cmp.Compare(key.(float64), node.(*sample).value)
+}
+
+// True if the samples are windowed, and so need the queue
+
+func (s *Samples) windowed() bool {
+	return s.window > 0 || s.maxAge > 0
+}
+
+// Evicts the samples that have fallen out of the window
+
+func (s *Samples) evict() {
+
+	if !s.windowed() {
+		return
+	}
+
+	var cutoff time.Time
+	if s.maxAge > 0 {
+		cutoff = s.now().Add(-s.maxAge)
+	}
+
+	for s.head < len(s.queue) {
+		oldest := s.queue[s.head]
+		switch {
+		case oldest.removed:
+		case s.window > 0 && s.tree.Len() > s.window:
+			s.tree.Remove(&oldest.header)
+		case s.maxAge > 0 && !oldest.at.After(cutoff):
+			s.tree.Remove(&oldest.header)
+		default:
+			return
+		}
+		s.queue[s.head] = nil
+		s.head++
+
+		// Reuse the front of the queue once it is half empty
+
+		if s.head > len(s.queue)/2 {
+			s.queue = s.queue[:copy(s.queue, s.queue[s.head:])]
+			s.head = 0
+		}
+	}
+}
+
+// Adds a sample
+
+func (s *Samples) Insert(value float64) {
+
+	s.seq++
+	x := &sample{value: value, seq: s.seq}
+	s.tree.Insert(&x.header, x, cmpSamples)
+
+	if s.windowed() {
+		x.at = s.now()
+		s.queue = append(s.queue, x)
+	}
+	s.evict()
+}
+
+// Removes a sample equal to value.  Returns false if there was none
+
+func (s *Samples) Remove(value float64) bool {
+
+	s.evict()
+
+	x, _ := s.tree.Lookup(value, cmpValue).(*sample)
+	if x == nil {
+		return false
+	}
+
+	s.tree.Remove(&x.header)
+	x.removed = true
+
+	return true
+}
+
+// Returns the number of samples
+
+func (s *Samples) Len() int {
+	s.evict()
+	return s.tree.Len()
+}
+
+// Returns the value at in-order index i, which is in range
+
+func (s *Samples) at(i int) float64 {
+	return s.tree.Select(i).(*sample).value
+}
+
+// Returns the q-quantile of the samples, q being clamped to [0, 1],
+// interpolating linearly between the two samples nearest to it as most
+// statistics packages do by default.  False if there are no samples
+
+func (s *Samples) Quantile(q float64) (float64, bool) {
+	s.evict()
+	return quantile(s.tree.Len(), s.at, q)
+}
+
+// Returns the q-quantile of n sorted values, of which at returns the
+// i'th, interpolating linearly between neighbours
+
+func quantile(n int, at func(i int) float64, q float64) (float64, bool) {
+
+	if n == 0 || math.IsNaN(q) {
+		return 0, false
+	}
+
+	h := float64(n-1) * min(max(q, 0), 1)
+	lo := int(h)
+	if lo == n-1 {
+		return at(lo), true
+	}
+
+	a, b := at(lo), at(lo+1)
+
+	return a + (h-float64(lo))*(b-a), true
+}
+
+// Returns the median of the samples, the mean of the middle two if there
+// is an even number of them.  False if there are no samples
+
+func (s *Samples) Median() (float64, bool) {
+	return s.Quantile(0.5)
+}
+
+// Returns the least sample, or false if there are none
+
+func (s *Samples) Min() (float64, bool) {
+	return s.Quantile(0)
+}
+
+// Returns the greatest sample, or false if there are none
+
+func (s *Samples) Max() (float64, bool) {
+	return s.Quantile(1)
+}
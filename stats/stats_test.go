@@ -0,0 +1,115 @@
+package stats
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSamples(t *testing.T) {
+
+	s := New()
+
+	_, ok := s.Median()
+	assert.False(t, ok)
+
+	for _, v := range []float64{12.5, 3, 7} {
+		s.Insert(v)
+	}
+	m, ok := s.Median()
+	assert.True(t, ok)
+	assert.Equal(t, 7.0, m)
+
+	s.Insert(9)
+	m, _ = s.Median()
+	assert.Equal(t, 8.0, m)
+
+	q, _ := s.Quantile(0.25)
+	assert.Equal(t, 6.0, q)
+	lo, _ := s.Min()
+	hi, _ := s.Max()
+	assert.Equal(t, 3.0, lo)
+	assert.Equal(t, 12.5, hi)
+
+	// Duplicates are kept, and removed one at a time
+
+	s.Insert(7)
+	assert.Equal(t, 5, s.Len())
+	assert.True(t, s.Remove(7))
+	assert.True(t, s.Remove(7))
+	assert.False(t, s.Remove(7))
+	assert.Equal(t, 3, s.Len())
+}
+
+func TestSamplesAgainstSort(t *testing.T) {
+
+	rnd := rand.New(rand.NewSource(1227))
+	s := New()
+	var vals []float64
+
+	for i := 0; i < 500; i++ {
+		v := float64(rnd.Intn(100))
+		s.Insert(v)
+		vals = append(vals, v)
+	}
+	sort.Float64s(vals)
+
+	for _, q := range []float64{0, 0.1, 0.5, 0.9, 0.99, 1} {
+		h := float64(len(vals)-1) * q
+		i := int(h)
+		want := vals[i]
+		if i+1 < len(vals) {
+			want += (h - float64(i)) * (vals[i+1] - vals[i])
+		}
+		got, _ := s.Quantile(q)
+		assert.InDelta(t, want, got, 1e-9, q)
+	}
+}
+
+func TestSamplesWindow(t *testing.T) {
+
+	s := New(WithWindow(3))
+	for _, v := range []float64{1, 2, 3, 4, 5} {
+		s.Insert(v)
+	}
+	assert.Equal(t, 3, s.Len())
+	m, _ := s.Median()
+	assert.Equal(t, 4.0, m)
+
+	// A removed sample leaves room, and is skipped when its turn comes
+
+	assert.True(t, s.Remove(4))
+	s.Insert(6)
+	lo, _ := s.Min()
+	assert.Equal(t, 3.0, lo)
+	s.Insert(7)
+	lo, _ = s.Min()
+	assert.Equal(t, 5.0, lo)
+	assert.Equal(t, 3, s.Len())
+}
+
+func TestSamplesMaxAge(t *testing.T) {
+
+	now := time.Unix(0, 0)
+	s := New(WithMaxAge(10*time.Second), WithClock(func() time.Time { return now }))
+
+	for i := 0; i < 20; i++ {
+		s.Insert(float64(i))
+		now = now.Add(time.Second)
+	}
+
+	// It is now 20s, so those inserted at 11s to 19s are left
+
+	assert.Equal(t, 9, s.Len())
+	lo, _ := s.Min()
+	assert.Equal(t, 11.0, lo)
+
+	// Reads expire samples too
+
+	now = now.Add(time.Hour)
+	_, ok := s.Median()
+	assert.False(t, ok)
+	assert.Equal(t, 0, s.Len())
+}
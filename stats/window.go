@@ -0,0 +1,161 @@
+package stats
+
+import (
+	"cmp"
+	"github.com/danswartzendruber/avl"
+	"time"
+)
+
+//
+// Sliding-window aggregates.  A Window holds the values added with a
+// timestamp within the last span of time, and answers the count, sum,
+// mean, minimum, maximum and quantiles of those.  Each value is linked
+// into two trees: one ordered by time, from which the oldest are
+// evicted, and one ordered by value, whose subtree sizes give the
+// quantiles in O(log n).  The sum is kept as a running total, updated as
+// values come and go.
+//
+// The window ends at the latest time seen, by Add or Advance, and
+// values may be added out of time order; one already older than the
+// window is dropped.
+//
+
+type windowEntry struct {
+	byTime  avl.AvlNode
+	byValue avl.AvlNode
+	t       time.Time
+	v       float64
+	seq     uint64 // Order of adding, telling equal entries apart
+}
+
+type Window struct {
+	byTime  avl.AvlTree
+	byValue avl.AvlTree
+	span    time.Duration
+	end     time.Time
+	sum     float64
+	seq     uint64
+}
+
+// Returns an empty window holding the values of the last span of time
+
+func NewWindow(span time.Duration) *Window {
+	return &Window{span: span}
+}
+
+func cmpByTime(a, b interface{}) int {
+
+	ea := a.(*windowEntry)
+	eb := b.(*windowEntry)
+
+	if c := ea.t.Compare(eb.t); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(ea.seq, eb.seq)
+}
+
+func cmpByValue(a, b interface{}) int {
+
+	ea := a.(*windowEntry)
+	eb := b.(*windowEntry)
+
+	if c := cmp.Compare(ea.v, eb.v); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(ea.seq, eb.seq)
+}
+
+// True if a value at t is older than the window
+
+func (w *Window) expired(t time.Time) bool {
+	return !t.After(w.end.Add(-w.span))
+}
+
+// Adds v at time t, moving the end of the window up to t if it is later
+
+func (w *Window) Add(t time.Time, v float64) {
+
+	w.Advance(t)
+	if w.expired(t) {
+		return
+	}
+
+	w.seq++
+	e := &windowEntry{t: t, v: v, seq: w.seq}
+	w.byTime.Insert(&e.byTime, e, cmpByTime)
+	w.byValue.Insert(&e.byValue, e, cmpByValue)
+	w.sum += v
+}
+
+// Moves the end of the window up to now, if it is later, evicting the
+// values that fall out of it.  Call it before reading the aggregates if
+// values may have expired since the last Add
+
+func (w *Window) Advance(now time.Time) {
+
+	if now.After(w.end) {
+		w.end = now
+	}
+
+	for {
+		e, _ := w.byTime.First().(*windowEntry)
+		if e == nil || !w.expired(e.t) {
+			break
+		}
+		w.byTime.Remove(&e.byTime)
+		w.byValue.Remove(&e.byValue)
+		w.sum -= e.v
+	}
+
+	// Start afresh when empty, rather than carry rounding errors
+
+	if w.byTime.Len() == 0 {
+		w.sum = 0
+	}
+}
+
+// Returns the number of values in the window
+
+func (w *Window) Count() int {
+	return w.byValue.Len()
+}
+
+// Returns the sum of the values in the window
+
+func (w *Window) Sum() float64 {
+	return w.sum
+}
+
+// Returns the mean of the values in the window, or false if it is empty
+
+func (w *Window) Mean() (float64, bool) {
+
+	if n := w.byValue.Len(); n > 0 {
+		return w.sum / float64(n), true
+	}
+
+	return 0, false
+}
+
+// Returns the least value in the window, or false if it is empty
+
+func (w *Window) Min() (float64, bool) {
+	return w.Quantile(0)
+}
+
+// Returns the greatest value in the window, or false if it is empty
+
+func (w *Window) Max() (float64, bool) {
+	return w.Quantile(1)
+}
+
+// Returns the q-quantile of the values in the window, as
+// Samples.Quantile does, or false if it is empty
+
+func (w *Window) Quantile(q float64) (float64, bool) {
+	return quantile(w.byValue.Len(), func(i int) float64 {
+		return w.byValue.Select(i).(*windowEntry).v
+	}, q)
+}
@@ -0,0 +1,101 @@
+package stats
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestWindow(t *testing.T) {
+
+	t0 := time.Unix(1000, 0)
+	at := func(s int) time.Time { return t0.Add(time.Duration(s) * time.Second) }
+
+	w := NewWindow(10 * time.Second)
+	_, ok := w.Mean()
+	assert.False(t, ok)
+
+	for s, v := range []float64{5, 1, 9, 3} {
+		w.Add(at(s), v)
+	}
+	assert.Equal(t, 4, w.Count())
+	assert.Equal(t, 18.0, w.Sum())
+	mean, _ := w.Mean()
+	assert.Equal(t, 4.5, mean)
+	lo, _ := w.Min()
+	hi, _ := w.Max()
+	assert.Equal(t, 1.0, lo)
+	assert.Equal(t, 9.0, hi)
+	med, _ := w.Quantile(0.5)
+	assert.Equal(t, 4.0, med)
+
+	// Out of order, and too old
+
+	w.Add(at(2), 7)
+	assert.Equal(t, 5, w.Count())
+	w.Advance(at(11))
+	assert.Equal(t, 3, w.Count())
+	assert.Equal(t, 19.0, w.Sum())
+	w.Add(at(0), 100)
+	assert.Equal(t, 3, w.Count())
+
+	// Everything expires
+
+	w.Advance(at(100))
+	assert.Equal(t, 0, w.Count())
+	assert.Equal(t, 0.0, w.Sum())
+	_, ok = w.Max()
+	assert.False(t, ok)
+}
+
+func TestWindowAgainstScan(t *testing.T) {
+
+	rnd := rand.New(rand.NewSource(1228))
+	t0 := time.Unix(0, 0)
+	w := NewWindow(time.Minute)
+
+	type point struct {
+		t time.Time
+		v float64
+	}
+	var points []point
+
+	now := t0
+	for i := 0; i < 2000; i++ {
+		now = now.Add(time.Duration(rnd.Intn(500)) * time.Millisecond)
+		p := point{now.Add(-time.Duration(rnd.Intn(5)) * time.Second), float64(rnd.Intn(1000))}
+		points = append(points, p)
+		w.Add(p.t, p.v)
+	}
+
+	// The window ends at the latest time added
+
+	var end time.Time
+	for _, p := range points {
+		if p.t.After(end) {
+			end = p.t
+		}
+	}
+
+	var vals []float64
+	var sum float64
+	for _, p := range points {
+		if p.t.After(end.Add(-time.Minute)) {
+			vals = append(vals, p.v)
+			sum += p.v
+		}
+	}
+	sort.Float64s(vals)
+
+	assert.Equal(t, len(vals), w.Count())
+	assert.InDelta(t, sum, w.Sum(), 1e-6)
+	lo, _ := w.Min()
+	hi, _ := w.Max()
+	assert.Equal(t, vals[0], lo)
+	assert.Equal(t, vals[len(vals)-1], hi)
+	med, _ := w.Quantile(0.5)
+	want, _ := quantile(len(vals), func(i int) float64 { return vals[i] }, 0.5)
+	assert.Equal(t, want, med)
+}
@@ -0,0 +1,234 @@
+package avl
+
+import (
+	"fmt"
+	"iter"
+	"reflect"
+	"strings"
+)
+
+//
+// Trees declared by struct tags.  A struct type to be kept in a tree
+// embeds AvlNode and tags its key fields:
+//
+//	type Job struct {
+//		avl.AvlNode
+//		Priority int    `avl:"key,desc"`
+//		Name     string `avl:"key"`
+//	}
+//
+//	jobs := avl.NewTreeFor[Job]()
+//	jobs.Insert(&Job{Priority: 2, Name: "build"})
+//	job := jobs.Lookup(2, "build")
+//
+// NewTreeFor finds the header and the key fields by reflection once, and
+// builds the comparison functions from them.  The key fields are compared
+// in the order they are declared, ascending unless tagged desc, as
+// CmpByField compares them; they may be unexported, but the header must
+// be embedded, or an exported field of type AvlNode, so that the tree
+// can take its address.
+//
+
+type AvlTreeFor[T any] struct {
+	tree   AvlTree
+	header []int
+	keys   []avlTaggedKey
+	cmp    CmpFuncNode
+	cmpKey CmpFuncKey
+}
+
+type avlTaggedKey struct {
+	index []int
+	sign  int
+	cmp   func(a, b reflect.Value) int
+}
+
+// Returns an empty tree of T, which must be a struct type with a header
+// and at least one field tagged `avl:"key"`.  Panics if it is not
+
+func NewTreeFor[T any](opts ...AvlTreeOption) *AvlTreeFor[T] {
+
+	header, keys, err := avlTaggedFields(reflect.TypeFor[T]())
+	if err != nil {
+		panic(err)
+	}
+
+	t := &AvlTreeFor[T]{header: header, keys: keys}
+	t.cmp = func(node1 interface{}, node2 interface{}) int {
+		a := reflect.ValueOf(node1).Elem()
+		b := reflect.ValueOf(node2).Elem()
+		for _, k := range t.keys {
+			if res := k.cmp(a.FieldByIndex(k.index), b.FieldByIndex(k.index)); res != 0 {
+				return k.sign * res
+			}
+		}
+		return 0
+	}
+	t.cmpKey = func(key interface{}, node interface{}) int {
+		values := key.([]reflect.Value)
+		n := reflect.ValueOf(node).Elem()
+		for i, k := range t.keys[:len(values)] {
+			if res := k.cmp(values[i], n.FieldByIndex(k.index)); res != 0 {
+				return k.sign * res
+			}
+		}
+		return 0
+	}
+
+	for _, opt := range opts {
+		opt(&t.tree)
+	}
+
+	return t
+}
+
+// Finds the header and the key fields of the struct type typ
+
+func avlTaggedFields(typ reflect.Type) ([]int, []avlTaggedKey, error) {
+
+	if typ.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("avl: %v is not a struct", typ)
+	}
+
+	var header []int
+	var keys []avlTaggedKey
+	nodeType := reflect.TypeFor[AvlNode]()
+
+	for _, f := range reflect.VisibleFields(typ) {
+
+		if f.Type == nodeType && f.IsExported() {
+			if header != nil {
+				return nil, nil, fmt.Errorf("avl: %v has more than one AvlNode", typ)
+			}
+			header = f.Index
+		}
+
+		tag, ok := f.Tag.Lookup("avl")
+		if !ok {
+			continue
+		}
+		name, order, _ := strings.Cut(tag, ",")
+		k := avlTaggedKey{index: f.Index, sign: 1}
+		switch {
+		case name != "key":
+			return nil, nil, fmt.Errorf("avl: %v.%s: unknown tag %q", typ, f.Name, tag)
+		case order == "desc":
+			k.sign = -1
+		case order != "" && order != "asc":
+			return nil, nil, fmt.Errorf("avl: %v.%s: unknown order %q", typ, f.Name, order)
+		}
+		cmp, err := avlFieldCmp(f.Type)
+		if err != nil {
+			return nil, nil, fmt.Errorf("avl: %v.%s: %w", typ, f.Name, err)
+		}
+		k.cmp = cmp
+		keys = append(keys, k)
+	}
+
+	switch {
+	case header == nil:
+		return nil, nil, fmt.Errorf("avl: %v does not embed AvlNode", typ)
+	case len(keys) == 0:
+		return nil, nil, fmt.Errorf("avl: %v has no field tagged `avl:\"key\"`", typ)
+	}
+
+	return header, keys, nil
+}
+
+// Returns item's header
+
+func (t *AvlTreeFor[T]) node(item *T) *AvlNode {
+	return reflect.ValueOf(item).Elem().FieldByIndex(t.header).Addr().Interface().(*AvlNode)
+}
+
+// Returns the owner o, or nil
+
+func avlOwnerOf[T any](o interface{}) *T {
+	if o == nil {
+		return nil
+	}
+	return o.(*T)
+}
+
+// Returns the underlying tree, for the rest of the AvlTree methods.
+// Insert into it only with Cmp
+
+func (t *AvlTreeFor[T]) Tree() *AvlTree {
+	return &t.tree
+}
+
+// Returns the comparison function the tags describe
+
+func (t *AvlTreeFor[T]) Cmp() CmpFuncNode {
+	return t.cmp
+}
+
+// Returns the comparison function the tags describe, for keys given as
+// to Lookup
+
+func (t *AvlTreeFor[T]) CmpKey() CmpFuncKey {
+	return t.cmpKey
+}
+
+// Returns the number of items
+
+func (t *AvlTreeFor[T]) Len() int {
+	return t.tree.Len()
+}
+
+// Inserts item.  Returns the item already present with the same key, in
+// which case item is not inserted, or nil
+
+func (t *AvlTreeFor[T]) Insert(item *T) *T {
+	return avlOwnerOf[T](t.tree.Insert(t.node(item), item, t.cmp))
+}
+
+// Removes item, which must be in the tree
+
+func (t *AvlTreeFor[T]) Remove(item *T) {
+	t.tree.Remove(t.node(item))
+}
+
+// Returns the item whose key fields equal keys, given in the order the
+// fields are declared, or nil.  Given fewer keys than key fields, it
+// returns some item whose leading key fields equal them.  Each key must
+// be of the same kind as its field, such as an int for an int64 field
+
+func (t *AvlTreeFor[T]) Lookup(keys ...interface{}) *T {
+
+	if len(keys) > len(t.keys) {
+		panic(fmt.Sprintf("avl: %d keys given for %d key fields", len(keys), len(t.keys)))
+	}
+
+	values := make([]reflect.Value, len(keys))
+	for i, k := range keys {
+		values[i] = reflect.ValueOf(k)
+	}
+
+	return avlOwnerOf[T](t.tree.Lookup(values, t.cmpKey))
+}
+
+// Returns the least item, or nil if the tree is empty
+
+func (t *AvlTreeFor[T]) First() *T {
+	return avlOwnerOf[T](t.tree.First())
+}
+
+// Returns the greatest item, or nil if the tree is empty
+
+func (t *AvlTreeFor[T]) Last() *T {
+	return avlOwnerOf[T](t.tree.Last())
+}
+
+// Yields the items in order.  The tree must not be modified while the
+// sequence is being iterated
+
+func (t *AvlTreeFor[T]) All() iter.Seq[*T] {
+	return func(yield func(*T) bool) {
+		for n := t.tree.first; n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+			if !yield(n.owner.(*T)) {
+				return
+			}
+		}
+	}
+}
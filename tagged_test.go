@@ -0,0 +1,88 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type taggedJob struct {
+	AvlNode
+	priority int64  `avl:"key,desc"`
+	Name     string `avl:"key"`
+	Note     string
+}
+
+func TestNewTreeFor(t *testing.T) {
+
+	jobs := NewTreeFor[taggedJob]()
+
+	for _, j := range []*taggedJob{
+		{priority: 1, Name: "lint"},
+		{priority: 3, Name: "deploy"},
+		{priority: 2, Name: "test"},
+		{priority: 2, Name: "build"},
+	} {
+		assert.Nil(t, jobs.Insert(j))
+	}
+	dup := &taggedJob{priority: 2, Name: "test"}
+	assert.NotNil(t, jobs.Insert(dup))
+	assert.Equal(t, 4, jobs.Len())
+
+	var got []string
+	for j := range jobs.All() {
+		got = append(got, j.Name)
+	}
+	assert.Equal(t, []string{"deploy", "build", "test", "lint"}, got)
+	assert.Equal(t, "deploy", jobs.First().Name)
+	assert.Equal(t, "lint", jobs.Last().Name)
+
+	// Keys of the same kind as their fields
+
+	assert.Equal(t, "test", jobs.Lookup(2, "test").Name)
+	assert.Nil(t, jobs.Lookup(2, "deploy"))
+	assert.Equal(t, int64(3), jobs.Lookup(3).priority)
+	assert.Panics(t, func() { jobs.Lookup(1, "lint", "extra") })
+
+	jobs.Remove(jobs.Lookup(2, "build"))
+	assert.Nil(t, jobs.Lookup(2, "build"))
+	assert.NoError(t, jobs.Tree().Validate(jobs.Cmp()))
+
+	empty := NewTreeFor[taggedJob]()
+	assert.Nil(t, empty.First())
+	assert.Nil(t, empty.Lookup(1, "x"))
+}
+
+func TestNewTreeForErrors(t *testing.T) {
+
+	type noHeader struct {
+		K int `avl:"key"`
+	}
+	type hiddenHeader struct {
+		node AvlNode
+		K    int `avl:"key"`
+	}
+	type noKey struct {
+		AvlNode
+		K int
+	}
+	type badTag struct {
+		AvlNode
+		K int `avl:"index"`
+	}
+	type badOrder struct {
+		AvlNode
+		K int `avl:"key,up"`
+	}
+	type badKind struct {
+		AvlNode
+		K []int `avl:"key"`
+	}
+
+	assert.Panics(t, func() { NewTreeFor[int]() })
+	assert.Panics(t, func() { NewTreeFor[noHeader]() })
+	assert.Panics(t, func() { NewTreeFor[hiddenHeader]() })
+	assert.Panics(t, func() { NewTreeFor[noKey]() })
+	assert.Panics(t, func() { NewTreeFor[badTag]() })
+	assert.Panics(t, func() { NewTreeFor[badOrder]() })
+	assert.Panics(t, func() { NewTreeFor[badKind]() })
+}
@@ -0,0 +1,263 @@
+package avl
+
+import (
+	"fmt"
+	"iter"
+)
+
+//
+// Threaded trees.  Each AvlThreadedNode also sits on a doubly linked
+// list of the nodes in key order, kept up to date by insertion and
+// removal, so stepping to the next or previous node is one pointer
+// rather than a climb through the parents; a full scan touches each
+// node once and never goes back up.  The threads cost two pointers per
+// node and a little work in Insert, which finds the new node's
+// neighbours on its way down, and Remove, which splices it out.
+//
+// The tree is otherwise an ordinary AVL tree, and its comparison
+// functions see the owners as usual.
+//
+
+type AvlThreadedNode struct {
+	node       AvlNode
+	next, prev *AvlThreadedNode
+	owner      interface{}
+}
+
+// A tree of AvlThreadedNodes.  The zero value is an empty tree ready to
+// use
+
+type AvlThreadedTree struct {
+	root        *AvlNode
+	size        int
+	first, last *AvlThreadedNode
+	rotations   uint64
+}
+
+func avlThreaded(node *AvlNode) *AvlThreadedNode {
+	return node.owner.(*AvlThreadedNode)
+}
+
+// Returns the node after n in key order, or nil
+
+func (n *AvlThreadedNode) Next() *AvlThreadedNode {
+	return n.next
+}
+
+// Returns the node before n in key order, or nil
+
+func (n *AvlThreadedNode) Prev() *AvlThreadedNode {
+	return n.prev
+}
+
+// Returns the owner stored in the node when it was inserted
+
+func (n *AvlThreadedNode) Owner() interface{} {
+	return n.owner
+}
+
+// Returns the number of nodes
+
+func (tree *AvlThreadedTree) Len() int {
+	return tree.size
+}
+
+// Returns the first node in key order, or nil if the tree is empty
+
+func (tree *AvlThreadedTree) First() *AvlThreadedNode {
+	return tree.first
+}
+
+// Returns the last node in key order, or nil if the tree is empty
+
+func (tree *AvlThreadedTree) Last() *AvlThreadedNode {
+	return tree.last
+}
+
+// Look up a specified key.  nil if not present
+
+func (tree *AvlThreadedTree) Lookup(key interface{}, cmp CmpFuncKey) interface{} {
+
+	for cur := tree.root; cur != nil; {
+		n := avlThreaded(cur)
+		res := cmp(key, n.owner)
+		if res < 0 {
+			cur = cur.left
+		} else if res > 0 {
+			cur = cur.right
+		} else {
+			return n.owner
+		}
+	}
+
+	return nil
+}
+
+// Returns the first node whose owner orders at or after key, or nil if
+// there is none.  Scan onwards from it with Next
+
+func (tree *AvlThreadedTree) Seek(key interface{}, cmp CmpFuncKey) *AvlThreadedNode {
+
+	var found *AvlThreadedNode
+
+	for cur := tree.root; cur != nil; {
+		n := avlThreaded(cur)
+		if cmp(key, n.owner) <= 0 {
+			found = n
+			cur = cur.left
+		} else {
+			cur = cur.right
+		}
+	}
+
+	return found
+}
+
+// Link item into the tree.  Returns nil if inserted, and the owner of
+// the existing node with the same key if not
+
+func (tree *AvlThreadedTree) Insert(item *AvlThreadedNode, owner interface{},
+	cmp CmpFuncNode) interface{} {
+
+	curPtr := &tree.root
+	var cur *AvlNode
+
+	// The new node's neighbours are the last nodes on the way down at
+	// which the descent went right and left
+
+	var prev, next *AvlThreadedNode
+
+	for *curPtr != nil {
+		cur = *curPtr
+		n := avlThreaded(cur)
+
+		res := cmp(owner, n.owner)
+		if res < 0 {
+			next = n
+			curPtr = &cur.left
+		} else if res > 0 {
+			prev = n
+			curPtr = &cur.right
+		} else {
+			return n.owner
+		}
+	}
+
+	item.owner = owner
+	item.prev = prev
+	item.next = next
+	if prev != nil {
+		prev.next = item
+	} else {
+		tree.first = item
+	}
+	if next != nil {
+		next.prev = item
+	} else {
+		tree.last = item
+	}
+
+	node := &item.node
+	*curPtr = node
+	node.parent = cur
+	node.balance = 1
+	node.owner = item
+	node.size = 1
+
+	avlAdjustSizes(cur, +1)
+	avlTreeRebalanceAfterInsert(&tree.root, node, &tree.rotations)
+	tree.size++
+
+	return nil
+}
+
+// Unlink item from the tree, and from the threads.  Returns its owner
+
+func (tree *AvlThreadedTree) Remove(item *AvlThreadedNode) interface{} {
+
+	if item.prev != nil {
+		item.prev.next = item.next
+	} else {
+		tree.first = item.next
+	}
+	if item.next != nil {
+		item.next.prev = item.prev
+	} else {
+		tree.last = item.prev
+	}
+	item.prev = nil
+	item.next = nil
+
+	avlTreeRemove(&tree.root, &item.node, &tree.rotations)
+	avlTreeNodeSetUnlinked(&item.node)
+	tree.size--
+
+	return item.owner
+}
+
+// Yields the owners in key order, following the threads.  The tree must
+// not be modified while the sequence is being iterated
+
+func (tree *AvlThreadedTree) All() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		for n := tree.first; n != nil; n = n.next {
+			if !yield(n.owner) {
+				return
+			}
+		}
+	}
+}
+
+// Yields the owners in reverse key order
+
+func (tree *AvlThreadedTree) Backward() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		for n := tree.last; n != nil; n = n.prev {
+			if !yield(n.owner) {
+				return
+			}
+		}
+	}
+}
+
+// Checks the tree's invariants, ordering by cmp if it is not nil, and
+// that the threads visit the nodes in the same order as an in-order walk
+
+func (tree *AvlThreadedTree) Validate(cmp CmpFuncNode) error {
+
+	var byNode CmpFuncNode
+	if cmp != nil {
+		byNode = func(a, b interface{}) int {
+			return cmp(a.(*AvlThreadedNode).owner, b.(*AvlThreadedNode).owner)
+		}
+	}
+	if err := AvlTreeValidate(tree.root, byNode); err != nil {
+		return err
+	}
+
+	var prev *AvlThreadedNode
+	n := tree.first
+	cur := avlTreeFirstOrLastInOrder(tree.root, -1)
+	for ; cur != nil; cur = avlTreeNextOrPrevInOrder(cur, 1) {
+		want := avlThreaded(cur)
+		if n != want {
+			return fmt.Errorf("%w: thread reaches %v where the tree has %v",
+				ErrInvalidTree, n, want.owner)
+		}
+		if n.prev != prev {
+			return fmt.Errorf("%w: back thread of %v is wrong", ErrInvalidTree,
+				n.owner)
+		}
+		prev = n
+		n = n.next
+	}
+	if n != nil || tree.last != prev {
+		return fmt.Errorf("%w: threads run past the last node", ErrInvalidTree)
+	}
+	if size := avlGetSize(tree.root); size != tree.size {
+		return fmt.Errorf("%w: %d nodes, size %d", ErrInvalidTree, size,
+			tree.size)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+type threadedNode struct {
+	avlHeader AvlThreadedNode
+	key       int
+}
+
+func cmpThreadedKey(key interface{}, node interface{}) int {
+	return key.(int) - node.(*threadedNode).key
+}
+
+func cmpThreadedNode(node1 interface{}, node2 interface{}) int {
+	return node1.(*threadedNode).key - node2.(*threadedNode).key
+}
+
+func TestAvlThreadedTree(t *testing.T) {
+
+	var tree AvlThreadedTree
+
+	assert.Nil(t, tree.First())
+	assert.NoError(t, tree.Validate(cmpThreadedNode))
+
+	rnd := rand.New(rand.NewSource(12))
+	model := map[int]*threadedNode{}
+
+	for i := 0; i < 3000; i++ {
+		k := rnd.Intn(300)
+		if n, ok := model[k]; ok {
+			if rnd.Intn(2) == 0 {
+				assert.Same(t, n, tree.Remove(&n.avlHeader))
+				assert.True(t, n.avlHeader.node.IsUnlinked())
+				delete(model, k)
+			} else {
+				m := &threadedNode{key: k}
+				assert.Same(t, n, tree.Insert(&m.avlHeader, m, cmpThreadedNode))
+			}
+		} else {
+			n := &threadedNode{key: k}
+			assert.Nil(t, tree.Insert(&n.avlHeader, n, cmpThreadedNode))
+			model[k] = n
+		}
+		if i%100 == 0 {
+			assert.NoError(t, tree.Validate(cmpThreadedNode))
+		}
+	}
+	assert.NoError(t, tree.Validate(cmpThreadedNode))
+	assert.Equal(t, len(model), tree.Len())
+
+	var keys []int
+	for k := range model {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	var got, back []int
+	for owner := range tree.All() {
+		got = append(got, owner.(*threadedNode).key)
+	}
+	for owner := range tree.Backward() {
+		back = append([]int{owner.(*threadedNode).key}, back...)
+	}
+	assert.Equal(t, keys, got)
+	assert.Equal(t, keys, back)
+
+	n := tree.Seek(keys[3]+1, cmpThreadedKey)
+	assert.Equal(t, keys[4], n.Owner().(*threadedNode).key)
+	assert.Equal(t, keys[5], n.Next().Owner().(*threadedNode).key)
+	assert.Equal(t, keys[3], n.Prev().Owner().(*threadedNode).key)
+	assert.Same(t, model[keys[0]], tree.Lookup(keys[0], cmpThreadedKey))
+	assert.Nil(t, tree.Seek(1000, cmpThreadedKey))
+}
@@ -0,0 +1,152 @@
+package avl
+
+import (
+	"iter"
+	"time"
+)
+
+//
+// Time series.  AvlTimeSeries is a boxed tree of values keyed by time,
+// with the comparator and the rounding glue that time-keyed trees all
+// need: range scans between two times, the latest value and the first
+// after a time, bucketing by a duration, and dropping old values.
+// Several values may share a time; they are kept in the order they were
+// added.
+//
+
+type avlTimeEntry[V any] struct {
+	header AvlNode
+	t      time.Time
+	v      V
+}
+
+type AvlTimeSeries[V any] struct {
+	tree AvlTree
+}
+
+func avlCmpTimeEntries[V any](a, b interface{}) int {
+	return a.(*avlTimeEntry[V]).t.Compare(b.(*avlTimeEntry[V]).t)
+}
+
+func avlCmpTimeKey[V any](key interface{}, node interface{}) int {
+	return key.(time.Time).Compare(node.(*avlTimeEntry[V]).t)
+}
+
+// Returns an empty time series
+
+func NewAvlTimeSeries[V any]() *AvlTimeSeries[V] {
+	ts := &AvlTimeSeries[V]{}
+	ts.tree.dups = AvlDupKeepRight
+	return ts
+}
+
+// Returns the number of values
+
+func (ts *AvlTimeSeries[V]) Len() int {
+	return ts.tree.Len()
+}
+
+// Adds v at time t
+
+func (ts *AvlTimeSeries[V]) Add(t time.Time, v V) {
+	e := &avlTimeEntry[V]{t: t, v: v}
+	ts.tree.Insert(&e.header, e, avlCmpTimeEntries[V])
+}
+
+func avlTimeEntryOf[V any](n *AvlNode) (time.Time, V, bool) {
+
+	if n == nil {
+		var v V
+		return time.Time{}, v, false
+	}
+
+	e := n.owner.(*avlTimeEntry[V])
+
+	return e.t, e.v, true
+}
+
+// Returns the earliest value and its time, or false if there are none
+
+func (ts *AvlTimeSeries[V]) Earliest() (time.Time, V, bool) {
+	return avlTimeEntryOf[V](ts.tree.first)
+}
+
+// Returns the latest value and its time, or false if there are none.
+// Of several values at the latest time, it is the last added
+
+func (ts *AvlTimeSeries[V]) Latest() (time.Time, V, bool) {
+	return avlTimeEntryOf[V](ts.tree.last)
+}
+
+// Returns the first value strictly after t, and its time, or false if
+// there is none
+
+func (ts *AvlTimeSeries[V]) EarliestAfter(t time.Time) (time.Time, V, bool) {
+
+	var found *AvlNode
+
+	for cur := ts.tree.root; cur != nil; {
+		if avlCmpTimeKey[V](t, cur.owner) < 0 {
+			found = cur
+			cur = cur.left
+		} else {
+			cur = cur.right
+		}
+	}
+
+	return avlTimeEntryOf[V](found)
+}
+
+// Yields the times and values in [t1, t2), in time order.  The series
+// must not be modified while the sequence is being iterated
+
+func (ts *AvlTimeSeries[V]) Between(t1, t2 time.Time) iter.Seq2[time.Time, V] {
+	return func(yield func(time.Time, V) bool) {
+		ts.tree.Range(t1, t2, avlCmpTimeKey[V], func(owner interface{}) bool {
+			e := owner.(*avlTimeEntry[V])
+			return yield(e.t, e.v)
+		})
+	}
+}
+
+// Groups the values in [t1, t2) into buckets of width d, aligned as by
+// time.Truncate (so per-minute buckets start on the minute), and yields
+// the start of each non-empty bucket with its values, in time order
+
+func (ts *AvlTimeSeries[V]) Buckets(t1, t2 time.Time, d time.Duration) iter.Seq2[time.Time, []V] {
+	return func(yield func(time.Time, []V) bool) {
+
+		var start time.Time
+		var vs []V
+
+		for t, v := range ts.Between(t1, t2) {
+			b := t.Truncate(d)
+			if vs != nil && !b.Equal(start) {
+				if !yield(start, vs) {
+					return
+				}
+				vs = nil
+			}
+			start = b
+			vs = append(vs, v)
+		}
+
+		if vs != nil {
+			yield(start, vs)
+		}
+	}
+}
+
+// Removes every value before t, and returns how many there were
+
+func (ts *AvlTimeSeries[V]) RemoveBefore(t time.Time) int {
+
+	removed := 0
+
+	for n := ts.tree.first; n != nil && avlCmpTimeKey[V](t, n.owner) > 0; n = ts.tree.first {
+		ts.tree.Remove(n)
+		removed++
+	}
+
+	return removed
+}
@@ -0,0 +1,59 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAvlTimeSeries(t *testing.T) {
+
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return base.Add(time.Duration(s) * time.Second) }
+
+	ts := NewAvlTimeSeries[int]()
+
+	_, _, ok := ts.Latest()
+	assert.False(t, ok)
+
+	for _, s := range []int{130, 10, 70, 70, 200, 59} {
+		ts.Add(at(s), s)
+	}
+	ts.Add(at(70), -70)
+
+	assert.Equal(t, 7, ts.Len())
+
+	tm, v, _ := ts.Latest()
+	assert.Equal(t, at(200), tm)
+	assert.Equal(t, 200, v)
+	tm, _, _ = ts.Earliest()
+	assert.Equal(t, at(10), tm)
+
+	// Values at the same time stay in the order they were added
+
+	var vs []int
+	for _, v := range ts.Between(at(59), at(130)) {
+		vs = append(vs, v)
+	}
+	assert.Equal(t, []int{59, 70, 70, -70}, vs)
+
+	_, v, _ = ts.EarliestAfter(at(70))
+	assert.Equal(t, 130, v)
+	_, _, ok = ts.EarliestAfter(at(200))
+	assert.False(t, ok)
+
+	// Per-minute buckets
+
+	var starts []time.Time
+	var sizes []int
+	for start, vs := range ts.Buckets(at(0), at(300), time.Minute) {
+		starts = append(starts, start)
+		sizes = append(sizes, len(vs))
+	}
+	assert.Equal(t, []time.Time{at(0), at(60), at(120), at(180)}, starts)
+	assert.Equal(t, []int{2, 3, 1, 1}, sizes)
+
+	assert.Equal(t, 2, ts.RemoveBefore(at(60)))
+	tm, _, _ = ts.Earliest()
+	assert.Equal(t, at(70), tm)
+}
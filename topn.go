@@ -0,0 +1,102 @@
+package avl
+
+import "iter"
+
+//
+// Top-N tracking.  An AvlTopN keeps the n greatest owners offered to it,
+// by a comparison function, for workloads that offer many owners and
+// keep few: the greatest latencies seen, the highest scores.  Once it is
+// full, an owner that does not beat the least one kept, the threshold,
+// is turned away after that single comparison, without touching the
+// tree; one that does is inserted and the threshold owner evicted, as
+// from a tree made WithMaxSize.  Among equal owners the first offered
+// ranks higher, so a tie with the threshold is turned away.  For the n
+// least owners, order by Reverse(cmp).
+//
+
+type AvlTopN struct {
+	tree     AvlTree
+	n        int
+	header   func(owner interface{}) *AvlNode
+	cmp      CmpFuncNode
+	rejected uint64
+}
+
+// Returns an empty tracker of the n greatest owners by cmp.  header
+// returns an owner's AvlNode, and onEvict, if not nil, is called with
+// each owner evicted to make room for a greater one
+
+func NewAvlTopN(n int, header func(owner interface{}) *AvlNode,
+	cmp CmpFuncNode, onEvict func(owner interface{})) *AvlTopN {
+
+	t := &AvlTopN{n: n, header: header, cmp: cmp}
+
+	// Keeping equal owners newest first makes the newest of the least
+	// ones the first evicted
+
+	t.tree.dups = AvlDupKeepLeft
+	WithMaxSize(n, onEvict)(&t.tree)
+
+	return t
+}
+
+// Offers owner, and returns true if it is kept, as one of the n greatest
+// so far.  An owner that is already kept is turned away, and stays kept.
+// O(1) if it is turned away by the threshold, and O(log n) otherwise
+
+func (t *AvlTopN) Offer(owner interface{}) bool {
+
+	if t.n <= 0 || (t.tree.size >= t.n && t.cmp(owner, t.tree.first.owner) <= 0) {
+		t.rejected++
+		return false
+	}
+
+	item := t.header(owner)
+	if avlTreeContains(t.tree.root, item) {
+		return false
+	}
+	t.tree.Insert(item, owner, t.cmp)
+
+	return true
+}
+
+// Returns the number of owners kept, at most n
+
+func (t *AvlTopN) Len() int {
+	return t.tree.Len()
+}
+
+// Returns the number of offers turned away without touching the tree
+
+func (t *AvlTopN) Rejected() uint64 {
+	return t.rejected
+}
+
+// True if owner is among those kept.  O(log n)
+
+func (t *AvlTopN) Contains(owner interface{}) bool {
+	return avlTreeContains(t.tree.root, t.header(owner))
+}
+
+// Returns the least owner kept, which an offer must beat to be kept, or
+// nil if the tracker is not full and so keeps anything offered.  O(1)
+
+func (t *AvlTopN) Threshold() interface{} {
+	if t.n <= 0 || t.tree.size < t.n {
+		return nil
+	}
+	return t.tree.first.owner
+}
+
+// Yields the owners kept, greatest first.  The tracker must not be
+// offered anything while the sequence is being iterated
+
+func (t *AvlTopN) All() iter.Seq[interface{}] {
+	return func(yield func(interface{}) bool) {
+		for n := t.tree.last; n != nil; n = avlTreeNextOrPrevInOrder(n, -1) {
+			if !yield(n.owner) {
+				return
+			}
+		}
+	}
+}
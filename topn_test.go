@@ -0,0 +1,83 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func intNodeHeader(owner interface{}) *AvlNode {
+	return &owner.(*intNode).avlHeader
+}
+
+func TestAvlTopN(t *testing.T) {
+
+	var evicted []int
+	top := NewAvlTopN(3, intNodeHeader, cmpIntNode, func(owner interface{}) {
+		evicted = append(evicted, owner.(*intNode).key)
+	})
+
+	ns := newIntNodes(5, 1, 8, 3, 9, 2, 8)
+	assert.Nil(t, top.Threshold())
+	for _, n := range ns[:3] {
+		assert.True(t, top.Offer(n))
+	}
+	assert.Same(t, ns[1], top.Threshold())
+
+	assert.True(t, top.Offer(ns[3]))  // 3 beats 1
+	assert.True(t, top.Offer(ns[4]))  // 9 beats 3
+	assert.False(t, top.Offer(ns[5])) // 2 does not beat 5
+	assert.Equal(t, []int{1, 3}, evicted)
+	assert.True(t, top.Contains(ns[2]))
+	assert.False(t, top.Contains(ns[1]))
+
+	// A tie with a kept owner above the threshold keeps both, until
+	// the threshold is lost; the later of the two goes first
+
+	assert.True(t, top.Offer(ns[6]))
+	assert.Equal(t, []interface{}{ns[4], ns[2], ns[6]}, collect(top.All()))
+	assert.Same(t, ns[6], top.Threshold())
+	assert.False(t, top.Offer(&intNode{key: 8}))
+	assert.Equal(t, uint64(2), top.Rejected())
+
+	// Offering a kept owner again leaves it kept, once
+
+	assert.False(t, top.Offer(ns[4]))
+	assert.False(t, top.Offer(ns[6]))
+	assert.Equal(t, []interface{}{ns[4], ns[2], ns[6]}, collect(top.All()))
+	assert.NoError(t, top.tree.Validate(cmpIntNode))
+
+	none := NewAvlTopN(0, intNodeHeader, cmpIntNode, nil)
+	assert.False(t, none.Offer(&intNode{key: 1}))
+	assert.Nil(t, none.Threshold())
+}
+
+func TestAvlTopNAgainstSort(t *testing.T) {
+
+	rnd := rand.New(rand.NewSource(1229))
+	top := NewAvlTopN(10, intNodeHeader, Reverse(CmpFuncNode(cmpIntNode)), nil)
+
+	var keys []int
+	for i := 0; i < 5000; i++ {
+		k := rnd.Intn(100000)
+		keys = append(keys, k)
+		top.Offer(&intNode{key: k})
+	}
+	sort.Ints(keys)
+
+	var got []int
+	for o := range top.All() {
+		got = append(got, o.(*intNode).key)
+	}
+	assert.Equal(t, keys[:10], got)
+	assert.True(t, top.Rejected() > 4900)
+}
+
+func collect(seq func(yield func(interface{}) bool)) []interface{} {
+	var out []interface{}
+	for x := range seq {
+		out = append(out, x)
+	}
+	return out
+}
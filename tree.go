@@ -0,0 +1,926 @@
+package avl
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+)
+
+//
+// AvlTree bundles a root pointer with the bookkeeping that the plain
+// AvlTreeXxx functions can't do on their own, because all they ever see
+// is the root pointer.  The zero value is an empty tree ready to use.
+//
+// The raw functions are still the building blocks; the AvlTree methods
+// call them and then do their bookkeeping.  Don't mix the two on the
+// same tree: a node inserted with the raw functions will not be counted
+// or seen by the tree's observers.
+//
+
+type AvlTree struct {
+	contents
+	observers   []avlObserverEntry
+	nextObsId   int
+	selfCheck   CmpFuncNode
+	dups        AvlDupPolicy
+	gen         atomic.Uint64
+	counts      AvlTreeCounts
+	profileName string
+	journal     *AvlJournal
+	bound       *avlBound
+	errSink     func(err error)
+	onMisuse    func(err error)
+	order       *avlInsertionOrder
+	filter      *avlFilterState
+	cmpCheck    *avlCmpCheck
+	keyCheck    *avlKeyCheck
+}
+
+// The part of a tree that moves with it when trees are swapped
+
+type contents struct {
+	root  *AvlNode
+	size  int
+	first *AvlNode
+	last  *AvlNode
+}
+
+// Running totals of the work done through a tree's methods
+
+type AvlTreeCounts struct {
+	Inserts   uint64
+	Removes   uint64
+	Rotations uint64 // A double rotation counts as two
+	Allocs    uint64 // Owners the package allocated, e.g. by AvlBuilder
+}
+
+// A snapshot of a tree's state, for monitoring
+
+type AvlTreeStats struct {
+	AvlTreeCounts
+	Size   int
+	Height int
+}
+
+// Operations reported to observers
+
+type AvlOp int
+
+const (
+	AvlOpInsert AvlOp = iota
+	AvlOpRemove
+)
+
+func (op AvlOp) String() string {
+	switch op {
+	case AvlOpInsert:
+		return "insert"
+	case AvlOpRemove:
+		return "remove"
+	default:
+		return "unknown"
+	}
+}
+
+// An observer is called after every successful mutation of the tree,
+// with the owner that was inserted or removed.  Observers run
+// synchronously, in registration order, and must not modify the tree
+
+type AvlObserver func(op AvlOp, owner interface{})
+
+type avlObserverEntry struct {
+	id  int
+	obs AvlObserver
+}
+
+// Options for NewAvlTree
+
+type AvlTreeOption func(tree *AvlTree)
+
+// Returns a new, empty tree with the given options
+
+func NewAvlTree(opts ...AvlTreeOption) *AvlTree {
+	tree := &AvlTree{}
+	for _, opt := range opts {
+		opt(tree)
+	}
+	return tree
+}
+
+// Debugging option: validate the whole tree, ordering by cmp, after
+// every insertion and removal, and panic at the first failure (or tell
+// the error sink; see WithErrorSink).  This
+// makes every mutation O(n), so it is only for tracking down corruption
+// (typically user code changing a key, or the links, of a node that is
+// still in the tree) in test and debug builds
+
+func WithSelfCheck(cmp CmpFuncNode) AvlTreeOption {
+	return func(tree *AvlTree) {
+		tree.selfCheck = cmp
+	}
+}
+
+// What Insert does with a node whose key is already in the tree
+
+type AvlDupPolicy int
+
+const (
+	// Leave the tree alone and return the existing owner
+	AvlDupReject AvlDupPolicy = iota
+
+	// Replace the existing node, as InsertOrReplace does
+	AvlDupReplace
+
+	// Keep both, the new node before all the nodes with the same key
+	AvlDupKeepLeft
+
+	// Keep both, the new node after all the nodes with the same key
+	AvlDupKeepRight
+)
+
+// Sets the tree's duplicate-key policy; the default is AvlDupReject.
+// The policy applies to Insert and TryInsert; the other insertion
+// methods say what they do with duplicates.  With the keep-both
+// policies Lookup returns one of the owners with the key, and
+// validation allows neighbouring owners with equal keys
+
+func WithDuplicates(policy AvlDupPolicy) AvlTreeOption {
+	return func(tree *AvlTree) {
+		tree.dups = policy
+	}
+}
+
+// Register an observer.  The returned function unregisters it
+
+func (tree *AvlTree) Watch(obs AvlObserver) (cancel func()) {
+	tree.nextObsId++
+	id := tree.nextObsId
+
+	tree.observers = append(tree.observers, avlObserverEntry{id, obs})
+
+	return func() {
+		for i := range tree.observers {
+			if tree.observers[i].id == id {
+				tree.observers = append(tree.observers[:i],
+					tree.observers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (tree *AvlTree) notify(op AvlOp, owner interface{}) {
+	for _, e := range tree.observers {
+		e.obs(op, owner)
+	}
+}
+
+// avlTreeInsertNode, counting rotations
+
+func (tree *AvlTree) insertNode(item *AvlNode, owner interface{},
+	cmp CmpFuncNode) *AvlNode {
+
+	return avlTreeInsertNodeDup(&tree.root, item, owner, cmp, 0,
+		&tree.counts.Rotations)
+}
+
+// Bookkeeping after node has been linked into the tree.  A new minimum
+// is always linked in as the left child of the old one, and stays there
+// through the rotations (which are all at its ancestors); likewise for a
+// new maximum
+
+func (tree *AvlTree) inserted(node *AvlNode) {
+	tree.gen.Add(1)
+	tree.counts.Inserts++
+	tree.size++
+	if tree.first == nil || tree.first.left == node {
+		tree.first = node
+	}
+	if tree.last == nil || tree.last.right == node {
+		tree.last = node
+	}
+	if tree.keyCheck != nil {
+		tree.keyCheck.keys[node] = tree.keyCheck.key(node.owner)
+		tree.checkKeys(avlGetParent(node))
+	}
+	tree.check(AvlOpInsert)
+	if tree.order != nil {
+		tree.order.push(node.owner)
+	}
+	if tree.filter != nil {
+		tree.filter.inserted(node.owner, tree.size)
+	}
+	if tree.journal != nil {
+		tree.journal.record(AvlOpInsert, node)
+	}
+	tree.notify(AvlOpInsert, node.owner)
+	if tree.bound != nil {
+		tree.bound.add(node.owner, 1)
+		tree.evict()
+	}
+}
+
+// Unlinks node from the tree, and marks it unlinked
+
+func (tree *AvlTree) unlink(node *AvlNode) {
+	if node == tree.first {
+		tree.first = avlTreeNextOrPrevInOrder(node, 1)
+	}
+	if node == tree.last {
+		tree.last = avlTreeNextOrPrevInOrder(node, -1)
+	}
+	tree.checkKeys(node)
+
+	avlTreeRemove(&tree.root, node, &tree.counts.Rotations)
+	node.SetUnlinked()
+
+	tree.removed(node)
+}
+
+// Bookkeeping after node has been unlinked from the tree
+
+func (tree *AvlTree) removed(node *AvlNode) {
+	tree.gen.Add(1)
+	tree.counts.Removes++
+	tree.size--
+	if tree.keyCheck != nil {
+		delete(tree.keyCheck.keys, node)
+	}
+	tree.check(AvlOpRemove)
+	if tree.bound != nil {
+		tree.bound.add(node.owner, -1)
+	}
+	if tree.order != nil {
+		tree.order.drop(node.owner)
+	}
+	if tree.filter != nil {
+		tree.filter.removed(tree.size)
+	}
+	if tree.journal != nil {
+		tree.journal.record(AvlOpRemove, node)
+	}
+	tree.notify(AvlOpRemove, node.owner)
+}
+
+// Replaces the contents of the tree with the tree rooted at root,
+// recomputing the cached state.  Observers are not notified, and the
+// journal, if any, is cleared
+
+func (tree *AvlTree) reset(root *AvlNode) {
+	tree.gen.Add(1)
+	if tree.journal != nil {
+		tree.journal.Clear()
+	}
+	tree.root = root
+	tree.size = avlGetSize(root)
+	tree.first = avlTreeFirstOrLastInOrder(root, -1)
+	tree.last = avlTreeFirstOrLastInOrder(root, 1)
+	if tree.bound != nil {
+		tree.recost()
+	}
+	if tree.order != nil {
+		tree.order.rethread(root)
+	}
+	if tree.filter != nil {
+		tree.filter.stale = true
+	}
+	if tree.keyCheck != nil {
+		tree.keyCheck.capture(root)
+	}
+}
+
+// True if the tree may hold several owners with the same key
+
+func (tree *AvlTree) keepsDups() bool {
+	return tree.dups == AvlDupKeepLeft || tree.dups == AvlDupKeepRight
+}
+
+func (tree *AvlTree) check(op AvlOp) {
+	if tree.cmpCheck != nil {
+		tree.cmpCheck.mutated(tree)
+	}
+	if tree.selfCheck == nil {
+		return
+	}
+	err := avlTreeValidate(context.Background(), tree.root, tree.selfCheck,
+		tree.keepsDups())
+	if err != nil {
+		tree.fail(fmt.Errorf("avl: self-check failed after %v: %w", op, err))
+	}
+}
+
+// Returns the root node of the tree, or nil if the tree is empty.
+// The root is for read-only use with the traversal functions
+
+func (tree *AvlTree) Root() *AvlNode {
+	return tree.root
+}
+
+// Returns the number of nodes in the tree
+
+func (tree *AvlTree) Len() int {
+	return tree.size
+}
+
+// Returns the tree's size, height and operation counts.  Joins and
+// splits (Prune and friends) rebalance without counting rotations.
+// O(log n)
+
+func (tree *AvlTree) Stats() AvlTreeStats {
+	return AvlTreeStats{
+		AvlTreeCounts: tree.counts,
+		Size:          tree.size,
+		Height:        avlHeight(tree.root),
+	}
+}
+
+// Look up a specified key.  nil if not present
+
+func (tree *AvlTree) Lookup(key interface{}, cmp CmpFuncKey) interface{} {
+	defer tree.recoverTo()
+	if tree.filter != nil && !tree.mayContain(key) {
+		return nil
+	}
+	return AvlTreeLookup(tree.root, key, cmp)
+}
+
+// Look up a specified key.  def if not present
+
+func (tree *AvlTree) LookupOr(key interface{}, cmp CmpFuncKey,
+	def interface{}) interface{} {
+
+	defer tree.recoverTo()
+
+	if tree.filter != nil && !tree.mayContain(key) {
+		return def
+	}
+	return AvlTreeLookupOr(tree.root, key, cmp, def)
+}
+
+// See AvlTreeSearch
+
+func (tree *AvlTree) Search(pred func(owner interface{}) bool) interface{} {
+	defer tree.recoverTo()
+	return AvlTreeSearch(tree.root, pred)
+}
+
+// See AvlTreeIndexOfFirstGE
+
+func (tree *AvlTree) IndexOfFirstGE(key interface{}, cmp CmpFuncKey) int {
+	defer tree.recoverTo()
+	return AvlTreeIndexOfFirstGE(tree.root, key, cmp)
+}
+
+// See AvlTreeIndexOfFirstGT
+
+func (tree *AvlTree) IndexOfFirstGT(key interface{}, cmp CmpFuncKey) int {
+	defer tree.recoverTo()
+	return AvlTreeIndexOfFirstGT(tree.root, key, cmp)
+}
+
+// See AvlTreeCountLess
+
+func (tree *AvlTree) CountLess(key interface{}, cmp CmpFuncKey) int {
+	defer tree.recoverTo()
+	return AvlTreeCountLess(tree.root, key, cmp)
+}
+
+// See AvlTreeCountGreater
+
+func (tree *AvlTree) CountGreater(key interface{}, cmp CmpFuncKey) int {
+	defer tree.recoverTo()
+	return AvlTreeCountGreater(tree.root, key, cmp)
+}
+
+// See AvlTreeSelect
+
+func (tree *AvlTree) Select(i int) interface{} {
+	return AvlTreeSelect(tree.root, i)
+}
+
+// See AvlTreeCDF
+
+func (tree *AvlTree) CDF(key interface{}, cmp CmpFuncKey) float64 {
+	defer tree.recoverTo()
+	return AvlTreeCDF(tree.root, key, cmp)
+}
+
+// See AvlTreeICDF
+
+func (tree *AvlTree) ICDF(p float64) interface{} {
+	return AvlTreeICDF(tree.root, p)
+}
+
+// See AvlTreeSample
+
+func (tree *AvlTree) Sample(n int, r *rand.Rand) []interface{} {
+	return AvlTreeSample(tree.root, n, r)
+}
+
+// Insert a node into the tree.  Returns nil if item was inserted.  What
+// happens if a node with the same key is already present depends on the
+// tree's duplicate-key policy (see WithDuplicates): by default the
+// existing owner is returned and the tree is left alone.  With
+// AvlDupReplace item is always inserted, and the owner it displaced is
+// returned, as InsertOrReplace does
+
+func (tree *AvlTree) Insert(item *AvlNode, owner interface{},
+	cmp CmpFuncNode) interface{} {
+
+	defer tree.recoverTo()
+
+	return tree.insert(item, owner, cmp)
+}
+
+// Insert, without recovering panics
+
+func (tree *AvlTree) insert(item *AvlNode, owner interface{},
+	cmp CmpFuncNode) interface{} {
+
+	if tree.misusedInsert(item, owner, cmp) {
+		return nil
+	}
+
+	dup := 0
+
+	switch tree.dups {
+	case AvlDupReplace:
+		return tree.replace(item, owner, cmp)
+	case AvlDupKeepLeft:
+		dup = -1
+	case AvlDupKeepRight:
+		dup = 1
+	}
+
+	existing := avlTreeInsertNodeDup(&tree.root, item, owner, cmp, dup,
+		&tree.counts.Rotations)
+	if existing != nil {
+		return existing.owner
+	}
+
+	tree.inserted(item)
+
+	return nil
+}
+
+// Insert a node into the tree if no node with the same key is present.
+// Returns true if item was inserted
+
+func (tree *AvlTree) InsertIfAbsent(item *AvlNode, owner interface{},
+	cmp CmpFuncNode) bool {
+
+	defer tree.recoverTo()
+
+	if tree.misusedInsert(item, owner, cmp) {
+		return false
+	}
+	if tree.insertNode(item, owner, cmp) != nil {
+		return false
+	}
+
+	tree.inserted(item)
+
+	return true
+}
+
+// Removes a node from the tree, and marks it unlinked.  Returns the
+// node's owner
+
+func (tree *AvlTree) Remove(node *AvlNode) interface{} {
+	defer tree.recoverTo()
+
+	if tree.misusedRemove(node) {
+		return nil
+	}
+	tree.unlink(node)
+	return node.owner
+}
+
+// Insert a node into the tree, replacing any node with the same key.
+// Returns the displaced owner, or nil if there was none.  The displaced
+// node is marked unlinked.  Observers see a replacement as the removal
+// of the old owner followed by the insertion of the new one
+
+func (tree *AvlTree) InsertOrReplace(item *AvlNode, owner interface{},
+	cmp CmpFuncNode) interface{} {
+
+	defer tree.recoverTo()
+
+	if tree.misusedInsert(item, owner, cmp) {
+		return nil
+	}
+
+	return tree.replace(item, owner, cmp)
+}
+
+// InsertOrReplace, after the misuse checks
+
+func (tree *AvlTree) replace(item *AvlNode, owner interface{},
+	cmp CmpFuncNode) interface{} {
+
+	existing := tree.insertNode(item, owner, cmp)
+	if existing == nil {
+		tree.inserted(item)
+		return nil
+	}
+
+	avlTreeReplaceNode(&tree.root, existing, item, owner)
+	existing.SetUnlinked()
+
+	if tree.first == existing {
+		tree.first = item
+	}
+	if tree.last == existing {
+		tree.last = item
+	}
+
+	tree.removed(existing)
+	if tree.journal != nil {
+		tree.journal.join = true
+	}
+	tree.inserted(item)
+
+	return existing.owner
+}
+
+// Insert a node into the tree, or call onExisting with the owner
+// already present under the same key.  Returns true if item was inserted
+
+func (tree *AvlTree) Upsert(item *AvlNode, owner interface{},
+	cmp CmpFuncNode, onExisting func(existing interface{})) bool {
+
+	defer tree.recoverTo()
+
+	if tree.misusedInsert(item, owner, cmp) {
+		return false
+	}
+	if existing := tree.insertNode(item, owner, cmp); existing != nil {
+		onExisting(existing.owner)
+
+		// onExisting may have broken the ordering
+
+		tree.checkKeys(existing)
+		tree.check(AvlOpInsert)
+		return false
+	}
+
+	tree.inserted(item)
+
+	return true
+}
+
+// Returns the owner already present under item's key, or inserts item
+// and returns owner.  The bool is true if item was inserted
+
+func (tree *AvlTree) GetOrInsert(item *AvlNode, owner interface{},
+	cmp CmpFuncNode) (interface{}, bool) {
+
+	defer tree.recoverTo()
+
+	if tree.misusedInsert(item, owner, cmp) {
+		return nil, false
+	}
+	if existing := tree.insertNode(item, owner, cmp); existing != nil {
+		return existing.owner, false
+	}
+
+	tree.inserted(item)
+
+	return owner, true
+}
+
+// Checks the invariants of the tree; see AvlTreeValidate
+
+func (tree *AvlTree) Validate(cmp CmpFuncNode) error {
+	return tree.ValidateContext(context.Background(), cmp)
+}
+
+// Checks the invariants of the tree, giving up once ctx is done; see
+// AvlTreeValidateContext
+
+func (tree *AvlTree) ValidateContext(ctx context.Context,
+	cmp CmpFuncNode) (err error) {
+
+	defer tree.recoverToErr(&err)
+
+	tree.labeled(ctx, "validate", func(ctx context.Context) {
+		err = avlTreeValidate(ctx, tree.root, cmp, tree.keepsDups())
+	})
+	if err == nil && tree.keyCheck != nil {
+		err = tree.keyCheck.verifyAll(tree.root)
+	}
+
+	return err
+}
+
+// Checked Insert; see AvlTreeTryInsert
+
+func (tree *AvlTree) TryInsert(item *AvlNode, owner interface{},
+	cmp CmpFuncNode) (existing interface{}, err error) {
+
+	defer tree.recoverToErr(&err)
+
+	if err := avlTreeCheckInsert(tree.root, item, cmp); err != nil {
+		return nil, err
+	}
+
+	return tree.insert(item, owner, cmp), nil
+}
+
+// See AvlTreeAssertContains
+
+func (tree *AvlTree) AssertContains(node *AvlNode) error {
+	return AvlTreeAssertContains(tree.root, node)
+}
+
+// Checked Remove; see AvlTreeTryRemove
+
+func (tree *AvlTree) TryRemove(node *AvlNode) (err error) {
+
+	defer tree.recoverToErr(&err)
+
+	if err := AvlTreeAssertContains(tree.root, node); err != nil {
+		return err
+	}
+
+	tree.unlink(node)
+
+	return nil
+}
+
+// Moves node from src to dst in one call.  Returns ErrNotInTree if node
+// is not linked into src, or ErrKeyExists if dst already holds a node
+// with the same key and its duplicate policy (see WithDuplicates) is
+// AvlDupReject; in both cases neither tree is changed.  Observers of src
+// see a removal and observers of dst an insertion, and a node dst
+// replaces as well a removal
+
+func AvlTreeMove(dst, src *AvlTree, node *AvlNode,
+	cmp CmpFuncNode) (err error) {
+
+	defer dst.recoverToErr(&err)
+
+	if node == nil {
+		return ErrNilNode
+	}
+	if cmp == nil {
+		return ErrNilComparator
+	}
+	if !avlTreeContains(src.root, node) {
+		return fmt.Errorf("%w: %v", ErrNotInTree, node.owner)
+	}
+	if dst == src {
+		return nil
+	}
+
+	owner := node.owner
+
+	if dst.dups == AvlDupReject &&
+		AvlTreeLookup(dst.root, owner, CmpFuncKey(cmp)) != nil {
+		return fmt.Errorf("%w: %v", ErrKeyExists, owner)
+	}
+
+	src.Remove(node)
+	dst.insert(node, owner, cmp)
+
+	return nil
+}
+
+// Exchanges the contents of two trees: nodes, size and any other
+// cached state move together, so each tree is consistent the moment
+// the call returns.  Observers stay with their tree objects and are not
+// notified, and both trees' journals are cleared.  As with every other
+// method, callers sharing the trees between goroutines must hold their
+// locks on both
+
+func AvlTreeSwap(a, b *AvlTree) {
+	a.contents, b.contents = b.contents, a.contents
+	a.gen.Add(1)
+	b.gen.Add(1)
+	if a.bound != nil {
+		a.recost()
+	}
+	if b.bound != nil {
+		b.recost()
+	}
+	if a.journal != nil {
+		a.journal.Clear()
+	}
+	if b.journal != nil {
+		b.journal.Clear()
+	}
+	if a.order != nil {
+		a.order.rethread(a.root)
+	}
+	if b.order != nil {
+		b.order.rethread(b.root)
+	}
+	if a.filter != nil {
+		a.filter.stale = true
+	}
+	if b.filter != nil {
+		b.filter.stale = true
+	}
+	if a.keyCheck != nil {
+		a.keyCheck.capture(a.root)
+	}
+	if b.keyCheck != nil {
+		b.keyCheck.capture(b.root)
+	}
+}
+
+// Detaches the subtree rooted at node, which must be in tree, and
+// returns it as a tree of its own, with tree's options but not its
+// observers, journal or lookup filter.  What remains of tree is
+// rebalanced, by rejoining the pieces left along the path from node up
+// to the root.  O(log^2 n).  Observers of tree see a removal for every
+// node pruned
+
+func AvlTreePrune(tree *AvlTree, node *AvlNode) *AvlTree {
+
+	defer tree.recoverTo()
+
+	var pruned *AvlTree
+
+	tree.labeled(context.Background(), "prune", func(context.Context) {
+		pruned = avlTreePrune(tree, node)
+	})
+
+	return pruned
+}
+
+func avlTreePrune(tree *AvlTree, node *AvlNode) *AvlTree {
+
+	pruned := tree.emptyLike()
+
+	var acc *AvlNode
+	accHeight := 0
+
+	// Work up from node, joining what has been kept so far with each
+	// ancestor and its other subtree
+
+	cur := node
+	for a := avlGetParent(node); a != nil; {
+		next := avlGetParent(a)
+
+		if cur == a.left {
+			r := a.right
+			if r != nil {
+				avlSetParent(r, nil)
+			}
+			acc = avlJoin(acc, accHeight, a, r, avlHeight(r))
+		} else {
+			l := a.left
+			if l != nil {
+				avlSetParent(l, nil)
+			}
+			acc = avlJoin(l, avlHeight(l), a, acc, accHeight)
+		}
+		accHeight = avlHeight(acc)
+
+		cur = a
+		a = next
+	}
+
+	avlSetParent(node, nil)
+	pruned.reset(node)
+
+	tree.reset(acc)
+
+	if len(tree.observers) > 0 {
+		for n := avlTreeFirstOrLastInOrder(node, -1); n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+			tree.notify(AvlOpRemove, n.owner)
+		}
+	}
+	tree.check(AvlOpRemove)
+
+	return pruned
+}
+
+// Splits tree by position: tree keeps its first k owners (all of them,
+// or none, if k is out of range) and the rest move to a new tree.
+// Returns tree and the new tree, which has tree's options (see
+// AvlTreePrune).  Descends to the split point by the
+// subtree sizes, then joins the subtrees hanging off the path on either
+// side, bottom up.  O(log^2 n).  Observers of tree see a removal for
+// every owner moved
+
+func AvlTreeSplitAt(tree *AvlTree, k int) (*AvlTree, *AvlTree) {
+
+	defer tree.recoverTo()
+
+	var rest *AvlTree
+
+	tree.labeled(context.Background(), "split", func(context.Context) {
+		rest = avlTreeSplitAt(tree, k)
+	})
+
+	return tree, rest
+}
+
+func avlTreeSplitAt(tree *AvlTree, k int) *AvlTree {
+
+	rest := tree.emptyLike()
+
+	// The path down to the split point, and whether each step went left,
+	// i.e. whether the node on it belongs to the rest
+
+	var path []*AvlNode
+	var toRest []bool
+
+	i := min(max(k, 0), tree.size)
+	for cur := tree.root; cur != nil; {
+		path = append(path, cur)
+		l := avlGetSize(cur.left)
+		if i <= l {
+			toRest = append(toRest, true)
+			cur = cur.left
+		} else {
+			i -= l + 1
+			toRest = append(toRest, false)
+			cur = cur.right
+		}
+	}
+
+	var lo, hi *AvlNode
+	loHeight, hiHeight := 0, 0
+
+	for j := len(path) - 1; j >= 0; j-- {
+		a := path[j]
+		if toRest[j] {
+			r := a.right
+			if r != nil {
+				avlSetParent(r, nil)
+			}
+			hi = avlJoin(hi, hiHeight, a, r, avlHeight(r))
+			hiHeight = avlHeight(hi)
+		} else {
+			l := a.left
+			if l != nil {
+				avlSetParent(l, nil)
+			}
+			lo = avlJoin(l, avlHeight(l), a, lo, loHeight)
+			loHeight = avlHeight(lo)
+		}
+	}
+
+	rest.reset(hi)
+	tree.reset(lo)
+
+	if len(tree.observers) > 0 {
+		for n := avlTreeFirstOrLastInOrder(hi, -1); n != nil; n = avlTreeNextOrPrevInOrder(n, 1) {
+			tree.notify(AvlOpRemove, n.owner)
+		}
+	}
+	tree.check(AvlOpRemove)
+
+	return rest
+}
+
+// Returns the least owner in the tree, or nil if it is empty.  O(1)
+
+func (tree *AvlTree) First() interface{} {
+	if tree.first != nil {
+		return tree.first.owner
+	} else {
+		return nil
+	}
+}
+
+// Returns the greatest owner in the tree, or nil if it is empty.  O(1)
+
+func (tree *AvlTree) Last() interface{} {
+	if tree.last != nil {
+		return tree.last.owner
+	} else {
+		return nil
+	}
+}
+
+// Removes the least node from the tree and returns its owner, or nil if
+// the tree is empty
+
+func (tree *AvlTree) PopMin() interface{} {
+	defer tree.recoverTo()
+	return tree.pop(tree.first)
+}
+
+// Removes the greatest node from the tree and returns its owner, or nil
+// if the tree is empty
+
+func (tree *AvlTree) PopMax() interface{} {
+	defer tree.recoverTo()
+	return tree.pop(tree.last)
+}
+
+func (tree *AvlTree) pop(node *AvlNode) interface{} {
+	if node == nil {
+		return nil
+	}
+
+	owner := node.owner
+	tree.unlink(node)
+
+	return owner
+}
@@ -0,0 +1,327 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+type intNode struct {
+	avlHeader AvlNode
+	key       int
+}
+
+func cmpIntKey(key interface{}, node interface{}) int {
+	return key.(int) - node.(*intNode).key
+}
+
+func cmpIntNode(node1 interface{}, node2 interface{}) int {
+	return node1.(*intNode).key - node2.(*intNode).key
+}
+
+func newIntNodes(keys ...int) []*intNode {
+	ns := make([]*intNode, len(keys))
+	for i, k := range keys {
+		ns[i] = &intNode{key: k}
+	}
+	return ns
+}
+
+func TestAvlTreeWatch(t *testing.T) {
+
+	var tree AvlTree
+	var ops []AvlOp
+	var owners []interface{}
+
+	cancel := tree.Watch(func(op AvlOp, owner interface{}) {
+		ops = append(ops, op)
+		owners = append(owners, owner)
+	})
+
+	ns := newIntNodes(1, 2, 1)
+
+	assert.Nil(t, tree.Insert(&ns[0].avlHeader, ns[0], cmpIntNode))
+	assert.Nil(t, tree.Insert(&ns[1].avlHeader, ns[1], cmpIntNode))
+	assert.Equal(t, ns[0], tree.Insert(&ns[2].avlHeader, ns[2], cmpIntNode))
+	tree.Remove(&ns[0].avlHeader)
+
+	assert.Equal(t, []AvlOp{AvlOpInsert, AvlOpInsert, AvlOpRemove}, ops)
+	assert.Equal(t, []interface{}{ns[0], ns[1], ns[0]}, owners)
+	assert.Equal(t, 1, tree.Len())
+
+	cancel()
+	tree.Remove(&ns[1].avlHeader)
+	assert.Len(t, ops, 3)
+	assert.Equal(t, 0, tree.Len())
+}
+
+func TestAvlTreeInsertOrReplaceNotifies(t *testing.T) {
+
+	var tree AvlTree
+	var ops []AvlOp
+
+	tree.Watch(func(op AvlOp, owner interface{}) {
+		ops = append(ops, op)
+	})
+
+	ns := newIntNodes(7, 7)
+	assert.Nil(t, tree.InsertOrReplace(&ns[0].avlHeader, ns[0], cmpIntNode))
+	assert.Equal(t, ns[0], tree.InsertOrReplace(&ns[1].avlHeader, ns[1], cmpIntNode))
+	assert.Equal(t, 1, tree.Len())
+	assert.Equal(t, []AvlOp{AvlOpInsert, AvlOpRemove, AvlOpInsert}, ops)
+}
+
+func TestAvlTreeRemoveMarksUnlinked(t *testing.T) {
+
+	var tree AvlTree
+
+	ns := newIntNodes(1, 2, 2)
+	tree.Insert(&ns[0].avlHeader, ns[0], cmpIntNode)
+	tree.Insert(&ns[1].avlHeader, ns[1], cmpIntNode)
+
+	tree.InsertOrReplace(&ns[2].avlHeader, ns[2], cmpIntNode)
+	assert.True(t, ns[1].avlHeader.IsUnlinked())
+
+	assert.Same(t, ns[0], tree.Remove(&ns[0].avlHeader))
+	assert.True(t, ns[0].avlHeader.IsUnlinked())
+	assert.False(t, ns[2].avlHeader.IsUnlinked())
+}
+
+func TestAvlTreeTryRemoveForeignNode(t *testing.T) {
+
+	var a, b AvlTree
+
+	ns := newIntNodes(1, 2)
+	a.Insert(&ns[0].avlHeader, ns[0], cmpIntNode)
+	b.Insert(&ns[1].avlHeader, ns[1], cmpIntNode)
+
+	assert.ErrorIs(t, a.TryRemove(&ns[1].avlHeader), ErrNotInTree)
+	assert.Equal(t, 1, a.Len())
+	assert.NoError(t, b.TryRemove(&ns[1].avlHeader))
+	assert.Equal(t, 0, b.Len())
+}
+
+func TestAvlTreeMove(t *testing.T) {
+
+	var a, b AvlTree
+
+	ns := newIntNodes(1, 2, 3, 2)
+	for _, n := range ns[:3] {
+		a.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+	b.Insert(&ns[3].avlHeader, ns[3], cmpIntNode)
+
+	assert.NoError(t, AvlTreeMove(&b, &a, &ns[0].avlHeader, cmpIntNode))
+	assert.Equal(t, 2, a.Len())
+	assert.Equal(t, 2, b.Len())
+	assert.Equal(t, ns[0], b.Lookup(1, cmpIntKey))
+	assert.Nil(t, a.Lookup(1, cmpIntKey))
+
+	// Collision in the destination leaves both trees alone
+
+	assert.ErrorIs(t, AvlTreeMove(&b, &a, &ns[1].avlHeader, cmpIntNode), ErrKeyExists)
+	assert.Equal(t, ns[1], a.Lookup(2, cmpIntKey))
+
+	// The node must belong to the source tree
+
+	assert.ErrorIs(t, AvlTreeMove(&a, &a, &ns[3].avlHeader, cmpIntNode), ErrNotInTree)
+
+	assert.NoError(t, a.Validate(cmpIntNode))
+	assert.NoError(t, b.Validate(cmpIntNode))
+}
+
+func TestAvlTreeMoveDuplicates(t *testing.T) {
+
+	var src AvlTree
+	keep := NewAvlTree(WithDuplicates(AvlDupKeepRight))
+	replace := NewAvlTree(WithDuplicates(AvlDupReplace))
+
+	ns := newIntNodes(1, 1, 1, 1)
+	keep.Insert(&ns[0].avlHeader, ns[0], cmpIntNode)
+	replace.Insert(&ns[1].avlHeader, ns[1], cmpIntNode)
+	src.Insert(&ns[2].avlHeader, ns[2], cmpIntNode)
+
+	// The destination's duplicate policy decides
+
+	assert.NoError(t, AvlTreeMove(keep, &src, &ns[2].avlHeader, cmpIntNode))
+	assert.Equal(t, 0, src.Len())
+	assert.Equal(t, 2, keep.Len())
+	assert.Same(t, ns[0], keep.First())
+	assert.Same(t, ns[2], keep.Last())
+
+	src.Insert(&ns[3].avlHeader, ns[3], cmpIntNode)
+	assert.NoError(t, AvlTreeMove(replace, &src, &ns[3].avlHeader, cmpIntNode))
+	assert.Equal(t, 0, src.Len())
+	assert.Equal(t, 1, replace.Len())
+	assert.Same(t, ns[3], replace.First())
+	assert.True(t, ns[1].avlHeader.IsUnlinked())
+
+	assert.NoError(t, keep.Validate(cmpIntNode))
+	assert.NoError(t, replace.Validate(cmpIntNode))
+}
+
+func TestAvlTreeSwap(t *testing.T) {
+
+	var a, b AvlTree
+
+	ns := newIntNodes(1, 2, 3)
+	a.Insert(&ns[0].avlHeader, ns[0], cmpIntNode)
+	b.Insert(&ns[1].avlHeader, ns[1], cmpIntNode)
+	b.Insert(&ns[2].avlHeader, ns[2], cmpIntNode)
+
+	AvlTreeSwap(&a, &b)
+
+	assert.Equal(t, 2, a.Len())
+	assert.Equal(t, 1, b.Len())
+	assert.Equal(t, ns[2], a.Lookup(3, cmpIntKey))
+	assert.Equal(t, ns[0], b.Lookup(1, cmpIntKey))
+}
+
+func TestAvlTreeSelfCheck(t *testing.T) {
+
+	tree := NewAvlTree(WithSelfCheck(cmpIntNode))
+
+	ns := newIntNodes(1, 2, 3, 4)
+	for _, n := range ns[:3] {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	// Changing the key of a linked node is caught by the next mutation
+
+	ns[0].key = 5
+	assert.Panics(t, func() {
+		tree.Insert(&ns[3].avlHeader, ns[3], cmpIntNode)
+	})
+}
+
+func TestAvlTreePopMinMaxCached(t *testing.T) {
+
+	var tree AvlTree
+
+	assert.Nil(t, tree.PopMin())
+	assert.Nil(t, tree.First())
+
+	rnd := rand.New(rand.NewSource(4))
+	model := map[int]bool{}
+
+	// Random inserts, replacements and removals, with the cached
+	// extremes checked against the tree after each
+
+	for i := 0; i < 3000; i++ {
+		k := rnd.Intn(200)
+		n := &intNode{key: k}
+		switch rnd.Intn(5) {
+		case 0, 1:
+			tree.Insert(&n.avlHeader, n, cmpIntNode)
+			model[k] = true
+		case 2:
+			tree.InsertOrReplace(&n.avlHeader, n, cmpIntNode)
+			model[k] = true
+		case 3:
+			if p := tree.PopMin(); p != nil {
+				delete(model, p.(*intNode).key)
+			}
+		case 4:
+			if p := tree.PopMax(); p != nil {
+				delete(model, p.(*intNode).key)
+			}
+		}
+
+		assert.Equal(t, len(model), tree.Len())
+		assert.Equal(t, AvlTreeFirstInOrder(tree.Root()), tree.First())
+		assert.Equal(t, AvlTreeLastInOrder(tree.Root()), tree.Last())
+	}
+
+	for tree.Len() > 0 {
+		min := tree.First()
+		assert.Equal(t, min, tree.PopMin())
+	}
+	assert.Nil(t, tree.Last())
+}
+
+func TestAvlTreeInsertIfAbsentMethod(t *testing.T) {
+
+	var tree AvlTree
+
+	ns := newIntNodes(5, 5)
+	assert.True(t, tree.InsertIfAbsent(&ns[0].avlHeader, ns[0], cmpIntNode))
+	assert.False(t, tree.InsertIfAbsent(&ns[1].avlHeader, ns[1], cmpIntNode))
+	assert.Equal(t, 1, tree.Len())
+}
+
+func TestAvlTreeDuplicatePolicy(t *testing.T) {
+
+	insertAll := func(policy AvlDupPolicy) (*AvlTree, []*intNode, []interface{}) {
+		tree := NewAvlTree(WithDuplicates(policy), WithSelfCheck(cmpIntNode))
+		ns := newIntNodes(2, 1, 2, 3, 2)
+		var rets []interface{}
+		for _, n := range ns {
+			rets = append(rets, tree.Insert(&n.avlHeader, n, cmpIntNode))
+		}
+		return tree, ns, rets
+	}
+
+	owners := func(tree *AvlTree) []interface{} {
+		var out []interface{}
+		tree.Range(nil, nil, cmpIntKey, func(owner interface{}) bool {
+			out = append(out, owner)
+			return true
+		})
+		return out
+	}
+
+	tree, ns, rets := insertAll(AvlDupReject)
+	assert.Equal(t, []interface{}{nil, nil, ns[0], nil, ns[0]}, rets)
+	assert.Equal(t, []interface{}{ns[1], ns[0], ns[3]}, owners(tree))
+
+	// Replacing returns the displaced owner
+
+	tree, ns, rets = insertAll(AvlDupReplace)
+	assert.Equal(t, []interface{}{nil, nil, ns[0], nil, ns[2]}, rets)
+	assert.Equal(t, []interface{}{ns[1], ns[4], ns[3]}, owners(tree))
+	assert.True(t, ns[0].avlHeader.IsUnlinked())
+
+	tree, ns, _ = insertAll(AvlDupKeepLeft)
+	assert.Equal(t, []interface{}{ns[1], ns[4], ns[2], ns[0], ns[3]}, owners(tree))
+	assert.NoError(t, tree.Validate(cmpIntNode))
+
+	tree, ns, _ = insertAll(AvlDupKeepRight)
+	assert.Equal(t, []interface{}{ns[1], ns[0], ns[2], ns[4], ns[3]}, owners(tree))
+	assert.Equal(t, 5, tree.Len())
+
+	// TryInsert follows the policy too, after its misuse checks
+
+	n := &intNode{key: 3}
+	_, err := tree.TryInsert(&n.avlHeader, n, cmpIntNode)
+	assert.NoError(t, err)
+	assert.Equal(t, n, tree.Last())
+	_, err = tree.TryInsert(&n.avlHeader, n, cmpIntNode)
+	assert.ErrorIs(t, err, ErrAlreadyLinked)
+}
+
+func TestAvlTreeStats(t *testing.T) {
+
+	var tree AvlTree
+
+	ns := newIntNodes(1, 2, 3, 0)
+	for _, n := range ns[:3] {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	// 1, 2, 3 in order needs one rotation, leaving 2 at the root
+
+	s := tree.Stats()
+	assert.Equal(t, AvlTreeCounts{Inserts: 3, Rotations: 1}, s.AvlTreeCounts)
+	assert.Equal(t, 3, s.Size)
+	assert.Equal(t, 2, s.Height)
+
+	// Adding 0 below 1 and removing 3 leaves 2 too left-heavy
+
+	tree.GetOrInsert(&ns[3].avlHeader, ns[3], cmpIntNode)
+	tree.Remove(&ns[2].avlHeader)
+	tree.Remove(&ns[3].avlHeader)
+
+	s = tree.Stats()
+	assert.Equal(t, AvlTreeCounts{Inserts: 4, Removes: 2, Rotations: 2}, s.AvlTreeCounts)
+	assert.Equal(t, 2, s.Height)
+}
@@ -0,0 +1,127 @@
+package avl
+
+//
+// Transactions.  Between Begin and Commit or Rollback every mutation of
+// the tree is applied at once, as usual, and recorded in a journal (see
+// AvlJournal) of its own; Rollback undoes them all, most recent first,
+// and Commit keeps them.  There is no copy of the tree, so a transaction
+// costs only its own operations.
+//
+// Transactions nest: one begun inside another is rolled back on its own,
+// or committed into the outer one.  A transaction committed into the
+// tree's undo journal is undone as one mutation.  If the tree's contents
+// are replaced wholesale during a transaction (by Swap, say), it can no
+// longer be rolled back.
+//
+
+type AvlTxn struct {
+	tree    *AvlTree
+	cmp     CmpFuncNode
+	journal *AvlJournal
+	outer   *AvlJournal
+	done    bool
+}
+
+// Begins a transaction on the tree, ordering by cmp
+
+func (tree *AvlTree) Begin(cmp CmpFuncNode) *AvlTxn {
+
+	txn := &AvlTxn{
+		tree:    tree,
+		cmp:     cmp,
+		journal: &AvlJournal{tree: tree, cmp: cmp},
+		outer:   tree.journal,
+	}
+	tree.journal = txn.journal
+
+	return txn
+}
+
+// Insert a node into the tree; see AvlTree.Insert
+
+func (txn *AvlTxn) Insert(item *AvlNode, owner interface{}) interface{} {
+	return txn.tree.Insert(item, owner, txn.cmp)
+}
+
+// Insert a node into the tree, replacing any node with the same key; see
+// AvlTree.InsertOrReplace
+
+func (txn *AvlTxn) InsertOrReplace(item *AvlNode, owner interface{}) interface{} {
+	return txn.tree.InsertOrReplace(item, owner, txn.cmp)
+}
+
+// Removes a node from the tree; see AvlTree.Remove
+
+func (txn *AvlTxn) Remove(node *AvlNode) interface{} {
+	return txn.tree.Remove(node)
+}
+
+// Ends the transaction, restoring the journal that was in place when it
+// began
+
+func (txn *AvlTxn) finish() error {
+
+	if txn.done {
+		return ErrTxnDone
+	}
+	if txn.tree.journal != txn.journal {
+		txn.tree.fail(ErrTxnOrder)
+		return ErrTxnOrder
+	}
+
+	txn.done = true
+	txn.tree.journal = txn.outer
+
+	return nil
+}
+
+// Keeps the transaction's mutations.  Inside another transaction, or
+// with an undo journal, they are recorded there as one mutation
+
+func (txn *AvlTxn) Commit() error {
+
+	if err := txn.finish(); err != nil {
+		return err
+	}
+
+	outer := txn.outer
+	switch {
+	case outer == nil:
+	case txn.journal.cleared:
+		outer.Clear()
+	case len(txn.journal.done) == 0:
+	default:
+		for i, op := range txn.journal.done {
+			op.first = i == 0
+			outer.done = append(outer.done, op)
+		}
+		outer.undone = outer.undone[:0]
+	}
+
+	return nil
+}
+
+// Undoes the transaction's mutations.  Observers see the reversing
+// insertions and removals.  Returns ErrTxnLost, and clears the outer
+// journal, if the tree's contents were replaced wholesale
+
+func (txn *AvlTxn) Rollback() error {
+
+	if err := txn.finish(); err != nil {
+		return err
+	}
+	if txn.journal.cleared {
+		if txn.outer != nil {
+			txn.outer.Clear()
+		}
+		return ErrTxnLost
+	}
+
+	// The undo is not a mutation of the outer journal's history
+
+	txn.tree.journal = txn.journal
+	txn.journal.Undo(len(txn.journal.done))
+	txn.tree.journal = txn.outer
+
+	return nil
+}
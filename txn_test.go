@@ -0,0 +1,111 @@
+package avl
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAvlTxn(t *testing.T) {
+
+	var tree AvlTree
+
+	ns := newIntNodes(1, 2, 3, 2, 4)
+	for _, n := range ns[:3] {
+		tree.Insert(&n.avlHeader, n, cmpIntNode)
+	}
+
+	// Rollback undoes everything, including mutations made on the tree
+	// directly
+
+	txn := tree.Begin(cmpIntNode)
+	txn.Remove(&ns[0].avlHeader)
+	assert.Nil(t, txn.InsertOrReplace(&ns[4].avlHeader, ns[4]))
+	assert.Equal(t, ns[1], txn.InsertOrReplace(&ns[3].avlHeader, ns[3]))
+	tree.PopMax()
+	assert.Equal(t, []int{2, 3}, keysOf(&tree))
+
+	assert.NoError(t, txn.Rollback())
+	assert.Equal(t, []int{1, 2, 3}, keysOf(&tree))
+	assert.Equal(t, ns[1], tree.Lookup(2, cmpIntKey))
+	assert.True(t, ns[4].avlHeader.IsUnlinked())
+	assert.NoError(t, tree.Validate(cmpIntNode))
+
+	assert.ErrorIs(t, txn.Rollback(), ErrTxnDone)
+	assert.ErrorIs(t, txn.Commit(), ErrTxnDone)
+
+	// Commit keeps them, and an undo journal sees the transaction as
+	// one mutation
+
+	j := tree.Journal(cmpIntNode)
+
+	txn = tree.Begin(cmpIntNode)
+	txn.Insert(&ns[4].avlHeader, ns[4])
+	txn.Remove(&ns[0].avlHeader)
+	assert.NoError(t, txn.Commit())
+	assert.Equal(t, []int{2, 3, 4}, keysOf(&tree))
+
+	assert.Equal(t, 1, j.UndoLen())
+	j.Undo(1)
+	assert.Equal(t, []int{1, 2, 3}, keysOf(&tree))
+}
+
+func TestAvlTxnNested(t *testing.T) {
+
+	var tree AvlTree
+
+	ns := newIntNodes(1, 2, 3)
+
+	outer := tree.Begin(cmpIntNode)
+	outer.Insert(&ns[0].avlHeader, ns[0])
+
+	inner := tree.Begin(cmpIntNode)
+	inner.Insert(&ns[1].avlHeader, ns[1])
+	assert.NoError(t, inner.Rollback())
+	assert.Equal(t, []int{1}, keysOf(&tree))
+
+	inner = tree.Begin(cmpIntNode)
+	inner.Insert(&ns[2].avlHeader, ns[2])
+	assert.Panics(t, func() { outer.Commit() })
+	assert.NoError(t, inner.Commit())
+
+	assert.NoError(t, outer.Rollback())
+	assert.Equal(t, 0, tree.Len())
+
+	// A wholesale replacement loses the history
+
+	txn := tree.Begin(cmpIntNode)
+	txn.Insert(&ns[0].avlHeader, ns[0])
+	tree.Freeze()
+	assert.ErrorIs(t, txn.Rollback(), ErrTxnLost)
+}
+
+func TestAvlTxnSwap(t *testing.T) {
+
+	for _, commit := range []bool{true, false} {
+		var tree, other AvlTree
+		undo := tree.Journal(cmpIntNode)
+
+		ns := newIntNodes(1, 2, 3)
+		tree.Insert(&ns[0].avlHeader, ns[0], cmpIntNode)
+		tree.Insert(&ns[1].avlHeader, ns[1], cmpIntNode)
+		other.Insert(&ns[2].avlHeader, ns[2], cmpIntNode)
+
+		// A swap with nothing after it still loses the outer history,
+		// whose nodes now belong to the other tree
+
+		txn := tree.Begin(cmpIntNode)
+		AvlTreeSwap(&tree, &other)
+		if commit {
+			assert.NoError(t, txn.Commit())
+		} else {
+			assert.ErrorIs(t, txn.Rollback(), ErrTxnLost)
+		}
+		assert.Equal(t, 0, undo.UndoLen())
+
+		undo.Undo(1)
+		assert.Equal(t, []int{3}, keysOf(&tree))
+		assert.Equal(t, []int{1, 2}, keysOf(&other))
+		assert.NoError(t, tree.Validate(cmpIntNode))
+		assert.NoError(t, other.Validate(cmpIntNode))
+	}
+}
@@ -0,0 +1,165 @@
+package avl
+
+import (
+	"context"
+	"fmt"
+)
+
+// How many nodes the long-running functions visit between checks of
+// their context
+
+const avlContextCheckInterval = 1024
+
+// Counts the nodes a long-running function visits, checking its context
+// every avlContextCheckInterval of them
+
+type avlContextTicker struct {
+	ctx     context.Context
+	visited int
+}
+
+// Counts a node.  Returns ctx.Err() if it is time for a check and ctx is
+// done
+
+func (t *avlContextTicker) tick() error {
+	t.visited++
+	if t.visited%avlContextCheckInterval == 0 {
+		return t.ctx.Err()
+	}
+	return nil
+}
+
+// One level of the explicit stack used by the validator
+
+type avlValidateFrame struct {
+	node        *AvlNode
+	stage       int
+	leftHeight  int
+	rightHeight int
+}
+
+// Checks the invariants of the tree rooted at root: parent pointers
+// agree with child pointers, every balance factor matches the heights
+// of the node's subtrees and is in the range [-1, +1], every subtree
+// size is one more than the sizes of the node's subtrees, and, if cmp is
+// not nil, an in-order walk visits the owners in strictly increasing
+// order.  Returns nil if the tree is valid, or an error wrapping
+// ErrInvalidTree describing the first problem found
+//
+// The walk uses an explicit stack and never follows a child pointer
+// whose parent pointer disagrees, so it terminates even on badly
+// corrupted trees
+
+func AvlTreeValidate(root *AvlNode, cmp CmpFuncNode) error {
+	return AvlTreeValidateContext(context.Background(), root, cmp)
+}
+
+// Like AvlTreeValidate, but gives up and returns ctx.Err() once ctx is
+// done
+
+func AvlTreeValidateContext(ctx context.Context, root *AvlNode,
+	cmp CmpFuncNode) error {
+
+	return avlTreeValidate(ctx, root, cmp, false)
+}
+
+// The validator.  With allowEqual, neighbouring owners may compare
+// equal, as in trees that keep duplicate keys
+
+func avlTreeValidate(ctx context.Context, root *AvlNode, cmp CmpFuncNode,
+	allowEqual bool) error {
+
+	if root == nil {
+		return nil
+	}
+
+	if root.parent != nil {
+		return fmt.Errorf("%w: root %v has a parent", ErrInvalidTree,
+			root.owner)
+	}
+
+	var prev *AvlNode
+	var height int
+
+	stack := []avlValidateFrame{{node: root}}
+	visited := 0
+
+	for len(stack) > 0 {
+		f := &stack[len(stack)-1]
+		n := f.node
+
+		switch f.stage {
+		case 0:
+			visited++
+			if visited%avlContextCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+
+			f.stage = 1
+			if n.left != nil {
+				if n.left.parent != n {
+					return fmt.Errorf("%w: left child %v of %v has the wrong parent",
+						ErrInvalidTree, n.left.owner, n.owner)
+				}
+				stack = append(stack, avlValidateFrame{node: n.left})
+			}
+
+		case 1:
+			if cmp != nil && prev != nil {
+				res := cmp(prev.owner, n.owner)
+				if res > 0 || (res == 0 && !allowEqual) {
+					return fmt.Errorf("%w: %v is not less than %v",
+						ErrInvalidTree, prev.owner, n.owner)
+				}
+			}
+			prev = n
+
+			f.stage = 2
+			if n.right != nil {
+				if n.right.parent != n {
+					return fmt.Errorf("%w: right child %v of %v has the wrong parent",
+						ErrInvalidTree, n.right.owner, n.owner)
+				}
+				stack = append(stack, avlValidateFrame{node: n.right})
+			}
+
+		case 2:
+			balance := avlGetBalanceFactor(n)
+			if balance < -1 || balance > 1 ||
+				balance != f.rightHeight-f.leftHeight {
+				return fmt.Errorf("%w: %v has balance factor %d but subtree heights %d/%d",
+					ErrInvalidTree, n.owner, balance, f.leftHeight,
+					f.rightHeight)
+			}
+
+			size := 1 + avlGetSize(n.left) + avlGetSize(n.right)
+			if int(n.size) != size {
+				return fmt.Errorf("%w: %v has subtree size %d, should be %d",
+					ErrInvalidTree, n.owner, n.size, size)
+			}
+
+			height = f.leftHeight
+			if f.rightHeight > height {
+				height = f.rightHeight
+			}
+			height++
+
+			stack = stack[:len(stack)-1]
+
+			// Hand the height of this subtree to the parent frame
+
+			if len(stack) > 0 {
+				p := &stack[len(stack)-1]
+				if p.stage == 1 {
+					p.leftHeight = height
+				} else {
+					p.rightHeight = height
+				}
+			}
+		}
+	}
+
+	return nil
+}
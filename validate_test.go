@@ -0,0 +1,76 @@
+package avl
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func buildIntTree(keys ...int) (*AvlNode, []*intNode) {
+
+	var r *AvlNode
+
+	ns := newIntNodes(keys...)
+	for _, n := range ns {
+		AvlTreeInsert(&r, &n.avlHeader, n, cmpIntNode)
+	}
+
+	return r, ns
+}
+
+func TestAvlTreeValidate(t *testing.T) {
+
+	assert.NoError(t, AvlTreeValidate(nil, cmpIntNode))
+
+	keys := make([]int, 500)
+	for i := range keys {
+		keys[i] = (i * 7919) % 500
+	}
+	r, ns := buildIntTree(keys...)
+	assert.NoError(t, AvlTreeValidate(r, cmpIntNode))
+
+	for i := 0; i < len(ns); i += 3 {
+		AvlTreeRemove(&r, &ns[i].avlHeader)
+		assert.NoError(t, AvlTreeValidate(r, cmpIntNode))
+	}
+}
+
+func TestAvlTreeValidateDetectsCorruption(t *testing.T) {
+
+	r, ns := buildIntTree(1, 2, 3, 4, 5, 6, 7)
+
+	// Ordering
+
+	ns[0].key = 10
+	assert.ErrorIs(t, AvlTreeValidate(r, cmpIntNode), ErrInvalidTree)
+	assert.NoError(t, AvlTreeValidate(r, nil))
+	ns[0].key = 1
+
+	// Balance factor
+
+	r.balance = 0
+	assert.ErrorIs(t, AvlTreeValidate(r, cmpIntNode), ErrInvalidTree)
+	r.balance = 1
+
+	// Parent pointers
+
+	r.left.parent = r.right
+	assert.ErrorIs(t, AvlTreeValidate(r, cmpIntNode), ErrInvalidTree)
+	r.left.parent = r
+
+	assert.NoError(t, AvlTreeValidate(r, cmpIntNode))
+}
+
+func TestAvlTreeValidateContext(t *testing.T) {
+
+	keys := make([]int, 5000)
+	for i := range keys {
+		keys[i] = i
+	}
+	r, _ := buildIntTree(keys...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, AvlTreeValidateContext(ctx, r, cmpIntNode), context.Canceled)
+}
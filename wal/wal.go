@@ -0,0 +1,304 @@
+//
+// Copyright as per Creative Commons Legal Code license, which can
+// be found in the file COPYING
+//
+
+/*
+
+Package wal makes an avl.AvlTree durable with a write-ahead log.  A Log
+watches a tree and appends a record for every insertion and removal made
+through the tree's methods; at startup, Restore rebuilds the tree from
+the last snapshot and the log written since:
+
+	tree := avl.NewAvlTree()
+	if err := wal.Restore(tree, snapFile, logFile, codec, cmp); err != nil {
+		...
+	}
+	log := wal.Attach(tree, logFile, codec, wal.CompactEvery(100000, rotate))
+
+Compaction writes a snapshot of the tree (in the avl binary dump format)
+and starts a new log, so the log doesn't grow without bound and restarts
+don't replay the whole history.
+
+Each record is an operation byte, the uvarint length of the owner's
+encoding, the encoding, and a CRC-32 of the operation and encoding.  A
+record cut short at the end of the log, as a crash in the middle of a
+write leaves it, is ignored by Replay.
+
+*/
+
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/danswartzendruber/avl"
+	"hash/crc32"
+	"io"
+)
+
+// Converts owners to and from their logged form
+
+type Codec interface {
+	// Encodes an owner.  The encoding must be a JSON value, as it is
+	// also a key in snapshots
+	Encode(owner interface{}) ([]byte, error)
+
+	// Creates a new owner, and returns its header, from an encoding
+	Decode(data []byte) (*avl.AvlNode, interface{}, error)
+
+	// Returns the header embedded in an owner
+	Header(owner interface{}) *avl.AvlNode
+}
+
+// Codec for trees of avl.AvlItems
+
+type ItemCodec struct{}
+
+func (ItemCodec) Encode(owner interface{}) ([]byte, error) {
+	return avl.AvlItemEncodeKey(owner)
+}
+
+func (ItemCodec) Decode(data []byte) (*avl.AvlNode, interface{}, error) {
+	return avl.AvlItemDecodeKey(data)
+}
+
+func (ItemCodec) Header(owner interface{}) *avl.AvlNode {
+	return &owner.(*avl.AvlItem).Header
+}
+
+// Returned by Replay for a record that fails its checksum or is otherwise
+// malformed
+
+var ErrCorrupt = errors.New("wal: corrupt log record")
+
+const (
+	opInsert = 'I'
+	opRemove = 'R'
+)
+
+// A log attached to a tree
+
+type Log struct {
+	tree   *avl.AvlTree
+	w      io.Writer
+	codec  Codec
+	cancel func()
+	err    error
+
+	records      int
+	compactEvery int
+	rotate       func() (snap, log io.Writer, err error)
+}
+
+// Options for Attach
+
+type Option func(l *Log)
+
+// Compacts the log once it holds n records: rotate returns where to
+// write the snapshot and the new log.  The caller is responsible for
+// making the switch atomic on disk, for instance by writing to new files
+// and renaming them over the old ones only once compaction has succeeded
+
+func CompactEvery(n int, rotate func() (snap, log io.Writer, err error)) Option {
+	return func(l *Log) {
+		l.compactEvery = n
+		l.rotate = rotate
+	}
+}
+
+// Starts logging the mutations of tree to w
+
+func Attach(tree *avl.AvlTree, w io.Writer, codec Codec, opts ...Option) *Log {
+
+	l := &Log{tree: tree, w: w, codec: codec}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	l.cancel = tree.Watch(l.observe)
+
+	return l
+}
+
+// Stops logging
+
+func (l *Log) Detach() {
+	l.cancel()
+}
+
+// Returns the first error met writing the log.  Observers can't fail a
+// mutation, so once a write has failed the tree has moved ahead of the
+// log, and no more records are written
+
+func (l *Log) Err() error {
+	return l.err
+}
+
+// Flushes the log to stable storage, if its writer knows how
+
+func (l *Log) Sync() error {
+
+	if l.err != nil {
+		return l.err
+	}
+	if s, ok := l.w.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+
+	return nil
+}
+
+func (l *Log) observe(op avl.AvlOp, owner interface{}) {
+
+	if l.err != nil {
+		return
+	}
+
+	code := byte(opInsert)
+	if op == avl.AvlOpRemove {
+		code = opRemove
+	}
+
+	data, err := l.codec.Encode(owner)
+	if err == nil {
+		err = writeRecord(l.w, code, data)
+	}
+	if err != nil {
+		l.err = fmt.Errorf("wal: logging %v of %v: %w", op, owner, err)
+		return
+	}
+
+	l.records++
+	if l.compactEvery > 0 && l.records >= l.compactEvery {
+		snap, log, err := l.rotate()
+		if err == nil {
+			err = l.Compact(snap, log)
+		}
+		if err != nil {
+			l.err = fmt.Errorf("wal: compacting: %w", err)
+		}
+	}
+}
+
+// Writes a snapshot of the tree to snap and continues the log in log
+
+func (l *Log) Compact(snap, log io.Writer) error {
+
+	d, err := l.tree.Snapshot().Dump(l.codec.Encode)
+	if err != nil {
+		return err
+	}
+	if err := avl.AvlDumpWriteBinary(snap, d); err != nil {
+		return err
+	}
+
+	l.w = log
+	l.records = 0
+
+	return nil
+}
+
+func writeRecord(w io.Writer, op byte, data []byte) error {
+
+	buf := make([]byte, 0, 1+binary.MaxVarintLen64+len(data)+4)
+	buf = append(buf, op)
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+	buf = append(buf, data...)
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte{op})
+	crc.Write(data)
+	buf = binary.LittleEndian.AppendUint32(buf, crc.Sum32())
+
+	_, err := w.Write(buf)
+
+	return err
+}
+
+// Applies the records in r to tree, ordered by cmp.  Insertion records
+// are applied with tree.Insert, so under the tree's duplicate-key
+// policy; removal records remove an owner with the same key, if there
+// is one
+
+func Replay(tree *avl.AvlTree, r io.Reader, codec Codec, cmp avl.CmpFuncNode) error {
+
+	br := bufio.NewReader(r)
+
+	for {
+		op, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return tail(err)
+		}
+		if n > 1<<30 {
+			return ErrCorrupt
+		}
+
+		data := make([]byte, n+4)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return tail(err)
+		}
+		data, sum := data[:n], binary.LittleEndian.Uint32(data[n:])
+
+		crc := crc32.NewIEEE()
+		crc.Write([]byte{op})
+		crc.Write(data)
+		if crc.Sum32() != sum {
+			return ErrCorrupt
+		}
+
+		node, owner, err := codec.Decode(data)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+
+		switch op {
+		case opInsert:
+			tree.Insert(node, owner, cmp)
+		case opRemove:
+			if found := tree.Lookup(owner, avl.CmpFuncKey(cmp)); found != nil {
+				tree.Remove(codec.Header(found))
+			}
+		default:
+			return ErrCorrupt
+		}
+	}
+}
+
+// A record cut short is the torn tail of a crashed write, and ends the
+// log
+
+func tail(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil
+	}
+	return err
+}
+
+// Rebuilds tree, which should be empty, from a snapshot (nil if there is
+// none) and the log written after it
+
+func Restore(tree *avl.AvlTree, snap, log io.Reader, codec Codec,
+	cmp avl.CmpFuncNode) error {
+
+	if snap != nil {
+		d, err := avl.AvlDumpRead(snap)
+		if err != nil {
+			return err
+		}
+		s, err := avl.AvlSnapshotFromDump(d, codec.Decode)
+		if err != nil {
+			return err
+		}
+		tree.RestoreFrom(s)
+	}
+
+	return Replay(tree, log, codec, cmp)
+}
@@ -0,0 +1,103 @@
+package wal
+
+import (
+	"bytes"
+	"github.com/danswartzendruber/avl"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func keys(tree *avl.AvlTree) []interface{} {
+
+	var out []interface{}
+	tree.Range(nil, nil, avl.AvlItemCmpKey, func(owner interface{}) bool {
+		out = append(out, owner.(*avl.AvlItem).Key)
+		return true
+	})
+
+	return out
+}
+
+func insert(tree *avl.AvlTree, k float64) {
+	it := &avl.AvlItem{Key: k}
+	tree.Insert(&it.Header, it, avl.AvlItemCmpNode)
+}
+
+func TestLogReplay(t *testing.T) {
+
+	tree := avl.NewAvlTree()
+
+	var log bytes.Buffer
+	l := Attach(tree, &log, ItemCodec{})
+
+	for _, k := range []float64{5, 3, 8, 1} {
+		insert(tree, k)
+	}
+	tree.Remove(&tree.Lookup(3.0, avl.AvlItemCmpKey).(*avl.AvlItem).Header)
+	assert.NoError(t, l.Err())
+	assert.NoError(t, l.Sync())
+
+	l.Detach()
+	insert(tree, 9)
+
+	replayed := avl.NewAvlTree()
+	assert.NoError(t, Replay(replayed, bytes.NewReader(log.Bytes()), ItemCodec{}, avl.AvlItemCmpNode))
+	assert.Equal(t, []interface{}{1.0, 5.0, 8.0}, keys(replayed))
+
+	// A torn final record is dropped
+
+	torn := log.Bytes()[:log.Len()-2]
+	replayed = avl.NewAvlTree()
+	assert.NoError(t, Replay(replayed, bytes.NewReader(torn), ItemCodec{}, avl.AvlItemCmpNode))
+	assert.Equal(t, []interface{}{1.0, 3.0, 5.0, 8.0}, keys(replayed))
+
+	// A damaged one is not
+
+	bad := append([]byte(nil), log.Bytes()...)
+	bad[3] ^= 0xff
+	assert.ErrorIs(t, Replay(avl.NewAvlTree(), bytes.NewReader(bad), ItemCodec{}, avl.AvlItemCmpNode), ErrCorrupt)
+}
+
+func TestCompaction(t *testing.T) {
+
+	tree := avl.NewAvlTree()
+
+	var snap, log bytes.Buffer
+	l := Attach(tree, &log, ItemCodec{}, CompactEvery(3, func() (io.Writer, io.Writer, error) {
+		snap.Reset()
+		log.Reset()
+		return &snap, &log, nil
+	}))
+
+	for k := 1.0; k <= 7; k++ {
+		insert(tree, k)
+	}
+	assert.NoError(t, l.Err())
+
+	// Compacted after 3 and 6 records, leaving one in the log
+
+	assert.True(t, snap.Len() > 0)
+	assert.True(t, log.Len() > 0)
+
+	restored := avl.NewAvlTree()
+	assert.NoError(t, Restore(restored, &snap, &log, ItemCodec{}, avl.AvlItemCmpNode))
+	assert.Equal(t, keys(tree), keys(restored))
+	assert.Equal(t, 7, restored.Len())
+}
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestLogWriteError(t *testing.T) {
+
+	tree := avl.NewAvlTree()
+	l := Attach(tree, failWriter{}, ItemCodec{})
+
+	insert(tree, 1)
+	assert.ErrorIs(t, l.Err(), io.ErrClosedPipe)
+	assert.Error(t, l.Sync())
+}